@@ -0,0 +1,115 @@
+package goxml
+
+import "sync"
+
+// elementPool holds released elements for reuse by the parser.
+var elementPool = sync.Pool{
+	New: func() any { return &Element{} },
+}
+
+// Release hands elt and all of its descendant elements back to the parser
+// for reuse. This keeps memory usage flat in long running jobs that parse
+// documents or records, process them and throw them away. elt is removed
+// from its parent first. After Release neither elt nor any node below it may
+// be used anymore, and no other part of the program may hold a reference to
+// them. Elements allocated with WithArena share their memory with other
+// elements and are not reused. While the document records changes for Undo
+// or a transaction, elt is only removed, since Undo could bring it back.
+func (elt *Element) Release() {
+	elt.checkMutable()
+	doc := documentOf(elt)
+	elt.Remove()
+	if doc != nil && (doc.history != nil || doc.tx != nil) {
+		return
+	}
+	elt.release()
+}
+
+// release puts elt and its descendants into the pool.
+func (elt *Element) release() {
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			cld.release()
+		}
+	}
+	inArena := elt.inArena
+	*elt = Element{}
+	if !inArena {
+		elementPool.Put(elt)
+	}
+}
+
+// Release hands all elements of the document back to the parser for reuse,
+// see Element.Release. The document is empty afterwards.
+func (xr *XMLDocument) Release() {
+	xr.checkMutable()
+	if xr.history != nil || xr.tx != nil {
+		xr.changeChildren()
+		xr.children = nil
+		return
+	}
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			elt.release()
+		}
+	}
+	xr.children = nil
+}
+
+// arena hands out elements, attributes and attribute lists from large
+// blocks. A zero size disables the block allocation.
+type arena struct {
+	size           int
+	elements       []Element
+	caches         []stringvalueCache
+	attributes     []Attribute
+	attributeLists []*Attribute
+}
+
+// newElement returns an element without namespace map.
+func (a *arena) newElement() *Element {
+	if a.size <= 0 {
+		elt := elementPool.Get().(*Element)
+		elt.svCache = &stringvalueCache{}
+		return elt
+	}
+	if len(a.elements) == 0 {
+		a.elements = make([]Element, a.size)
+		a.caches = make([]stringvalueCache, a.size)
+	}
+	elt := &a.elements[0]
+	elt.svCache = &a.caches[0]
+	elt.inArena = true
+	a.elements = a.elements[1:]
+	a.caches = a.caches[1:]
+	return elt
+}
+
+func (a *arena) newAttribute() *Attribute {
+	if a.size <= 0 {
+		return &Attribute{}
+	}
+	if len(a.attributes) == 0 {
+		a.attributes = make([]Attribute, a.size)
+	}
+	attr := &a.attributes[0]
+	a.attributes = a.attributes[1:]
+	return attr
+}
+
+// newAttributeList returns an empty slice with capacity n. Appending more
+// than n attributes reallocates the slice and does not touch the block.
+func (a *arena) newAttributeList(n int) []*Attribute {
+	if a.size <= 0 || n == 0 {
+		return nil
+	}
+	if n > a.size {
+		return make([]*Attribute, 0, n)
+	}
+	if len(a.attributeLists) < n {
+		a.attributeLists = make([]*Attribute, a.size)
+	}
+	attrs := a.attributeLists[:0:n]
+	a.attributeLists = a.attributeLists[n:]
+	return attrs
+}
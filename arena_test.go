@@ -0,0 +1,59 @@
+package goxml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleaseRemovesElement(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a><b/>t</a><c/></r>`)
+	a := r.children[0].(*Element)
+	a.Release()
+	if got, want := doc.ToXML(), `<r><c /></r>`; got != want {
+		t.Errorf("after Release: %s, want %s", got, want)
+	}
+	doc.Release()
+	if len(doc.Children()) != 0 {
+		t.Errorf("the document has %d children after Release", len(doc.Children()))
+	}
+}
+
+func TestReleaseArenaElements(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<r><a/><b><c/></b></r>`), WithArena(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inArena := make(map[*Element]bool)
+	var collect func(n XMLNode)
+	collect = func(n XMLNode) {
+		for _, c := range n.Children() {
+			if elt, ok := c.(*Element); ok {
+				inArena[elt] = true
+				collect(elt)
+			}
+		}
+	}
+	collect(doc)
+	doc.Release()
+	for i := 0; i < 2*len(inArena); i++ {
+		if elt := elementPool.Get().(*Element); inArena[elt] {
+			t.Fatal("an element of the arena has been put into the pool")
+		}
+	}
+}
+
+func TestReleaseWithUndo(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a x="1"><b/></a><c/></r>`)
+	before := doc.ToXML()
+	doc.EnableUndo(0)
+	r.children[0].(*Element).Release()
+	if got, want := doc.ToXML(), `<r><c /></r>`; got != want {
+		t.Errorf("after Release: %s, want %s", got, want)
+	}
+	if !doc.Undo() {
+		t.Fatal("Undo() = false")
+	}
+	if got := doc.ToXML(); got != before {
+		t.Errorf("after Undo: %s, want %s", got, before)
+	}
+}
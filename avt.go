@@ -0,0 +1,108 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// AVTResolver returns the value of the expression expr in an attribute value
+// template on the element elt.
+type AVTResolver func(elt *Element, expr string) (string, error)
+
+// PathResolver returns an AVTResolver that evaluates the expressions as path
+// expressions, see Path, with the element as the context node. The value is
+// the string value of the first node selected, empty if there is none. The
+// prefixes in the expressions are bound by namespaces, or by the namespace
+// declarations in scope of the element if namespaces is nil. The resolver
+// keeps the compiled paths and must not be used concurrently.
+func PathResolver(namespaces map[string]string) AVTResolver {
+	cache := make(map[string]*Path)
+	return func(elt *Element, expr string) (string, error) {
+		var n XMLNode
+		if namespaces == nil {
+			var err error
+			if n, err = FindFirst(elt, expr, elt.InScopeNamespaces()); err != nil {
+				return "", err
+			}
+		} else {
+			p, ok := cache[expr]
+			if !ok {
+				var err error
+				if p, err = CompilePath(expr, namespaces); err != nil {
+					return "", err
+				}
+				cache[expr] = p
+			}
+			n = p.First(elt)
+		}
+		if n == nil {
+			return "", nil
+		}
+		return nodeString(n), nil
+	}
+}
+
+// ExpandAVT expands the attribute value templates in the attributes of elt
+// like XSLT does: each part {expr} of a value is replaced by the result of
+// resolve for expr, {{ and }} stand for literal braces. Braces inside of
+// quotes and brackets in an expression do not end it. The expanded
+// attributes are set with SetAttribute, which moves them to the end of the
+// list, attributes without braces are kept as they are. If an expression
+// fails or a brace is not matched, ExpandAVT returns an error with the line
+// of the element and leaves elt unchanged.
+func ExpandAVT(elt *Element, resolve AVTResolver) error {
+	var changed []xml.Attr
+	for _, attr := range elt.attributes {
+		if !strings.ContainsAny(attr.Value, "{}") {
+			continue
+		}
+		value, err := expandAVT(attr.Value, func(expr string) (string, error) {
+			return resolve(elt, expr)
+		})
+		if err != nil {
+			return fmt.Errorf("line %d: <%s> attribute %s: %w", elt.Line, elt.qualifiedName(), qualifiedName(attr.Prefix, attr.Name), err)
+		}
+		changed = append(changed, xml.Attr{Name: xml.Name{Space: attr.Namespace, Local: attr.Name}, Value: value})
+	}
+	for _, attr := range changed {
+		elt.SetAttribute(attr)
+	}
+	return nil
+}
+
+// expandAVT returns the attribute value template s with the expressions
+// replaced by the values of eval.
+func expandAVT(s string, eval func(expr string) (string, error)) (string, error) {
+	var sb strings.Builder
+	for {
+		i := strings.IndexAny(s, "{}")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		sb.WriteString(s[:i])
+		if i+1 < len(s) && s[i+1] == s[i] {
+			sb.WriteByte(s[i])
+			s = s[i+2:]
+			continue
+		}
+		if s[i] == '}' {
+			return "", fmt.Errorf("unmatched } in %q", s)
+		}
+		end := indexOutside(s[i+1:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unmatched { in %q", s)
+		}
+		expr := strings.TrimSpace(s[i+1 : i+1+end])
+		if expr == "" {
+			return "", fmt.Errorf("empty expression in %q", s)
+		}
+		value, err := eval(expr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(value)
+		s = s[i+2+end:]
+	}
+}
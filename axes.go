@@ -0,0 +1,172 @@
+package goxml
+
+// The methods in this file navigate between the elements of a tree. They
+// return elements only, text and other nodes are skipped. Use Walk to visit
+// all nodes.
+
+// Descendants returns the descendant elements of elt in document order,
+// without elt.
+func (elt *Element) Descendants() []*Element {
+	var found []*Element
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			WalkElements(cld, func(e *Element) WalkAction {
+				found = append(found, e)
+				return Continue
+			})
+		}
+	}
+	return found
+}
+
+// Ancestors returns the ancestor elements of elt, the parent first and the
+// root element last.
+func (elt *Element) Ancestors() []*Element {
+	var found []*Element
+	for n := elt.Parent; n != nil; {
+		parent, ok := n.(*Element)
+		if !ok {
+			break
+		}
+		found = append(found, parent)
+		n = parent.Parent
+	}
+	return found
+}
+
+// siblings returns the children of the parent of elt and the index of elt
+// among them, or -1 if elt has no parent.
+func (elt *Element) siblings() ([]XMLNode, int) {
+	if elt.Parent == nil {
+		return nil, -1
+	}
+	children := elt.Parent.Children()
+	return children, childIndex(elt.Parent, elt)
+}
+
+// FollowingSiblings returns the sibling elements after elt in document
+// order.
+func (elt *Element) FollowingSiblings() []*Element {
+	children, i := elt.siblings()
+	if i < 0 {
+		return nil
+	}
+	var found []*Element
+	for _, c := range children[i+1:] {
+		if sib, ok := c.(*Element); ok {
+			found = append(found, sib)
+		}
+	}
+	return found
+}
+
+// PrecedingSiblings returns the sibling elements before elt, the nearest
+// first, like the preceding-sibling axis of XPath.
+func (elt *Element) PrecedingSiblings() []*Element {
+	children, i := elt.siblings()
+	var found []*Element
+	for i--; i >= 0; i-- {
+		if sib, ok := children[i].(*Element); ok {
+			found = append(found, sib)
+		}
+	}
+	return found
+}
+
+// NextSiblingElement returns the first sibling element after elt, nil if
+// there is none.
+func (elt *Element) NextSiblingElement() *Element {
+	children, i := elt.siblings()
+	if i < 0 {
+		return nil
+	}
+	for _, c := range children[i+1:] {
+		if sib, ok := c.(*Element); ok {
+			return sib
+		}
+	}
+	return nil
+}
+
+// PreviousSiblingElement returns the last sibling element before elt, nil if
+// there is none.
+func (elt *Element) PreviousSiblingElement() *Element {
+	children, i := elt.siblings()
+	for i--; i >= 0; i-- {
+		if sib, ok := children[i].(*Element); ok {
+			return sib
+		}
+	}
+	return nil
+}
+
+// FirstChildElement returns the first child element of elt, nil if there is
+// none.
+func (elt *Element) FirstChildElement() *Element {
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			return cld
+		}
+	}
+	return nil
+}
+
+// LastChildElement returns the last child element of elt, nil if there is
+// none.
+func (elt *Element) LastChildElement() *Element {
+	for i := len(elt.children) - 1; i >= 0; i-- {
+		if cld, ok := elt.children[i].(*Element); ok {
+			return cld
+		}
+	}
+	return nil
+}
+
+// ChildElements returns the child elements of elt in document order.
+func (elt *Element) ChildElements() []*Element {
+	var found []*Element
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			found = append(found, cld)
+		}
+	}
+	return found
+}
+
+// FindElements returns the descendant elements of elt with the local name
+// and the namespace URI in document order, without elt. An empty namespace
+// matches elements in no namespace, "*" matches any namespace and any local
+// name.
+func (elt *Element) FindElements(localname, namespace string) []*Element {
+	var found []*Element
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			found = findElements(found, cld, localname, namespace)
+		}
+	}
+	return found
+}
+
+// FindElements returns the elements of the document with the local name and
+// the namespace URI in document order, see Element.FindElements.
+func (xr *XMLDocument) FindElements(localname, namespace string) []*Element {
+	var found []*Element
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			found = findElements(found, elt, localname, namespace)
+		}
+	}
+	return found
+}
+
+// findElements appends elt and its descendants with the local name and the
+// namespace URI to found.
+func findElements(found []*Element, elt *Element, localname, namespace string) []*Element {
+	WalkElements(elt, func(e *Element) WalkAction {
+		if (localname == "*" || e.Name == localname) && (namespace == "*" || e.NamespaceURI() == namespace) {
+			found = append(found, e)
+		}
+		return Continue
+	})
+	return found
+}
@@ -0,0 +1,79 @@
+package goxml
+
+import "net/url"
+
+// BaseURI returns the base URI of the document, which is set with
+// WithBaseURI or SetBaseURI.
+func (xr *XMLDocument) BaseURI() string {
+	return xr.baseURI
+}
+
+// SetBaseURI sets the base URI of the document, such as the URL it has been
+// retrieved from.
+func (xr *XMLDocument) SetBaseURI(uri string) {
+	xr.checkMutable()
+	xr.baseURI = uri
+}
+
+// BaseURI returns the effective base URI of the element. It is the base URI
+// of the document, resolved against the xml:base attributes of the ancestors
+// and the element from the outside in. An error is returned if one of the
+// URIs cannot be parsed.
+func (elt *Element) BaseURI() (string, error) {
+	base, err := elt.baseURL()
+	if err != nil || base == nil {
+		return "", err
+	}
+	return base.String(), nil
+}
+
+// ResolveReference resolves the URI reference rel, such as the value of an
+// href attribute, against the base URI of the element.
+func (elt *Element) ResolveReference(rel string) (string, error) {
+	ref, err := url.Parse(rel)
+	if err != nil {
+		return "", err
+	}
+	base, err := elt.baseURL()
+	if err != nil {
+		return "", err
+	}
+	if base == nil {
+		return ref.String(), nil
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// baseURL returns the effective base URI of the element or nil if neither the
+// document nor any xml:base attribute sets one.
+func (elt *Element) baseURL() (*url.URL, error) {
+	var base *url.URL
+	switch p := elt.Parent.(type) {
+	case *Element:
+		var err error
+		if base, err = p.baseURL(); err != nil {
+			return nil, err
+		}
+	case *XMLDocument:
+		if p.baseURI != "" {
+			var err error
+			if base, err = url.Parse(p.baseURI); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, attr := range elt.attributes {
+		if attr.Name != "base" || attr.Namespace != xmlNamespace {
+			continue
+		}
+		u, err := url.Parse(attr.Value)
+		if err != nil {
+			return nil, err
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		return u, nil
+	}
+	return base, nil
+}
@@ -0,0 +1,98 @@
+package goxml
+
+import "strings"
+
+// E returns a new element with the name and the contents, which can be
+// attributes made with Attr, AttrNS and Xmlns, text made with T, other
+// elements made with E and comments made with C, so that a document is
+// written down like its XML:
+//
+//	doc := goxml.Doc(
+//		goxml.E("book", goxml.Attr("id", "1"),
+//			goxml.E("title", goxml.T("Go")),
+//			goxml.C("draft"),
+//		),
+//	)
+//
+// The name can have a prefix such as "dc:title", declared with Xmlns on the
+// element or an ancestor. nil contents are skipped, so optional parts can be
+// written inline. Doc assigns the IDs. The contents are added with Append,
+// so text is escaped when the document is serialized, whatever it contains.
+func E(name string, contents ...XMLNode) *Element {
+	elt := &Element{}
+	if prefix, local, ok := strings.Cut(name, ":"); ok {
+		elt.Prefix, elt.Name = prefix, local
+	} else {
+		elt.Name = name
+	}
+	for _, c := range contents {
+		switch t := c.(type) {
+		case nil:
+		case *Element:
+			if t != nil {
+				elt.Append(t)
+			}
+		case Attribute:
+			if t.Prefix == "xmlns" || t.Prefix == "" && t.Name == "xmlns" {
+				prefix := t.Name
+				if t.Prefix == "" {
+					prefix = ""
+				}
+				elt.DeclareNamespace(prefix, t.Value)
+				continue
+			}
+			elt.Append(t)
+		default:
+			elt.Append(c)
+		}
+	}
+	return elt
+}
+
+// Attr returns an attribute without namespace for E.
+func Attr(name, value string) Attribute {
+	return Attribute{Name: name, Value: value}
+}
+
+// AttrNS returns an attribute in the namespace ns for E. A prefix bound to
+// ns on the element is used or one is declared.
+func AttrNS(ns, name, value string) Attribute {
+	return Attribute{Name: name, Namespace: ns, Value: value}
+}
+
+// Xmlns returns a namespace declaration for E, the empty prefix declares the
+// default namespace.
+func Xmlns(prefix, uri string) Attribute {
+	if prefix == "" {
+		return Attribute{Name: "xmlns", Value: uri}
+	}
+	return Attribute{Name: prefix, Prefix: "xmlns", Value: uri}
+}
+
+// T returns a text node for E.
+func T(text string) CharData {
+	return CharData{Contents: text}
+}
+
+// C returns a comment for E.
+func C(text string) Comment {
+	return Comment{Contents: text}
+}
+
+// PI returns a processing instruction for E and Doc.
+func PI(target, inst string) ProcInst {
+	return ProcInst{Target: target, Inst: []byte(inst)}
+}
+
+// Doc returns a new document with the nodes, usually a root element made
+// with E, and assigns IDs in document order to all nodes.
+func Doc(nodes ...XMLNode) *XMLDocument {
+	doc := NewDocument()
+	for _, n := range nodes {
+		if n != nil {
+			doc.Append(n)
+		}
+	}
+	doc.renumber()
+	return doc
+}
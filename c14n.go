@@ -0,0 +1,208 @@
+package goxml
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+// Canonicalize writes the subtree of the element to w in the form of
+// Canonical XML 1.0 without comments, so that equivalent XML gives the same
+// bytes, for example to compute a signature or a hash of a fragment. Empty
+// elements are written with a start and an end tag, attributes in double
+// quotes, sorted by namespace URI and local name after the namespace
+// declarations, which are sorted by prefix. The element declares all
+// namespace bindings in its scope, its descendants only those that change
+// the binding of their parent, and it gets the xml:lang and xml:space
+// attributes it inherits from its ancestors. CDATA sections are written as
+// text and references as the characters they stand for, references to
+// entities whose replacement text is not known are kept. Characters not
+// allowed in XML are an error.
+func (elt *Element) Canonicalize(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	xw := newXMLWriter(bw)
+	attributes := elt.attributes
+	for _, name := range []string{"lang", "space"} {
+		if attr, ok := elt.inheritedXMLAttribute(name); ok {
+			// the attributes of elt are not changed
+			attributes = append(attributes[:len(attributes):len(attributes)], attr)
+		}
+	}
+	canonicalElement(xw, elt, elt.InScopeNamespaces(), nil, attributes)
+	_, err := xw.flush(bw)
+	return err
+}
+
+// Canonicalize writes the document to w in the form of Canonical XML 1.0
+// without comments, see Element.Canonicalize. The XML declaration, the
+// DOCTYPE declaration and the white space outside of the root element are
+// left out, each processing instruction before the root element is followed
+// by a newline and each one after it preceded by one.
+func (xr *XMLDocument) Canonicalize(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	xw := newXMLWriter(bw)
+	afterRoot := false
+	for _, c := range xr.children {
+		switch t := c.(type) {
+		case *Element:
+			canonicalElement(xw, t, t.Namespaces, nil, t.attributes)
+			afterRoot = true
+		case ProcInst:
+			if t.Target == "xml" {
+				continue
+			}
+			if afterRoot {
+				xw.writeString("\n")
+			}
+			canonicalProcInst(xw, t)
+			if !afterRoot {
+				xw.writeString("\n")
+			}
+		}
+	}
+	_, err := xw.flush(bw)
+	return err
+}
+
+// inheritedXMLAttribute returns the attribute xml:name of the nearest
+// ancestor of elt that has it, if elt has none of its own.
+func (elt *Element) inheritedXMLAttribute(name string) (*Attribute, bool) {
+	var cur XMLNode = elt
+	for cur != nil {
+		e, ok := cur.(*Element)
+		if !ok {
+			break
+		}
+		for _, attr := range e.attributes {
+			if attr.Name == name && attr.Namespace == xmlNamespace {
+				if e == elt {
+					return nil, false
+				}
+				return &Attribute{Name: name, Namespace: xmlNamespace, Prefix: "xml", Value: attr.Value}, true
+			}
+		}
+		cur = e.Parent
+	}
+	return nil, false
+}
+
+// canonicalElement writes elt with the namespace bindings in namespaces, of
+// which those that differ from the bindings rendered on the output ancestors
+// are declared, and the attributes.
+func canonicalElement(xw *xmlWriter, elt *Element, namespaces, rendered map[string]string, attributes []*Attribute) {
+	if xw.err != nil {
+		return
+	}
+	xw.writeString("<")
+	elt.writeName(xw)
+	var declared map[string]string
+	for _, prefix := range sortedPrefixes(namespaces) {
+		ns := namespaces[prefix]
+		if prefix == "xml" {
+			continue
+		}
+		if outer, ok := rendered[prefix]; ok && outer == ns || !ok && ns == "" {
+			// the binding does not change, an empty default namespace is
+			// only undeclared if a default namespace is rendered
+			continue
+		}
+		if declared == nil {
+			declared = make(map[string]string, len(rendered)+len(namespaces))
+			for p, outer := range rendered {
+				declared[p] = outer
+			}
+		}
+		declared[prefix] = ns
+		xw.writeString(" ", namespaceAttributeName(prefix), "=\"")
+		canonicalText(xw, ns, true)
+		xw.writeString("\"")
+	}
+	if declared == nil {
+		declared = rendered
+	}
+	if len(attributes) > 1 {
+		attributes = append([]*Attribute(nil), attributes...)
+		sort.SliceStable(attributes, func(i, j int) bool {
+			if attributes[i].Namespace != attributes[j].Namespace {
+				return attributes[i].Namespace < attributes[j].Namespace
+			}
+			return attributes[i].Name < attributes[j].Name
+		})
+	}
+	for _, attr := range attributes {
+		xw.writeString(" ", qualifiedName(attr.Prefix, attr.Name), "=\"")
+		canonicalText(xw, attr.Value, true)
+		xw.writeString("\"")
+	}
+	xw.writeString(">")
+	for _, c := range elt.children {
+		switch t := c.(type) {
+		case *Element:
+			canonicalElement(xw, t, t.Namespaces, declared, t.attributes)
+		case CharData:
+			canonicalText(xw, t.Contents, false)
+		case EntityRef:
+			if t.Value == "" {
+				xw.writeString("&", t.Name, ";")
+			} else {
+				canonicalText(xw, t.Value, false)
+			}
+		case ProcInst:
+			canonicalProcInst(xw, t)
+		}
+	}
+	elt.writeEndTag(xw)
+}
+
+func canonicalProcInst(xw *xmlWriter, pi ProcInst) {
+	xw.writeString("<?", pi.Target)
+	if len(pi.Inst) > 0 {
+		xw.writeString(" ", string(pi.Inst))
+	}
+	xw.writeString("?>")
+}
+
+// canonicalText writes s with the references Canonical XML requires for
+// text or, if attribute is set, for attribute values.
+func canonicalText(xw *xmlWriter, s string, attribute bool) {
+	last := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		size := 1
+		var esc string
+		switch {
+		case c == '&':
+			esc = "&amp;"
+		case c == '<':
+			esc = "&lt;"
+		case c == '>' && !attribute:
+			esc = "&gt;"
+		case c == '"' && attribute:
+			esc = "&quot;"
+		case c == '\t' && attribute:
+			esc = "&#x9;"
+		case c == '\n' && attribute:
+			esc = "&#xA;"
+		case c == '\r':
+			esc = "&#xD;"
+		case c < 0x20 && c != '\t' && c != '\n':
+			esc = xw.illegalChar(rune(c))
+		case c >= utf8.RuneSelf:
+			var r rune
+			r, size = utf8.DecodeRuneInString(s[i:])
+			if (r != utf8.RuneError || size > 1) && isXMLChar(r) {
+				i += size
+				continue
+			}
+			esc = xw.illegalChar(r)
+		default:
+			i++
+			continue
+		}
+		xw.writeString(s[last:i], esc)
+		i += size
+		last = i
+	}
+	xw.writeString(s[last:])
+}
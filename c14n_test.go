@@ -0,0 +1,106 @@
+package goxml
+
+import (
+	"strings"
+	"testing"
+)
+
+// The vectors follow the examples in section 3 of the Canonical XML 1.0
+// recommendation, without the parts that need a DTD.
+var c14nTests = []struct {
+	name, in, want string
+}{
+	{
+		"PIs, comments and outside of document element",
+		"<?xml version=\"1.0\"?>\n\n<?xml-stylesheet   href=\"doc.xsl\"\n   type=\"text/xsl\"   ?>\n\n<doc>Hello, world!<!-- Comment 1 --></doc>\n\n<?pi-without-data     ?>\n\n<!-- Comment 2 -->\n\n<!-- Comment 3 -->",
+		"<?xml-stylesheet href=\"doc.xsl\"\n   type=\"text/xsl\"   ?>\n<doc>Hello, world!</doc>\n<?pi-without-data?>",
+	},
+	{
+		"whitespace in document content",
+		"<doc>\n   <clean>   </clean>\n   <dirty>   A   B   </dirty>\n   <mixed>\n      A\n      <clean>   </clean>\n      B\n      <dirty>   A   B   </dirty>\n      C\n   </mixed>\n</doc>",
+		"<doc>\n   <clean>   </clean>\n   <dirty>   A   B   </dirty>\n   <mixed>\n      A\n      <clean>   </clean>\n      B\n      <dirty>   A   B   </dirty>\n      C\n   </mixed>\n</doc>",
+	},
+	{
+		"start and end tags",
+		"<doc>\n   <e1   />\n   <e2   ></e2>\n   <e3   name = \"elem3\"   id=\"elem3\"   />\n   <e4   name=\"elem4\"   id=\"elem4\"   ></e4>\n   <e5 a:attr=\"out\" b:attr=\"sorted\" attr2=\"all\" attr=\"I'm\"\n      xmlns:b=\"http://www.ietf.org\"\n      xmlns:a=\"http://www.w3.org\"\n      xmlns=\"http://example.org\"/>\n   <e6 xmlns=\"\" xmlns:a=\"http://www.w3.org\">\n      <e7 xmlns=\"http://www.ietf.org\">\n         <e8 xmlns=\"\" xmlns:a=\"http://www.w3.org\">\n            <e9 xmlns=\"\" xmlns:a=\"http://www.ietf.org\"/>\n         </e8>\n      </e7>\n   </e6>\n</doc>",
+		"<doc>\n   <e1></e1>\n   <e2></e2>\n   <e3 id=\"elem3\" name=\"elem3\"></e3>\n   <e4 id=\"elem4\" name=\"elem4\"></e4>\n   <e5 xmlns=\"http://example.org\" xmlns:a=\"http://www.w3.org\" xmlns:b=\"http://www.ietf.org\" attr=\"I'm\" attr2=\"all\" b:attr=\"sorted\" a:attr=\"out\"></e5>\n   <e6 xmlns:a=\"http://www.w3.org\">\n      <e7 xmlns=\"http://www.ietf.org\">\n         <e8 xmlns=\"\">\n            <e9 xmlns:a=\"http://www.ietf.org\"></e9>\n         </e8>\n      </e7>\n   </e6>\n</doc>",
+	},
+	{
+		"character modifications and character references",
+		"<doc>\n   <text>First line&#x0d;&#10;Second line</text>\n   <value>&#x32;</value>\n   <compute><![CDATA[value>\"0\" && value<\"10\" ?\"valid\":\"error\"]]></compute>\n   <compute expr='value>\"0\" &amp;&amp; value&lt;\"10\" ?\"valid\":\"error\"'>valid</compute>\n   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>\n</doc>",
+		"<doc>\n   <text>First line&#xD;\nSecond line</text>\n   <value>2</value>\n   <compute>value&gt;\"0\" &amp;&amp; value&lt;\"10\" ?\"valid\":\"error\"</compute>\n   <compute expr=\"value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;\">valid</compute>\n   <norm attr=\" '    &#xD;&#xA;&#x9;   ' \"></norm>\n</doc>",
+	},
+	{
+		"UTF-8 encoding",
+		"<doc>&#169;</doc>",
+		"<doc>\u00a9</doc>",
+	},
+}
+
+func TestCanonicalize(t *testing.T) {
+	for _, tc := range c14nTests {
+		doc, err := Parse(strings.NewReader(tc.in))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		var sb strings.Builder
+		if err = doc.Canonicalize(&sb); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := sb.String(); got != tc.want {
+			t.Errorf("%s:\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCanonicalizeElement(t *testing.T) {
+	_, r := parseRoot(t, `<r xmlns="urn:d" xmlns:p="urn:p" xml:lang="de" xml:space="preserve"><p:a xml:space="default" b="2" a="1"><c/></p:a></r>`)
+	a := r.FirstChildElement()
+	var sb strings.Builder
+	if err := a.Canonicalize(&sb); err != nil {
+		t.Fatal(err)
+	}
+	// the element gets the namespaces in scope and the inherited xml:lang,
+	// but keeps its own xml:space
+	want := `<p:a xmlns="urn:d" xmlns:p="urn:p" a="1" b="2" xml:lang="de" xml:space="default"><c></c></p:a>`
+	if got := sb.String(); got != want {
+		t.Errorf("Canonicalize:\n%s\nwant\n%s", got, want)
+	}
+	if len(a.Attributes()) != 3 {
+		t.Errorf("Canonicalize changed the attributes of the element: %v", a.Attributes())
+	}
+}
+
+func TestCanonicalizeIllegalChar(t *testing.T) {
+	_, r := parseRoot(t, `<r/>`)
+	r.Append(CharData{Contents: "a\x01b"})
+	var sb strings.Builder
+	if err := r.Canonicalize(&sb); err == nil {
+		t.Errorf("Canonicalize of text with U+0001 succeeds: %q", sb.String())
+	}
+}
+
+func TestDeepEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{`<a x="1" y="2"/>`, `<a y="2" x="1"></a>`, true},
+		{`<p:a xmlns:p="urn:u"><p:b/></p:a>`, `<a xmlns="urn:u"><b xmlns="urn:u"/></a>`, true},
+		{`<a>x<![CDATA[<y>]]></a>`, `<a>x&lt;y></a>`, true},
+		{`<a xmlns="urn:u"/>`, `<a/>`, false},
+		{`<a> x</a>`, `<a>x</a>`, false},
+		{`<a><!--c--></a>`, `<a/>`, false},
+		{`<a x="1"/>`, `<a x="1" y="1"/>`, false},
+		{`<a><?pi x?></a>`, `<a><?pi y?></a>`, false},
+	}
+	for _, tc := range tests {
+		a, _ := parseRoot(t, tc.a)
+		b, _ := parseRoot(t, tc.b)
+		if got := DeepEqual(a, b); got != tc.equal {
+			t.Errorf("DeepEqual(%s, %s) = %t, want %t", tc.a, tc.b, got, tc.equal)
+		}
+	}
+}
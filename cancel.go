@@ -0,0 +1,44 @@
+package goxml
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// contextWriter fails all writes once its context is done.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw contextWriter) Write(b []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(b)
+}
+
+// WriteToContext writes the XML representation of the document to w like
+// WriteTo, but stops when ctx is cancelled, for example because the client
+// of a network connection has gone away. The context is checked each time
+// a block of output is passed to w, after cancellation no more than one
+// block is written and the rest of the document is not serialized.
+// WriteToContext then returns the error of the context. A Write call that
+// blocks is not interrupted, the writer needs a deadline of its own for that.
+func (xr *XMLDocument) WriteToContext(ctx context.Context, w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(contextWriter{ctx: ctx, w: w})
+	xw := newXMLWriter(bw)
+	xr.serialize(xw)
+	return xw.flush(bw)
+}
+
+// WriteToContext writes the XML representation of the element to w like
+// WriteTo and stops when ctx is cancelled, see XMLDocument.WriteToContext.
+func (elt Element) WriteToContext(ctx context.Context, w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(contextWriter{ctx: ctx, w: w})
+	xw := newXMLWriter(bw)
+	xw.inherited = elt.inheritedNamespaces()
+	elt.serialize(xw)
+	return xw.flush(bw)
+}
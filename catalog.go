@@ -0,0 +1,297 @@
+package goxml
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// catalogNamespace is the namespace of OASIS XML catalog files.
+const catalogNamespace = "urn:oasis:names:tc:entity:xmlns:xml:catalog"
+
+// Catalog maps public identifiers, system identifiers and URIs to other
+// locations as defined by OASIS XML Catalogs 1.1, usually to local copies of
+// remote resources. A Catalog is a Resolver, so it can be used for XInclude
+// processing. The entry types system, rewriteSystem, systemSuffix, public,
+// uri, rewriteURI, uriSuffix, group and nextCatalog are supported.
+type Catalog struct {
+	// Resolver opens the resolved URIs. The default is FileResolver.
+	Resolver Resolver
+	entries  []catalogEntry
+	next     []string
+	// loaded contains the catalogs of the nextCatalog entries once they are
+	// needed
+	loaded []*Catalog
+}
+
+type catalogEntry struct {
+	kind string
+	// match is the identifier, URI, prefix or suffix to match
+	match  string
+	target string
+	// preferPublic is false if public entries are ignored for resources
+	// with a system identifier
+	preferPublic bool
+}
+
+// LoadCatalog reads the catalog file at path.
+func LoadCatalog(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCatalog(f, fileURI(path))
+}
+
+// LoadCatalogs reads the catalog files in the order given and returns a
+// catalog that consults them in that order.
+func LoadCatalogs(paths ...string) (*Catalog, error) {
+	c := &Catalog{}
+	for _, path := range paths {
+		sub, err := LoadCatalog(path)
+		if err != nil {
+			return nil, err
+		}
+		c.next = append(c.next, path)
+		c.loaded = append(c.loaded, sub)
+	}
+	return c, nil
+}
+
+// CatalogFromEnv reads the catalog files listed in the environment variable
+// XML_CATALOG_FILES, separated by spaces, like xmllint does. It returns nil
+// if the variable is not set.
+func CatalogFromEnv() (*Catalog, error) {
+	files := strings.Fields(os.Getenv("XML_CATALOG_FILES"))
+	if len(files) == 0 {
+		return nil, nil
+	}
+	for i, f := range files {
+		if u, err := url.Parse(f); err == nil && u.Scheme == "file" {
+			files[i] = filepath.FromSlash(u.Path)
+		}
+	}
+	return LoadCatalogs(files...)
+}
+
+// ParseCatalog reads a catalog file from r. Relative URIs in the catalog are
+// resolved against base.
+func ParseCatalog(r io.Reader, base string) (*Catalog, error) {
+	doc, err := Parse(r, WithBaseURI(base))
+	if err != nil {
+		return nil, err
+	}
+	root, err := doc.Root()
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{}
+	c.read(root, true)
+	return c, nil
+}
+
+// read adds the entries of the catalog or group element elt.
+func (c *Catalog) read(elt *Element, preferPublic bool) {
+	if p := catalogAttr(elt, "prefer"); p != "" {
+		preferPublic = p == "public"
+	}
+	for _, child := range elt.children {
+		entry, ok := child.(*Element)
+		if !ok || entry.NamespaceURI() != catalogNamespace {
+			continue
+		}
+		var match, target string
+		switch entry.Name {
+		case "group":
+			c.read(entry, preferPublic)
+			continue
+		case "nextCatalog":
+			if next, err := entry.ResolveReference(catalogAttr(entry, "catalog")); err == nil {
+				c.next = append(c.next, next)
+			}
+			continue
+		case "system":
+			match, target = catalogAttr(entry, "systemId"), catalogAttr(entry, "uri")
+		case "rewriteSystem":
+			match, target = catalogAttr(entry, "systemIdStartString"), catalogAttr(entry, "rewritePrefix")
+		case "systemSuffix":
+			match, target = catalogAttr(entry, "systemIdSuffix"), catalogAttr(entry, "uri")
+		case "public":
+			match, target = normalizePublicID(catalogAttr(entry, "publicId")), catalogAttr(entry, "uri")
+		case "uri":
+			match, target = catalogAttr(entry, "name"), catalogAttr(entry, "uri")
+		case "rewriteURI":
+			match, target = catalogAttr(entry, "uriStartString"), catalogAttr(entry, "rewritePrefix")
+		case "uriSuffix":
+			match, target = catalogAttr(entry, "uriSuffix"), catalogAttr(entry, "uri")
+		default:
+			continue
+		}
+		if match == "" {
+			continue
+		}
+		abs, err := entry.ResolveReference(target)
+		if err != nil {
+			continue
+		}
+		c.entries = append(c.entries, catalogEntry{
+			kind:         entry.Name,
+			match:        match,
+			target:       abs,
+			preferPublic: preferPublic,
+		})
+	}
+}
+
+func catalogAttr(elt *Element, name string) string {
+	for _, attr := range elt.attributes {
+		if attr.Name == name && attr.Namespace == "" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// ResolveSystem returns the location of the resource with the system
+// identifier systemID.
+func (c *Catalog) ResolveSystem(systemID string) (string, bool) {
+	return c.resolve("system", "rewriteSystem", "systemSuffix", systemID)
+}
+
+// ResolvePublic returns the location of the resource with the public
+// identifier publicID and the optional system identifier systemID. The
+// system identifier is tried first.
+func (c *Catalog) ResolvePublic(publicID, systemID string) (string, bool) {
+	if systemID != "" {
+		if loc, ok := c.ResolveSystem(systemID); ok {
+			return loc, true
+		}
+	}
+	publicID = normalizePublicID(publicID)
+	return c.lookup(func(cat *Catalog) (string, bool) {
+		for _, e := range cat.entries {
+			if e.kind == "public" && e.match == publicID && (systemID == "" || e.preferPublic) {
+				return e.target, true
+			}
+		}
+		return "", false
+	})
+}
+
+// ResolveURI returns the location of the resource with the URI uri.
+func (c *Catalog) ResolveURI(uri string) (string, bool) {
+	return c.resolve("uri", "rewriteURI", "uriSuffix", uri)
+}
+
+// Open opens the resource at uri, which is mapped through the uri and then
+// the system entries of the catalog. URIs without a matching entry are
+// opened unchanged.
+func (c *Catalog) Open(uri string) (io.ReadCloser, error) {
+	loc, ok := c.ResolveURI(uri)
+	if !ok {
+		if loc, ok = c.ResolveSystem(uri); !ok {
+			loc = uri
+		}
+	}
+	r := c.Resolver
+	if r == nil {
+		r = FileResolver{}
+	}
+	return r.Open(loc)
+}
+
+// resolve looks for an exact match, then the longest rewrite prefix, then
+// the longest suffix in each catalog.
+func (c *Catalog) resolve(exact, rewrite, suffix, id string) (string, bool) {
+	return c.lookup(func(cat *Catalog) (string, bool) {
+		for _, e := range cat.entries {
+			if e.kind == exact && e.match == id {
+				return e.target, true
+			}
+		}
+		var best *catalogEntry
+		for i, e := range cat.entries {
+			if e.kind == rewrite && strings.HasPrefix(id, e.match) && (best == nil || len(e.match) > len(best.match)) {
+				best = &cat.entries[i]
+			}
+		}
+		if best != nil {
+			return best.target + id[len(best.match):], true
+		}
+		for i, e := range cat.entries {
+			if e.kind == suffix && strings.HasSuffix(id, e.match) && (best == nil || len(e.match) > len(best.match)) {
+				best = &cat.entries[i]
+			}
+		}
+		if best != nil {
+			return best.target, true
+		}
+		return "", false
+	})
+}
+
+// lookup applies find to c and then to the next catalogs.
+func (c *Catalog) lookup(find func(*Catalog) (string, bool)) (string, bool) {
+	if loc, ok := find(c); ok {
+		return loc, true
+	}
+	for i := range c.next {
+		next := c.nextCatalog(i)
+		if next == nil {
+			continue
+		}
+		if loc, ok := next.lookup(find); ok {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+// nextCatalog returns the catalog of the ith nextCatalog entry or nil if it
+// cannot be read.
+func (c *Catalog) nextCatalog(i int) *Catalog {
+	for len(c.loaded) <= i {
+		c.loaded = append(c.loaded, nil)
+	}
+	if c.loaded[i] == nil {
+		r, err := c.openCatalog(c.next[i])
+		if err != nil {
+			return nil
+		}
+		defer r.Close()
+		next, err := ParseCatalog(r, c.next[i])
+		if err != nil {
+			return nil
+		}
+		c.loaded[i] = next
+	}
+	return c.loaded[i]
+}
+
+func (c *Catalog) openCatalog(uri string) (io.ReadCloser, error) {
+	if c.Resolver != nil {
+		return c.Resolver.Open(uri)
+	}
+	return FileResolver{}.Open(uri)
+}
+
+// normalizePublicID collapses the white space in a public identifier.
+func normalizePublicID(id string) string {
+	return strings.Join(strings.Fields(id), " ")
+}
+
+// fileURI returns the file URI of the file at path.
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// a Windows path with a drive letter
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,112 @@
+package goxml
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCatalog = `<catalog xmlns="urn:oasis:names:tc:entity:xmlns:xml:catalog" prefer="public">
+  <system systemId="http://example.com/a.dtd" uri="dtd/a.dtd"/>
+  <rewriteSystem systemIdStartString="http://example.com/" rewritePrefix="cache/"/>
+  <rewriteSystem systemIdStartString="http://example.com/long/" rewritePrefix="/long/"/>
+  <systemSuffix systemIdSuffix="/b.dtd" uri="dtd/b.dtd"/>
+  <public publicId="-//Example//DTD  A//EN" uri="dtd/public-a.dtd"/>
+  <group prefer="system">
+    <public publicId="-//Example//DTD B//EN" uri="dtd/public-b.dtd"/>
+  </group>
+  <uri name="http://example.com/ns" uri="schema/ns.xsd"/>
+  <rewriteURI uriStartString="urn:x:" rewritePrefix="x/"/>
+  <uriSuffix uriSuffix=".xsl" uri="style.xsl"/>
+  <nextCatalog catalog="next.xml"/>
+</catalog>`
+
+const testNextCatalog = `<catalog xmlns="urn:oasis:names:tc:entity:xmlns:xml:catalog">
+  <uri name="urn:next" uri="from-next.xml"/>
+</catalog>`
+
+func TestCatalogResolve(t *testing.T) {
+	c, err := ParseCatalog(strings.NewReader(testCatalog), "file:///etc/xml/catalog.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Resolver = mapResolver{"file:///etc/xml/next.xml": testNextCatalog}
+	tests := []struct {
+		name string
+		got  func() (string, bool)
+		want string
+	}{
+		{"system", func() (string, bool) { return c.ResolveSystem("http://example.com/a.dtd") }, "file:///etc/xml/dtd/a.dtd"},
+		{"rewriteSystem", func() (string, bool) { return c.ResolveSystem("http://example.com/c/d.dtd") }, "file:///etc/xml/cache/c/d.dtd"},
+		{"longest rewriteSystem", func() (string, bool) { return c.ResolveSystem("http://example.com/long/d.dtd") }, "file:///long/d.dtd"},
+		{"systemSuffix", func() (string, bool) { return c.ResolveSystem("http://other.org/b.dtd") }, "file:///etc/xml/dtd/b.dtd"},
+		{"unknown system", func() (string, bool) { return c.ResolveSystem("http://other.org/c.dtd") }, ""},
+		{"public", func() (string, bool) { return c.ResolvePublic(" -//Example//DTD A//EN ", "") }, "file:///etc/xml/dtd/public-a.dtd"},
+		{"public before system", func() (string, bool) { return c.ResolvePublic("-//Example//DTD A//EN", "http://other.org/c.dtd") }, "file:///etc/xml/dtd/public-a.dtd"},
+		{"system before public", func() (string, bool) { return c.ResolvePublic("-//Example//DTD A//EN", "http://example.com/a.dtd") }, "file:///etc/xml/dtd/a.dtd"},
+		{"prefer system", func() (string, bool) { return c.ResolvePublic("-//Example//DTD B//EN", "http://other.org/c.dtd") }, ""},
+		{"public without system", func() (string, bool) { return c.ResolvePublic("-//Example//DTD B//EN", "") }, "file:///etc/xml/dtd/public-b.dtd"},
+		{"uri", func() (string, bool) { return c.ResolveURI("http://example.com/ns") }, "file:///etc/xml/schema/ns.xsd"},
+		{"rewriteURI", func() (string, bool) { return c.ResolveURI("urn:x:y/z.xml") }, "file:///etc/xml/x/y/z.xml"},
+		{"uriSuffix", func() (string, bool) { return c.ResolveURI("http://other.org/a.xsl") }, "file:///etc/xml/style.xsl"},
+		{"nextCatalog", func() (string, bool) { return c.ResolveURI("urn:next") }, "file:///etc/xml/from-next.xml"},
+	}
+	for _, tc := range tests {
+		got, ok := tc.got()
+		if got != tc.want || ok != (tc.want != "") {
+			t.Errorf("%s: got %q, %t, want %q", tc.name, got, ok, tc.want)
+		}
+	}
+}
+
+func TestCatalogOpen(t *testing.T) {
+	c, err := ParseCatalog(strings.NewReader(testCatalog), "file:///etc/xml/catalog.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Resolver = mapResolver{
+		"file:///etc/xml/schema/ns.xsd": "schema",
+		"file:///etc/xml/dtd/a.dtd":     "dtd",
+		"urn:unmapped":                  "unmapped",
+	}
+	for uri, want := range map[string]string{
+		"http://example.com/ns":    "schema",
+		"http://example.com/a.dtd": "dtd",
+		"urn:unmapped":             "unmapped",
+	} {
+		r, err := c.Open(uri)
+		if err != nil {
+			t.Errorf("Open(%q): %v", uri, err)
+			continue
+		}
+		b, _ := io.ReadAll(r)
+		r.Close()
+		if string(b) != want {
+			t.Errorf("Open(%q) reads %q, want %q", uri, b, want)
+		}
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "catalog.xml")
+	if err := os.WriteFile(path, []byte(testCatalog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "next.xml"), []byte(testNextCatalog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCatalogs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fileURI(filepath.Join(dir, "from-next.xml"))
+	if got, ok := c.ResolveURI("urn:next"); !ok || got != want {
+		t.Errorf("ResolveURI through the next catalog file = %q, %t, want %q", got, ok, want)
+	}
+	if _, err := LoadCatalog(filepath.Join(dir, "missing.xml")); err == nil {
+		t.Error("LoadCatalog of a missing file succeeds")
+	}
+}
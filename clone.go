@@ -0,0 +1,93 @@
+package goxml
+
+// CloneWithNamespaces returns a deep copy of the element without parent in
+// which namespaces are renamed, for example to move a document to the
+// namespace of a new schema version. uris maps old namespace URIs to new
+// ones and prefixes maps old prefixes to new ones, where the empty prefix
+// stands for the default namespace. Either map may be nil. The changes apply
+// to the names of the elements and attributes and to the namespace
+// declarations. The bindings the element inherits from its ancestors are
+// declared on the copy, so that it can be used on its own. An attribute
+// whose prefix would be renamed to the empty prefix gets a prefix bound to
+// its namespace instead, because unprefixed attributes are in no namespace.
+// Where two namespaces end up with the same prefix, the binding is declared
+// again on the elements that need it. The nodes of the copy keep their IDs.
+func (elt *Element) CloneWithNamespaces(uris, prefixes map[string]string) *Element {
+	cp := copyElement(elt)
+	rescope(cp, elt.inheritedNamespaces(), nil)
+	// the namespaces are looked up before any declaration changes
+	spaces := make(map[*Element]string)
+	var collect func(*Element)
+	collect = func(e *Element) {
+		spaces[e] = e.NamespaceURI()
+		// the copy is not written like the source
+		e.spanEnd = 0
+		for _, c := range e.children {
+			if cld, ok := c.(*Element); ok {
+				collect(cld)
+			}
+		}
+	}
+	collect(cp)
+	rename := func(m map[string]string, s string) string {
+		if r, ok := m[s]; ok {
+			return r
+		}
+		return s
+	}
+	var rewrite func(*Element)
+	rewrite = func(e *Element) {
+		if len(e.Namespaces) > 0 {
+			declared := make(map[string]string, len(e.Namespaces))
+			for prefix, ns := range e.Namespaces {
+				if ns == xmlNamespace || ns == "" {
+					// xmlns="" is checked below
+					declared[prefix] = ns
+					continue
+				}
+				declared[rename(prefixes, prefix)] = rename(uris, ns)
+			}
+			e.Namespaces = declared
+		}
+		if space := spaces[e]; space == "" {
+			if ns, _ := e.LookupNamespace(""); ns != "" {
+				// a prefix has been renamed to the empty prefix, the
+				// declaration moves to the descendants that need it
+				delete(e.Namespaces, "")
+				if ns, _ = e.LookupNamespace(""); ns != "" {
+					e.DeclareNamespace("", "")
+				}
+			}
+		} else {
+			e.Prefix = rename(prefixes, e.Prefix)
+			if ns, _ := e.LookupNamespace(e.Prefix); ns != rename(uris, space) {
+				e.DeclareNamespace(e.Prefix, rename(uris, space))
+			}
+		}
+		for _, attr := range e.attributes {
+			if attr.Namespace == "" || attr.Namespace == xmlNamespace {
+				continue
+			}
+			attr.Namespace = rename(uris, attr.Namespace)
+			prefix := rename(prefixes, attr.Prefix)
+			if prefix != "" {
+				if ns, ok := e.LookupNamespace(prefix); !ok {
+					e.DeclareNamespace(prefix, attr.Namespace)
+				} else if ns != attr.Namespace {
+					prefix = ""
+				}
+			}
+			if prefix == "" {
+				prefix = e.attributePrefix(attr.Namespace)
+			}
+			attr.Prefix = prefix
+		}
+		for _, c := range e.children {
+			if cld, ok := c.(*Element); ok {
+				rewrite(cld)
+			}
+		}
+	}
+	rewrite(cp)
+	return cp
+}
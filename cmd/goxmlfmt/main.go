@@ -0,0 +1,194 @@
+// Command goxmlfmt formats XML documents.
+//
+// Without file arguments, goxmlfmt reads a document from standard input and
+// writes the formatted document to standard output. With files, it formats
+// each file and writes the result to standard output, or back to the file
+// with -w.
+//
+// Usage:
+//
+//	goxmlfmt [flags] [file ...]
+//
+// The flags are:
+//
+//	-w
+//		write the result to the file instead of standard output
+//	-l
+//		list the files whose formatting differs
+//	-indent n
+//		indent by n spaces per level (default 2)
+//	-tabs
+//		indent with tabs
+//	-wrap n
+//		write start tags with more than n attributes one attribute per line
+//	-width n
+//		write start tags longer than n columns one attribute per line
+//	-attrindent n
+//		indent wrapped attributes by n spaces (default: like -indent)
+//	-expand
+//		write empty elements with a start and an end tag
+//
+// The elements that contain only other elements are indented, elements with
+// text and elements with xml:space="preserve" are kept as they are. The
+// prolog up to the root element, including the DOCTYPE declaration, is
+// copied unchanged. Character and entity references in text are kept.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/speedata/goxml"
+)
+
+var (
+	write      = flag.Bool("w", false, "write the result to the file instead of standard output")
+	list       = flag.Bool("l", false, "list the files whose formatting differs")
+	indent     = flag.Int("indent", 2, "indent by `n` spaces per level")
+	tabs       = flag.Bool("tabs", false, "indent with tabs")
+	wrap       = flag.Int("wrap", 0, "write start tags with more than `n` attributes one attribute per line")
+	width      = flag.Int("width", 0, "write start tags longer than `n` columns one attribute per line")
+	attrIndent = flag.Int("attrindent", -1, "indent wrapped attributes by `n` spaces (default: like -indent)")
+	expand     = flag.Bool("expand", false, "write empty elements with a start and an end tag")
+	exitErr    = 0
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: goxmlfmt [flags] [file ...]")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "goxmlfmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+		}
+		os.Exit(exitErr)
+	}
+	for _, path := range flag.Args() {
+		if err := processPath(path); err != nil {
+			report(err)
+		}
+	}
+	os.Exit(exitErr)
+}
+
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	exitErr = 2
+}
+
+func processPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return processFile(path, f, os.Stdout)
+}
+
+// processFile formats the document read from in and writes it to out, lists
+// the file or rewrites it, depending on the flags.
+func processFile(path string, in io.Reader, out io.Writer) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	res, err := format(path, src)
+	if err != nil {
+		return err
+	}
+	if *list {
+		if !bytes.Equal(src, res) {
+			fmt.Fprintln(out, path)
+		}
+		return nil
+	}
+	if *write {
+		if bytes.Equal(src, res) {
+			return nil
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, res, fi.Mode().Perm())
+	}
+	_, err = out.Write(res)
+	return err
+}
+
+// format returns the formatted document src.
+func format(path string, src []byte) ([]byte, error) {
+	doc, err := goxml.ParseBytes(src, goxml.WithSourceName(path), goxml.WithEntityRefs())
+	if err != nil {
+		return nil, err
+	}
+	n, err := prologLength(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if prolog := bytes.TrimRight(src[:n], " \t\r\n"); len(prolog) > 0 {
+		buf.Write(prolog)
+		buf.WriteByte('\n')
+	}
+	// the prolog is not part of the tree completely, so only the root
+	// element and the nodes after it are formatted
+	rest := goxml.NewDocument()
+	root := false
+	for _, c := range doc.Children() {
+		if _, ok := c.(*goxml.Element); ok {
+			root = true
+		}
+		if root {
+			rest.Append(c)
+		}
+	}
+	opts := goxml.SerializeOptions{
+		Indent:         strings.Repeat(" ", *indent),
+		WrapAttributes: *wrap,
+		MaxLineLength:  *width,
+		ExpandEmpty:    *expand,
+	}
+	if *tabs {
+		opts.Indent = "\t"
+	}
+	if *attrIndent >= 0 {
+		opts.AttributeIndent = strings.Repeat(" ", *attrIndent)
+	}
+	if err = rest.WriteXML(&buf, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// prologLength returns the number of bytes before the start tag of the root
+// element.
+func prologLength(src []byte) (int, error) {
+	dec := xml.NewDecoder(bytes.NewReader(src))
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			return int(offset), nil
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"strconv"
+)
+
+// generator writes the Go code for a schema.
+type generator struct {
+	s   *schema
+	buf bytes.Buffer
+	// prefix is the prefix of the target namespace in the generated
+	// documents, empty if it is the default namespace
+	prefix string
+}
+
+func generate(s *schema, pkg, file string) ([]byte, error) {
+	g := &generator{s: s}
+	if s.targetNS != "" && !s.allQualified() {
+		g.prefix = "tns"
+	}
+	g.printf("// Code generated by goxmlgen from %s. DO NOT EDIT.\n\n", filepath.Base(file))
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n\"fmt\"\n\"strconv\"\n\"strings\"\n\n\"github.com/speedata/goxml\"\n)\n\n")
+	if s.targetNS != "" {
+		g.printf("// TargetNamespace is the namespace of the schema.\n")
+		g.printf("const TargetNamespace = %q\n\n", s.targetNS)
+	}
+	for _, r := range s.roots {
+		g.root(r)
+	}
+	for _, t := range s.types {
+		g.typ(t)
+	}
+	g.helpers()
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting the generated code: %w", err)
+	}
+	return src, nil
+}
+
+// allQualified reports whether all local elements are in the target
+// namespace, so that it can be the default namespace of the documents.
+func (s *schema) allQualified() bool {
+	for _, t := range s.types {
+		for _, f := range t.elems {
+			if f.ns == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// qname returns the name of the element f in the generated documents.
+func (g *generator) qname(f *field) string {
+	if f.ns != "" && g.prefix != "" {
+		return g.prefix + ":" + f.name
+	}
+	return f.name
+}
+
+// nsExpr returns the expression for the namespace in the generated code.
+func (g *generator) nsExpr(ns string) string {
+	if ns != "" && ns == g.s.targetNS {
+		return "TargetNamespace"
+	}
+	return strconv.Quote(ns)
+}
+
+func (g *generator) root(r goRoot) {
+	ns := g.s.targetNS
+	g.printf("// Unmarshal%s reads the root element %s of doc.\n", r.goName, r.name)
+	g.printf("func Unmarshal%s(doc *goxml.XMLDocument) (*%s, error) {\n", r.goName, r.typ)
+	g.printf("root, err := doc.Root()\nif err != nil {\nreturn nil, err\n}\n")
+	g.printf("if root.Name != %q || root.NamespaceURI() != %s {\n", r.name, g.nsExpr(ns))
+	g.printf("return nil, fmt.Errorf(\"line %%d: the root element is %%s, not %s\", root.Line, root.Name)\n}\n", r.name)
+	g.printf("v := &%s{}\nif err := v.UnmarshalElement(root); err != nil {\nreturn nil, err\n}\nreturn v, nil\n}\n\n", r.typ)
+
+	g.printf("// New%sDocument returns a document with v as the root element %s.\n", r.goName, r.name)
+	g.printf("func New%sDocument(v *%s) *goxml.XMLDocument {\n", r.goName, r.typ)
+	name := r.name
+	if ns != "" && g.prefix != "" {
+		name = g.prefix + ":" + name
+	}
+	g.printf("elt := v.MarshalElement(%q)\n", name)
+	if ns != "" {
+		g.printf("elt.DeclareNamespace(%q, TargetNamespace)\n", g.prefix)
+	}
+	g.printf("return goxml.Doc(elt)\n}\n\n")
+}
+
+// goType returns the type of the field in the struct.
+func (f *field) goType() string {
+	switch {
+	case f.complex && f.multiple:
+		return "[]*" + f.kind
+	case f.complex:
+		return "*" + f.kind
+	case f.multiple:
+		return "[]" + f.kind
+	case f.optional:
+		return "*" + f.kind
+	}
+	return f.kind
+}
+
+func (g *generator) typ(t *goType) {
+	g.printf("// %s is generated from %s.\n", t.name, t.from)
+	g.printf("type %s struct {\n", t.name)
+	for _, f := range t.attrs {
+		g.printf("%s %s\n", f.goName, f.goType())
+	}
+	for _, f := range t.elems {
+		g.printf("%s %s\n", f.goName, f.goType())
+	}
+	if t.text != nil {
+		g.printf("Value %s\n", t.text.kind)
+	}
+	g.printf("// OtherAttributes and OtherElements contain the attributes and\n")
+	g.printf("// elements the schema does not declare.\n")
+	g.printf("OtherAttributes []goxml.Attribute\n")
+	g.printf("OtherElements []*goxml.Element\n")
+	g.printf("}\n\n")
+	g.unmarshal(t)
+	g.marshal(t)
+}
+
+func (g *generator) unmarshal(t *goType) {
+	g.printf("// UnmarshalElement sets the fields of v from elt.\n")
+	g.printf("func (v *%s) UnmarshalElement(elt *goxml.Element) error {\n", t.name)
+	g.printf("for _, attr := range elt.Attributes() {\nswitch {\n")
+	for _, f := range t.attrs {
+		g.printf("case attr.Name == %q && attr.Namespace == %s:\n", f.name, g.nsExpr(f.ns))
+		if f.kind == "string" {
+			g.assign(f, "attr.Value")
+			continue
+		}
+		g.printf("x, err := %s(elt, attr)\nif err != nil {\nreturn err\n}\n", parseFunc(f.kind))
+		g.assign(f, "x")
+	}
+	g.printf("default:\nv.OtherAttributes = append(v.OtherAttributes, *attr)\n}\n}\n")
+	g.printf("for _, c := range elt.Children() {\ncld, ok := c.(*goxml.Element)\nif !ok {\ncontinue\n}\nswitch {\n")
+	for _, f := range t.elems {
+		g.printf("case cld.Name == %q && cld.NamespaceURI() == %s:\n", f.name, g.nsExpr(f.ns))
+		switch {
+		case f.complex:
+			g.printf("x := &%s{}\nif err := x.UnmarshalElement(cld); err != nil {\nreturn err\n}\n", f.kind)
+			if f.multiple {
+				g.printf("v.%s = append(v.%s, x)\n", f.goName, f.goName)
+			} else {
+				g.printf("v.%s = x\n", f.goName)
+			}
+		case f.kind == "string":
+			g.assign(f, "cld.Stringvalue()")
+		default:
+			g.printf("x, err := cld.%s()\nif err != nil {\nreturn err\n}\n", valueMethod(f.kind))
+			g.assign(f, "x")
+		}
+	}
+	g.printf("default:\nv.OtherElements = append(v.OtherElements, cld.CloneWithNamespaces(nil, nil))\n}\n}\n")
+	if t.text != nil {
+		if t.text.kind == "string" {
+			g.printf("v.Value = elt.Stringvalue()\n")
+		} else {
+			g.printf("x, err := elt.%s()\nif err != nil {\nreturn err\n}\nv.Value = x\n", valueMethod(t.text.kind))
+		}
+	}
+	g.printf("return nil\n}\n\n")
+}
+
+// assign writes the assignment of the simple value x to the field f.
+func (g *generator) assign(f *field, x string) {
+	switch {
+	case f.multiple:
+		g.printf("v.%s = append(v.%s, %s)\n", f.goName, f.goName, x)
+	case f.optional:
+		if x != "x" {
+			g.printf("x := %s\n", x)
+		}
+		g.printf("v.%s = &x\n", f.goName)
+	default:
+		g.printf("v.%s = %s\n", f.goName, x)
+	}
+}
+
+func (g *generator) marshal(t *goType) {
+	g.printf("// MarshalElement returns an element with the name and the fields of v.\n")
+	g.printf("func (v *%s) MarshalElement(name string) *goxml.Element {\n", t.name)
+	g.printf("elt := goxml.E(name)\n")
+	for _, f := range t.attrs {
+		attr := func(value string) {
+			if f.ns == "" {
+				g.printf("elt.Append(goxml.Attr(%q, %s))\n", f.name, value)
+			} else {
+				g.printf("elt.Append(goxml.AttrNS(%s, %q, %s))\n", g.nsExpr(f.ns), f.name, value)
+			}
+		}
+		if f.optional {
+			g.printf("if v.%s != nil {\n", f.goName)
+			attr(formatExpr(f.kind, "*v."+f.goName))
+			g.printf("}\n")
+		} else {
+			attr(formatExpr(f.kind, "v."+f.goName))
+		}
+	}
+	g.printf("for _, attr := range v.OtherAttributes {\n")
+	g.printf("elt.Append(goxml.AttrNS(attr.Namespace, attr.Name, attr.Value))\n}\n")
+	if t.text != nil {
+		g.printf("elt.Append(goxml.T(%s))\n", formatExpr(t.text.kind, "v.Value"))
+	}
+	for _, f := range t.elems {
+		name := strconv.Quote(g.qname(f))
+		value := func(x string) string {
+			if f.complex {
+				return x + ".MarshalElement(" + name + ")"
+			}
+			return "goxml.E(" + name + ", goxml.T(" + formatExpr(f.kind, x) + "))"
+		}
+		switch {
+		case f.multiple:
+			g.printf("for _, x := range v.%s {\nelt.Append(%s)\n}\n", f.goName, value("x"))
+		case f.complex:
+			g.printf("if v.%s != nil {\nelt.Append(%s)\n}\n", f.goName, value("v."+f.goName))
+		case f.optional:
+			g.printf("if v.%s != nil {\nelt.Append(%s)\n}\n", f.goName, value("*v."+f.goName))
+		default:
+			g.printf("elt.Append(%s)\n", value("v."+f.goName))
+		}
+	}
+	g.printf("for _, o := range v.OtherElements {\nelt.Append(o.CloneWithNamespaces(nil, nil))\n}\n")
+	g.printf("return elt\n}\n\n")
+}
+
+// formatExpr returns the expression for the string form of the value x of
+// the kind.
+func formatExpr(kind, x string) string {
+	switch kind {
+	case "int":
+		return "strconv.Itoa(" + x + ")"
+	case "bool":
+		return "strconv.FormatBool(" + x + ")"
+	case "float64":
+		return "strconv.FormatFloat(" + x + ", 'g', -1, 64)"
+	}
+	return x
+}
+
+// valueMethod returns the goxml.Element method that reads a value of the
+// kind.
+func valueMethod(kind string) string {
+	switch kind {
+	case "int":
+		return "Int"
+	case "bool":
+		return "Bool"
+	}
+	return "Float"
+}
+
+// parseFunc returns the generated function that reads an attribute value of
+// the kind.
+func parseFunc(kind string) string {
+	switch kind {
+	case "int":
+		return "parseInt"
+	case "bool":
+		return "parseBool"
+	}
+	return "parseFloat"
+}
+
+func (g *generator) helpers() {
+	g.printf(`func attributeError(elt *goxml.Element, attr *goxml.Attribute, kind string) error {
+	return fmt.Errorf("line %%d: <%%s>: attribute %%s: invalid %%s %%q", elt.Line, elt.Name, attr.Name, kind, attr.Value)
+}
+
+func parseInt(elt *goxml.Element, attr *goxml.Attribute) (int, error) {
+	i, err := strconv.ParseInt(strings.TrimSpace(attr.Value), 10, 0)
+	if err != nil {
+		return 0, attributeError(elt, attr, "integer")
+	}
+	return int(i), nil
+}
+
+func parseFloat(elt *goxml.Element, attr *goxml.Attribute) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(attr.Value), 64)
+	if err != nil {
+		return 0, attributeError(elt, attr, "number")
+	}
+	return f, nil
+}
+
+func parseBool(elt *goxml.Element, attr *goxml.Attribute) (bool, error) {
+	switch strings.TrimSpace(attr.Value) {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, attributeError(elt, attr, "boolean")
+}
+`)
+}
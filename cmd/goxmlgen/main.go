@@ -0,0 +1,91 @@
+// Command goxmlgen generates Go types for the complex types of an XML Schema.
+//
+// Usage:
+//
+//	goxmlgen [flags] schema.xsd
+//
+// Each complex type, named or anonymous, becomes a struct with a field for
+// each attribute and child element it declares. The generated methods
+// UnmarshalElement and MarshalElement read the fields from a goxml.Element
+// and build an element from them. Attributes and child elements that the
+// schema does not declare are kept in the fields OtherAttributes and
+// OtherElements and written again, so a document survives a round trip
+// through the types, except for the order of undeclared elements among the
+// declared ones, comments and the text of mixed content. For each global
+// element with a complex type, the functions Unmarshal<Name> and
+// New<Name>Document read and write whole documents.
+//
+// The simple types are mapped to string, int, bool and float64 by their
+// built-in base type. Optional attributes and elements become pointers,
+// repeated elements slices. Groups, attribute groups and extensions of
+// complex types are resolved. Included and imported schemas, substitution
+// groups and xs:any are not read, the content they allow ends up in the
+// Other fields. goxml itself has no schema validation.
+//
+// Use it with go generate:
+//
+//	//go:generate goxmlgen -pkg books -o books_gen.go books.xsd
+//
+// The flags are:
+//
+//	-pkg name
+//		the package name of the generated file, main by default
+//	-o file
+//		write the code to file instead of standard output
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/speedata/goxml"
+)
+
+var (
+	pkg = flag.String("pkg", "main", "the package `name` of the generated code")
+	out = flag.String("o", "", "write the generated code to `file`")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: goxmlgen [flags] schema.xsd")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, "goxmlgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	doc, err := goxml.Parse(f, goxml.WithSourceName(file))
+	f.Close()
+	if err != nil {
+		return err
+	}
+	s, err := readSchema(doc)
+	if err != nil {
+		return err
+	}
+	src, err := generate(s, *pkg, file)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
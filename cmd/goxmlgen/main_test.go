@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/speedata/goxml"
+	"github.com/speedata/goxml/xmltest"
+)
+
+// libraryMain uses the types generated from testdata/library.xsd.
+const libraryMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/speedata/goxml"
+)
+
+func main() {
+	doc, err := goxml.Parse(os.Stdin)
+	if err != nil {
+		panic(err)
+	}
+	lib, err := UnmarshalLibrary(doc)
+	if err != nil {
+		panic(err)
+	}
+	b := lib.Book[0]
+	fmt.Println(lib.Name, *lib.Open, len(lib.Book), b.ID, *b.Year, b.Title, *b.Price, b.Author, lib.Book[1].Year == nil)
+	*b.Year++
+	fmt.Println(NewLibraryDocument(lib).ToXML())
+}
+`
+
+const libraryDoc = `<library xmlns="urn:lib" open="true" extra="x">
+  <name>City</name>
+  <book id="b1" year="1999"><title>One</title><price>9.5</price><author>A</author><author>B</author></book>
+  <book id="b2"><title>Two</title><author>C</author><note>kept</note></book>
+</library>`
+
+func generateFile(t *testing.T, file string) []byte {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goxml.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := readSchema(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(s, "main", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+// TestGeneratedCode builds the generated code in a module that uses the
+// goxml package of this repository and runs a document through it.
+func TestGeneratedCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	repo, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	gomod := "module gentest\n\ngo 1.19\n\nrequire github.com/speedata/goxml v0.0.0\n\nreplace github.com/speedata/goxml => " + repo + "\n"
+	files := map[string][]byte{
+		"go.mod":         []byte(gomod),
+		"library_gen.go": generateFile(t, "testdata/library.xsd"),
+		"main.go":        []byte(libraryMain),
+	}
+	for name, data := range files {
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(gocmd, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	cmd.Stdin = strings.NewReader(libraryDoc)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+	fields, xml, _ := strings.Cut(string(out), "\n")
+	if want := "City true 2 b1 1999 One 9.5 [A B] true"; fields != want {
+		t.Errorf("fields %q, want %q", fields, want)
+	}
+	want := strings.Replace(libraryDoc, `year="1999"`, `year="2000"`, 1)
+	xmltest.AssertEqual(t, xml, want, xmltest.Options{})
+}
+
+func TestReadSchemaErrors(t *testing.T) {
+	for _, s := range []string{
+		`<schema/>`,
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="a" type="missing"/></xs:schema>`,
+	} {
+		doc, err := goxml.Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = readSchema(doc); err == nil {
+			t.Errorf("readSchema(%q) succeeds", s)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/speedata/goxml"
+)
+
+const xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+
+// goType is a struct generated for a complex type.
+type goType struct {
+	name string
+	// from describes the source of the type for its doc comment
+	from  string
+	attrs []*field
+	elems []*field
+	// text is the value of a type with simple content
+	text *field
+}
+
+// field is an attribute, a child element or the text of a goType.
+type field struct {
+	goName string
+	name   string
+	ns     string
+	// kind is string, int, bool or float64 for simple values, the name of
+	// the goType otherwise
+	kind     string
+	complex  bool
+	multiple bool
+	optional bool
+}
+
+// goRoot is a global element with a complex type.
+type goRoot struct {
+	goName string
+	name   string
+	typ    string
+}
+
+type schema struct {
+	targetNS  string
+	qualified bool
+	// the global declarations by name
+	complexTypes    map[string]*goxml.Element
+	simpleTypes     map[string]*goxml.Element
+	elements        map[string]*goxml.Element
+	attributes      map[string]*goxml.Element
+	groups          map[string]*goxml.Element
+	attributeGroups map[string]*goxml.Element
+
+	types []*goType
+	roots []goRoot
+	// named contains the goTypes of the named complex types, done the
+	// goTypes whose fields are complete
+	named map[string]*goType
+	done  map[*goType]bool
+	// used contains the Go names in use
+	used map[string]bool
+}
+
+func isXSD(elt *goxml.Element, name string) bool {
+	return elt.Name == name && elt.NamespaceURI() == xsdNamespace
+}
+
+// xsdChildren returns the child elements of elt in the XML Schema namespace.
+func xsdChildren(elt *goxml.Element) []*goxml.Element {
+	var children []*goxml.Element
+	for _, c := range elt.Children() {
+		if cld, ok := c.(*goxml.Element); ok && cld.NamespaceURI() == xsdNamespace {
+			children = append(children, cld)
+		}
+	}
+	return children
+}
+
+func attr(elt *goxml.Element, name string) string {
+	for _, a := range elt.Attributes() {
+		if a.Name == name && a.Namespace == "" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// resolveQName returns the namespace and the local name of the QName in
+// the attribute name of elt.
+func resolveQName(elt *goxml.Element, name string) (string, string) {
+	prefix, local, ok := strings.Cut(attr(elt, name), ":")
+	if !ok {
+		prefix, local = "", prefix
+	}
+	ns, _ := elt.LookupNamespace(prefix)
+	return ns, local
+}
+
+func readSchema(doc *goxml.XMLDocument) (*schema, error) {
+	root, err := doc.Root()
+	if err != nil {
+		return nil, err
+	}
+	if !isXSD(root, "schema") {
+		return nil, errors.New("the root element is not xs:schema")
+	}
+	s := &schema{
+		targetNS:        attr(root, "targetNamespace"),
+		qualified:       attr(root, "elementFormDefault") == "qualified",
+		complexTypes:    map[string]*goxml.Element{},
+		simpleTypes:     map[string]*goxml.Element{},
+		elements:        map[string]*goxml.Element{},
+		attributes:      map[string]*goxml.Element{},
+		groups:          map[string]*goxml.Element{},
+		attributeGroups: map[string]*goxml.Element{},
+		named:           map[string]*goType{},
+		done:            map[*goType]bool{},
+		used:            map[string]bool{},
+	}
+	for _, c := range xsdChildren(root) {
+		name := attr(c, "name")
+		switch c.Name {
+		case "complexType":
+			s.complexTypes[name] = c
+		case "simpleType":
+			s.simpleTypes[name] = c
+		case "element":
+			s.elements[name] = c
+		case "attribute":
+			s.attributes[name] = c
+		case "group":
+			s.groups[name] = c
+		case "attributeGroup":
+			s.attributeGroups[name] = c
+		}
+	}
+	for _, c := range xsdChildren(root) {
+		switch c.Name {
+		case "complexType":
+			if _, err := s.namedType(attr(c, "name")); err != nil {
+				return nil, err
+			}
+		case "element":
+			kind, complex, err := s.elementType(c, "")
+			if err != nil {
+				return nil, err
+			}
+			if complex {
+				s.roots = append(s.roots, goRoot{goName: exported(attr(c, "name")), name: attr(c, "name"), typ: kind})
+			}
+		}
+	}
+	return s, nil
+}
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{"Id": true, "Uri": true, "Url": true, "Xml": true, "Html": true, "Http": true}
+
+// exported returns an exported Go name for the XML name.
+func exported(name string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && len(word) > 0 && !unicode.IsUpper(word[len(word)-1]):
+			flush()
+			word = append(word, r)
+		case len(word) == 0:
+			word = append(word, unicode.ToUpper(r))
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+	for i, w := range words {
+		if initialisms[w] {
+			words[i] = strings.ToUpper(w)
+		}
+	}
+	goName := strings.Join(words, "")
+	if goName == "" || !unicode.IsLetter([]rune(goName)[0]) {
+		goName = "X" + goName
+	}
+	return goName
+}
+
+// goName returns a Go name for a type from the XML name that
+// is not used yet.
+func (s *schema) goName(name string) string {
+	goName := exported(name)
+	candidate := goName
+	for i := 2; s.used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", goName, i)
+	}
+	s.used[candidate] = true
+	return candidate
+}
+
+// namedType returns the goType of the named complex type.
+func (s *schema) namedType(name string) (*goType, error) {
+	if t, ok := s.named[name]; ok {
+		return t, nil
+	}
+	ct, ok := s.complexTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("complex type %s not found", name)
+	}
+	t := &goType{name: s.goName(name), from: "the complex type " + name}
+	s.named[name] = t
+	if err := s.fill(t, ct); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// anonymousType returns a goType for the anonymous complex type ct.
+func (s *schema) anonymousType(goName, from string, ct *goxml.Element) (*goType, error) {
+	t := &goType{name: s.goName(goName), from: from}
+	if err := s.fill(t, ct); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// fill adds the fields of the complex type ct to t.
+func (s *schema) fill(t *goType, ct *goxml.Element) error {
+	s.types = append(s.types, t)
+	if err := s.content(t, ct, false, false); err != nil {
+		return err
+	}
+	s.done[t] = true
+	return nil
+}
+
+// content adds the fields declared by the children of elt. multiple and
+// optional are set inside of repeated or optional particles.
+func (s *schema) content(t *goType, elt *goxml.Element, multiple, optional bool) error {
+	for _, c := range xsdChildren(elt) {
+		switch c.Name {
+		case "sequence", "all", "choice":
+			m := multiple || repeated(c)
+			o := optional || attr(c, "minOccurs") == "0"
+			if c.Name == "choice" {
+				o = true
+			}
+			if err := s.content(t, c, m, o); err != nil {
+				return err
+			}
+		case "group":
+			_, name := resolveQName(c, "ref")
+			g, ok := s.groups[name]
+			if !ok {
+				return fmt.Errorf("line %d: group %s not found", c.Line, name)
+			}
+			if err := s.content(t, g, multiple || repeated(c), optional || attr(c, "minOccurs") == "0"); err != nil {
+				return err
+			}
+		case "attributeGroup":
+			_, name := resolveQName(c, "ref")
+			g, ok := s.attributeGroups[name]
+			if !ok {
+				return fmt.Errorf("line %d: attribute group %s not found", c.Line, name)
+			}
+			if err := s.content(t, g, false, false); err != nil {
+				return err
+			}
+		case "complexContent":
+			if err := s.content(t, c, multiple, optional); err != nil {
+				return err
+			}
+		case "simpleContent":
+			for _, d := range xsdChildren(c) {
+				if d.Name != "extension" && d.Name != "restriction" {
+					continue
+				}
+				kind, complex, err := s.typeName(d, "base")
+				if err != nil {
+					return err
+				}
+				if complex {
+					// the value and the attributes of the base type
+					_, name := resolveQName(d, "base")
+					base := s.named[name]
+					if !s.done[base] {
+						return fmt.Errorf("line %d: complex type %s extends itself", d.Line, name)
+					}
+					t.text = base.text
+					t.attrs = append(t.attrs, base.attrs...)
+				} else {
+					t.text = &field{goName: "Value", kind: kind}
+				}
+				if err := s.content(t, d, false, false); err != nil {
+					return err
+				}
+			}
+		case "extension":
+			ns, name := resolveQName(c, "base")
+			if ns != xsdNamespace {
+				base, err := s.namedType(name)
+				if err != nil {
+					return err
+				}
+				if !s.done[base] {
+					return fmt.Errorf("line %d: complex type %s extends itself", c.Line, name)
+				}
+				t.attrs = append(t.attrs, base.attrs...)
+				t.elems = append(t.elems, base.elems...)
+				t.text = base.text
+			}
+			if err := s.content(t, c, multiple, optional); err != nil {
+				return err
+			}
+		case "restriction":
+			if err := s.content(t, c, multiple, optional); err != nil {
+				return err
+			}
+		case "element":
+			f, err := s.elementField(t, c, multiple, optional)
+			if err != nil {
+				return err
+			}
+			t.elems = append(t.elems, f)
+		case "attribute":
+			f, err := s.attributeField(t, c)
+			if err != nil {
+				return err
+			}
+			if f != nil {
+				t.attrs = append(t.attrs, f)
+			}
+		}
+	}
+	return nil
+}
+
+// repeated reports whether the particle can occur more than once.
+func repeated(elt *goxml.Element) bool {
+	max := attr(elt, "maxOccurs")
+	return max != "" && max != "0" && max != "1"
+}
+
+func (s *schema) elementField(t *goType, elt *goxml.Element, multiple, optional bool) (*field, error) {
+	f := &field{
+		multiple: multiple || repeated(elt),
+		optional: optional || attr(elt, "minOccurs") == "0",
+	}
+	decl := elt
+	if attr(elt, "ref") != "" {
+		ns, name := resolveQName(elt, "ref")
+		global, ok := s.elements[name]
+		if !ok || ns != s.targetNS {
+			return nil, fmt.Errorf("line %d: element %s not found", elt.Line, name)
+		}
+		decl = global
+		f.name, f.ns = name, s.targetNS
+	} else {
+		f.name = attr(elt, "name")
+		form := attr(elt, "form")
+		if form == "qualified" || form == "" && s.qualified {
+			f.ns = s.targetNS
+		}
+	}
+	f.goName = fieldName(t, f.name)
+	var err error
+	f.kind, f.complex, err = s.elementType(decl, t.name+exported(f.name))
+	return f, err
+}
+
+// elementType returns the Go type of the element declaration elt. An
+// anonymous complex type gets the name goName, or the name of the element
+// if goName is empty.
+func (s *schema) elementType(elt *goxml.Element, goName string) (string, bool, error) {
+	if attr(elt, "type") != "" {
+		return s.typeName(elt, "type")
+	}
+	for _, c := range xsdChildren(elt) {
+		switch c.Name {
+		case "complexType":
+			if goName == "" {
+				goName = attr(elt, "name")
+			}
+			t, err := s.anonymousType(goName, "the element "+attr(elt, "name"), c)
+			if err != nil {
+				return "", false, err
+			}
+			return t.name, true, nil
+		case "simpleType":
+			return s.simpleKind(c), false, nil
+		}
+	}
+	return "string", false, nil
+}
+
+// typeName returns the Go type for the type name in the attribute name of
+// elt.
+func (s *schema) typeName(elt *goxml.Element, name string) (string, bool, error) {
+	ns, local := resolveQName(elt, name)
+	if ns == xsdNamespace {
+		return builtinKind(local), false, nil
+	}
+	if st, ok := s.simpleTypes[local]; ok {
+		return s.simpleKind(st), false, nil
+	}
+	if _, ok := s.complexTypes[local]; ok {
+		t, err := s.namedType(local)
+		if err != nil {
+			return "", false, err
+		}
+		return t.name, true, nil
+	}
+	return "", false, fmt.Errorf("line %d: type %s not found", elt.Line, local)
+}
+
+// simpleKind returns the Go type of a simple type by its base type. Lists
+// and unions are strings.
+func (s *schema) simpleKind(st *goxml.Element) string {
+	for _, c := range xsdChildren(st) {
+		if c.Name != "restriction" {
+			continue
+		}
+		if attr(c, "base") == "" {
+			for _, d := range xsdChildren(c) {
+				if d.Name == "simpleType" {
+					return s.simpleKind(d)
+				}
+			}
+			return "string"
+		}
+		ns, local := resolveQName(c, "base")
+		if ns == xsdNamespace {
+			return builtinKind(local)
+		}
+		if base, ok := s.simpleTypes[local]; ok && base != st {
+			return s.simpleKind(base)
+		}
+	}
+	return "string"
+}
+
+func builtinKind(name string) string {
+	switch name {
+	case "int", "integer", "long", "short", "byte", "nonNegativeInteger", "positiveInteger",
+		"nonPositiveInteger", "negativeInteger", "unsignedInt", "unsignedShort", "unsignedByte", "unsignedLong":
+		return "int"
+	case "boolean":
+		return "bool"
+	case "decimal", "float", "double":
+		return "float64"
+	}
+	return "string"
+}
+
+func (s *schema) attributeField(t *goType, elt *goxml.Element) (*field, error) {
+	if attr(elt, "use") == "prohibited" {
+		return nil, nil
+	}
+	f := &field{optional: attr(elt, "use") != "required"}
+	decl := elt
+	if attr(elt, "ref") != "" {
+		ns, name := resolveQName(elt, "ref")
+		f.name, f.ns = name, ns
+		if global, ok := s.attributes[name]; ok && ns == s.targetNS {
+			decl = global
+		}
+	} else {
+		f.name = attr(elt, "name")
+		if attr(elt, "form") == "qualified" {
+			f.ns = s.targetNS
+		}
+	}
+	f.goName = fieldName(t, f.name)
+	f.kind = "string"
+	if attr(decl, "type") != "" {
+		kind, complex, err := s.typeName(decl, "type")
+		if err != nil {
+			return nil, err
+		}
+		if !complex {
+			f.kind = kind
+		}
+	} else {
+		for _, c := range xsdChildren(decl) {
+			if c.Name == "simpleType" {
+				f.kind = s.simpleKind(c)
+			}
+		}
+	}
+	return f, nil
+}
+
+// fieldName returns a Go field name for the XML name that is unique in t.
+func fieldName(t *goType, name string) string {
+	goName := exported(name)
+	switch goName {
+	case "OtherAttributes", "OtherElements", "Value":
+		goName += "_"
+	}
+	candidate := goName
+	for i := 2; ; i++ {
+		taken := false
+		for _, f := range append(append([]*field{}, t.attrs...), t.elems...) {
+			if f.goName == candidate {
+				taken = true
+			}
+		}
+		if !taken {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", goName, i)
+	}
+}
@@ -0,0 +1,224 @@
+// Command goxmlq prints the nodes of XML documents selected by a path
+// expression.
+//
+// Usage:
+//
+//	goxmlq [flags] path [file ...]
+//
+// Without file arguments, goxmlq reads a document from standard input. The
+// path language is described in the documentation of goxml.Path. The flags
+// are:
+//
+//	-o format
+//		the output format: xml (default), text or json
+//	-ns prefix=uri
+//		bind the prefix to the namespace URI, can be repeated. The empty
+//		prefix (-ns =uri) applies to unprefixed element names.
+//
+// In the xml format, each selected node is written as XML on a line of its
+// own, attributes as name="value". The text format writes the string value
+// of each node. With more than one file, each line starts with the file
+// name. The json format writes an array with an object for each node.
+//
+// The exit status is 0 if a node has been selected, 1 if none has been
+// selected and 2 if an error occurred.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/speedata/goxml"
+)
+
+// namespaces collects the -ns flags.
+type namespaces map[string]string
+
+func (ns namespaces) String() string {
+	var bindings []string
+	for prefix, uri := range ns {
+		bindings = append(bindings, prefix+"="+uri)
+	}
+	return strings.Join(bindings, " ")
+}
+
+func (ns namespaces) Set(s string) error {
+	prefix, uri, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("%q is not of the form prefix=uri", s)
+	}
+	ns[prefix] = uri
+	return nil
+}
+
+// match is a selected node in the json format.
+type match struct {
+	File      string `json:"file"`
+	Type      string `json:"type"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Value     string `json:"value"`
+	XML       string `json:"xml,omitempty"`
+}
+
+var (
+	format = flag.String("o", "xml", "output `format`: xml, text or json")
+	nsmap  = namespaces{}
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: goxmlq [flags] path [file ...]")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Var(nsmap, "ns", "bind a namespace `prefix=uri`")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch *format {
+	case "xml", "text", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "goxmlq: unknown output format %q\n", *format)
+		os.Exit(2)
+	}
+	path, err := goxml.CompilePath(flag.Arg(0), nsmap)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goxmlq:", err)
+		os.Exit(2)
+	}
+	files := flag.Args()[1:]
+	q := query{path: path, prefix: len(files) > 1, matches: []match{}}
+	status := 1
+	if len(files) == 0 {
+		if err = q.run("<standard input>", os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+		}
+	}
+	for _, file := range files {
+		if err = q.runFile(file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+		}
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err = enc.Encode(q.matches); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+		}
+	}
+	if status == 1 && q.found {
+		status = 0
+	}
+	os.Exit(status)
+}
+
+type query struct {
+	path *goxml.Path
+	// prefix is set if the output lines start with the file name
+	prefix  bool
+	found   bool
+	matches []match
+}
+
+func (q *query) runFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return q.run(file, f)
+}
+
+// run evaluates the path on the document read from r and prints the result.
+func (q *query) run(file string, r io.Reader) error {
+	doc, err := goxml.Parse(r, goxml.WithSourceName(file))
+	if err != nil {
+		return err
+	}
+	for _, n := range q.path.Select(doc) {
+		q.found = true
+		m := describe(n)
+		m.File = file
+		switch *format {
+		case "json":
+			q.matches = append(q.matches, m)
+			continue
+		case "text":
+			q.print(file, m.Value)
+		default:
+			q.print(file, m.XML)
+		}
+	}
+	return nil
+}
+
+func (q *query) print(file, s string) {
+	if q.prefix {
+		fmt.Printf("%s: %s\n", file, s)
+	} else {
+		fmt.Println(s)
+	}
+}
+
+// describe returns the properties of the node n.
+func describe(n goxml.XMLNode) match {
+	switch t := n.(type) {
+	case *goxml.XMLDocument:
+		m := match{Type: "document", XML: t.ToXML()}
+		if root, err := t.Root(); err == nil {
+			m.Value = root.Stringvalue()
+		}
+		return m
+	case *goxml.Element:
+		return match{
+			Type:      "element",
+			Name:      qualifiedName(t.Prefix, t.Name),
+			Namespace: t.NamespaceURI(),
+			Line:      t.Line,
+			Value:     t.Stringvalue(),
+			XML:       t.ToXML(),
+		}
+	case *goxml.Attribute:
+		return match{
+			Type:      "attribute",
+			Name:      qualifiedName(t.Prefix, t.Name),
+			Namespace: t.Namespace,
+			Value:     t.Value,
+			XML:       qualifiedName(t.Prefix, t.Name) + `="` + attrEscaper.Replace(t.Value) + `"`,
+		}
+	case goxml.CharData:
+		return match{Type: "text", Value: t.Contents, XML: escaper.Replace(t.Contents)}
+	case goxml.Comment:
+		return match{Type: "comment", Value: t.Contents, XML: "<!--" + t.Contents + "-->"}
+	case goxml.ProcInst:
+		return match{Type: "processing-instruction", Name: t.Target, Value: string(t.Inst), XML: "<?" + t.Target + " " + string(t.Inst) + "?>"}
+	}
+	return match{}
+}
+
+// escaper and attrEscaper escape text and attribute values in the xml
+// format.
+var (
+	escaper     = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#9;", "\n", "&#10;", "\r", "&#13;")
+)
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs goxmlq instead of the tests if the test binary is started by
+// goxmlq below.
+func TestMain(m *testing.M) {
+	if os.Getenv("GOXMLQ_TEST_MAIN") == "1" {
+		os.Args = append([]string{"goxmlq"}, strings.Fields(os.Getenv("GOXMLQ_TEST_ARGS"))...)
+		main()
+	}
+	os.Exit(m.Run())
+}
+
+// goxmlq runs the command with the arguments and the standard input and
+// returns the standard output and the exit status.
+func goxmlq(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "GOXMLQ_TEST_MAIN=1", "GOXMLQ_TEST_ARGS="+strings.Join(args, " "))
+	cmd.Stdin = strings.NewReader(stdin)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	if ee, ok := err.(*exec.ExitError); ok {
+		return out.String(), ee.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), 0
+}
+
+const testDoc = `<shop xmlns:p="P"><item sku="A&amp;B">a &lt; b</item><p:item sku="C"/><!--c--></shop>`
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   string
+		status int
+	}{
+		{[]string{"/shop/item"}, "<item xmlns:p=\"P\" sku=\"A&amp;B\">a &lt; b</item>\n", 0},
+		{[]string{"-o", "text", "/shop/item"}, "a < b\n", 0},
+		{[]string{"//@sku"}, "sku=\"A&amp;B\"\nsku=\"C\"\n", 0},
+		{[]string{"-ns", "x=P", "-o", "text", "/shop/x:item/@sku"}, "C\n", 0},
+		{[]string{"/shop/comment()"}, "<!--c-->\n", 0},
+		{[]string{"/shop/missing"}, "", 1},
+		{[]string{"/shop/item["}, "", 2},
+		{[]string{"-o", "yaml", "/shop"}, "", 2},
+		{nil, "", 2},
+	}
+	for _, tc := range tests {
+		out, status := goxmlq(t, testDoc, tc.args...)
+		if out != tc.want || status != tc.status {
+			t.Errorf("goxmlq %v: %q, status %d, want %q, status %d", tc.args, out, status, tc.want, tc.status)
+		}
+	}
+}
+
+func TestQueryFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.xml"), filepath.Join(dir, "b.xml")
+	os.WriteFile(a, []byte(`<r><x>1</x></r>`), 0o644)
+	os.WriteFile(b, []byte(`<r><x>2</x><x>3</x></r>`), 0o644)
+	out, status := goxmlq(t, "", "-o", "text", "//x", a, b)
+	if want := a + ": 1\n" + b + ": 2\n" + b + ": 3\n"; out != want || status != 0 {
+		t.Errorf("goxmlq with two files: %q, status %d, want %q", out, status, want)
+	}
+	// an unreadable file is reported, the other files are queried
+	out, status = goxmlq(t, "", "-o", "text", "//x", filepath.Join(dir, "missing.xml"), a)
+	if want := a + ": 1\n"; out != want || status != 2 {
+		t.Errorf("goxmlq with a missing file: %q, status %d, want %q, status 2", out, status, want)
+	}
+}
+
+func TestQueryJSON(t *testing.T) {
+	out, status := goxmlq(t, testDoc, "-o", "json", "-ns", "x=P", "/shop/x:item")
+	if status != 0 {
+		t.Fatalf("status %d", status)
+	}
+	var matches []match
+	if err := json.Unmarshal([]byte(out), &matches); err != nil {
+		t.Fatal(err)
+	}
+	want := match{File: "<standard input>", Type: "element", Name: "p:item", Namespace: "P", Line: 1, XML: `<p:item xmlns:p="P" sku="C" />`}
+	if len(matches) != 1 || matches[0] != want {
+		t.Errorf("json output %+v, want %+v", matches, want)
+	}
+	// without a match the output is an empty array
+	if out, _ = goxmlq(t, testDoc, "-o", "json", "/none"); strings.TrimSpace(out) != "[]" {
+		t.Errorf("json output without a match: %q, want []", out)
+	}
+}
@@ -0,0 +1,183 @@
+// Command goxmlvalidate checks XML documents.
+//
+// Usage:
+//
+//	goxmlvalidate [flags] file|dir|pattern ...
+//
+// Each argument is a file, a directory, whose .xml files are checked
+// recursively, or a glob pattern such as "docs/*.xml". The documents are
+// checked for well-formedness, including the namespace constraints, and all
+// recoverable problems of a document are reported, not just the first one.
+// goxml has no schema support, so XSD, RELAX NG and Schematron validation
+// are not available.
+//
+// The flags are:
+//
+//	-json
+//		write the result as a JSON array with an object per file
+//	-q
+//		do not write anything, only set the exit status
+//
+// The exit status is 0 if all documents are well-formed, 1 if a document
+// has a problem and 2 if a file cannot be read or an argument matches no
+// file.
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/speedata/goxml"
+)
+
+var (
+	jsonOutput = flag.Bool("json", false, "write the result as JSON")
+	quiet      = flag.Bool("q", false, "only set the exit status")
+)
+
+// result is the outcome for one file in the JSON output.
+type result struct {
+	File   string    `json:"file"`
+	Valid  bool      `json:"valid"`
+	Errors []problem `json:"errors,omitempty"`
+}
+
+type problem struct {
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Element string `json:"element,omitempty"`
+	Message string `json:"message"`
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: goxmlvalidate [flags] file|dir|pattern ...")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	status := 0
+	var files []string
+	for _, arg := range flag.Args() {
+		matches, err := expand(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "goxmlvalidate:", err)
+			status = 2
+			continue
+		}
+		files = append(files, matches...)
+	}
+	results := []result{}
+	for _, file := range files {
+		res, err := check(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "goxmlvalidate:", err)
+			status = 2
+			continue
+		}
+		if !res.Valid && status == 0 {
+			status = 1
+		}
+		if *quiet {
+			continue
+		}
+		if *jsonOutput {
+			results = append(results, res)
+			continue
+		}
+		for _, p := range res.Errors {
+			fmt.Printf("%s:%d:%d: %s\n", res.File, p.Line, p.Column, p.Message)
+		}
+	}
+	if *jsonOutput && !*quiet {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, "goxmlvalidate:", err)
+			status = 2
+		}
+	}
+	os.Exit(status)
+}
+
+// expand returns the files an argument stands for.
+func expand(arg string) ([]string, error) {
+	if strings.ContainsAny(arg, "*?[") {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no matching files", arg)
+		}
+		return matches, nil
+	}
+	fi, err := os.Stat(arg)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{arg}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".xml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// check parses the file and returns the problems found. The error is only
+// set if the file cannot be read.
+func check(file string) (result, error) {
+	res := result{File: file}
+	f, err := os.Open(file)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	doc, err := goxml.Parse(f, goxml.WithSourceName(file), goxml.WithCollectErrors())
+	var pe *goxml.ParseError
+	if err != nil && !errors.As(err, &pe) {
+		return res, err
+	}
+	var errs []*goxml.ParseError
+	if doc != nil {
+		errs = doc.Errors()
+	} else if pe != nil {
+		errs = []*goxml.ParseError{pe}
+	}
+	for _, e := range errs {
+		msg := e.Err.Error()
+		var se *xml.SyntaxError
+		if errors.As(e.Err, &se) {
+			msg = se.Msg
+		}
+		res.Errors = append(res.Errors, problem{
+			Line:    e.Line,
+			Column:  e.Column,
+			Element: e.Element,
+			Message: msg,
+		})
+	}
+	res.Valid = len(res.Errors) == 0
+	return res, nil
+}
@@ -0,0 +1,69 @@
+package goxml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CollectionRoot is the name of the root element of the documents created by
+// ParseFiles and ParseGlob.
+const CollectionRoot = "collection"
+
+// ParseFiles parses the files and returns a document whose root element
+// collection contains the root elements of the files in the given order, so
+// that queries can run over all of them at once. Each of these elements gets
+// an xml:base attribute with its path, and its Source method returns the
+// path. Comments and processing instructions outside of the root elements are
+// dropped. The nodes are numbered again in document order. The first file
+// that cannot be read or parsed stops ParseFiles, the error contains the
+// path.
+func ParseFiles(paths []string, opts ...ParseOption) (*XMLDocument, error) {
+	p := NewParser(opts...)
+	doc := NewDocument()
+	collection := NewElement()
+	collection.Name = CollectionRoot
+	doc.Append(collection)
+	for _, path := range paths {
+		root, err := p.parseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		root.SetAttribute(xmlBaseAttr(filepath.ToSlash(path)))
+		root.source = path
+		collection.Append(root)
+	}
+	doc.renumber()
+	return doc, nil
+}
+
+// ParseGlob parses the files that match the pattern like ParseFiles, in the
+// lexical order of their names. The syntax of the pattern is the one of
+// filepath.Match.
+func ParseGlob(pattern string, opts ...ParseOption) (*XMLDocument, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFiles(paths, opts...)
+}
+
+// parseFile returns the root element of the document in the file path.
+func (p *Parser) parseFile(path string) (*Element, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p.opts.sourceName = path
+	p.Reset(f)
+	doc, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	root, err := doc.Root()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return root, nil
+}
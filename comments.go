@@ -0,0 +1,49 @@
+package goxml
+
+// LeadingComments returns the comments attached to the element, see
+// WithAttachedComments. They are written before the start tag of the element
+// when its parent is serialized, together with the white space that followed
+// them in the source.
+func (elt *Element) LeadingComments() []Comment {
+	var comments []Comment
+	for _, n := range elt.leading {
+		if cmt, ok := n.(Comment); ok {
+			comments = append(comments, cmt)
+		}
+	}
+	return comments
+}
+
+// SetLeadingComments replaces the comments attached to the element. No
+// comments remove them.
+func (elt *Element) SetLeadingComments(comments ...Comment) {
+	elt.invalidate()
+	elt.leading = nil
+	for _, cmt := range comments {
+		elt.leading = append(elt.leading, cmt)
+	}
+}
+
+// writeLeading writes the comments attached to the element. When indenting,
+// each comment is written on a line of its own.
+func (elt *Element) writeLeading(xw *xmlWriter) {
+	for _, n := range elt.leading {
+		if xw.indent != "" && !xw.keepSpace {
+			if _, ok := n.(CharData); !ok {
+				n.serialize(xw)
+				xw.newline()
+			}
+			continue
+		}
+		n.serialize(xw)
+	}
+}
+
+// serializeChild writes the child of an element including the comments
+// attached to it.
+func serializeChild(xw *xmlWriter, child XMLNode) {
+	if elt, ok := child.(*Element); ok {
+		elt.writeLeading(xw)
+	}
+	child.serialize(xw)
+}
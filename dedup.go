@@ -0,0 +1,167 @@
+package goxml
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// Deduplicate finds the subtrees of the document that are equal to an
+// earlier subtree, with the same names, prefixes, namespace declarations,
+// attributes in the same order and contents, and makes them use the memory
+// of the text, the attribute values and the cached string values of the
+// first one. It returns the number of such subtrees, subtrees inside of them
+// are not counted. In catalog-like documents with many repeated entries
+// most of the memory for text is freed.
+//
+// The elements themselves are not shared, because every node has a single
+// Parent and an ID of its own, so the document stays a tree and all methods
+// work as before. Since strings cannot be changed, changes to one of the
+// subtrees never affect the others. Deduplicate changes the nodes and must
+// not run concurrently with readers of the document.
+func (xr *XMLDocument) Deduplicate() int {
+	hashes := make(map[*Element]uint64)
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			exactHash(elt, hashes)
+		}
+	}
+	first := make(map[uint64][]*Element)
+	count := 0
+	var visit func(elt *Element)
+	visit = func(elt *Element) {
+		h := hashes[elt]
+		for _, src := range first[h] {
+			if exactEqual(src, elt) {
+				shareStrings(elt, src)
+				count++
+				return
+			}
+		}
+		first[h] = append(first[h], elt)
+		for _, c := range elt.children {
+			if cld, ok := c.(*Element); ok {
+				visit(cld)
+			}
+		}
+	}
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			visit(elt)
+		}
+	}
+	return count
+}
+
+// exactHash returns a hash of the subtree of elt that includes everything
+// exactEqual compares and stores it with the hashes of the descendants in
+// hashes.
+func exactHash(elt *Element, hashes map[*Element]uint64) uint64 {
+	h := fnv.New64a()
+	writeHashPart(h, 'e', elt.Prefix, elt.Name)
+	prefixes := make([]string, 0, len(elt.Namespaces))
+	for prefix := range elt.Namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		writeHashPart(h, 'n', prefix, elt.Namespaces[prefix])
+	}
+	for _, attr := range elt.attributes {
+		writeHashPart(h, 'a', attr.Prefix, attr.Name, attr.Value)
+	}
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			writeHashPart(h, 'h', strconv.FormatUint(exactHash(cld, hashes), 16))
+		} else {
+			writeHash(h, c, HashOptions{})
+		}
+	}
+	sum := h.Sum64()
+	hashes[elt] = sum
+	return sum
+}
+
+// exactEqual reports whether the subtrees of a and b are equal including
+// the prefixes, namespace declarations and the order of the attributes.
+func exactEqual(a, b *Element) bool {
+	if a.Name != b.Name || a.Prefix != b.Prefix || len(a.Namespaces) != len(b.Namespaces) ||
+		len(a.attributes) != len(b.attributes) || len(a.children) != len(b.children) {
+		return false
+	}
+	for prefix, ns := range a.Namespaces {
+		if other, ok := b.Namespaces[prefix]; !ok || other != ns {
+			return false
+		}
+	}
+	for i, attr := range a.attributes {
+		other := b.attributes[i]
+		if attr.Name != other.Name || attr.Prefix != other.Prefix || attr.Namespace != other.Namespace || attr.Value != other.Value {
+			return false
+		}
+	}
+	for i, c := range a.children {
+		switch t := c.(type) {
+		case *Element:
+			other, ok := b.children[i].(*Element)
+			if !ok || !exactEqual(t, other) {
+				return false
+			}
+		case CharData:
+			other, ok := b.children[i].(CharData)
+			if !ok || t.Contents != other.Contents {
+				return false
+			}
+		case Comment:
+			other, ok := b.children[i].(Comment)
+			if !ok || t.Contents != other.Contents {
+				return false
+			}
+		case ProcInst:
+			other, ok := b.children[i].(ProcInst)
+			if !ok || t.Target != other.Target || string(t.Inst) != string(other.Inst) {
+				return false
+			}
+		case EntityRef:
+			other, ok := b.children[i].(EntityRef)
+			if !ok || t.Name != other.Name || t.Value != other.Value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// shareStrings makes the subtree of dst use the strings of the equal subtree
+// of src.
+func shareStrings(dst, src *Element) {
+	dst.Name, dst.Prefix = src.Name, src.Prefix
+	for i, attr := range dst.attributes {
+		s := src.attributes[i]
+		attr.Name, attr.Prefix, attr.Namespace, attr.Value = s.Name, s.Prefix, s.Namespace, s.Value
+		if attr.RawValue == s.RawValue {
+			attr.RawValue = s.RawValue
+		}
+		if attr.parsed == s.parsed {
+			attr.parsed = s.parsed
+		}
+	}
+	if v, ok := dst.cachedStringvalue(); ok && src.svCache != nil && src.svCache.value == v {
+		dst.svCache.value = src.svCache.value
+	}
+	if dst.serializedCached && src.serializedCached && dst.serializedKey == src.serializedKey && dst.serialized == src.serialized {
+		dst.serialized = src.serialized
+	}
+	for i, c := range dst.children {
+		switch t := c.(type) {
+		case *Element:
+			shareStrings(t, src.children[i].(*Element))
+		case CharData:
+			t.Contents = src.children[i].(CharData).Contents
+			dst.children[i] = t
+		case Comment:
+			t.Contents = src.children[i].(Comment).Contents
+			dst.children[i] = t
+		}
+	}
+}
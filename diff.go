@@ -0,0 +1,262 @@
+package goxml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// DiffNamespace is the namespace of the markup that AnnotatedDiff adds.
+const DiffNamespace = "urn:speedata:goxml:diff"
+
+// DiffType is the kind of a Difference.
+type DiffType int
+
+const (
+	// DiffInsert is a node that is only in the new document.
+	DiffInsert DiffType = iota
+	// DiffDelete is a node that is only in the old document.
+	DiffDelete
+	// DiffAttribute is an attribute that has been added, removed or
+	// changed.
+	DiffAttribute
+)
+
+// Difference is a change between two versions of a document, see Diff.
+type Difference struct {
+	Type DiffType
+	// Path is the path of the element with the change in the new document,
+	// such as /book[1]/chapter[2], or / for the document itself.
+	Path string
+	// Node is the inserted or deleted node.
+	Node XMLNode
+	// Name is the name of a changed attribute, the space is the namespace
+	// URI. OldValue is empty for added attributes, NewValue for removed
+	// ones.
+	Name     xml.Name
+	OldValue string
+	NewValue string
+}
+
+// Diff returns the differences between the documents old and new, in the
+// order of the new document. Children are matched like with Merge3, so an
+// element whose contents have changed is reported by the differences of
+// its contents, changed text by the deletion of the old and the insertion of
+// the new text. Namespace prefixes and the order of attributes are ignored.
+func Diff(old, new *XMLDocument) []Difference {
+	d := &differ{}
+	d.children(nil, new, old.children, new.children, old)
+	return d.diffs
+}
+
+// AnnotatedDiff returns a copy of the new document with the differences to
+// the old document marked with markup in DiffNamespace, bound to the prefix
+// diff on the root element: inserted and deleted elements get the attribute
+// diff:op with the value insert or delete, other inserted and deleted nodes
+// such as text are wrapped in diff:ins and diff:del elements. Elements with
+// changed attributes get diff:op="change", the old value of each changed or
+// removed attribute in an attribute diff:old-name, with the local name of the
+// attribute, and the names of the added attributes in diff:added. Deleted
+// nodes are inserted at their place in the old document. If the root
+// elements do not match, the result has both of them.
+func AnnotatedDiff(old, new *XMLDocument) *XMLDocument {
+	doc := NewDocument()
+	doc.baseURI = new.baseURI
+	doc.source = new.source
+	d := &differ{annotate: true}
+	d.children(doc, new, old.children, new.children, old)
+	if root, err := doc.Root(); err == nil {
+		root.DeclareNamespace("diff", DiffNamespace)
+	}
+	doc.renumber()
+	return doc
+}
+
+// WriteUnifiedDiff writes the differences like a unified diff: a line with
+// the path of the changed element in @@ is followed by the deleted parts
+// with a leading - and the inserted parts with a leading +. Consecutive
+// differences of an element share the header.
+func WriteUnifiedDiff(w io.Writer, diffs []Difference) error {
+	bw := bufio.NewWriter(w)
+	path := ""
+	for i, diff := range diffs {
+		if i == 0 || diff.Path != path {
+			path = diff.Path
+			bw.WriteString("@@ " + path + " @@\n")
+		}
+		switch diff.Type {
+		case DiffInsert:
+			writeDiffLines(bw, '+', nodesXML([]XMLNode{diff.Node}))
+		case DiffDelete:
+			writeDiffLines(bw, '-', nodesXML([]XMLNode{diff.Node}))
+		case DiffAttribute:
+			name := diff.Name.Local
+			if diff.Name.Space != "" {
+				name = "{" + diff.Name.Space + "}" + name
+			}
+			if diff.OldValue != "" || diff.NewValue == "" {
+				writeDiffLines(bw, '-', "@"+name+"="+AttrQuote(diff.OldValue))
+			}
+			if diff.NewValue != "" || diff.OldValue == "" {
+				writeDiffLines(bw, '+', "@"+name+"="+AttrQuote(diff.NewValue))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// writeDiffLines writes each line of s with the mark in front.
+func writeDiffLines(bw *bufio.Writer, mark byte, s string) {
+	for _, line := range strings.Split(s, "\n") {
+		bw.WriteByte(mark)
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+}
+
+type differ struct {
+	diffs    []Difference
+	annotate bool
+}
+
+// children compares the children a of the node oldParent with the children
+// b of newParent and adds the annotated nodes to dest.
+func (d *differ) children(dest, newParent XMLNode, a, b []XMLNode, oldParent XMLNode) {
+	match := matchNodes(a, b)
+	j := 0
+	for i, n := range a {
+		if match[i] < 0 {
+			d.changed(dest, newParent, n, oldParent, DiffDelete)
+			continue
+		}
+		for ; j < match[i]; j++ {
+			d.changed(dest, newParent, b[j], newParent, DiffInsert)
+		}
+		d.matched(dest, n, b[j])
+		j++
+	}
+	for ; j < len(b); j++ {
+		d.changed(dest, newParent, b[j], newParent, DiffInsert)
+	}
+}
+
+// changed records the inserted or deleted node n, whose parent is parent.
+func (d *differ) changed(dest, newParent, n, parent XMLNode, typ DiffType) {
+	d.diffs = append(d.diffs, Difference{Type: typ, Path: mergePath(newParent), Node: n})
+	if !d.annotate {
+		return
+	}
+	op, wrapper := "insert", "ins"
+	if typ == DiffDelete {
+		op, wrapper = "delete", "del"
+	}
+	elt, ok := n.(*Element)
+	if !ok {
+		if _, isElt := dest.(*Element); !isElt {
+			// no markup outside of the root element
+			if typ == DiffInsert {
+				dest.(Appender).Append(n)
+			}
+			return
+		}
+		w := &Element{Name: wrapper, Prefix: "diff"}
+		w.Append(n)
+		dest.(Appender).Append(w)
+		return
+	}
+	copyNodes(dest, []XMLNode{elt}, parent)
+	children := dest.Children()
+	cp := children[len(children)-1].(*Element)
+	cp.Append(diffAttribute("op", op))
+}
+
+// matched compares the node a of the old document with the matching node b
+// of the new document.
+func (d *differ) matched(dest, a, b XMLNode) {
+	oldElt, ok := a.(*Element)
+	if !ok {
+		if d.annotate {
+			dest.(Appender).Append(b)
+		}
+		return
+	}
+	newElt := b.(*Element)
+	var elt *Element
+	if d.annotate {
+		elt = NewElement()
+		elt.Name = newElt.Name
+		elt.Prefix = newElt.Prefix
+		elt.Line, elt.Pos = newElt.Line, newElt.Pos
+		elt.source = newElt.source
+		for prefix, ns := range newElt.Namespaces {
+			elt.Namespaces[prefix] = ns
+		}
+		for _, attr := range newElt.attributes {
+			cp := *attr
+			elt.attributes = append(elt.attributes, &cp)
+		}
+		dest.(Appender).Append(elt)
+	}
+	d.attributes(elt, oldElt, newElt)
+	var next XMLNode
+	if elt != nil {
+		next = elt
+	}
+	d.children(next, newElt, oldElt.children, newElt.children, oldElt)
+}
+
+// attributes compares the attributes of a and b and annotates elt.
+func (d *differ) attributes(elt, a, b *Element) {
+	find := func(e *Element, attr *Attribute) *Attribute {
+		for _, cur := range e.attributes {
+			if cur.Namespace == attr.Namespace && cur.Name == attr.Name {
+				return cur
+			}
+		}
+		return nil
+	}
+	var added []string
+	changed := false
+	add := func(oldValue, newValue string, attr *Attribute) {
+		changed = true
+		d.diffs = append(d.diffs, Difference{
+			Type:     DiffAttribute,
+			Path:     mergePath(b),
+			Name:     xml.Name{Space: attr.Namespace, Local: attr.Name},
+			OldValue: oldValue,
+			NewValue: newValue,
+		})
+	}
+	for _, attr := range a.attributes {
+		cur := find(b, attr)
+		switch {
+		case cur == nil:
+			add(attr.Value, "", attr)
+		case cur.Value != attr.Value:
+			add(attr.Value, cur.Value, attr)
+		default:
+			continue
+		}
+		if elt != nil {
+			elt.Append(diffAttribute("old-"+attr.Name, attr.Value))
+		}
+	}
+	for _, attr := range b.attributes {
+		if find(a, attr) == nil {
+			add("", attr.Value, attr)
+			added = append(added, qualifiedName(attr.Prefix, attr.Name))
+		}
+	}
+	if elt == nil || !changed {
+		return
+	}
+	elt.Append(diffAttribute("op", "change"))
+	if len(added) > 0 {
+		elt.Append(diffAttribute("added", strings.Join(added, " ")))
+	}
+}
+
+func diffAttribute(name, value string) Attribute {
+	return Attribute{Name: name, Namespace: DiffNamespace, Prefix: "diff", Value: value}
+}
@@ -0,0 +1,73 @@
+package goxml
+
+import "sync/atomic"
+
+// dirtyEpoch counts the ClearDirty calls of all documents. A change stores
+// the current epoch plus one in the changed element, so it is newer than
+// the cleanEpoch of its document exactly if it has been made after the last
+// ClearDirty call. Changes only read the counter, so documents in different
+// goroutines do not slow each other down.
+var dirtyEpoch int64
+
+// ClearDirty marks all nodes of the document as unchanged. Parse calls it
+// for the new document.
+func (xr *XMLDocument) ClearDirty() {
+	xr.cleanEpoch = atomic.AddInt64(&dirtyEpoch, 1)
+}
+
+// Modified reports whether the element has been changed through its methods
+// since the last ClearDirty call of its document, such as by Append,
+// SetAttribute or DeclareNamespace. Changes to the descendants of the element
+// do not count, changes to the exported fields are not noticed. For elements
+// that do not belong to a document, all changes count.
+func (elt *Element) Modified() bool {
+	return elt.modified > elt.cleanEpoch()
+}
+
+// cleanEpoch returns the epoch of the last ClearDirty call of the document
+// of elt, zero if elt is not part of a document.
+func (elt *Element) cleanEpoch() int64 {
+	for cur := elt; ; {
+		switch p := cur.Parent.(type) {
+		case *Element:
+			cur = p
+		case *XMLDocument:
+			return p.cleanEpoch
+		default:
+			return 0
+		}
+	}
+}
+
+// DirtyNodes returns the nodes that have been changed since the last
+// ClearDirty call, see Element.Modified, in document order. The document
+// itself is included if elements or other nodes have been appended to it.
+// Only the subtrees that contain changes are visited, so the call is cheap
+// for large documents with few changes. A node that has been removed from
+// the document is not returned, its former parent is.
+func (xr *XMLDocument) DirtyNodes() []XMLNode {
+	var dirty []XMLNode
+	if xr.modified > xr.cleanEpoch {
+		dirty = append(dirty, xr)
+	}
+	var collect func(*Element)
+	collect = func(elt *Element) {
+		if elt.modifiedBelow <= xr.cleanEpoch {
+			return
+		}
+		if elt.modified > xr.cleanEpoch {
+			dirty = append(dirty, elt)
+		}
+		for _, c := range elt.children {
+			if cld, ok := c.(*Element); ok {
+				collect(cld)
+			}
+		}
+	}
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			collect(elt)
+		}
+	}
+	return dirty
+}
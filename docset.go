@@ -0,0 +1,244 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DocumentSetOptions controls how a DocumentSet loads documents and finds
+// the links between them.
+type DocumentSetOptions struct {
+	// Resolver opens the documents and the linked resources. The default is
+	// FileResolver.
+	Resolver Resolver
+	// ParseOptions are used for parsing the documents. The base URI and the
+	// source name are set to the URI of each document.
+	ParseOptions []ParseOption
+	// LinkAttributes are the attributes whose values are links. The default
+	// is href and fileref without namespace and xlink:href.
+	LinkAttributes []xml.Name
+	// Follow reports whether the linked resource uri is a document of the
+	// set, which is loaded as well. Other resources, such as images, are
+	// only opened to check that they exist. By default, resources whose path
+	// ends in .xml are followed.
+	Follow func(uri string) bool
+}
+
+// Link is a link from an element of a document in a DocumentSet.
+type Link struct {
+	// Element is the element with the link attribute Name.
+	Element *Element
+	Name    xml.Name
+	// Href is the value of the attribute, URI is the absolute URI of the
+	// resource without the fragment identifier, Fragment the identifier
+	// without #.
+	Href     string
+	URI      string
+	Fragment string
+	// Source is the URI of the document with the link.
+	Source string
+}
+
+// BrokenLink is a link whose resource or fragment cannot be found.
+type BrokenLink struct {
+	Link
+	Err error
+}
+
+// DocumentSet is a set of related documents, such as the files of a book
+// project, whose links are resolved against each other. Relative links are
+// resolved against the base URI of the element, so xml:base attributes are
+// taken into account.
+type DocumentSet struct {
+	opts  DocumentSetOptions
+	docs  map[string]*XMLDocument
+	uris  []string
+	links []Link
+	// errs contains the errors of the followed documents that could not be
+	// loaded, exists the results of opening other resources
+	errs   map[string]error
+	exists map[string]error
+}
+
+// NewDocumentSet returns an empty document set.
+func NewDocumentSet(opts DocumentSetOptions) *DocumentSet {
+	if opts.Resolver == nil {
+		opts.Resolver = FileResolver{}
+	}
+	if opts.LinkAttributes == nil {
+		opts.LinkAttributes = []xml.Name{{Local: "href"}, {Local: "fileref"}, {Space: XLinkNamespace, Local: "href"}}
+	}
+	if opts.Follow == nil {
+		opts.Follow = func(uri string) bool {
+			u, err := url.Parse(uri)
+			return err == nil && strings.EqualFold(path.Ext(u.Path), ".xml")
+		}
+	}
+	return &DocumentSet{
+		opts:   opts,
+		docs:   make(map[string]*XMLDocument),
+		errs:   make(map[string]error),
+		exists: make(map[string]error),
+	}
+}
+
+// Load loads the document at uri and the documents it links to, see
+// DocumentSetOptions.Follow, and returns the first one. A relative file path
+// is made absolute first. Documents that are already in the set are not
+// loaded again. Linked documents that cannot be loaded are reported by
+// BrokenLinks, only an error for the document at uri itself is returned.
+func (ds *DocumentSet) Load(uri string) (*XMLDocument, error) {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "" && !path.IsAbs(u.Path) {
+		abs, err := filepath.Abs(filepath.FromSlash(u.Path))
+		if err != nil {
+			return nil, err
+		}
+		u.Path = filepath.ToSlash(abs)
+		uri = u.String()
+	}
+	start := len(ds.links)
+	doc, err := ds.load(uri)
+	if err != nil {
+		return nil, err
+	}
+	// the links of the new documents are followed breadth first
+	for i := start; i < len(ds.links); i++ {
+		link := ds.links[i]
+		if _, loaded := ds.docs[link.URI]; loaded || ds.errs[link.URI] != nil || !ds.opts.Follow(link.URI) {
+			continue
+		}
+		if _, err := ds.load(link.URI); err != nil {
+			ds.errs[link.URI] = err
+		}
+	}
+	return doc, nil
+}
+
+// load parses the document at uri and adds it with its links to the set.
+func (ds *DocumentSet) load(uri string) (*XMLDocument, error) {
+	if doc, ok := ds.docs[uri]; ok {
+		return doc, nil
+	}
+	r, err := ds.opts.Resolver.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	opts := append(append([]ParseOption(nil), ds.opts.ParseOptions...), WithBaseURI(uri), WithSourceName(uri))
+	doc, err := Parse(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ds.docs[uri] = doc
+	ds.uris = append(ds.uris, uri)
+	var visit func(elt *Element)
+	visit = func(elt *Element) {
+		for _, attr := range elt.attributes {
+			name := xml.Name{Space: attr.Namespace, Local: attr.Name}
+			for _, ln := range ds.opts.LinkAttributes {
+				if ln == name {
+					ds.links = append(ds.links, ds.link(uri, elt, name, attr.Value))
+				}
+			}
+		}
+		for _, c := range elt.children {
+			if cld, ok := c.(*Element); ok {
+				visit(cld)
+			}
+		}
+	}
+	for _, c := range doc.children {
+		if elt, ok := c.(*Element); ok {
+			visit(elt)
+		}
+	}
+	return doc, nil
+}
+
+// link returns the link of the attribute name of elt in the document
+// source.
+func (ds *DocumentSet) link(source string, elt *Element, name xml.Name, href string) Link {
+	l := Link{Element: elt, Name: name, Href: href, Source: source}
+	resolved, err := elt.ResolveReference(strings.TrimSpace(href))
+	if err != nil {
+		// reported by BrokenLinks
+		return l
+	}
+	l.URI, l.Fragment, _ = strings.Cut(resolved, "#")
+	return l
+}
+
+// Document returns the document at uri, nil if it is not in the set.
+func (ds *DocumentSet) Document(uri string) *XMLDocument {
+	return ds.docs[uri]
+}
+
+// URIs returns the URIs of the documents in the order they were loaded.
+func (ds *DocumentSet) URIs() []string {
+	return ds.uris
+}
+
+// Links returns the links of all documents in the set.
+func (ds *DocumentSet) Links() []Link {
+	return ds.links
+}
+
+// Resolve returns the document and the element a link points to. The
+// element is the one with the fragment identifier as xml:id or id, or the
+// root element for links without fragment identifier. Resolve loads no
+// documents, links to resources outside of the set return an error.
+func (ds *DocumentSet) Resolve(l Link) (*XMLDocument, *Element, error) {
+	if l.URI == "" {
+		return nil, nil, fmt.Errorf("invalid link %q", l.Href)
+	}
+	doc, ok := ds.docs[l.URI]
+	if !ok {
+		if err := ds.errs[l.URI]; err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%s is not in the document set", l.URI)
+	}
+	if l.Fragment == "" {
+		root, err := doc.Root()
+		return doc, root, err
+	}
+	if elt := findID(doc, l.Fragment); elt != nil {
+		return doc, elt, nil
+	}
+	return nil, nil, fmt.Errorf("%s: no element with ID %q", l.URI, l.Fragment)
+}
+
+// BrokenLinks returns the links of the documents in the set that cannot be
+// resolved: invalid URI references, links to documents that cannot be
+// loaded or that lack the element of the fragment identifier, and links to
+// other resources that the Resolver cannot open. Fragment identifiers of
+// other resources are not checked. FileResolver cannot open http links and
+// the like, so these are reported as well unless the Resolver handles them.
+func (ds *DocumentSet) BrokenLinks() []BrokenLink {
+	var broken []BrokenLink
+	for _, l := range ds.links {
+		if _, ok := ds.docs[l.URI]; ok || l.URI == "" || ds.errs[l.URI] != nil {
+			if _, _, err := ds.Resolve(l); err != nil {
+				broken = append(broken, BrokenLink{Link: l, Err: err})
+			}
+			continue
+		}
+		err, checked := ds.exists[l.URI]
+		if !checked {
+			var r io.ReadCloser
+			if r, err = ds.opts.Resolver.Open(l.URI); err == nil {
+				r.Close()
+			}
+			ds.exists[l.URI] = err
+		}
+		if err != nil {
+			broken = append(broken, BrokenLink{Link: l, Err: err})
+		}
+	}
+	return broken
+}
@@ -0,0 +1,614 @@
+package goxml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DTD contains the declarations of a document type definition, usually the
+// internal subset of the DOCTYPE declaration of a document, see
+// XMLDocument.DTD. Tools can use it to document the elements of a document
+// type, to offer completions or to write skeleton documents. goxml does not
+// validate documents against a DTD.
+type DTD struct {
+	// Name is the name of the root element in a DOCTYPE declaration,
+	// PublicID and SystemID are the identifiers of the external subset.
+	Name     string
+	PublicID string
+	SystemID string
+	// Elements contains the element declarations in the order of the DTD.
+	Elements []*ElementDecl
+	// Attributes contains the attribute declarations of the ATTLIST
+	// declarations by the qualified name of the element, which need not be
+	// declared in Elements.
+	Attributes map[string][]AttributeDecl
+	// Entities contains the general and the parameter entities in the order
+	// of the DTD.
+	Entities []*EntityDecl
+}
+
+// ContentType is the kind of content an element declaration allows.
+type ContentType int
+
+const (
+	// ContentEmpty is declared with EMPTY.
+	ContentEmpty ContentType = iota
+	// ContentAny is declared with ANY.
+	ContentAny
+	// ContentMixed allows text and the elements of a (#PCDATA|...)* group.
+	ContentMixed
+	// ContentChildren allows elements as given by the content model.
+	ContentChildren
+)
+
+// ElementDecl is an ELEMENT declaration.
+type ElementDecl struct {
+	Name string
+	Type ContentType
+	// Model is the content model as written in the declaration, for example
+	// "(title,para+)", and empty for EMPTY and ANY.
+	Model string
+	// Particle is the parsed content model of ContentMixed and
+	// ContentChildren declarations, a group.
+	Particle *Particle
+}
+
+// Particle is a part of a content model, an element name or a group of
+// particles.
+type Particle struct {
+	// Name is the name of the element or #PCDATA in mixed content, it is
+	// empty for a group.
+	Name string
+	// Choice is set for a group of alternatives separated by |, a group
+	// without it is a sequence.
+	Choice   bool
+	Children []*Particle
+	// Occurs is "?", "*", "+" or empty for exactly once.
+	Occurs string
+}
+
+// AttributeDecl is an attribute declared in an ATTLIST declaration.
+type AttributeDecl struct {
+	// Name is the qualified name of the attribute.
+	Name string
+	// Type is the declared type such as CDATA, ID or NMTOKENS, NOTATION or
+	// ENUMERATION for an enumeration of values.
+	Type string
+	// Values contains the allowed values of NOTATION and ENUMERATION
+	// attributes.
+	Values []string
+	// Mode is #REQUIRED, #IMPLIED, #FIXED or empty for an attribute with a
+	// default value.
+	Mode string
+	// Default is the default or fixed value, with character references
+	// expanded.
+	Default string
+}
+
+// EntityDecl is an ENTITY declaration.
+type EntityDecl struct {
+	Name string
+	// Parameter is set for parameter entities declared with %.
+	Parameter bool
+	// Value is the replacement text of an internal entity, in which only
+	// the character references are expanded.
+	Value string
+	// PublicID and SystemID identify an external entity, Notation is the
+	// notation of an unparsed entity.
+	PublicID string
+	SystemID string
+	Notation string
+}
+
+// hasDefault reports whether the attribute has a default or fixed value.
+func (ad AttributeDecl) hasDefault() bool {
+	return ad.Mode == "" || ad.Mode == "#FIXED"
+}
+
+// tokenized reports whether the values of the attribute are normalized like
+// tokens.
+func (ad AttributeDecl) tokenized() bool {
+	return ad.Type != "CDATA"
+}
+
+// Element returns the declaration of the element name, nil if there is none.
+func (d *DTD) Element(name string) *ElementDecl {
+	for _, ed := range d.Elements {
+		if ed.Name == name {
+			return ed
+		}
+	}
+	return nil
+}
+
+// Entity returns the declaration of the general entity name, nil if there
+// is none. The first declaration of an entity is binding.
+func (d *DTD) Entity(name string) *EntityDecl {
+	for _, ent := range d.Entities {
+		if ent.Name == name && !ent.Parameter {
+			return ent
+		}
+	}
+	return nil
+}
+
+// ChildNames returns the names of the elements the content model allows as
+// children, each once in the order of the model.
+func (ed *ElementDecl) ChildNames() []string {
+	var names []string
+	var collect func(p *Particle)
+	collect = func(p *Particle) {
+		if p.Name != "" && p.Name != "#PCDATA" && !containsName(names, p.Name) {
+			names = append(names, p.Name)
+		}
+		for _, c := range p.Children {
+			collect(c)
+		}
+	}
+	if ed.Particle != nil {
+		collect(ed.Particle)
+	}
+	return names
+}
+
+// String returns the particle in DTD syntax.
+func (p *Particle) String() string {
+	if p.Name != "" {
+		return p.Name + p.Occurs
+	}
+	sep := ","
+	if p.Choice {
+		sep = "|"
+	}
+	parts := make([]string, len(p.Children))
+	for i, c := range p.Children {
+		parts[i] = c.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")" + p.Occurs
+}
+
+// DTD returns the declarations in the internal subset of the DOCTYPE
+// declaration of the document, nil if the document has none. The external
+// subset is not read, use ParseDTD for it. Declarations that refer to
+// parameter entities are skipped, as they cannot be resolved without the
+// external subset.
+func (xr *XMLDocument) DTD() *DTD {
+	if xr.doctype == "" {
+		return nil
+	}
+	d, _ := parseDoctype(xr.doctype)
+	return d
+}
+
+// Doctype returns the DOCTYPE declaration of the document as written in the
+// source, such as <!DOCTYPE html>, or an empty string if there is none.
+// Comments in the internal subset are replaced by spaces. The declaration is
+// written with the document.
+func (xr *XMLDocument) Doctype() string {
+	if xr.doctype == "" {
+		return ""
+	}
+	return "<!" + xr.doctype + ">"
+}
+
+// ParseDTD reads the markup declarations of a DTD, for example of an
+// external subset in a .dtd file. Comments and processing instructions are
+// skipped, as are declarations that refer to parameter entities.
+func ParseDTD(s string) (*DTD, error) {
+	d := &DTD{}
+	return d, d.parse(s)
+}
+
+// parseDoctype reads the DOCTYPE directive without the leading <! and the
+// trailing >.
+func parseDoctype(directive string) (*DTD, error) {
+	d := &DTD{}
+	header := strings.TrimPrefix(directive, "DOCTYPE")
+	subset := ""
+	for i := 0; i < len(header); i++ {
+		switch c := header[i]; c {
+		case '"', '\'':
+			if end := strings.IndexByte(header[i+1:], c); end >= 0 {
+				i += end + 1
+			}
+		case '[':
+			subset = header[i+1:]
+			if end := strings.LastIndexByte(subset, ']'); end >= 0 {
+				subset = subset[:end]
+			}
+			header = header[:i]
+		}
+	}
+	tokens, _ := dtdTokens(header)
+	if len(tokens) > 0 {
+		d.Name = tokens[0]
+	}
+	d.PublicID, d.SystemID, _ = externalID(tokens[1:])
+	return d, d.parse(subset)
+}
+
+// externalID reads a SYSTEM or PUBLIC identifier from the tokens and returns
+// the remaining ones.
+func externalID(tokens []string) (publicID, systemID string, rest []string) {
+	switch {
+	case len(tokens) >= 2 && tokens[0] == "SYSTEM":
+		systemID, _ = unquoteDTD(tokens[1])
+		return "", systemID, tokens[2:]
+	case len(tokens) >= 3 && tokens[0] == "PUBLIC":
+		publicID, _ = unquoteDTD(tokens[1])
+		systemID, _ = unquoteDTD(tokens[2])
+		return publicID, systemID, tokens[3:]
+	}
+	return "", "", tokens
+}
+
+// parse reads the markup declarations in s and adds them to d.
+func (d *DTD) parse(s string) error {
+	for {
+		start := strings.IndexByte(s, '<')
+		if start < 0 {
+			return nil
+		}
+		s = s[start:]
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				return errors.New("DTD: unterminated comment")
+			}
+			s = s[end+3:]
+		case strings.HasPrefix(s, "<?"):
+			end := strings.Index(s, "?>")
+			if end < 0 {
+				return errors.New("DTD: unterminated processing instruction")
+			}
+			s = s[end+2:]
+		case strings.HasPrefix(s, "<!ELEMENT"):
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				return errors.New("DTD: unterminated ELEMENT declaration")
+			}
+			decl := s[len("<!ELEMENT"):end]
+			s = s[end+1:]
+			if strings.ContainsRune(decl, '%') {
+				continue
+			}
+			ed, err := parseElementDecl(decl)
+			if err != nil {
+				return err
+			}
+			if d.Element(ed.Name) == nil {
+				d.Elements = append(d.Elements, ed)
+			}
+		case strings.HasPrefix(s, "<!ATTLIST"):
+			tokens, n := dtdTokens(s[len("<!ATTLIST"):])
+			s = s[len("<!ATTLIST")+n:]
+			if len(tokens) == 0 || strings.ContainsRune(strings.Join(tokens, " "), '%') {
+				continue
+			}
+			d.addAttlist(tokens[0], tokens[1:])
+		case strings.HasPrefix(s, "<!ENTITY"):
+			tokens, n := dtdTokens(s[len("<!ENTITY"):])
+			s = s[len("<!ENTITY")+n:]
+			ent := &EntityDecl{}
+			if len(tokens) > 0 && tokens[0] == "%" {
+				ent.Parameter = true
+				tokens = tokens[1:]
+			}
+			if len(tokens) < 2 {
+				continue
+			}
+			ent.Name = tokens[0]
+			if value, ok := unquoteLiteral(tokens[1], false); ok {
+				ent.Value = value
+			} else {
+				var rest []string
+				ent.PublicID, ent.SystemID, rest = externalID(tokens[1:])
+				if len(rest) == 2 && rest[0] == "NDATA" {
+					ent.Notation = rest[1]
+				}
+			}
+			d.Entities = append(d.Entities, ent)
+		default:
+			// other declarations such as NOTATION
+			s = s[1:]
+		}
+	}
+}
+
+// addAttlist adds the attribute definitions of an ATTLIST declaration for
+// element. The first declaration of an attribute is binding.
+func (d *DTD) addAttlist(element string, tokens []string) {
+	for i := 0; i+1 < len(tokens); {
+		ad := AttributeDecl{Name: tokens[i], Type: tokens[i+1]}
+		i += 2
+		if ad.Type == "NOTATION" && i < len(tokens) {
+			// the notation names follow
+			ad.Values = groupNames(tokens[i])
+			i++
+		}
+		if strings.HasPrefix(ad.Type, "(") {
+			ad.Type, ad.Values = "ENUMERATION", groupNames(ad.Type)
+		}
+		if i >= len(tokens) {
+			break
+		}
+		switch tok := tokens[i]; tok {
+		case "#REQUIRED", "#IMPLIED":
+			ad.Mode = tok
+			i++
+		case "#FIXED":
+			ad.Mode = tok
+			i++
+			if i < len(tokens) {
+				ad.Default, _ = unquoteDTD(tokens[i])
+				i++
+			}
+		default:
+			ad.Default, _ = unquoteDTD(tok)
+			i++
+		}
+		if d.Attributes == nil {
+			d.Attributes = make(map[string][]AttributeDecl)
+		}
+		dup := false
+		for _, prev := range d.Attributes[element] {
+			if prev.Name == ad.Name {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			d.Attributes[element] = append(d.Attributes[element], ad)
+		}
+	}
+}
+
+// groupNames returns the names in a group like (a|b|c).
+func groupNames(group string) []string {
+	group = strings.TrimSuffix(strings.TrimPrefix(group, "("), ")")
+	var names []string
+	for _, name := range strings.Split(group, "|") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseElementDecl reads an ELEMENT declaration without <!ELEMENT and >.
+func parseElementDecl(decl string) (*ElementDecl, error) {
+	decl = strings.TrimSpace(decl)
+	end := strings.IndexAny(decl, " \t\r\n(")
+	if end <= 0 {
+		return nil, fmt.Errorf("DTD: invalid ELEMENT declaration %q", decl)
+	}
+	ed := &ElementDecl{Name: decl[:end]}
+	model := strings.Join(strings.Fields(decl[end:]), "")
+	switch model {
+	case "EMPTY":
+		ed.Type = ContentEmpty
+		return ed, nil
+	case "ANY":
+		ed.Type = ContentAny
+		return ed, nil
+	}
+	ed.Model = model
+	ed.Type = ContentChildren
+	if strings.HasPrefix(model, "(#PCDATA") {
+		ed.Type = ContentMixed
+	}
+	p, rest, err := parseParticle(model)
+	if err != nil || rest != "" || p.Name != "" {
+		return nil, fmt.Errorf("DTD: invalid content model %q of element %s", model, ed.Name)
+	}
+	ed.Particle = p
+	return ed, nil
+}
+
+// parseParticle reads a particle at the start of the content model s without
+// white space and returns it with the rest of s.
+func parseParticle(s string) (*Particle, string, error) {
+	p := &Particle{}
+	if strings.HasPrefix(s, "(") {
+		s = s[1:]
+		for {
+			c, rest, err := parseParticle(s)
+			if err != nil {
+				return nil, "", err
+			}
+			p.Children = append(p.Children, c)
+			if rest == "" {
+				return nil, "", errors.New("unterminated group")
+			}
+			sep := rest[0]
+			s = rest[1:]
+			if sep == ')' {
+				break
+			}
+			if sep != ',' && sep != '|' || len(p.Children) > 1 && p.Choice != (sep == '|') {
+				return nil, "", errors.New("invalid separator")
+			}
+			p.Choice = sep == '|'
+		}
+	} else {
+		end := strings.IndexAny(s, ",|)?*+")
+		if end < 0 {
+			end = len(s)
+		}
+		if end == 0 {
+			return nil, "", errors.New("missing name")
+		}
+		p.Name, s = s[:end], s[end:]
+	}
+	if s != "" && strings.IndexByte("?*+", s[0]) >= 0 {
+		p.Occurs, s = s[:1], s[1:]
+	}
+	return p, s, nil
+}
+
+// parseAttlists reads the ATTLIST declarations in the internal subset of a
+// DOCTYPE directive and returns the declared attributes by the qualified
+// name of the element, nil if there are none.
+func parseAttlists(directive string) map[string][]AttributeDecl {
+	d, _ := parseDoctype(directive)
+	return d.Attributes
+}
+
+// dtdTokens splits a declaration up to the closing angle bracket into names,
+// quoted literals and parenthesized groups. It returns the tokens and the
+// number of bytes read.
+func dtdTokens(s string) ([]string, int) {
+	var tokens []string
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '>':
+			return tokens, i + 1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return tokens, len(s)
+			}
+			tokens = append(tokens, s[i:i+end+2])
+			i += end + 2
+		case c == '(':
+			end := strings.IndexByte(s[i:], ')')
+			if end < 0 {
+				return tokens, len(s)
+			}
+			tokens = append(tokens, s[i:i+end+1])
+			i += end + 1
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r>\"'(", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, s[start:i])
+		}
+	}
+	return tokens, i
+}
+
+// unquoteDTD returns the value of a quoted literal with the predefined
+// entities and character references expanded.
+func unquoteDTD(lit string) (string, bool) {
+	return unquoteLiteral(lit, true)
+}
+
+// unquoteLiteral returns the value of a quoted literal with the character
+// references expanded and the predefined entities if predefined is set.
+func unquoteLiteral(lit string, predefined bool) (string, bool) {
+	if len(lit) < 2 || lit[0] != lit[len(lit)-1] || lit[0] != '"' && lit[0] != '\'' {
+		return "", false
+	}
+	s := lit[1 : len(lit)-1]
+	if !strings.ContainsRune(s, '&') {
+		return s, true
+	}
+	var sb strings.Builder
+	for {
+		amp := strings.IndexByte(s, '&')
+		semi := strings.IndexByte(s, ';')
+		if amp < 0 || semi < amp {
+			sb.WriteString(s)
+			return sb.String(), true
+		}
+		sb.WriteString(s[:amp])
+		name := s[amp+1 : semi]
+		switch {
+		case !predefined && !strings.HasPrefix(name, "#"):
+			sb.WriteString(s[amp : semi+1])
+		case name == "amp":
+			sb.WriteByte('&')
+		case name == "lt":
+			sb.WriteByte('<')
+		case name == "gt":
+			sb.WriteByte('>')
+		case name == "apos":
+			sb.WriteByte('\'')
+		case name == "quot":
+			sb.WriteByte('"')
+		default:
+			if r, ok := parseCharReference(name); ok && utf8.ValidRune(r) {
+				sb.WriteRune(r)
+			} else {
+				sb.WriteString(s[amp : semi+1])
+			}
+		}
+		s = s[semi+1:]
+	}
+}
+
+// addDefaultAttributes adds the declared attributes with a default value
+// that are missing on elt. For attributes of a tokenized type, the values are
+// normalized if normalize is set.
+func (p *Parser) addDefaultAttributes(elt *Element, normalize bool) {
+	decls := p.attlists[elt.qualifiedName()]
+	for _, ad := range decls {
+		prefix, local := "", ad.Name
+		if i := strings.IndexByte(ad.Name, ':'); i >= 0 {
+			prefix, local = ad.Name[:i], ad.Name[i+1:]
+		}
+		if prefix == "xmlns" || prefix == "" && local == "xmlns" {
+			continue
+		}
+		var found *Attribute
+		for _, attr := range elt.attributes {
+			if attr.Prefix == prefix && attr.Name == local {
+				found = attr
+				break
+			}
+		}
+		if found != nil {
+			if normalize && ad.tokenized() {
+				found.Value = found.TokenizedValue()
+			}
+			continue
+		}
+		if !ad.hasDefault() {
+			continue
+		}
+		attr := p.nodes.newAttribute()
+		attr.ID = p.doc.NextID()
+		attr.Name = p.names.intern(local)
+		attr.Prefix = p.names.intern(prefix)
+		attr.Value = ad.Default
+		attr.Defaulted = true
+		if ad.tokenized() {
+			attr.Value = attr.TokenizedValue()
+		}
+		if prefix == "xml" {
+			attr.Namespace = xmlNamespace
+		} else if prefix != "" {
+			attr.Namespace, _ = elt.LookupNamespace(prefix)
+		}
+		elt.attributes = append(elt.attributes, attr)
+	}
+}
+
+// defaultNamespaces declares the namespaces of the xmlns attributes with a
+// default value that elt does not declare itself.
+func (p *Parser) defaultNamespaces(elt *Element) {
+	for _, ad := range p.attlists[elt.qualifiedName()] {
+		if !ad.hasDefault() {
+			continue
+		}
+		var prefix string
+		switch {
+		case ad.Name == "xmlns":
+		case strings.HasPrefix(ad.Name, "xmlns:"):
+			prefix = ad.Name[len("xmlns:"):]
+		default:
+			continue
+		}
+		if _, ok := elt.Namespaces[prefix]; !ok {
+			elt.DeclareNamespace(p.names.intern(prefix), p.names.intern(ad.Default))
+		}
+	}
+}
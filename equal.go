@@ -0,0 +1,110 @@
+package goxml
+
+import "sort"
+
+// DeepEqual reports whether a and b are equal documents, elements or other
+// nodes, for example to compare a result with the expected XML in a test.
+// Elements and attributes are compared by their namespace URIs and local
+// names, so the prefixes and the places of the namespace declarations do not
+// matter, and the attributes in any order. Adjacent text nodes are compared
+// as one text, so CDATA sections equal the same text written with
+// references. Comments, processing instructions and white space are
+// compared as they are. The IDs of the nodes and the user data are ignored.
+func DeepEqual(a, b XMLNode) bool {
+	// attributes are returned as *Attribute by Find
+	if attr, ok := a.(*Attribute); ok {
+		a = *attr
+	}
+	if attr, ok := b.(*Attribute); ok {
+		b = *attr
+	}
+	switch t := a.(type) {
+	case *XMLDocument:
+		other, ok := b.(*XMLDocument)
+		return ok && equalChildren(t.children, other.children)
+	case *Element:
+		other, ok := b.(*Element)
+		return ok && equalElements(t, other)
+	case CharData:
+		other, ok := b.(CharData)
+		return ok && t.Contents == other.Contents
+	case Comment:
+		other, ok := b.(Comment)
+		return ok && t.Contents == other.Contents
+	case ProcInst:
+		other, ok := b.(ProcInst)
+		return ok && t.Target == other.Target && string(t.Inst) == string(other.Inst)
+	case EntityRef:
+		other, ok := b.(EntityRef)
+		return ok && t.Name == other.Name && t.Value == other.Value
+	case Attribute:
+		other, ok := b.(Attribute)
+		return ok && t.Namespace == other.Namespace && t.Name == other.Name && t.Value == other.Value
+	}
+	return a == nil && b == nil
+}
+
+func equalElements(a, b *Element) bool {
+	if a.Name != b.Name || len(a.attributes) != len(b.attributes) || a.NamespaceURI() != b.NamespaceURI() {
+		return false
+	}
+	if len(a.attributes) > 0 {
+		attrs := func(elt *Element) []string {
+			s := make([]string, len(elt.attributes))
+			for i, attr := range elt.attributes {
+				s[i] = attr.Namespace + " " + attr.Name + "=" + attr.Value
+			}
+			sort.Strings(s)
+			return s
+		}
+		as, bs := attrs(a), attrs(b)
+		for i := range as {
+			if as[i] != bs[i] {
+				return false
+			}
+		}
+	}
+	return equalChildren(a.children, b.children)
+}
+
+// equalChildren compares the nodes with adjacent text joined.
+func equalChildren(a, b []XMLNode) bool {
+	a, b = joinText(a), joinText(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !DeepEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// joinText returns the nodes with each run of adjacent text nodes replaced
+// by a single one. nodes is returned unchanged if there is no such run.
+func joinText(nodes []XMLNode) []XMLNode {
+	adjacent := false
+	for i := 1; i < len(nodes); i++ {
+		_, prev := nodes[i-1].(CharData)
+		_, cur := nodes[i].(CharData)
+		if prev && cur {
+			adjacent = true
+			break
+		}
+	}
+	if !adjacent {
+		return nodes
+	}
+	joined := make([]XMLNode, 0, len(nodes))
+	for _, n := range nodes {
+		if cd, ok := n.(CharData); ok && len(joined) > 0 {
+			if prev, ok := joined[len(joined)-1].(CharData); ok {
+				joined[len(joined)-1] = CharData{Contents: prev.Contents + cd.Contents}
+				continue
+			}
+		}
+		joined = append(joined, n)
+	}
+	return joined
+}
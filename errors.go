@@ -0,0 +1,80 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrLimitExceeded is the underlying error of a ParseError when the input
+// exceeds a limit set with WithMaxDepth or WithMaxSize.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// ParseError describes a problem found while parsing a document. The
+// underlying error, usually an *xml.SyntaxError, is available through
+// errors.As and errors.Unwrap.
+type ParseError struct {
+	// Source is the name of the input given with WithSourceName or the file
+	// name. It is empty if unknown.
+	Source string
+	Line   int
+	Column int
+	// Offset is the byte offset in the input.
+	Offset int64
+	// Element is the name of the innermost open element, empty on the
+	// document level.
+	Element string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+	if e.Source != "" {
+		sb.WriteString(e.Source)
+		sb.WriteString(":")
+	}
+	fmt.Fprintf(&sb, "%d:%d: ", e.Line, e.Column)
+	if se, ok := e.Err.(*xml.SyntaxError); ok {
+		sb.WriteString(se.Msg)
+	} else {
+		sb.WriteString(e.Err.Error())
+	}
+	if e.Element != "" {
+		fmt.Fprintf(&sb, " (in element <%s>)", e.Element)
+	}
+	return sb.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError wraps err with the current position of dec.
+func (p *Parser) newParseError(dec *xml.Decoder, cur XMLNode, err error) *ParseError {
+	pe := &ParseError{
+		Source: p.opts.sourceName,
+		Offset: p.sourceOffset(dec.InputOffset()),
+		Err:    err,
+	}
+	pe.Line, pe.Column = dec.InputPos()
+	if se, ok := err.(*xml.SyntaxError); ok {
+		// the line of the syntax error is more precise when the decoder
+		// has read ahead
+		pe.Line = se.Line
+	}
+	if ie, ok := err.(*inputError); ok {
+		pe.Line, pe.Column, pe.Offset = ie.line, ie.column, ie.offset
+	}
+	if elt, ok := cur.(*Element); ok {
+		pe.Element = elt.qualifiedName()
+	}
+	return pe
+}
+
+// Errors returns the problems found while parsing the document with
+// WithCollectErrors and the repairs made with WithRepair.
+func (xr *XMLDocument) Errors() []*ParseError {
+	return xr.errors
+}
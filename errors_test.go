@@ -0,0 +1,75 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in      string
+		line    int
+		element string
+		msg     string
+	}{
+		{`<a><b></a>`, 1, "b", "element <b> closed by </a>"},
+		{`<a></a></b>`, 1, "", "unexpected end element </b>"},
+		{`<a>`, 1, "a", "unexpected EOF"},
+		{`<a x="1" x="2"/>`, 1, "", "duplicate attribute x"},
+		{"<a>\n<b>&foo;</b></a>", 2, "b", "invalid character entity &foo;"},
+		{`<a xmlns:p=""/>`, 1, "", "the prefix p must not be undeclared"},
+		{`<a xmlns:xml="x"/>`, 1, "", "the prefix xml must not be bound to x"},
+	}
+	for _, tc := range tests {
+		_, err := Parse(strings.NewReader(tc.in))
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Parse(%q) = %v, want a *ParseError", tc.in, err)
+			continue
+		}
+		if pe.Line != tc.line || pe.Element != tc.element || !strings.Contains(pe.Error(), tc.msg) {
+			t.Errorf("Parse(%q) = %v (line %d, element %q), want %q in line %d, element %q", tc.in, pe, pe.Line, pe.Element, tc.msg, tc.line, tc.element)
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		in     string
+		opts   []ParseOption
+		offset int64
+	}{
+		{"<a></b>", nil, 7},
+		// the offset counts the bytes the input filter has removed
+		{"<a>\x01\x01\x01</b>", []ParseOption{WithIllegalChars(CharStrip)}, 10},
+		{"<a>\x01</b>", []ParseOption{WithIllegalChars(CharReplace)}, 8},
+	}
+	for _, tc := range tests {
+		_, err := Parse(strings.NewReader(tc.in), tc.opts...)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Parse(%q) = %v, want a *ParseError", tc.in, err)
+			continue
+		}
+		if pe.Offset != tc.offset {
+			t.Errorf("Parse(%q) reports offset %d, want %d", tc.in, pe.Offset, tc.offset)
+		}
+	}
+}
+
+func TestParseErrorUnwrap(t *testing.T) {
+	_, err := Parse(strings.NewReader("<a><b/></a>"), WithMaxDepth(1), WithSourceName("in.xml"))
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Parse with WithMaxDepth(1) = %v, want ErrLimitExceeded", err)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "in.xml:1:") {
+		t.Errorf("error %v does not start with the source name and line", err)
+	}
+	_, err = Parse(strings.NewReader("<a>"))
+	var se *xml.SyntaxError
+	if !errors.As(err, &se) {
+		t.Errorf("Parse(%q) = %v, want an underlying *xml.SyntaxError", "<a>", err)
+	}
+}
@@ -0,0 +1,50 @@
+package goxml
+
+import "encoding/xml"
+
+// ExtractOptions controls ExtractDocument.
+type ExtractOptions struct {
+	// KeepBase sets xml:base on the new root element to the base URI of the
+	// element, so that relative references resolve to the same resources
+	// as before.
+	KeepBase bool
+	// KeepLang sets xml:lang on the new root element to the language the
+	// element inherits from its ancestors, see Lang.
+	KeepLang bool
+}
+
+// ExtractDocument returns a new document with a deep copy of elt as its root
+// element, for example to split a large document into files of its own. The
+// namespace bindings the element inherits from its ancestors are declared on
+// the new root element, so that all prefixes keep their meaning. The nodes
+// of the new document are numbered anew, its Source is the one of elt. elt
+// is not changed.
+func (elt *Element) ExtractDocument(opts ExtractOptions) *XMLDocument {
+	cp := copyElement(elt)
+	inherited := elt.inheritedNamespaces()
+	rescope(cp, inherited, nil)
+	changed := len(inherited) > 0
+	if opts.KeepBase {
+		if base, err := elt.BaseURI(); err == nil && base != "" {
+			cp.SetAttribute(xmlBaseAttr(base))
+			changed = true
+		}
+	}
+	if opts.KeepLang {
+		if _, own := elt.ownLang(); !own {
+			if lang := elt.Lang(); lang != "" {
+				cp.SetAttribute(xml.Attr{Name: xml.Name{Space: xmlNamespace, Local: "lang"}, Value: lang})
+				changed = true
+			}
+		}
+	}
+	if changed {
+		// the start tag is not written like the source
+		cp.spanEnd = 0
+	}
+	doc := NewDocument()
+	doc.source = elt.Source()
+	doc.Append(cp)
+	doc.renumber()
+	return doc
+}
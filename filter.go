@@ -0,0 +1,86 @@
+package goxml
+
+import "strings"
+
+// pathFilter decides which elements Parse builds when WithKeepOnly is given.
+// A nil filter keeps everything.
+type pathFilter struct {
+	paths [][]string
+	// path is the path of the current element outside of a kept subtree
+	path []string
+	// depth is the nesting level inside a kept subtree
+	depth int
+}
+
+func newPathFilter(paths []string) *pathFilter {
+	if len(paths) == 0 {
+		return nil
+	}
+	pf := &pathFilter{}
+	for _, p := range paths {
+		pf.paths = append(pf.paths, strings.Split(strings.Trim(p, "/"), "/"))
+	}
+	return pf
+}
+
+// startElement reports whether the element with the local name name should be
+// built. If not, the caller must skip the element including its end tag.
+func (pf *pathFilter) startElement(name string) bool {
+	if pf == nil {
+		return true
+	}
+	if pf.depth > 0 {
+		pf.depth++
+		return true
+	}
+	pf.path = append(pf.path, name)
+	keep := false
+	for _, p := range pf.paths {
+		if !pathPrefix(pf.path, p) {
+			continue
+		}
+		if len(p) == len(pf.path) {
+			pf.depth = 1
+			return true
+		}
+		// ancestor of a kept element
+		keep = true
+	}
+	if !keep {
+		pf.path = pf.path[:len(pf.path)-1]
+	}
+	return keep
+}
+
+func (pf *pathFilter) endElement() {
+	if pf == nil {
+		return
+	}
+	if pf.depth > 1 {
+		pf.depth--
+		return
+	}
+	pf.depth = 0
+	pf.path = pf.path[:len(pf.path)-1]
+}
+
+// keepContent reports whether text, comments and processing instructions at
+// the current position should be built. Only the kept subtrees and the
+// document level have content, the ancestors of kept elements are empty.
+func (pf *pathFilter) keepContent() bool {
+	return pf == nil || pf.depth > 0 || len(pf.path) == 0
+}
+
+// pathPrefix reports whether path is a prefix of pattern. A * in the pattern
+// matches any name.
+func pathPrefix(path, pattern []string) bool {
+	if len(path) > len(pattern) {
+		return false
+	}
+	for i, name := range path {
+		if pattern[i] != "*" && pattern[i] != name {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package goxml
+
+// Concurrent use of documents
+//
+// The methods that only read a document, such as Children, Attributes,
+// LookupNamespace, Lang and Path.Select, can be called from several
+// goroutines at the same time. Stringvalue and serialization with
+// SerializeOptions.Cache fill caches in the elements, so they must not run
+// concurrently with each other or with other readers, unless the document
+// is frozen. Changes to a document are never safe concurrently with any
+// other use.
+
+// Freeze makes the document read-only. Afterwards, Append, SetAttribute,
+// DeclareNamespace, XInclude and the other methods that change the document
+// or its elements panic, also when called on an element of the document, and
+// elements of the document cannot be appended elsewhere. Stringvalue and
+// serialization only use the caches filled before Freeze and do not change
+// the elements, so all reading methods are safe for concurrent use. Changes
+// to the exported fields of the nodes are not prevented. User data set with
+// SetUserData is not covered, it must not be changed while other goroutines
+// read it, Seal takes care of that as well. A frozen document cannot be
+// unfrozen, but its Snapshot can be changed.
+func (xr *XMLDocument) Freeze() {
+	xr.frozen = true
+	freezeChildren(xr.children)
+}
+
+func freezeChildren(children []XMLNode) {
+	for _, c := range children {
+		if elt, ok := c.(*Element); ok {
+			elt.frozen = true
+			freezeChildren(elt.children)
+		}
+	}
+}
+
+// Frozen reports whether Freeze has been called for the document.
+func (xr *XMLDocument) Frozen() bool {
+	return xr.frozen
+}
+
+// errFrozen is the panic value of a change to a frozen document.
+const errFrozen = "goxml: change of a frozen document"
+
+func (xr *XMLDocument) checkMutable() {
+	if xr.frozen {
+		panic(errFrozen)
+	}
+}
+
+func (elt *Element) checkMutable() {
+	if elt.frozen {
+		panic(errFrozen)
+	}
+}
@@ -0,0 +1,104 @@
+package goxml
+
+import (
+	"hash"
+	"io"
+	"sort"
+)
+
+// HashOptions select the differences that change the hash of a subtree. The
+// namespace prefixes and the order of the attributes never change it, the
+// namespace URIs do.
+type HashOptions struct {
+	// IgnoreComments and IgnoreProcInsts leave out comments and processing
+	// instructions, the text around them is hashed as one text.
+	IgnoreComments  bool
+	IgnoreProcInsts bool
+	// IgnoreWhitespace leaves out the text that consists of white space
+	// only, such as indentation between elements.
+	IgnoreWhitespace bool
+}
+
+// Hash writes a digest of the subtree of the element to h, for example a
+// sha256.New() hash, so that equal subtrees give the same hash regardless of
+// their namespace prefixes and attribute order. Repeated fragments can be
+// found by the hashes of their elements and unchanged sections of imported
+// documents by comparing the hashes with the ones of the previous import.
+// The digest is stable across program runs and versions of the package.
+func (elt *Element) Hash(h hash.Hash, opts HashOptions) {
+	writeHash(h, elt, opts)
+}
+
+// Hash writes a digest of the document to h, see Element.Hash.
+func (xr *XMLDocument) Hash(h hash.Hash, opts HashOptions) {
+	writeHashChildren(h, xr.children, opts)
+}
+
+// writeHashPart writes a part of the digest: its kind and the strings, each
+// followed by a zero byte.
+func writeHashPart(w io.Writer, kind byte, strs ...string) {
+	w.Write([]byte{kind})
+	for _, s := range strs {
+		io.WriteString(w, s)
+		w.Write([]byte{0})
+	}
+}
+
+func writeHash(w io.Writer, n XMLNode, opts HashOptions) {
+	switch t := n.(type) {
+	case *Element:
+		writeHashPart(w, 'e', t.NamespaceURI(), t.Name)
+		attrs := make([]string, 0, len(t.attributes))
+		for _, attr := range t.attributes {
+			attrs = append(attrs, attr.Namespace+" "+attr.Name+"="+attr.Value)
+		}
+		sort.Strings(attrs)
+		writeHashPart(w, 'a', attrs...)
+		writeHashChildren(w, t.children, opts)
+		writeHashPart(w, '/')
+	case CharData:
+		if !opts.IgnoreWhitespace || !isSpace(t.Contents) {
+			writeHashPart(w, 't', t.Contents)
+		}
+	case EntityRef:
+		writeHashPart(w, 'r', t.Name)
+	case Comment:
+		if !opts.IgnoreComments {
+			writeHashPart(w, 'c', t.Contents)
+		}
+	case ProcInst:
+		if !opts.IgnoreProcInsts {
+			writeHashPart(w, 'p', t.Target, string(t.Inst))
+		}
+	}
+}
+
+// writeHashChildren writes the digest of the nodes. Adjacent text is joined,
+// so that the text around an ignored comment gives the same digest as the
+// text without the comment.
+func writeHashChildren(w io.Writer, nodes []XMLNode, opts HashOptions) {
+	text := ""
+	for _, c := range nodes {
+		switch t := c.(type) {
+		case CharData:
+			text += t.Contents
+			continue
+		case Comment:
+			if opts.IgnoreComments {
+				continue
+			}
+		case ProcInst:
+			if opts.IgnoreProcInsts {
+				continue
+			}
+		}
+		if text != "" {
+			writeHash(w, CharData{Contents: text}, opts)
+			text = ""
+		}
+		writeHash(w, c, opts)
+	}
+	if text != "" {
+		writeHash(w, CharData{Contents: text}, opts)
+	}
+}
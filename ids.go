@@ -0,0 +1,119 @@
+package goxml
+
+// renumber assigns new IDs in document order to all nodes of the document.
+// The user data would belong to other nodes afterwards, so it is removed.
+func (xr *XMLDocument) renumber() {
+	xr.lastID = 0
+	xr.userData = nil
+	renumberChildren(xr, xr.children, nil)
+}
+
+// reorder assigns new IDs in document order to all nodes of the document
+// after nodes have been inserted, and moves the user data to the new IDs.
+func (xr *XMLDocument) reorder() {
+	data := xr.userData
+	xr.lastID = 0
+	xr.userData = nil
+	renumberChildren(xr, xr.children, func(old, id int64) {
+		if d, ok := data[old]; ok && old != 0 {
+			if xr.userData == nil {
+				xr.userData = make(map[int64]map[string]any, len(data))
+			}
+			xr.userData[id] = d
+		}
+	})
+}
+
+// renumberChildren numbers the children and their descendants and calls
+// moved, if it is not nil, with the old and the new ID of each node.
+func renumberChildren(xr *XMLDocument, children []XMLNode, moved func(old, id int64)) {
+	next := func(old int64) int64 {
+		id := xr.NextID()
+		if moved != nil {
+			moved(old, id)
+		}
+		return id
+	}
+	for i, c := range children {
+		switch t := c.(type) {
+		case *Element:
+			t.ID = next(t.ID)
+			for _, attr := range t.attributes {
+				attr.ID = next(attr.ID)
+			}
+			renumberChildren(xr, t.children, moved)
+		case CharData:
+			t.ID = next(t.ID)
+			children[i] = t
+		case Comment:
+			t.ID = next(t.ID)
+			children[i] = t
+		case ProcInst:
+			t.ID = next(t.ID)
+			children[i] = t
+		case EntityRef:
+			t.ID = next(t.ID)
+			children[i] = t
+		}
+	}
+}
+
+// renumberFrom assigns new IDs to the children of parent from index on,
+// their descendants and all nodes after parent in document order, after
+// nodes have been inserted or moved there. The nodes before keep their IDs
+// and the others get IDs above all IDs of the document, so the IDs stay in
+// document order and a change near the end of a document is cheap. The user
+// data moves to the new IDs. If the IDs of the document would run out, all
+// nodes are numbered anew.
+func (xr *XMLDocument) renumberFrom(parent XMLNode, index int) {
+	var parts [][]XMLNode
+	parts = append(parts, parent.Children()[index:])
+	for cur := parent; ; {
+		elt, ok := cur.(*Element)
+		if !ok || elt.Parent == nil {
+			break
+		}
+		cur = elt.Parent
+		parts = append(parts, cur.Children()[childIndex(cur, elt)+1:])
+	}
+	count := int64(0)
+	for _, part := range parts {
+		count += countNodes(part)
+	}
+	if xr.lastID+count > maxNodeNumber {
+		xr.reorder()
+		return
+	}
+	var moved [][2]int64
+	for _, part := range parts {
+		renumberChildren(xr, part, func(old, id int64) {
+			if _, ok := xr.userData[old]; ok && old != 0 {
+				moved = append(moved, [2]int64{old, id})
+			}
+		})
+	}
+	// the data is looked up before the old IDs are removed, copies of a
+	// value node share the ID
+	data := make([]map[string]any, len(moved))
+	for i, m := range moved {
+		data[i] = xr.userData[m[0]]
+	}
+	for _, m := range moved {
+		delete(xr.userData, m[0])
+	}
+	for i, m := range moved {
+		xr.userData[m[1]] = data[i]
+	}
+}
+
+// countNodes returns the number of nodes, including the attributes, in the
+// subtrees of nodes.
+func countNodes(nodes []XMLNode) int64 {
+	count := int64(len(nodes))
+	for _, n := range nodes {
+		if elt, ok := n.(*Element); ok {
+			count += int64(len(elt.attributes)) + countNodes(elt.children)
+		}
+	}
+	return count
+}
@@ -0,0 +1,72 @@
+package goxml
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkIDs reports nodes of the document whose IDs are not increasing in
+// document order.
+func checkIDs(t *testing.T, doc *XMLDocument) {
+	t.Helper()
+	var last int64
+	var check func(n XMLNode)
+	check = func(n XMLNode) {
+		for _, c := range n.Children() {
+			if c.getID() <= last {
+				t.Errorf("ID %d of %v is not greater than %d", c.getID(), c, last)
+			}
+			last = c.getID()
+			if elt, ok := c.(*Element); ok {
+				for _, attr := range elt.attributes {
+					if attr.ID <= last {
+						t.Errorf("ID %d of attribute %v is not greater than %d", attr.ID, attr, last)
+					}
+					last = attr.ID
+				}
+				check(elt)
+			}
+		}
+	}
+	check(doc)
+}
+
+func TestParseIDsInDocumentOrder(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<a x="1"><b y="2">t<!--c--></b><?pi?><c/></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, doc)
+}
+
+func TestDocumentIDs(t *testing.T) {
+	first, second := NewDocument(), NewDocument()
+	if first.ID == second.ID || first.ID&maxNodeNumber != 0 {
+		t.Fatalf("document IDs %#x and %#x", first.ID, second.ID)
+	}
+	a, b := first.NextID(), second.NextID()
+	if a>>32 != first.ID>>32 || b>>32 != second.ID>>32 {
+		t.Errorf("node IDs %#x and %#x are not in the ranges of their documents", a, b)
+	}
+	if next := first.NextID(); next != a+1 {
+		t.Errorf("NextID() = %#x after %#x", next, a)
+	}
+	// nodes of older documents sort first
+	if first.ID < second.ID && a >= b {
+		t.Errorf("ID %#x of the first document is not less than %#x", a, b)
+	}
+}
+
+func TestNextIDExhausted(t *testing.T) {
+	doc := NewDocument()
+	doc.lastID = maxNodeNumber - 1
+	if id := doc.NextID(); id != doc.ID+maxNodeNumber {
+		t.Errorf("last NextID() = %#x, want %#x", id, doc.ID+maxNodeNumber)
+	}
+	defer func() {
+		if r := recover(); r != errIDsExhausted {
+			t.Errorf("NextID() panics with %v, want %v", r, errIDsExhausted)
+		}
+	}()
+	doc.NextID()
+}
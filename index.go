@@ -0,0 +1,352 @@
+package goxml
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Index records the byte ranges of the elements of a document, so that
+// later runs can parse only the subtrees they need with ParseIndexed instead
+// of the whole document. Build it with BuildIndex in one pass over the
+// document, store it with WriteTo and load it with ReadIndex.
+type Index struct {
+	// Size is the length of the indexed document in bytes. An index is
+	// only valid for the unchanged document, comparing Size with the file
+	// size catches most changes.
+	Size    int64
+	Entries []IndexEntry
+	// scopes are the namespace bindings referenced by the entries
+	scopes []map[string]string
+	ids    map[string]int
+}
+
+// IndexEntry is an element in an Index.
+type IndexEntry struct {
+	// Path is the list of local names from the root element to the element
+	// separated by slashes, such as /catalog/book.
+	Path string
+	// ID is the value of the id or xml:id attribute of the element.
+	ID string
+	// Offset is the position of the start tag in the document and Length
+	// the number of bytes up to and including the end tag.
+	Offset int64
+	Length int64
+	// Line is the line of the start tag.
+	Line int
+	// scope is the index of the namespace bindings inherited from the
+	// ancestors in Index.scopes
+	scope int
+}
+
+// IndexOptions controls BuildIndex.
+type IndexOptions struct {
+	// MaxDepth limits the index to the elements up to this nesting level,
+	// the root element has level 1. Zero indexes all elements. Elements with
+	// an id or xml:id attribute are always indexed.
+	MaxDepth int
+}
+
+// BuildIndex reads the document from r and returns the byte ranges of its
+// elements. No tree is built, so documents of any size can be indexed. The
+// document must not use entities other than the predefined ones.
+func BuildIndex(r io.Reader, opts IndexOptions) (*Index, error) {
+	ix := &Index{ids: make(map[string]int)}
+	type scope struct {
+		bindings map[string]string
+		// index is the index of the bindings in ix.scopes, -1 if they have
+		// not been added yet
+		index int
+	}
+	type open struct {
+		entry int
+		path  string
+		scope *scope
+	}
+	stack := []open{{entry: -1, scope: &scope{bindings: map[string]string{}, index: -1}}}
+	dec := xml.NewDecoder(r)
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch v := tok.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			cur := open{entry: -1, path: parent.path + "/" + v.Name.Local, scope: parent.scope}
+			var id string
+			for _, attr := range v.Attr {
+				switch {
+				case attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					if cur.scope == parent.scope {
+						cur.scope = &scope{bindings: make(map[string]string, len(parent.scope.bindings)+1), index: -1}
+						for k, v := range parent.scope.bindings {
+							cur.scope.bindings[k] = v
+						}
+					}
+					if attr.Name.Space == "" {
+						cur.scope.bindings[""] = attr.Value
+					} else {
+						cur.scope.bindings[attr.Name.Local] = attr.Value
+					}
+				case attr.Name.Local == "id" && (attr.Name.Space == "" || attr.Name.Space == "xml"):
+					id = attr.Value
+				}
+			}
+			if opts.MaxDepth <= 0 || len(stack) <= opts.MaxDepth || id != "" {
+				if parent.scope.index < 0 {
+					parent.scope.index = len(ix.scopes)
+					ix.scopes = append(ix.scopes, parent.scope.bindings)
+				}
+				line, _ := dec.InputPos()
+				cur.entry = len(ix.Entries)
+				ix.Entries = append(ix.Entries, IndexEntry{
+					Path:   cur.path,
+					ID:     id,
+					Offset: offset,
+					Line:   line,
+					scope:  parent.scope.index,
+				})
+				if _, dup := ix.ids[id]; id != "" && !dup {
+					ix.ids[id] = cur.entry
+				}
+			}
+			stack = append(stack, cur)
+		case xml.EndElement:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected end element </%s>", v.Name.Local)
+			}
+			if e := stack[len(stack)-1].entry; e >= 0 {
+				ix.Entries[e].Length = dec.InputOffset() - ix.Entries[e].Offset
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 1 {
+		return nil, errors.New("unexpected EOF")
+	}
+	ix.Size = dec.InputOffset()
+	return ix, nil
+}
+
+// Lookup returns the entries whose path matches pattern, in document order.
+// The pattern is a path of local names like /catalog/book, where * matches
+// any name.
+func (ix *Index) Lookup(pattern string) []IndexEntry {
+	steps := strings.Split(strings.Trim(pattern, "/"), "/")
+	var found []IndexEntry
+	for _, e := range ix.Entries {
+		path := strings.Split(e.Path[1:], "/")
+		if len(path) == len(steps) && pathPrefix(path, steps) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// LookupID returns the first entry with the id or xml:id attribute id.
+func (ix *Index) LookupID(id string) (IndexEntry, bool) {
+	i, ok := ix.ids[id]
+	if !ok {
+		return IndexEntry{}, false
+	}
+	return ix.Entries[i], true
+}
+
+// Namespaces returns the namespace bindings the element of the entry
+// inherits from its ancestors.
+func (ix *Index) Namespaces(e IndexEntry) map[string]string {
+	return ix.scopes[e.scope]
+}
+
+// ParseIndexed parses the element of the index entry from r, which must
+// contain the indexed document. The result is a document with the element as
+// its root element. The namespace bindings inherited from the ancestors are
+// declared on the root element. Line numbers and offsets in the result and in
+// errors count from the start tag of the element.
+func (ix *Index) ParseIndexed(r io.ReaderAt, e IndexEntry, opts ...ParseOption) (*XMLDocument, error) {
+	opts = append(opts, withInheritedNamespaces(ix.scopes[e.scope]))
+	return Parse(io.NewSectionReader(r, e.Offset, e.Length), opts...)
+}
+
+// withInheritedNamespaces declares the bindings on the root element unless
+// it declares the prefixes itself.
+func withInheritedNamespaces(bindings map[string]string) ParseOption {
+	return func(po *parseOptions) {
+		po.inherited = bindings
+	}
+}
+
+// indexMagic starts the stored form of an index.
+const indexMagic = "goxml index 1\n"
+
+// WriteTo writes the index to w in a compact binary form that ReadIndex
+// reads.
+func (ix *Index) WriteTo(w io.Writer) (int64, error) {
+	iw := indexWriter{w: bufio.NewWriter(w)}
+	iw.writeString(indexMagic)
+	iw.writeInt(ix.Size)
+	iw.writeInt(int64(len(ix.scopes)))
+	for _, scope := range ix.scopes {
+		prefixes := make([]string, 0, len(scope))
+		for prefix := range scope {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Strings(prefixes)
+		iw.writeInt(int64(len(prefixes)))
+		for _, prefix := range prefixes {
+			iw.writeString(prefix)
+			iw.writeString(scope[prefix])
+		}
+	}
+	iw.writeInt(int64(len(ix.Entries)))
+	// paths repeat a lot, so each one is written once
+	paths := make(map[string]int64)
+	for _, e := range ix.Entries {
+		if n, ok := paths[e.Path]; ok {
+			iw.writeInt(n)
+		} else {
+			paths[e.Path] = int64(len(paths)) + 1
+			iw.writeInt(0)
+			iw.writeString(e.Path)
+		}
+		iw.writeString(e.ID)
+		iw.writeInt(e.Offset)
+		iw.writeInt(e.Length)
+		iw.writeInt(int64(e.Line))
+		iw.writeInt(int64(e.scope))
+	}
+	if iw.err == nil {
+		iw.err = iw.w.Flush()
+	}
+	return iw.n, iw.err
+}
+
+type indexWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (iw *indexWriter) writeInt(i int64) {
+	if iw.err != nil {
+		return
+	}
+	var n int
+	n, iw.err = iw.w.Write(iw.buf[:binary.PutVarint(iw.buf[:], i)])
+	iw.n += int64(n)
+}
+
+func (iw *indexWriter) writeString(s string) {
+	iw.writeInt(int64(len(s)))
+	if iw.err != nil {
+		return
+	}
+	var n int
+	n, iw.err = iw.w.WriteString(s)
+	iw.n += int64(n)
+}
+
+// ReadIndex reads an index written by Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	ir := indexReader{r: bufio.NewReader(r)}
+	if ir.readString() != indexMagic {
+		return nil, errors.New("not a goxml index")
+	}
+	ix := &Index{Size: ir.readInt(), ids: make(map[string]int)}
+	nScopes := ir.readCount()
+	for i := 0; i < nScopes && ir.err == nil; i++ {
+		n := ir.readCount()
+		scope := make(map[string]string)
+		for j := 0; j < n && ir.err == nil; j++ {
+			prefix := ir.readString()
+			scope[prefix] = ir.readString()
+		}
+		ix.scopes = append(ix.scopes, scope)
+	}
+	nEntries := ir.readCount()
+	var paths []string
+	for i := 0; i < nEntries && ir.err == nil; i++ {
+		var e IndexEntry
+		switch n := ir.readInt(); {
+		case n == 0:
+			e.Path = ir.readString()
+			paths = append(paths, e.Path)
+		case n <= int64(len(paths)):
+			e.Path = paths[n-1]
+		default:
+			ir.fail()
+		}
+		e.ID = ir.readString()
+		e.Offset = ir.readInt()
+		e.Length = ir.readInt()
+		e.Line = int(ir.readInt())
+		e.scope = int(ir.readInt())
+		if e.scope < 0 || e.scope >= len(ix.scopes) {
+			ir.fail()
+		}
+		if _, dup := ix.ids[e.ID]; e.ID != "" && !dup {
+			ix.ids[e.ID] = len(ix.Entries)
+		}
+		ix.Entries = append(ix.Entries, e)
+	}
+	if ir.err != nil {
+		return nil, ir.err
+	}
+	return ix, nil
+}
+
+type indexReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (ir *indexReader) fail() {
+	if ir.err == nil {
+		ir.err = errors.New("corrupt goxml index")
+	}
+}
+
+func (ir *indexReader) readInt() int64 {
+	if ir.err != nil {
+		return 0
+	}
+	i, err := binary.ReadVarint(ir.r)
+	if err != nil {
+		ir.fail()
+	}
+	return i
+}
+
+// readCount reads a number of items. It is not used to allocate memory in
+// advance, so a corrupt index cannot request huge amounts.
+func (ir *indexReader) readCount() int {
+	n := ir.readInt()
+	if n < 0 || n > 1<<40 {
+		ir.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (ir *indexReader) readString() string {
+	n := ir.readCount()
+	if ir.err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	if _, err := io.CopyN(&sb, ir.r, int64(n)); err != nil {
+		ir.fail()
+	}
+	return sb.String()
+}
@@ -0,0 +1,116 @@
+package goxml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const indexTestDoc = `<catalog xmlns="urn:c" xmlns:x="urn:x">
+<book id="b1"><title>One</title></book>
+<shelf><book xml:id="b2"><x:title>Two</x:title></book></shelf>
+<book><title>Three &amp; more</title></book>
+</catalog>`
+
+func TestIndex(t *testing.T) {
+	ix, err := BuildIndex(strings.NewReader(indexTestDoc), IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Size != int64(len(indexTestDoc)) {
+		t.Errorf("Size = %d, want %d", ix.Size, len(indexTestDoc))
+	}
+	books := ix.Lookup("/catalog/book")
+	if len(books) != 2 {
+		t.Fatalf("Lookup(/catalog/book) returns %d entries, want 2", len(books))
+	}
+	if got := indexTestDoc[books[1].Offset : books[1].Offset+books[1].Length]; got != `<book><title>Three &amp; more</title></book>` {
+		t.Errorf("byte range of the second book: %q", got)
+	}
+	if books[1].Line != 4 {
+		t.Errorf("line of the second book = %d, want 4", books[1].Line)
+	}
+	if got := len(ix.Lookup("/*/*/book")); got != 1 {
+		t.Errorf("Lookup(/*/*/book) returns %d entries, want 1", got)
+	}
+	for _, id := range []string{"b1", "b2"} {
+		if _, ok := ix.LookupID(id); !ok {
+			t.Errorf("LookupID(%q) finds nothing", id)
+		}
+	}
+	if _, ok := ix.LookupID("b3"); ok {
+		t.Error("LookupID(b3) finds an entry")
+	}
+
+	e, _ := ix.LookupID("b2")
+	doc, err := ix.ParseIndexed(strings.NewReader(indexTestDoc), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := doc.Root()
+	if root.Name != "book" || root.NamespaceURI() != "urn:c" {
+		t.Errorf("root element {%s}%s, want {urn:c}book", root.NamespaceURI(), root.Name)
+	}
+	if title := root.FirstChildElement(); title.NamespaceURI() != "urn:x" {
+		t.Errorf("namespace of the title = %q, want urn:x", title.NamespaceURI())
+	}
+	if ns := ix.Namespaces(e); ns["x"] != "urn:x" || ns[""] != "urn:c" {
+		t.Errorf("Namespaces = %v", ns)
+	}
+}
+
+func TestIndexMaxDepth(t *testing.T) {
+	ix, err := BuildIndex(strings.NewReader(indexTestDoc), IndexOptions{MaxDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(ix.Lookup("/catalog/book/title")); got != 0 {
+		t.Errorf("%d title entries below MaxDepth, want 0", got)
+	}
+	// elements with an ID are indexed at any depth
+	if _, ok := ix.LookupID("b2"); !ok {
+		t.Error("the element with xml:id below MaxDepth is not indexed")
+	}
+}
+
+func TestIndexWriteRead(t *testing.T) {
+	ix, err := BuildIndex(strings.NewReader(indexTestDoc), IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = ix.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	stored := buf.Bytes()
+	ix2, err := ReadIndex(bytes.NewReader(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix2.Size != ix.Size || len(ix2.Entries) != len(ix.Entries) {
+		t.Fatalf("read index has size %d and %d entries, want %d and %d", ix2.Size, len(ix2.Entries), ix.Size, len(ix.Entries))
+	}
+	for i, e := range ix.Entries {
+		if ix2.Entries[i] != e {
+			t.Errorf("entry %d: %+v, want %+v", i, ix2.Entries[i], e)
+		}
+	}
+	e, ok := ix2.LookupID("b2")
+	if !ok {
+		t.Fatal("LookupID(b2) on the read index finds nothing")
+	}
+	if ns := ix2.Namespaces(e); ns["x"] != "urn:x" {
+		t.Errorf("Namespaces on the read index = %v", ns)
+	}
+	for _, bad := range [][]byte{nil, []byte("no index"), stored[:len(stored)/2]} {
+		if _, err := ReadIndex(bytes.NewReader(bad)); err == nil {
+			t.Errorf("ReadIndex(%q) succeeds", bad)
+		}
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	if _, err := BuildIndex(strings.NewReader(`<a><b></a>`), IndexOptions{}); err == nil {
+		t.Error("BuildIndex of a malformed document succeeds")
+	}
+}
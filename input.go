@@ -0,0 +1,567 @@
+package goxml
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// inputError is a problem found by the input filter that stops parsing.
+type inputError struct {
+	line   int
+	column int
+	offset int64
+	msg    string
+}
+
+func (e *inputError) Error() string {
+	return e.msg
+}
+
+// states of the input filter
+const (
+	inText = iota
+	inLT
+	inBang
+	inTag
+	inAttributeValue
+	inComment
+	inCDATA
+	inProcInst
+	inDeclaration
+	inEntity
+)
+
+// With keepRefs, the ampersand of a kept reference in text is replaced by
+// refMarker, and a literal refMarker in text is followed by refEscape. Both
+// are noncharacters, which are not used in documents for interchange.
+const (
+	refMarker = '\uFDD0'
+	refEscape = '\uFDD1'
+)
+
+// maxEntityName limits the length of an entity reference the input filter
+// collects before giving up.
+const maxEntityName = 64
+
+// inputFilter reads the raw input and looks for problems that stop
+// encoding/xml for good: references to undefined entities and characters
+// that are not allowed in XML. Each problem is passed to report, if set, and
+// repaired, so the decoder can read past it. Characters not allowed in XML
+// and invalid UTF-8 sequences, also in character references, are replaced
+// or removed according to policy. If checkEntities is set, the ampersand of
+// an undefined entity reference is escaped, so the reference ends up as text.
+// Without report, a character not allowed with the CharError policy stops
+// the filter with an *inputError. Invalid UTF-8 is handled according to
+// invalidUTF8 first. If keepCR is set, carriage returns in text and attribute
+// values are turned into references, which the decoder does not normalize to
+// newlines. If normalize is set, literal white space in attribute values is
+// replaced by spaces. If normalize or recordRaw is set, the values as written
+// are queued in rawValues together with their quotes. If recordTags is set,
+// the layout of each start tag is queued in tagLayouts. If recordCDATA is
+// set, the output offsets of the CDATA sections are queued in cdata. If
+// keepRefs is set, references in text are marked for the parser, see
+// refMarker.
+type inputFilter struct {
+	r             io.Reader
+	report        func(line, column int, offset int64, msg string)
+	entities      map[string]string
+	checkEntities bool
+	policy        CharPolicy
+	invalidUTF8   UTF8Policy
+	normalize     bool
+	keepRefs      bool
+	keepCR        bool
+	recordRaw     bool
+	rawValues     []rawValue
+	recordTags    bool
+	tagLayouts    []*tagLayout
+	recordCDATA   bool
+	cdata         []int64
+	// tag collects the current tag without the attribute values
+	tag []rune
+	raw []rune
+	err error
+	in  []byte
+	// pending holds the bytes of an incomplete rune at the end of in
+	pending []byte
+	out     []byte
+	// hold is the position of the ampersand of an unfinished entity
+	// reference in out, -1 if there is none. The bytes from there on are
+	// not handed out until the reference is checked.
+	hold int
+
+	state       int
+	returnState int
+	quote       rune
+	// last contains the recent runes to detect multi-rune delimiters
+	last   [3]rune
+	bang   []rune
+	depth  int
+	entity []rune
+
+	line   int
+	column int
+	offset int64
+	// emitted is the number of bytes handed out by Read, shifts maps the
+	// output back to the input where the filter changed the length
+	emitted int64
+	shifts  []offsetShift
+}
+
+// offsetShift is the difference between the input and the output offset
+// from the output offset out on.
+type offsetShift struct {
+	out   int64
+	delta int64
+}
+
+func newInputFilter(r io.Reader, policy CharPolicy) *inputFilter {
+	return &inputFilter{
+		r:      r,
+		policy: policy,
+		in:     make([]byte, 4096),
+		hold:   -1,
+		line:   1,
+		column: 1,
+	}
+}
+
+func (f *inputFilter) Read(p []byte) (int, error) {
+	for f.available() == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+		n, err := f.r.Read(f.in)
+		if ferr := f.process(f.in[:n], err != nil); ferr != nil {
+			err = ferr
+		}
+		f.err = err
+	}
+	n := copy(p, f.out[:f.available()])
+	f.out = f.out[n:]
+	f.emitted += int64(n)
+	if f.hold >= 0 {
+		f.hold -= n
+	}
+	return n, nil
+}
+
+// available returns the number of bytes in out that can be handed out.
+func (f *inputFilter) available() int {
+	if f.hold >= 0 {
+		return f.hold
+	}
+	return len(f.out)
+}
+
+// process checks buf and appends it to the output. If final is false, an
+// incomplete rune at the end is kept for the next call. The returned error
+// stops the input.
+func (f *inputFilter) process(buf []byte, final bool) error {
+	if len(f.pending) > 0 {
+		buf = append(f.pending, buf...)
+		f.pending = nil
+	}
+	for len(buf) > 0 {
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size == 1 {
+			if !final && !utf8.FullRune(buf) {
+				f.pending = append(f.pending, buf...)
+				return nil
+			}
+			switch f.invalidUTF8 {
+			case UTF8Windows1252:
+				r = windows1252(buf[0])
+				f.out = utf8.AppendRune(f.out, r)
+				f.advance(r, 1)
+				buf = buf[1:]
+				continue
+			case UTF8Replace:
+				f.out = utf8.AppendRune(f.out, utf8.RuneError)
+			default:
+				if err := f.illegal("invalid UTF-8"); err != nil {
+					return err
+				}
+			}
+			f.advance(utf8.RuneError, 1)
+			buf = buf[1:]
+			continue
+		}
+		if !isXMLChar(r) {
+			if err := f.illegal("illegal character code " + strconv.QuoteRune(r)); err != nil {
+				return err
+			}
+			f.advance(utf8.RuneError, size)
+			buf = buf[size:]
+			continue
+		}
+		if f.normalize && f.inAttributeValue() && (r == '\t' || r == '\n' || r == '\r') {
+			// CR LF counts as a single line end
+			if r != '\n' || f.last[2] != '\r' {
+				f.out = append(f.out, ' ')
+			}
+		} else if f.keepRefs && r == refMarker && (f.state == inText || f.state == inCDATA) {
+			f.out = utf8.AppendRune(utf8.AppendRune(f.out, refMarker), refEscape)
+		} else if r == '\r' && f.keepCR && (f.state == inText || f.state == inAttributeValue) {
+			// the decoder does not normalize references
+			f.out = append(f.out, "&#13;"...)
+		} else if r == '\r' && f.keepCR && f.state == inCDATA {
+			f.out = append(f.out, "]]>&#13;<![CDATA["...)
+			if f.recordCDATA {
+				f.cdata = append(f.cdata, f.emitted+int64(len(f.out))-int64(len("<![CDATA[")))
+			}
+		} else {
+			f.out = append(f.out, buf[:size]...)
+		}
+		f.advance(r, size)
+		buf = buf[size:]
+	}
+	if final && f.state == inEntity {
+		f.checkEntity(false)
+		f.state = f.returnState
+		f.recordShift()
+	}
+	return nil
+}
+
+// recordShift notes a change of the difference between the input and the
+// output offset.
+func (f *inputFilter) recordShift() {
+	out := f.emitted + int64(len(f.out))
+	delta := f.offset - out
+	last := int64(0)
+	if len(f.shifts) > 0 {
+		last = f.shifts[len(f.shifts)-1].delta
+	}
+	if delta != last {
+		f.shifts = append(f.shifts, offsetShift{out: out, delta: delta})
+	}
+}
+
+// inputOffset returns the offset in the input of the byte at the output
+// offset out. Offsets at markup boundaries are exact, inside of text changed
+// by the filter they are approximate.
+func (f *inputFilter) inputOffset(out int64) int64 {
+	i := sort.Search(len(f.shifts), func(i int) bool { return f.shifts[i].out > out })
+	if i == 0 {
+		return out
+	}
+	return out + f.shifts[i-1].delta
+}
+
+// illegal handles a character that is not allowed at the current position.
+// It returns an error if the character stops the input, otherwise it reports
+// the problem and adds the replacement to the output.
+func (f *inputFilter) illegal(msg string) error {
+	if f.policy == CharError && f.report == nil {
+		return &inputError{line: f.line, column: f.column, offset: f.offset, msg: msg}
+	}
+	f.problem(msg)
+	f.replaceChar()
+	return nil
+}
+
+// replaceChar adds the replacement for a character that is not allowed to
+// the output.
+func (f *inputFilter) replaceChar() {
+	if f.policy != CharStrip {
+		f.out = utf8.AppendRune(f.out, utf8.RuneError)
+	}
+}
+
+func (f *inputFilter) problem(msg string) {
+	if f.report != nil {
+		f.report(f.line, f.column, f.offset, msg)
+	}
+}
+
+// inAttributeValue reports whether the next rune is part of an attribute
+// value.
+func (f *inputFilter) inAttributeValue() bool {
+	return f.state == inAttributeValue || f.state == inEntity && f.returnState == inAttributeValue
+}
+
+// rawValue is an attribute value as written in the source.
+type rawValue struct {
+	value string
+	quote byte
+}
+
+// nextRawValue returns the oldest queued attribute value.
+func (f *inputFilter) nextRawValue() rawValue {
+	if len(f.rawValues) == 0 {
+		return rawValue{}
+	}
+	rv := f.rawValues[0]
+	f.rawValues = f.rawValues[1:]
+	return rv
+}
+
+// nextTagLayout returns the oldest queued start tag layout.
+func (f *inputFilter) nextTagLayout() *tagLayout {
+	if len(f.tagLayouts) == 0 {
+		return nil
+	}
+	l := f.tagLayouts[0]
+	f.tagLayouts[0] = nil
+	f.tagLayouts = f.tagLayouts[1:]
+	return l
+}
+
+// isCDATA reports whether a CDATA section starts at the output offset.
+// The offsets must be passed in increasing order.
+func (f *inputFilter) isCDATA(offset int64) bool {
+	for len(f.cdata) > 0 && f.cdata[0] < offset {
+		f.cdata = f.cdata[1:]
+	}
+	return len(f.cdata) > 0 && f.cdata[0] == offset
+}
+
+// advance runs the state machine for r and updates the position.
+func (f *inputFilter) advance(r rune, size int) {
+	if (f.normalize || f.recordRaw) && f.inAttributeValue() && r != f.quote {
+		f.raw = append(f.raw, r)
+	}
+	if f.recordTags && (f.state == inLT || f.state == inTag) {
+		f.tag = append(f.tag, r)
+	}
+	f.last[0], f.last[1], f.last[2] = f.last[1], f.last[2], r
+	f.step(r)
+	if r == '\n' {
+		f.line++
+		f.column = 1
+	} else {
+		f.column++
+	}
+	f.offset += int64(size)
+	f.recordShift()
+}
+
+func (f *inputFilter) step(r rune) {
+	switch f.state {
+	case inText:
+		switch r {
+		case '<':
+			f.state = inLT
+			f.tag = f.tag[:0]
+		case '&':
+			f.startEntity(inText)
+		}
+	case inLT:
+		switch r {
+		case '!':
+			f.state = inBang
+			f.bang = f.bang[:0]
+		case '?':
+			f.state = inProcInst
+		default:
+			f.state = inTag
+		}
+	case inBang:
+		f.bang = append(f.bang, r)
+		switch s := string(f.bang); {
+		case s == "--":
+			f.state = inComment
+			f.last = [3]rune{}
+		case s == "[CDATA[":
+			f.state = inCDATA
+			f.last = [3]rune{}
+			if f.recordCDATA {
+				f.cdata = append(f.cdata, f.emitted+int64(len(f.out))-int64(len("<![CDATA[")))
+			}
+		case len(s) == 2 && s != "--" && s != "[C", len(s) >= 7:
+			f.state = inDeclaration
+			f.depth = 0
+		}
+	case inTag:
+		switch r {
+		case '"', '\'':
+			f.state = inAttributeValue
+			f.quote = r
+			f.raw = f.raw[:0]
+		case '>':
+			f.state = inText
+			if f.recordTags && len(f.tag) > 0 && f.tag[0] != '/' {
+				tag := string(f.tag)
+				if !f.keepCR {
+					tag = string(normalizeLineEnds([]byte(tag)))
+				}
+				f.tagLayouts = append(f.tagLayouts, parseTagLayout(tag))
+			}
+		}
+	case inAttributeValue:
+		switch r {
+		case f.quote:
+			f.state = inTag
+			if f.normalize || f.recordRaw {
+				f.rawValues = append(f.rawValues, rawValue{value: string(f.raw), quote: byte(f.quote)})
+			}
+		case '&':
+			f.startEntity(inAttributeValue)
+		}
+	case inComment:
+		if f.last == [3]rune{'-', '-', '>'} {
+			f.state = inText
+		}
+	case inCDATA:
+		if f.last == [3]rune{']', ']', '>'} {
+			f.state = inText
+		}
+	case inProcInst:
+		if f.last[1] == '?' && f.last[2] == '>' {
+			f.state = inText
+		}
+	case inDeclaration:
+		switch r {
+		case '[':
+			f.depth++
+		case ']':
+			f.depth--
+		case '>':
+			if f.depth <= 0 {
+				f.state = inText
+			}
+		}
+	case inEntity:
+		switch {
+		case r == ';':
+			f.checkEntity(true)
+			f.state = f.returnState
+		case len(f.entity) > maxEntityName || !isEntityNameRune(r):
+			f.checkEntity(false)
+			f.state = f.returnState
+			// the rune after a malformed reference still needs the state
+			// machine
+			f.step(r)
+		default:
+			f.entity = append(f.entity, r)
+		}
+	}
+}
+
+// startEntity is called after the ampersand of an entity reference has been
+// added to out.
+func (f *inputFilter) startEntity(returnState int) {
+	f.state = inEntity
+	f.returnState = returnState
+	f.entity = f.entity[:0]
+	f.hold = len(f.out) - 1
+}
+
+// checkEntity reports the collected entity reference if it is not defined or
+// not terminated and escapes its ampersand. A reference to a character not
+// allowed in XML is replaced according to the policy.
+func (f *inputFilter) checkEntity(terminated bool) {
+	hold := f.hold
+	f.hold = -1
+	name := string(f.entity)
+	if terminated && f.keepRefs && f.returnState == inText && keepReference(name) {
+		ref := append([]byte(nil), f.out[hold+1:]...)
+		f.out = append(utf8.AppendRune(f.out[:hold], refMarker), ref...)
+		return
+	}
+	if terminated && f.entityDefined(name) {
+		return
+	}
+	if terminated && isCharReference(name) {
+		if f.policy == CharError && f.report == nil {
+			// let the decoder report the problem
+			return
+		}
+		f.problem("illegal character reference &" + name + ";")
+		f.out = f.out[:hold]
+		f.replaceChar()
+		return
+	}
+	if !f.checkEntities {
+		// let the decoder report the problem
+		return
+	}
+	if terminated {
+		f.problem("invalid character entity &" + name + ";")
+	} else {
+		f.problem("invalid character entity &" + name + " (no semicolon)")
+	}
+	f.out = append(f.out[:hold+1], append([]byte("amp;"), f.out[hold+1:]...)...)
+}
+
+func (f *inputFilter) entityDefined(name string) bool {
+	switch name {
+	case "amp", "lt", "gt", "apos", "quot":
+		return true
+	}
+	if _, ok := f.entities[name]; ok {
+		return true
+	}
+	if r, ok := parseCharReference(name); ok {
+		return isXMLChar(r)
+	}
+	return false
+}
+
+// keepReference reports whether the reference name is kept with keepRefs.
+// The predefined entities are expanded and references to characters not
+// allowed in XML are subject to the character policy.
+func keepReference(name string) bool {
+	switch name {
+	case "", "amp", "lt", "gt", "apos", "quot":
+		return false
+	}
+	if name[0] == '#' {
+		r, ok := parseCharReference(name)
+		return ok && isXMLChar(r)
+	}
+	return true
+}
+
+// parseCharReference returns the character of a reference name such as #65
+// or #x41.
+func parseCharReference(name string) (rune, bool) {
+	if len(name) < 2 || name[0] != '#' {
+		return 0, false
+	}
+	var n uint64
+	var err error
+	if name[1] == 'x' {
+		n, err = strconv.ParseUint(name[2:], 16, 32)
+	} else {
+		n, err = strconv.ParseUint(name[1:], 10, 32)
+	}
+	return rune(n), err == nil
+}
+
+func isCharReference(name string) bool {
+	_, ok := parseCharReference(name)
+	return ok
+}
+
+func isEntityNameRune(r rune) bool {
+	return r == '#' || r == '_' || r == '-' || r == '.' || r == ':' ||
+		r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 0x7f
+}
+
+// windows1252 returns the character of b in the windows-1252 encoding. The
+// five undefined bytes map to the C1 control characters like in browsers.
+func windows1252(b byte) rune {
+	if b >= 0x80 && b < 0xA0 {
+		return windows1252High[b-0x80]
+	}
+	return rune(b)
+}
+
+var windows1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// isXMLChar reports whether r is in the Char production of XML 1.0.
+func isXMLChar(r rune) bool {
+	return r == 0x09 ||
+		r == 0x0A ||
+		r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
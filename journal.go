@@ -0,0 +1,260 @@
+package goxml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Journal records the changes of a document as a list of entries that can be
+// stored, for example as JSON, sent to another process and applied to a copy
+// of the document with ApplyJournal. It is started with StartJournal.
+type Journal struct {
+	// Entries are the recorded changes in the order they have been made.
+	Entries []JournalEntry
+	cancel  func()
+}
+
+// JournalEntry is a single change of a document. The changed element is
+// given by its path from the document, so that the entry refers to the same
+// element in a copy of the document.
+type JournalEntry struct {
+	Type MutationType `json:"type"`
+	// Path contains the indexes of the children that lead from the
+	// document to the changed element, it is empty for changes of the
+	// document itself.
+	Path []int `json:"path"`
+	// Index is the position of the appended, removed or changed child.
+	Index int `json:"index,omitempty"`
+	// Space and Name are the namespace URI and the local name of a changed
+	// attribute.
+	Space string `json:"space,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Value is the new value of an attribute or text node.
+	Value string `json:"value,omitempty"`
+	// Node is the appended node.
+	Node *JournalNode `json:"node,omitempty"`
+}
+
+// JournalNode is a copy of an appended node in a JournalEntry. Kind is
+// element, text, cdata, comment, pi or entity.
+type JournalNode struct {
+	Kind string `json:"kind"`
+	// Name is the local name of an element, the target of a processing
+	// instruction or the name of an entity reference.
+	Name       string             `json:"name,omitempty"`
+	Prefix     string             `json:"prefix,omitempty"`
+	Namespaces map[string]string  `json:"namespaces,omitempty"`
+	Attributes []JournalAttribute `json:"attributes,omitempty"`
+	Children   []JournalNode      `json:"children,omitempty"`
+	// Value is the text of a text node or comment, the instruction of a
+	// processing instruction or the value of a character reference.
+	Value string `json:"value,omitempty"`
+}
+
+// JournalAttribute is an attribute of an element in a JournalNode.
+type JournalAttribute struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	Value     string `json:"value"`
+}
+
+// StartJournal starts recording the changes of the document that are reported
+// to its observers, see Observe. Namespace declarations and changes of the
+// exported fields are not recorded, neither are Undo and Redo. Stop ends the
+// recording.
+func (xr *XMLDocument) StartJournal() *Journal {
+	j := &Journal{}
+	j.cancel = xr.Observe(func(ev MutationEvent) {
+		entry := JournalEntry{
+			Type:  ev.Type,
+			Path:  nodePath(ev.Target),
+			Index: ev.Index,
+		}
+		switch ev.Type {
+		case NodeAppended:
+			node := journalNode(ev.Node)
+			entry.Node = &node
+		case AttributeChanged:
+			entry.Space = ev.Name.Space
+			entry.Name = ev.Name.Local
+			entry.Value = ev.NewValue
+		case TextChanged:
+			entry.Value = ev.NewValue
+		}
+		j.Entries = append(j.Entries, entry)
+	})
+	return j
+}
+
+// Stop ends the recording of the journal. The entries are kept.
+func (j *Journal) Stop() {
+	if j.cancel != nil {
+		j.cancel()
+		j.cancel = nil
+	}
+}
+
+// nodePath returns the indexes of the children from the document to n.
+func nodePath(n XMLNode) []int {
+	path := []int{}
+	for {
+		elt, ok := n.(*Element)
+		if !ok || elt.Parent == nil {
+			break
+		}
+		for i, c := range elt.Parent.Children() {
+			if c == XMLNode(elt) {
+				path = append(path, i)
+				break
+			}
+		}
+		n = elt.Parent
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// journalNode returns a copy of n for a journal entry.
+func journalNode(n XMLNode) JournalNode {
+	switch t := n.(type) {
+	case *Element:
+		jn := JournalNode{Kind: "element", Name: t.Name, Prefix: t.Prefix}
+		if len(t.Namespaces) > 0 {
+			jn.Namespaces = make(map[string]string, len(t.Namespaces))
+			for prefix, ns := range t.Namespaces {
+				jn.Namespaces[prefix] = ns
+			}
+		}
+		for _, attr := range t.attributes {
+			jn.Attributes = append(jn.Attributes, JournalAttribute{Name: attr.Name, Namespace: attr.Namespace, Prefix: attr.Prefix, Value: attr.Value})
+		}
+		for _, c := range t.children {
+			jn.Children = append(jn.Children, journalNode(c))
+		}
+		return jn
+	case CharData:
+		if t.CDATA {
+			return JournalNode{Kind: "cdata", Value: t.Contents}
+		}
+		return JournalNode{Kind: "text", Value: t.Contents}
+	case Comment:
+		return JournalNode{Kind: "comment", Value: t.Contents}
+	case ProcInst:
+		return JournalNode{Kind: "pi", Name: t.Target, Value: string(t.Inst)}
+	case EntityRef:
+		return JournalNode{Kind: "entity", Name: t.Name, Value: t.Value}
+	}
+	return JournalNode{}
+}
+
+// newNode creates the node described by jn with IDs of the document xr.
+func (jn JournalNode) newNode(xr *XMLDocument) (XMLNode, error) {
+	switch jn.Kind {
+	case "element":
+		elt := &Element{ID: xr.NextID(), Name: jn.Name, Prefix: jn.Prefix}
+		if len(jn.Namespaces) > 0 {
+			elt.Namespaces = make(map[string]string, len(jn.Namespaces))
+			for prefix, ns := range jn.Namespaces {
+				elt.Namespaces[prefix] = ns
+			}
+		}
+		for _, attr := range jn.Attributes {
+			elt.attributes = append(elt.attributes, &Attribute{ID: xr.NextID(), Name: attr.Name, Namespace: attr.Namespace, Prefix: attr.Prefix, Value: attr.Value})
+		}
+		for _, c := range jn.Children {
+			n, err := c.newNode(xr)
+			if err != nil {
+				return nil, err
+			}
+			n.setParent(elt)
+			elt.children = append(elt.children, n)
+		}
+		return elt, nil
+	case "text":
+		return CharData{ID: xr.NextID(), Contents: jn.Value}, nil
+	case "cdata":
+		return CharData{ID: xr.NextID(), Contents: jn.Value, CDATA: true}, nil
+	case "comment":
+		return Comment{ID: xr.NextID(), Contents: jn.Value}, nil
+	case "pi":
+		return ProcInst{ID: xr.NextID(), Target: jn.Name, Inst: []byte(jn.Value)}, nil
+	case "entity":
+		return EntityRef{ID: xr.NextID(), Name: jn.Name, Value: jn.Value}, nil
+	}
+	return nil, fmt.Errorf("unknown node kind %q", jn.Kind)
+}
+
+// ApplyJournal makes the changes recorded in entries, which must have been
+// recorded on a document with the same contents as xr at the start of the
+// journal, for example a copy parsed from the same source on another
+// machine. The changes are reported to the observers of xr and recorded for
+// undo like other changes. ApplyJournal stops at the first entry that does
+// not fit the document and returns an error, the entries before it have
+// been applied.
+func (xr *XMLDocument) ApplyJournal(entries []JournalEntry) error {
+	for i, entry := range entries {
+		if err := xr.applyEntry(entry); err != nil {
+			return fmt.Errorf("journal entry %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (xr *XMLDocument) applyEntry(entry JournalEntry) error {
+	var target XMLNode = xr
+	for _, i := range entry.Path {
+		children := target.Children()
+		if i < 0 || i >= len(children) {
+			return fmt.Errorf("path %v not found", entry.Path)
+		}
+		target = children[i]
+	}
+	if _, ok := target.(*Element); !ok && target != XMLNode(xr) {
+		return fmt.Errorf("path %v does not lead to an element", entry.Path)
+	}
+	children := target.Children()
+	switch entry.Type {
+	case NodeAppended:
+		if entry.Node == nil {
+			return errors.New("append without node")
+		}
+		n, err := entry.Node.newNode(xr)
+		if err != nil {
+			return err
+		}
+		// text is not merged with the previous text node like with Append,
+		// such an append has been recorded as a change of the text
+		return insertChild(target, entry.Index, n)
+	case NodeRemoved:
+		_, err := removeChild(target, entry.Index)
+		return err
+	case AttributeChanged:
+		elt, ok := target.(*Element)
+		if !ok {
+			return errors.New("attribute of the document")
+		}
+		elt.Append(Attribute{ID: xr.NextID(), Name: entry.Name, Namespace: entry.Space, Value: entry.Value})
+	case TextChanged:
+		elt, ok := target.(*Element)
+		if !ok {
+			return errors.New("text of the document")
+		}
+		if entry.Index < 0 || entry.Index >= len(children) {
+			return fmt.Errorf("index %d out of range", entry.Index)
+		}
+		cd, ok := children[entry.Index].(CharData)
+		if !ok {
+			return fmt.Errorf("child %d is not a text node", entry.Index)
+		}
+		doc := elt.invalidate()
+		changed := CharData{ID: cd.ID, Contents: entry.Value, CDATA: cd.CDATA}
+		elt.children[entry.Index] = changed
+		doc.notify(MutationEvent{Type: TextChanged, Target: elt, Node: changed, Index: entry.Index, OldValue: cd.Contents, NewValue: entry.Value})
+	default:
+		return fmt.Errorf("unknown mutation type %d", int(entry.Type))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package goxml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const journalTestDoc = `<r xmlns:p="P"><a>t</a><b x="1"/><c/></r>`
+
+func TestJournalReplay(t *testing.T) {
+	doc, r := parseRoot(t, journalTestDoc)
+	a, b := r.children[0].(*Element), r.children[1].(*Element)
+	j := doc.StartJournal()
+
+	n := &Element{Name: "n", Prefix: "p"}
+	n.Append(Attribute{Name: "y", Namespace: "P", Prefix: "p", Value: "2"})
+	n.Append(CharData{Contents: "text"})
+	n.Append(Comment{Contents: "c"})
+	n.Append(ProcInst{Target: "pi", Inst: []byte("data")})
+	if err := r.InsertChild(1, n); err != nil {
+		t.Fatal(err)
+	}
+	a.Append(CharData{Contents: " more"})
+	b.SetAttribute(xml.Attr{Name: xml.Name{Local: "x"}, Value: "changed"})
+	r.children[3].(*Element).Remove()
+	j.Stop()
+	// changes after Stop are not recorded
+	r.Append(&Element{Name: "late"})
+
+	if len(j.Entries) != 4 {
+		t.Fatalf("%d entries recorded, want 4: %+v", len(j.Entries), j.Entries)
+	}
+	data, err := json.Marshal(j.Entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []JournalEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	copyDoc, _ := parseRoot(t, journalTestDoc)
+	if err = copyDoc.ApplyJournal(entries); err != nil {
+		t.Fatal(err)
+	}
+	want := `<r xmlns:p="P"><a>t more</a><p:n p:y="2">text<!--c--><?pi data?></p:n><b x="changed" /></r>`
+	if got := copyDoc.ToXML(); got != want {
+		t.Errorf("replayed document:\n%s\nwant\n%s", got, want)
+	}
+	checkIDs(t, copyDoc)
+	if got := strings.Replace(doc.ToXML(), "<late />", "", 1); got != want {
+		t.Errorf("original document:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestJournalMismatch(t *testing.T) {
+	doc, _ := parseRoot(t, `<r/>`)
+	for _, entry := range []JournalEntry{
+		{Type: NodeRemoved, Path: []int{0, 5}},
+		{Type: NodeRemoved, Path: []int{0}, Index: 0},
+		{Type: NodeAppended, Path: []int{0}},
+		{Type: TextChanged, Path: []int{0}, Index: 0, Value: "x"},
+	} {
+		if err := doc.ApplyJournal([]JournalEntry{entry}); err == nil {
+			t.Errorf("ApplyJournal(%+v) succeeds on %s", entry, doc.ToXML())
+		}
+	}
+	if got := doc.ToXML(); got != "<r />" {
+		t.Errorf("failed entries changed the document: %s", got)
+	}
+}
@@ -0,0 +1,224 @@
+package goxml
+
+import (
+	"sort"
+	"strings"
+)
+
+// Lang returns the effective language of the element, the value of the
+// nearest xml:lang attribute on the element or its ancestors. It returns an
+// empty string if no language is set or if it is explicitly unset with
+// xml:lang="".
+func (elt *Element) Lang() string {
+	for cur := elt; ; {
+		if lang, ok := cur.ownLang(); ok {
+			return lang
+		}
+		p, ok := cur.Parent.(*Element)
+		if !ok {
+			return ""
+		}
+		cur = p
+	}
+}
+
+// ownLang returns the value of the xml:lang attribute of the element.
+func (elt *Element) ownLang() (string, bool) {
+	for _, attr := range elt.attributes {
+		if attr.Name == "lang" && attr.Namespace == xmlNamespace {
+			return strings.TrimSpace(attr.Value), true
+		}
+	}
+	return "", false
+}
+
+// MatchLang reports whether the language tag matches the language range
+// langRange according to the extended filtering of RFC 4647. The comparison
+// is case insensitive. "de" matches "de", "de-DE" and "de-Latn-DE", "de-DE"
+// matches "de-DE" and "de-Latn-DE" and "*-DE" matches all tags for Germany.
+// An empty tag matches no range.
+func MatchLang(tag, langRange string) bool {
+	if tag == "" || langRange == "" {
+		return false
+	}
+	tags := strings.Split(strings.ToLower(tag), "-")
+	ranges := strings.Split(strings.ToLower(langRange), "-")
+	if ranges[0] != "*" && ranges[0] != tags[0] {
+		return false
+	}
+	i, j := 1, 1
+	for i < len(ranges) {
+		switch {
+		case ranges[i] == "*":
+			i++
+		case j >= len(tags):
+			return false
+		case ranges[i] == tags[j]:
+			i++
+			j++
+		case len(tags[j]) == 1:
+			// singletons such as x for private use subtags cannot be skipped
+			return false
+		default:
+			j++
+		}
+	}
+	return true
+}
+
+// LangSubtrees returns the outermost elements of the document whose
+// effective language matches langRange, see MatchLang. Each element stands
+// for the subtree in that language, descendants that switch to a language
+// that does not match are not removed.
+func (xr *XMLDocument) LangSubtrees(langRange string) []*Element {
+	var found []*Element
+	for _, n := range xr.children {
+		if elt, ok := n.(*Element); ok {
+			found = elt.langSubtrees(langRange, "", found)
+		}
+	}
+	return found
+}
+
+// langSubtrees adds the outermost matching elements of the subtree of elt to
+// found. lang is the language inherited from the parent.
+func (elt *Element) langSubtrees(langRange, lang string, found []*Element) []*Element {
+	if own, ok := elt.ownLang(); ok {
+		lang = own
+	}
+	if MatchLang(lang, langRange) {
+		return append(found, elt)
+	}
+	for _, n := range elt.children {
+		if child, ok := n.(*Element); ok {
+			found = child.langSubtrees(langRange, lang, found)
+		}
+	}
+	return found
+}
+
+// SelectLanguage returns a copy of the document reduced to one language.
+// Sibling elements with the same name and the same attributes apart from
+// their own xml:lang attributes are alternatives in different languages, of
+// which only the best match for langRanges is kept. The ranges are given in
+// order of preference. The best match is found with the lookup of RFC 4647:
+// for each range, the alternative whose tag equals the range is chosen, and
+// if there is none, the range is shortened by its last subtag, "de-CH-1996"
+// to "de-CH" and to "de". If no range finds an alternative, the first
+// alternative that matches a range by the extended filtering of MatchLang is
+// kept, for example "de-DE" for the range "de", and otherwise the first
+// alternative. Elements without alternatives are kept whatever their
+// language, so the output is complete even if a part was not translated.
+// The nodes of the copy are numbered anew, doc is not changed.
+func SelectLanguage(doc *XMLDocument, langRanges ...string) *XMLDocument {
+	cp := NewDocument()
+	cp.baseURI = doc.baseURI
+	cp.source = doc.source
+	for _, c := range doc.children {
+		n := snapshotNode(c, cp)
+		if elt, ok := n.(*Element); ok {
+			selectLanguage(elt, langRanges)
+		}
+		cp.children = append(cp.children, n)
+	}
+	cp.renumber()
+	return cp
+}
+
+// selectLanguage removes the alternatives that are not selected from the
+// descendants of the copied element elt. It reports whether the subtree has
+// changed.
+func selectLanguage(elt *Element, langRanges []string) bool {
+	// the alternatives by name and attributes
+	groups := make(map[string][]*Element)
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			if key, ok := alternativeKey(cld); ok {
+				groups[key] = append(groups[key], cld)
+			}
+		}
+	}
+	drop := make(map[*Element]bool)
+	for _, alternatives := range groups {
+		if len(alternatives) < 2 {
+			continue
+		}
+		best := bestLanguage(alternatives, langRanges)
+		for _, alt := range alternatives {
+			if alt != best {
+				drop[alt] = true
+			}
+		}
+	}
+	changed := len(drop) > 0
+	children := elt.children[:0]
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			if drop[cld] {
+				continue
+			}
+			if selectLanguage(cld, langRanges) {
+				changed = true
+			}
+		}
+		children = append(children, c)
+	}
+	elt.children = children
+	if changed {
+		// the copy is not written like the source
+		elt.svCache = &stringvalueCache{}
+		elt.serializedCached = false
+		elt.spanEnd = 0
+	}
+	return changed
+}
+
+// alternativeKey returns the key of the group of alternatives of elt, which
+// consists of the namespace, the name and the attributes except xml:lang. It
+// returns false if elt has no xml:lang attribute of its own.
+func alternativeKey(elt *Element) (string, bool) {
+	if _, ok := elt.ownLang(); !ok {
+		return "", false
+	}
+	parts := []string{elt.NamespaceURI(), elt.Name}
+	var attrs []string
+	for _, attr := range elt.attributes {
+		if attr.Name == "lang" && attr.Namespace == xmlNamespace {
+			continue
+		}
+		attrs = append(attrs, attr.Namespace+"\x00"+attr.Name+"\x00"+attr.Value)
+	}
+	sort.Strings(attrs)
+	return strings.Join(append(parts, attrs...), "\x01"), true
+}
+
+// bestLanguage returns the alternative that matches langRanges best.
+func bestLanguage(alternatives []*Element, langRanges []string) *Element {
+	for _, langRange := range langRanges {
+		r := strings.ToLower(strings.TrimSpace(langRange))
+		for r != "" && r != "*" {
+			for _, alt := range alternatives {
+				if lang, _ := alt.ownLang(); strings.ToLower(lang) == r {
+					return alt
+				}
+			}
+			i := strings.LastIndexByte(r, '-')
+			if i < 0 {
+				break
+			}
+			r = r[:i]
+			// a singleton is removed with the subtag after it
+			if i := strings.LastIndexByte(r, '-'); i >= 0 && i == len(r)-2 {
+				r = r[:i]
+			}
+		}
+	}
+	for _, langRange := range langRanges {
+		for _, alt := range alternatives {
+			if lang, _ := alt.ownLang(); MatchLang(lang, langRange) {
+				return alt
+			}
+		}
+	}
+	return alternatives[0]
+}
@@ -0,0 +1,132 @@
+package goxml
+
+import "strings"
+
+// tagLayout is the white space in a start tag as written in the source.
+type tagLayout struct {
+	// attrs are the attributes and namespace declarations in source order
+	attrs []attrLayout
+	// end is the white space before > or />
+	end         string
+	selfClosing bool
+}
+
+type attrLayout struct {
+	// name is the qualified name as written
+	name string
+	// before is the white space before the name, eq the text between the
+	// name and the value
+	before string
+	eq     string
+}
+
+// parseTagLayout reads the layout from a start tag without the attribute
+// values: the text from the element name up to and including the closing
+// angle bracket, where only the opening quote of each value is left.
+func parseTagLayout(tag string) *tagLayout {
+	l := &tagLayout{}
+	i := strings.IndexAny(tag, " \t\r\n/>")
+	if i < 0 {
+		return l
+	}
+	tag = tag[i:]
+	for {
+		rest := strings.TrimLeft(tag, " \t\r\n")
+		space := tag[:len(tag)-len(rest)]
+		if rest == "" || rest[0] == '/' || rest[0] == '>' {
+			l.end = space
+			l.selfClosing = strings.HasPrefix(rest, "/")
+			return l
+		}
+		a := attrLayout{before: space}
+		n := strings.IndexAny(rest, " \t\r\n=")
+		if n < 0 {
+			return l
+		}
+		a.name = rest[:n]
+		rest = rest[n:]
+		q := strings.IndexAny(rest, `"'`)
+		if q < 0 {
+			return l
+		}
+		a.eq = rest[:q]
+		l.attrs = append(l.attrs, a)
+		tag = rest[q+1:]
+	}
+}
+
+// layout returns the layout the start tag of the element is written with,
+// or nil.
+func (elt *Element) layout(xw *xmlWriter) *tagLayout {
+	if !xw.keepLayout || xw.indent != "" {
+		return nil
+	}
+	return elt.startTag
+}
+
+// writeLayoutAttributes writes the attributes and namespace declarations of
+// the layout that the element still has, with their white space. It returns
+// the names written.
+func (elt *Element) writeLayoutAttributes(xw *xmlWriter, l *tagLayout) map[string]bool {
+	written := make(map[string]bool, len(l.attrs))
+	for _, a := range l.attrs {
+		if a.name == "xmlns" || strings.HasPrefix(a.name, "xmlns:") {
+			prefix := strings.TrimPrefix(strings.TrimPrefix(a.name, "xmlns"), ":")
+			ns, ok := elt.Namespaces[prefix]
+			if !ok || written[a.name] {
+				continue
+			}
+			xw.writeString(a.before, a.name, a.eq, "\"")
+			xw.writeAttributeValue(ns)
+			xw.writeString("\"")
+			written[a.name] = true
+			continue
+		}
+		for _, att := range elt.attributes {
+			if qualifiedName(att.Prefix, att.Name) != a.name || att.Defaulted && xw.skipDefaulted || written[a.name] {
+				continue
+			}
+			xw.writeString(a.before, a.name, a.eq)
+			xw.writeQuotedValue(att)
+			written[a.name] = true
+			break
+		}
+	}
+	return written
+}
+
+// closeStartTag finishes the start tag written by writeStartTag. For
+// elements without children it writes the end tag as well and returns
+// false.
+func (elt *Element) closeStartTag(xw *xmlWriter) bool {
+	l := elt.layout(xw)
+	if len(elt.children) == 0 {
+		switch {
+		case l == nil && xw.expandEmpty:
+			xw.writeString(">")
+			elt.writeEndTag(xw)
+		case l == nil:
+			xw.writeString(" />")
+		case l.selfClosing:
+			xw.writeString(l.end, "/>")
+		default:
+			xw.writeString(l.end, ">")
+			elt.writeEndTag(xw)
+		}
+		return false
+	}
+	if l != nil {
+		xw.writeString(l.end)
+	}
+	xw.writeString(">")
+	return true
+}
+
+// namespaceAttributeName returns the name of the attribute that declares
+// prefix.
+func namespaceAttributeName(prefix string) string {
+	if prefix == "" {
+		return "xmlns"
+	}
+	return "xmlns:" + prefix
+}
@@ -0,0 +1,303 @@
+package goxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LazyDocument is a read-only view of a document in a byte buffer for
+// documents that are too large to be parsed into a tree and are only
+// queried. The child elements of an element are read from the buffer when
+// they are first asked for, so memory grows with the part of the tree that
+// is visited, not with the size of the document. Text is not kept but read
+// from the buffer each time. Subtrees that are needed as regular elements,
+// for example for Path queries, can be parsed with LazyElement.Parse.
+//
+// The buffer must not change while the view is in use. A memory mapped file
+// keeps the resident memory small for the buffer as well. The methods of a
+// LazyDocument and its elements must not be called concurrently, as reading
+// children changes the view.
+type LazyDocument struct {
+	data         []byte
+	root         *LazyElement
+	materialized int
+}
+
+// LazyElement is an element in a LazyDocument.
+type LazyElement struct {
+	Name   string
+	Prefix string
+	Parent *LazyElement
+	// Namespaces contains the namespace declarations of this element, the
+	// keys are the prefixes. It is nil for elements without declarations.
+	Namespaces map[string]string
+	// Line is the line of the start tag.
+	Line       int
+	attributes []*Attribute
+	doc        *LazyDocument
+	// start and end are the offsets of the element in the buffer, content
+	// and contentEnd those of its contents
+	start, content, contentEnd, end int64
+	// children is nil until the child elements are read
+	children     []*LazyElement
+	materialized bool
+}
+
+// NewLazyDocument checks that data is a well-formed document and returns a
+// view of it. Only the root element is read, the check keeps no more than
+// the names of the open elements. The document must not use entities other
+// than the predefined ones.
+func NewLazyDocument(data []byte) (*LazyDocument, error) {
+	ld := &LazyDocument{data: data}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var open []xml.Name
+	for {
+		line, _ := dec.InputPos()
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch v := tok.(type) {
+		case xml.StartElement:
+			if len(open) == 0 {
+				if ld.root != nil {
+					return nil, fmt.Errorf("line %d: more than one root element", line)
+				}
+				ld.root = ld.newElement(nil, v, offset, dec.InputOffset(), line)
+			}
+			open = append(open, v.Name)
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != v.Name {
+				return nil, fmt.Errorf("line %d: unexpected end element </%s>", line, qualifiedName(v.Name.Space, v.Name.Local))
+			}
+			open = open[:len(open)-1]
+			if len(open) == 0 {
+				ld.root.contentEnd = offset
+				ld.root.end = dec.InputOffset()
+			}
+		case xml.CharData:
+			if len(open) == 0 && !isSpace(string(v)) {
+				return nil, fmt.Errorf("line %d: text outside of the root element", line)
+			}
+		}
+	}
+	if len(open) > 0 {
+		return nil, errors.New("unexpected EOF")
+	}
+	if ld.root == nil {
+		return nil, errors.New("no root element")
+	}
+	return ld, nil
+}
+
+// newElement returns the element of the start tag v at offset, whose
+// contents start at content.
+func (ld *LazyDocument) newElement(parent *LazyElement, v xml.StartElement, offset, content int64, line int) *LazyElement {
+	le := &LazyElement{
+		Name:    v.Name.Local,
+		Prefix:  v.Name.Space,
+		Parent:  parent,
+		Line:    line,
+		doc:     ld,
+		start:   offset,
+		content: content,
+	}
+	for _, attr := range v.Attr {
+		switch {
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			le.declare("", attr.Value)
+		case attr.Name.Space == "xmlns":
+			le.declare(attr.Name.Local, attr.Value)
+		}
+	}
+	for _, attr := range v.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+			continue
+		}
+		a := &Attribute{Name: attr.Name.Local, Prefix: attr.Name.Space, Value: attr.Value}
+		if a.Prefix != "" {
+			a.Namespace, _ = le.LookupNamespace(a.Prefix)
+		}
+		le.attributes = append(le.attributes, a)
+	}
+	ld.materialized++
+	return le
+}
+
+func (le *LazyElement) declare(prefix, uri string) {
+	if le.Namespaces == nil {
+		le.Namespaces = make(map[string]string)
+	}
+	le.Namespaces[prefix] = uri
+}
+
+// Root returns the root element.
+func (ld *LazyDocument) Root() *LazyElement {
+	return ld.root
+}
+
+// Materialized returns the number of elements that have been read and are
+// held by the view.
+func (ld *LazyDocument) Materialized() int {
+	return ld.materialized
+}
+
+// Children returns the child elements in document order. They are read from
+// the buffer on the first call.
+func (le *LazyElement) Children() []*LazyElement {
+	if le.materialized {
+		return le.children
+	}
+	data := le.doc.data[le.content:le.contentEnd]
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// the first line of the contents is the last line of the start tag
+	firstLine := le.Line + bytes.Count(le.doc.data[le.start:le.content], []byte{'\n'})
+	depth := 0
+	var cur *LazyElement
+	for {
+		line, _ := dec.InputPos()
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err != nil {
+			// the document has been checked by NewLazyDocument
+			break
+		}
+		switch v := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				cur = le.doc.newElement(le, v, le.content+offset, le.content+dec.InputOffset(), firstLine+line-1)
+				le.children = append(le.children, cur)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				cur.contentEnd = le.content + offset
+				cur.end = le.content + dec.InputOffset()
+			}
+		}
+	}
+	le.materialized = true
+	return le.children
+}
+
+// Child returns the first child element with the local name name, nil if
+// there is none.
+func (le *LazyElement) Child(name string) *LazyElement {
+	for _, c := range le.Children() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Unload drops the child elements that have been read, along with their
+// descendants, so that the memory can be reclaimed once the caller holds no
+// more references to them. They are read again when they are asked for.
+func (le *LazyElement) Unload() {
+	for _, c := range le.children {
+		c.Unload()
+	}
+	le.doc.materialized -= len(le.children)
+	le.children = nil
+	le.materialized = false
+}
+
+// Attributes returns the attributes of the element without the namespace
+// declarations.
+func (le *LazyElement) Attributes() []*Attribute {
+	return le.attributes
+}
+
+// LookupNamespace returns the namespace bound to prefix in the scope of the
+// element, the empty prefix stands for the default namespace. ok is false if
+// the prefix is not bound.
+func (le *LazyElement) LookupNamespace(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for cur := le; cur != nil; cur = cur.Parent {
+		if ns, ok := cur.Namespaces[prefix]; ok {
+			return ns, ns != ""
+		}
+	}
+	return "", false
+}
+
+// NamespaceURI returns the namespace of the element, empty for elements in
+// no namespace and for elements with an unbound prefix.
+func (le *LazyElement) NamespaceURI() string {
+	ns, _ := le.LookupNamespace(le.Prefix)
+	return ns
+}
+
+// InScopeNamespaces returns all namespace bindings in the scope of the
+// element, the keys are the prefixes.
+func (le *LazyElement) InScopeNamespaces() map[string]string {
+	namespaces := make(map[string]string)
+	for cur := le; cur != nil; cur = cur.Parent {
+		for prefix, ns := range cur.Namespaces {
+			if _, ok := namespaces[prefix]; !ok {
+				namespaces[prefix] = ns
+			}
+		}
+	}
+	for prefix, ns := range namespaces {
+		if ns == "" {
+			delete(namespaces, prefix)
+		}
+	}
+	return namespaces
+}
+
+// Stringvalue returns the text of the element and its descendants. It is
+// read from the buffer without reading the descendant elements.
+func (le *LazyElement) Stringvalue() string {
+	var sb strings.Builder
+	dec := xml.NewDecoder(bytes.NewReader(le.doc.data[le.content:le.contentEnd]))
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			break
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			sb.Write(cd)
+		}
+	}
+	return sb.String()
+}
+
+// SourceSpan returns the byte offsets of the start of the start tag and the
+// end of the end tag of the element in the buffer.
+func (le *LazyElement) SourceSpan() (start, end int64) {
+	return le.start, le.end
+}
+
+// RawBytes returns the bytes of the element as they are written in the
+// buffer. The result is a part of the buffer.
+func (le *LazyElement) RawBytes() []byte {
+	return le.doc.data[le.start:le.end]
+}
+
+// Parse parses the element with its subtree into a document with the
+// element as its root element, like Index.ParseIndexed does. The namespace
+// bindings inherited from the ancestors are declared on the root element.
+// Line numbers and offsets in the result count from the start tag of the
+// element.
+func (le *LazyElement) Parse(opts ...ParseOption) (*XMLDocument, error) {
+	var inherited map[string]string
+	if le.Parent != nil {
+		inherited = le.Parent.InScopeNamespaces()
+	}
+	opts = append(opts, withInheritedNamespaces(inherited))
+	return ParseBytes(le.RawBytes(), opts...)
+}
@@ -0,0 +1,96 @@
+package goxml
+
+import (
+	"testing"
+)
+
+const lazyTestDoc = `<lib xmlns="urn:l" xmlns:x="urn:x">
+<shelf n="1">
+  <book id="a"><title>One</title> &amp; more</book>
+  <x:book/>
+</shelf>
+<shelf n="2" xmlns=""><book>Two</book></shelf>
+</lib>`
+
+func TestLazyDocument(t *testing.T) {
+	ld, err := NewLazyDocument([]byte(lazyTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := ld.Root()
+	if root.Name != "lib" || root.NamespaceURI() != "urn:l" {
+		t.Errorf("root element {%s}%s, want {urn:l}lib", root.NamespaceURI(), root.Name)
+	}
+	if got := ld.Materialized(); got != 1 {
+		t.Errorf("%d elements read before a child is asked for, want 1", got)
+	}
+	shelves := root.Children()
+	if len(shelves) != 2 || shelves[1].Line != 6 || shelves[1].Attributes()[0].Value != "2" {
+		t.Fatalf("children of the root: %+v", shelves)
+	}
+	if got := ld.Materialized(); got != 3 {
+		t.Errorf("%d elements read, want 3", got)
+	}
+	books := shelves[0].Children()
+	if len(books) != 2 || books[0].Line != 3 {
+		t.Fatalf("children of the first shelf: %+v", books)
+	}
+	if got := books[0].Stringvalue(); got != "One & more" {
+		t.Errorf("Stringvalue = %q, want %q", got, "One & more")
+	}
+	if got := string(books[0].RawBytes()); got != `<book id="a"><title>One</title> &amp; more</book>` {
+		t.Errorf("RawBytes = %q", got)
+	}
+	if start, end := books[1].SourceSpan(); lazyTestDoc[start:end] != "<x:book/>" {
+		t.Errorf("SourceSpan selects %q, want <x:book/>", lazyTestDoc[start:end])
+	}
+	if got := books[1].NamespaceURI(); got != "urn:x" {
+		t.Errorf("namespace of x:book = %q, want urn:x", got)
+	}
+	// xmlns="" undeclares the default namespace
+	other := shelves[1].Child("book")
+	if other == nil || other.NamespaceURI() != "" {
+		t.Fatalf("book of the second shelf: %+v", other)
+	}
+	if ns := other.InScopeNamespaces(); len(ns) != 1 || ns["x"] != "urn:x" {
+		t.Errorf("InScopeNamespaces = %v, want only x", ns)
+	}
+	if shelves[0].Child("missing") != nil {
+		t.Error("Child finds a missing element")
+	}
+
+	shelves[0].Unload()
+	if got := ld.Materialized(); got != 4 {
+		t.Errorf("%d elements held after Unload, want 4", got)
+	}
+	if again := shelves[0].Children(); len(again) != 2 || again[0].Stringvalue() != "One & more" {
+		t.Errorf("children read again after Unload: %+v", again)
+	}
+}
+
+func TestLazyParse(t *testing.T) {
+	ld, err := NewLazyDocument([]byte(lazyTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := ld.Root().Child("shelf").Child("book")
+	doc, err := book.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := doc.Root()
+	if r.NamespaceURI() != "urn:l" || r.FirstChildElement().NamespaceURI() != "urn:l" {
+		t.Errorf("parsed element is in %q, want urn:l: %s", r.NamespaceURI(), doc.ToXML())
+	}
+	if got := r.Stringvalue(); got != "One & more" {
+		t.Errorf("Stringvalue of the parsed element = %q", got)
+	}
+}
+
+func TestLazyMalformed(t *testing.T) {
+	for _, s := range []string{``, `<a>`, `<a></b>`, `<a/><b/>`, `text`} {
+		if _, err := NewLazyDocument([]byte(s)); err == nil {
+			t.Errorf("NewLazyDocument(%q) succeeds", s)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// LinkOptions tells LinkGraph which attributes are IDs and references
+// besides the ones the DTD declares.
+type LinkOptions struct {
+	// IDAttributes are the names of attributes without namespace whose
+	// values are IDs. xml:id is always an ID. The default is id.
+	IDAttributes []string
+	// RefAttributes are the names of attributes without namespace whose
+	// values are a single reference, such as linkend in DocBook.
+	RefAttributes []string
+	// RefsAttributes are the names of attributes without namespace whose
+	// values are lists of references separated by white space, such as
+	// arearefs in DocBook.
+	RefsAttributes []string
+}
+
+// Reference is a reference to an ID.
+type Reference struct {
+	// Element is the element with the reference attribute Name.
+	Element *Element
+	Name    xml.Name
+	ID      string
+	// Target is the element with the ID, nil if the reference is not
+	// resolved.
+	Target *Element
+}
+
+// LinkGraph contains the IDs of a document and the references to them. It is
+// built by XMLDocument.LinkGraph and does not follow later changes of the
+// document.
+type LinkGraph struct {
+	ids        map[string]*Element
+	order      []string
+	duplicates []string
+	refs       []Reference
+	to         map[string][]int
+}
+
+// LinkGraph collects the IDs of the document and the references to them.
+// The attributes of type ID, IDREF and IDREFS declared in the internal
+// subset of the DOCTYPE declaration are used along with xml:id and the
+// attributes in opts. The first element with an ID defines it, later ones
+// are reported by DuplicateIDs.
+func (xr *XMLDocument) LinkGraph(opts LinkOptions) *LinkGraph {
+	if opts.IDAttributes == nil {
+		opts.IDAttributes = []string{"id"}
+	}
+	g := &LinkGraph{ids: make(map[string]*Element), to: make(map[string][]int)}
+	var types map[string]map[string]string
+	if d := xr.DTD(); d != nil {
+		for element, decls := range d.Attributes {
+			for _, ad := range decls {
+				if ad.Type == "ID" || ad.Type == "IDREF" || ad.Type == "IDREFS" {
+					if types == nil {
+						types = make(map[string]map[string]string)
+					}
+					if types[element] == nil {
+						types[element] = make(map[string]string)
+					}
+					types[element][ad.Name] = ad.Type
+				}
+			}
+		}
+	}
+	var visit func(elt *Element)
+	visit = func(elt *Element) {
+		declared := types[elt.qualifiedName()]
+		for _, attr := range elt.attributes {
+			typ := declared[qualifiedName(attr.Prefix, attr.Name)]
+			if typ == "" {
+				switch {
+				case attr.Namespace == xmlNamespace && attr.Name == "id":
+					typ = "ID"
+				case attr.Namespace != "":
+				case containsName(opts.IDAttributes, attr.Name):
+					typ = "ID"
+				case containsName(opts.RefAttributes, attr.Name):
+					typ = "IDREF"
+				case containsName(opts.RefsAttributes, attr.Name):
+					typ = "IDREFS"
+				}
+			}
+			switch typ {
+			case "ID":
+				g.define(strings.TrimSpace(attr.Value), elt)
+			case "IDREF", "IDREFS":
+				for _, id := range strings.Fields(attr.Value) {
+					g.refs = append(g.refs, Reference{Element: elt, Name: xml.Name{Space: attr.Namespace, Local: attr.Name}, ID: id})
+				}
+			}
+		}
+		for _, c := range elt.children {
+			if cld, ok := c.(*Element); ok {
+				visit(cld)
+			}
+		}
+	}
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			visit(elt)
+		}
+	}
+	for i := range g.refs {
+		ref := &g.refs[i]
+		ref.Target = g.ids[ref.ID]
+		g.to[ref.ID] = append(g.to[ref.ID], i)
+	}
+	return g
+}
+
+// define adds the ID id of elt.
+func (g *LinkGraph) define(id string, elt *Element) {
+	if id == "" {
+		return
+	}
+	if _, ok := g.ids[id]; ok {
+		g.duplicates = append(g.duplicates, id)
+		return
+	}
+	g.ids[id] = elt
+	g.order = append(g.order, id)
+}
+
+// Lookup returns the element with the ID id, nil if there is none.
+func (g *LinkGraph) Lookup(id string) *Element {
+	return g.ids[id]
+}
+
+// IDs returns the IDs in document order.
+func (g *LinkGraph) IDs() []string {
+	return g.order
+}
+
+// References returns all references in document order.
+func (g *LinkGraph) References() []Reference {
+	return g.refs
+}
+
+// ReferencesTo returns the references to id in document order.
+func (g *LinkGraph) ReferencesTo(id string) []Reference {
+	var refs []Reference
+	for _, i := range g.to[id] {
+		refs = append(refs, g.refs[i])
+	}
+	return refs
+}
+
+// ReferencesFrom returns the references in the attributes of elt.
+func (g *LinkGraph) ReferencesFrom(elt *Element) []Reference {
+	var refs []Reference
+	for _, ref := range g.refs {
+		if ref.Element == elt {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// UnresolvedRefs returns the references to IDs that are not defined.
+func (g *LinkGraph) UnresolvedRefs() []Reference {
+	var refs []Reference
+	for _, ref := range g.refs {
+		if ref.Target == nil {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// UnreferencedIDs returns the IDs without references in document order.
+func (g *LinkGraph) UnreferencedIDs() []string {
+	var ids []string
+	for _, id := range g.order {
+		if len(g.to[id]) == 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// DuplicateIDs returns the IDs that are defined more than once, once for
+// each additional definition.
+func (g *LinkGraph) DuplicateIDs() []string {
+	return g.duplicates
+}
@@ -0,0 +1,481 @@
+package goxml
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// MergeConflict is a part of a document that has been changed differently in
+// both versions of a three-way merge.
+type MergeConflict struct {
+	// Path locates the conflict in the merged document, such as
+	// /book[1]/chapter[2] for conflicting changes to the children of the
+	// second chapter or /book[1]/@version for an attribute. The path of
+	// conflicts on the document level is /.
+	Path string
+	// Base, Mine and Theirs contain the conflicting nodes of each version as
+	// XML, or the attribute values. They are empty if the nodes are missing
+	// in a version.
+	Base   string
+	Mine   string
+	Theirs string
+}
+
+// Merge3 applies the changes from base to mine and the changes from base to
+// theirs to a copy of base. Changes to different attributes and to different
+// children of an element are combined. Where both versions change the same
+// part differently, the version of mine is kept and a MergeConflict is
+// reported. The documents are not changed.
+//
+// Children are matched by their contents first and then by their name, and
+// by the value of an id or xml:id attribute if present, so that elements
+// that are changed in one version are still recognized. Elements with an id
+// attribute are merged more reliably. A child that is unique by these keys
+// and that one version moves to another place among its siblings is moved
+// in the result, and the changes to it in the other version are merged. If
+// both versions move it to different places, the place in mine is kept and
+// a MergeConflict is reported.
+func Merge3(base, mine, theirs *XMLDocument) (*XMLDocument, []MergeConflict) {
+	m := &merger{}
+	doc := NewDocument()
+	doc.baseURI = mine.baseURI
+	doc.source = mine.source
+	m.mergeChildren(doc, base.children, mine.children, theirs.children, mine, theirs)
+	doc.renumber()
+	return doc, m.conflicts
+}
+
+type merger struct {
+	conflicts []MergeConflict
+}
+
+// mergeChildren merges the children of the corresponding nodes of the three
+// versions into dest. mine and theirs are the parents of the children in
+// these versions.
+func (m *merger) mergeChildren(dest XMLNode, b, mi, th []XMLNode, mine, theirs XMLNode) {
+	b, mi, th = m.applyMoves(dest, b, mi, th)
+	mm := matchNodes(b, mi)
+	mt := matchNodes(b, th)
+	i, j, k := 0, 0, 0
+	for {
+		// the next node of base that is kept in both versions
+		next := i
+		for next < len(b) && (mm[next] < 0 || mt[next] < 0) {
+			next++
+		}
+		jEnd, kEnd := len(mi), len(th)
+		if next < len(b) {
+			jEnd, kEnd = mm[next], mt[next]
+		}
+		m.mergeChunk(dest, b[i:next], mi[j:jEnd], th[k:kEnd], mine, theirs)
+		if next == len(b) {
+			return
+		}
+		m.mergeNode(dest, b[next], mi[jEnd], th[kEnd])
+		i, j, k = next+1, jEnd+1, kEnd+1
+	}
+}
+
+// applyMoves returns the children of the versions with the moves of unique
+// nodes in mine or theirs made in the other versions as well, so that the
+// moved nodes are matched in place and merged like the others. The slices
+// passed in are not changed.
+func (m *merger) applyMoves(dest XMLNode, b, mi, th []XMLNode) ([]XMLNode, []XMLNode, []XMLNode) {
+	kb, km, kt := mergeKeys(b), mergeKeys(mi), mergeKeys(th)
+	unique := make(map[string]bool)
+	for _, k := range kb {
+		_, seen := unique[k]
+		unique[k] = !seen
+	}
+	for _, keys := range [][]string{km, kt} {
+		seen := make(map[string]bool)
+		for _, k := range keys {
+			if seen[k] {
+				unique[k] = false
+			}
+			seen[k] = true
+		}
+	}
+	done := make(map[string]bool)
+	copied := false
+	for {
+		mm, mt := matchNodes(b, mi), matchNodes(b, th)
+		i, jm, jt := -1, -1, -1
+		for bi, k := range kb {
+			if !unique[k] || done[k] {
+				continue
+			}
+			jm, jt = indexOf(km, k), indexOf(kt, k)
+			if jm >= 0 && mm[bi] < 0 || jt >= 0 && mt[bi] < 0 {
+				i = bi
+				break
+			}
+		}
+		if i < 0 {
+			return b, mi, th
+		}
+		done[kb[i]] = true
+		if !copied {
+			b, mi, th = append([]XMLNode(nil), b...), append([]XMLNode(nil), mi...), append([]XMLNode(nil), th...)
+			copied = true
+		}
+		movedMine, movedTheirs := jm >= 0 && mm[i] < 0, jt >= 0 && mt[i] < 0
+		if movedMine {
+			if movedTheirs && movedPosition(kb, km, jm, unique) != movedPosition(kb, kt, jt, unique) {
+				m.conflicts = append(m.conflicts, MergeConflict{
+					Path:   mergePath(dest),
+					Base:   nodesXML(b[i : i+1]),
+					Mine:   nodesXML(mi[jm : jm+1]),
+					Theirs: nodesXML(th[jt : jt+1]),
+				})
+			}
+			// the place in mine
+			if jt >= 0 {
+				th, kt = moveAfter(th, kt, jt, movedPosition(kt, km, jm, unique))
+			}
+			b, kb = moveAfter(b, kb, i, movedPosition(kb, km, jm, unique))
+		} else {
+			if jm >= 0 {
+				mi, km = moveAfter(mi, km, jm, movedPosition(km, kt, jt, unique))
+			}
+			b, kb = moveAfter(b, kb, i, movedPosition(kb, kt, jt, unique))
+		}
+	}
+}
+
+// indexOf returns the index of k in keys, -1 if it is not there.
+func indexOf(keys []string, k string) int {
+	for i, key := range keys {
+		if key == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// movedPosition returns the index of the node in keys after which the node
+// at index j of the version with the keys moved is to be placed: after the
+// nearest unique node before it that keys has as well, -1 for the start.
+func movedPosition(keys, moved []string, j int, unique map[string]bool) int {
+	for p := j - 1; p >= 0; p-- {
+		if !unique[moved[p]] {
+			continue
+		}
+		if i := indexOf(keys, moved[p]); i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveAfter moves the node at index i in nodes, and its key, behind the
+// node at index after, to the start if after is -1.
+func moveAfter(nodes []XMLNode, keys []string, i, after int) ([]XMLNode, []string) {
+	n, k := nodes[i], keys[i]
+	nodes = append(nodes[:i], nodes[i+1:]...)
+	keys = append(keys[:i], keys[i+1:]...)
+	if after > i {
+		after--
+	}
+	nodes = append(nodes[:after+1], append([]XMLNode{n}, nodes[after+1:]...)...)
+	keys = append(keys[:after+1], append([]string{k}, keys[after+1:]...)...)
+	return nodes, keys
+}
+
+// mergeChunk adds the nodes between two nodes that are kept in all versions.
+func (m *merger) mergeChunk(dest XMLNode, b, mi, th []XMLNode, mine, theirs XMLNode) {
+	if len(b) == 0 && len(mi) == 0 && len(th) == 0 {
+		return
+	}
+	switch {
+	case sameNodes(b, mi):
+		copyNodes(dest, th, theirs)
+		return
+	case sameNodes(b, th), sameNodes(mi, th):
+	default:
+		m.conflicts = append(m.conflicts, MergeConflict{
+			Path:   mergePath(dest),
+			Base:   nodesXML(b),
+			Mine:   nodesXML(mi),
+			Theirs: nodesXML(th),
+		})
+	}
+	copyNodes(dest, mi, mine)
+}
+
+// mergeNode merges a node that is in all versions. Nodes other than elements
+// match only if they are equal.
+func (m *merger) mergeNode(dest XMLNode, b, mi, th XMLNode) {
+	mineElt, ok := mi.(*Element)
+	if !ok {
+		dest.(Appender).Append(mi)
+		return
+	}
+	baseElt, theirsElt := b.(*Element), th.(*Element)
+	elt := NewElement()
+	elt.Name = mineElt.Name
+	elt.Prefix = mineElt.Prefix
+	elt.Line, elt.Pos = mineElt.Line, mineElt.Pos
+	elt.source = mineElt.source
+	elt.leading = mineElt.leading
+	for prefix, ns := range mineElt.Namespaces {
+		elt.Namespaces[prefix] = ns
+	}
+	dest.(Appender).Append(elt)
+	m.mergeAttributes(elt, baseElt, mineElt, theirsElt)
+	m.mergeChildren(elt, baseElt.children, mineElt.children, theirsElt.children, mineElt, theirsElt)
+}
+
+func (m *merger) mergeAttributes(elt, b, mi, th *Element) {
+	type attrKey struct{ ns, name string }
+	find := func(e *Element, key attrKey) *Attribute {
+		for _, attr := range e.attributes {
+			if attr.Namespace == key.ns && attr.Name == key.name {
+				return attr
+			}
+		}
+		return nil
+	}
+	same := func(a, b *Attribute) bool {
+		return a == nil && b == nil || a != nil && b != nil && a.Value == b.Value
+	}
+	var keys []attrKey
+	seen := make(map[attrKey]bool)
+	for _, e := range []*Element{mi, th, b} {
+		for _, attr := range e.attributes {
+			if key := (attrKey{attr.Namespace, attr.Name}); !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	for _, key := range keys {
+		ab, am, at := find(b, key), find(mi, key), find(th, key)
+		keep := am
+		switch {
+		case same(am, at), same(ab, at):
+		case same(ab, am):
+			keep = at
+		default:
+			name := key.name
+			if am != nil && am.Prefix != "" {
+				name = am.Prefix + ":" + name
+			}
+			m.conflicts = append(m.conflicts, MergeConflict{
+				Path:   mergePath(elt) + "/@" + name,
+				Base:   attributeValue(ab),
+				Mine:   attributeValue(am),
+				Theirs: attributeValue(at),
+			})
+		}
+		if keep == nil {
+			continue
+		}
+		attr := *keep
+		if attr.Namespace != "" {
+			if ns, _ := elt.LookupNamespace(attr.Prefix); ns != attr.Namespace {
+				attr.Prefix = elt.attributePrefix(attr.Namespace)
+			}
+		}
+		elt.attributes = append(elt.attributes, &attr)
+	}
+}
+
+func attributeValue(attr *Attribute) string {
+	if attr == nil {
+		return ""
+	}
+	return attr.Value
+}
+
+// copyNodes appends copies of the nodes to dest. parent is the parent of
+// the nodes in their version.
+func copyNodes(dest XMLNode, nodes []XMLNode, parent XMLNode) {
+	var scope map[string]string
+	if elt, ok := parent.(*Element); ok {
+		scope = elt.InScopeNamespaces()
+	}
+	for _, n := range nodes {
+		if elt, ok := n.(*Element); ok {
+			cp := copyElement(elt)
+			cp.source = elt.Source()
+			rescope(cp, scope, dest)
+			n = cp
+		}
+		dest.(Appender).Append(n)
+	}
+}
+
+// mergePath returns the path of n in the merged document. The siblings after
+// n are not added yet, so the positions are final.
+func mergePath(n XMLNode) string {
+	elt, ok := n.(*Element)
+	if !ok {
+		return "/"
+	}
+	var steps []string
+	for ok {
+		pos := 1
+		if elt.Parent != nil {
+			for _, c := range elt.Parent.Children() {
+				if c == XMLNode(elt) {
+					break
+				}
+				if sib, isElt := c.(*Element); isElt && sib.Name == elt.Name && sib.Prefix == elt.Prefix {
+					pos++
+				}
+			}
+		}
+		steps = append(steps, elt.qualifiedName()+"["+strconv.Itoa(pos)+"]")
+		elt, ok = elt.Parent.(*Element)
+	}
+	var sb strings.Builder
+	for i := len(steps) - 1; i >= 0; i-- {
+		sb.WriteString("/")
+		sb.WriteString(steps[i])
+	}
+	return sb.String()
+}
+
+// nodesXML returns the XML representation of the nodes.
+func nodesXML(nodes []XMLNode) string {
+	var sb strings.Builder
+	xw := newXMLWriter(&sb)
+	xw.illegalChars = CharReplace
+	for _, n := range nodes {
+		if elt, ok := n.(*Element); ok {
+			xw.inherited = elt.inheritedNamespaces()
+		}
+		n.serialize(xw)
+	}
+	return sb.String()
+}
+
+// sameNodes reports whether a and b have the same contents.
+func sameNodes(a, b []XMLNode) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if fingerprint(a[i]) != fingerprint(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchNodes matches the nodes of b to the nodes of a, keeping their order.
+// The result contains the index in b for each node of a, or -1. Nodes with
+// the same contents are matched first, the remaining nodes by their name.
+func matchNodes(a, b []XMLNode) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	lcs(fingerprints(a), fingerprints(b), match, 0, 0)
+	ka, kb := mergeKeys(a), mergeKeys(b)
+	prevA, prevB := 0, 0
+	for i := 0; i <= len(a); i++ {
+		if i < len(a) && match[i] < 0 {
+			continue
+		}
+		endB := len(b)
+		if i < len(a) {
+			endB = match[i]
+		}
+		lcs(ka[prevA:i], kb[prevB:endB], match, prevA, prevB)
+		prevA, prevB = i+1, endB+1
+	}
+	return match
+}
+
+// lcs matches the elements of a longest common subsequence of a and b. The
+// match of a[i] is stored in match[offA+i] as offB+j.
+func lcs[T comparable](a, b []T, match []int, offA, offB int) {
+	// common prefix and suffix, which are most of a typical edit
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		match[offA] = offB
+		a, b = a[1:], b[1:]
+		offA++
+		offB++
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		match[offA+len(a)-1] = offB + len(b) - 1
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
+	// length[i][j] is the length of the LCS of a[i:] and b[j:]
+	w := len(b) + 1
+	length := make([]int, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				length[i*w+j] = length[(i+1)*w+j+1] + 1
+			case length[(i+1)*w+j] >= length[i*w+j+1]:
+				length[i*w+j] = length[(i+1)*w+j]
+			default:
+				length[i*w+j] = length[i*w+j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			match[offA+i] = offB + j
+			i++
+			j++
+		case length[(i+1)*w+j] >= length[i*w+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+}
+
+// mergeKeys returns the keys by which nodes with changed contents are
+// matched: the expanded name and the id of elements and the contents of
+// other nodes.
+func mergeKeys(nodes []XMLNode) []string {
+	keys := make([]string, len(nodes))
+	for i, n := range nodes {
+		switch t := n.(type) {
+		case *Element:
+			key := "e" + t.NamespaceURI() + " " + t.Name
+			for _, attr := range t.attributes {
+				if attr.Name == "id" && (attr.Namespace == "" || attr.Namespace == xmlNamespace) {
+					key += " " + attr.Value
+				}
+			}
+			keys[i] = key
+		case CharData:
+			keys[i] = "t" + t.Contents
+		case EntityRef:
+			keys[i] = "r" + t.Name
+		case Comment:
+			keys[i] = "c" + t.Contents
+		case ProcInst:
+			keys[i] = "p" + t.Target + " " + string(t.Inst)
+		}
+	}
+	return keys
+}
+
+func fingerprints(nodes []XMLNode) []uint64 {
+	fps := make([]uint64, len(nodes))
+	for i, n := range nodes {
+		fps[i] = fingerprint(n)
+	}
+	return fps
+}
+
+// fingerprint returns a hash of the contents of n. Namespace prefixes and
+// the order of attributes do not change the hash.
+func fingerprint(n XMLNode) uint64 {
+	h := fnv.New64a()
+	writeHash(h, n, HashOptions{})
+	return h.Sum64()
+}
@@ -0,0 +1,135 @@
+package goxml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name, base, mine, theirs, want string
+		conflicts                      []MergeConflict
+	}{
+		{
+			name:   "changes to different children",
+			base:   `<r><a/><b/></r>`,
+			mine:   `<r><a/><n/><b/></r>`,
+			theirs: `<r><a/><b/><o/></r>`,
+			want:   `<r><a /><n /><b /><o /></r>`,
+		},
+		{
+			name:   "moved in theirs, changed in mine",
+			base:   `<r><a id="1">x</a><b id="2">y</b><c id="3"/></r>`,
+			mine:   `<r><a id="1">X</a><b id="2">y</b><c id="3"/></r>`,
+			theirs: `<r><b id="2">y</b><a id="1">x</a></r>`,
+			want:   `<r><b id="2">y</b><a id="1">X</a></r>`,
+		},
+		{
+			name:   "moved with indentation",
+			base:   "<r>\n <a id=\"1\">x</a>\n <b id=\"2\">y</b>\n <c id=\"3\"/>\n</r>",
+			mine:   "<r>\n <a id=\"1\">X</a>\n <b id=\"2\">y</b>\n <c id=\"3\"/>\n</r>",
+			theirs: "<r>\n <b id=\"2\">y</b>\n <c id=\"3\"/>\n <a id=\"1\">x</a>\n</r>",
+			want:   "<r>\n <b id=\"2\">y</b>\n <c id=\"3\" />\n <a id=\"1\">X</a>\n</r>",
+		},
+		{
+			name:   "moved in both",
+			base:   `<r><a id="1"/><b id="2"/><c id="3"/></r>`,
+			mine:   `<r><b id="2"/><a id="1"/><c id="3"/></r>`,
+			theirs: `<r><b id="2"/><c id="3"/><a id="1"/></r>`,
+			want:   `<r><b id="2" /><a id="1" /><c id="3" /></r>`,
+			conflicts: []MergeConflict{
+				{Path: "/r[1]", Base: `<a id="1" />`, Mine: `<a id="1" />`, Theirs: `<a id="1" />`},
+			},
+		},
+		{
+			name:   "same move in both",
+			base:   `<r><a id="1"/><b id="2"/><c id="3"/></r>`,
+			mine:   `<r><b id="2"/><c id="3"/><a id="1"/></r>`,
+			theirs: `<r><b id="2"/><c id="3"/><a id="1"/></r>`,
+			want:   `<r><b id="2" /><c id="3" /><a id="1" /></r>`,
+		},
+		{
+			name:   "deleted in mine, moved in theirs",
+			base:   `<r><a id="1"/><b id="2"/><c id="3"/></r>`,
+			mine:   `<r><b id="2"/><c id="3"/></r>`,
+			theirs: `<r><b id="2"/><c id="3"/><a id="1"/></r>`,
+			want:   `<r><b id="2" /><c id="3" /></r>`,
+		},
+		{
+			name:   "deleted in mine, moved and changed in theirs",
+			base:   `<r><a id="1">x</a><b id="2"/><c id="3"/></r>`,
+			mine:   `<r><b id="2"/><c id="3"/></r>`,
+			theirs: `<r><b id="2"/><c id="3"/><a id="1">z</a></r>`,
+			want:   `<r><b id="2" /><c id="3" /></r>`,
+			conflicts: []MergeConflict{
+				{Path: "/r[1]", Base: `<a id="1">x</a>`, Theirs: `<a id="1">z</a>`},
+			},
+		},
+		{
+			name:   "deleted in theirs",
+			base:   `<r><a/><b>x</b></r>`,
+			mine:   `<r><a/><b>x</b></r>`,
+			theirs: `<r><a/></r>`,
+			want:   `<r><a /></r>`,
+		},
+		{
+			name:   "edit and edit",
+			base:   `<r><a>x</a><b>y</b></r>`,
+			mine:   `<r><a>m</a><b>y</b></r>`,
+			theirs: `<r><a>t</a><b>y2</b></r>`,
+			want:   `<r><a>m</a><b>y2</b></r>`,
+			conflicts: []MergeConflict{
+				{Path: "/r[1]/a[1]", Base: "x", Mine: "m", Theirs: "t"},
+			},
+		},
+		{
+			name:   "attributes",
+			base:   `<r v="1" w="1" x="1"/>`,
+			mine:   `<r v="2" w="1"/>`,
+			theirs: `<r v="3" w="2" x="1"/>`,
+			want:   `<r v="2" w="2" />`,
+			conflicts: []MergeConflict{
+				{Path: "/r[1]/@v", Base: "1", Mine: "2", Theirs: "3"},
+			},
+		},
+	}
+	parse := func(s string) *XMLDocument {
+		doc, err := Parse(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	for _, tc := range tests {
+		doc, conflicts := Merge3(parse(tc.base), parse(tc.mine), parse(tc.theirs))
+		if got := doc.ToXML(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+		if len(conflicts) != len(tc.conflicts) {
+			t.Errorf("%s: conflicts %+v, want %+v", tc.name, conflicts, tc.conflicts)
+			continue
+		}
+		for i, c := range conflicts {
+			if c != tc.conflicts[i] {
+				t.Errorf("%s: conflict %+v, want %+v", tc.name, c, tc.conflicts[i])
+			}
+		}
+		checkIDs(t, doc)
+	}
+}
+
+func TestMerge3KeepsInputs(t *testing.T) {
+	base, _ := parseRoot(t, `<r><a id="1"/><b id="2"/></r>`)
+	mine, _ := parseRoot(t, `<r><b id="2"/><a id="1"/></r>`)
+	theirs, _ := parseRoot(t, `<r><a id="1"/><b id="2">t</b></r>`)
+	before := []string{base.ToXML(), mine.ToXML(), theirs.ToXML()}
+	doc, conflicts := Merge3(base, mine, theirs)
+	if got, want := doc.ToXML(), `<r><b id="2">t</b><a id="1" /></r>`; got != want || len(conflicts) > 0 {
+		t.Errorf("got %s %+v, want %s", got, conflicts, want)
+	}
+	for i, d := range []*XMLDocument{base, mine, theirs} {
+		if got := d.ToXML(); got != before[i] {
+			t.Errorf("Merge3 changed input %d to %s", i, got)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package goxml
+
+import "os"
+
+// ParseFileMmap reads the file at path and parses it. Memory mapping is not
+// available on this platform, so the file is read into memory instead.
+func ParseFileMmap(path string, opts ...ParseOption) (*XMLDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(data, append([]ParseOption{WithSourceName(path)}, opts...)...)
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package goxml
+
+import (
+	"os"
+	"syscall"
+)
+
+// ParseFileMmap maps the file at path into memory and parses it from there.
+// All strings in the returned document are copies, so the mapping is removed
+// before ParseFileMmap returns.
+func ParseFileMmap(path string, opts ...ParseOption) (*XMLDocument, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		return ParseBytes(nil, append([]ParseOption{WithSourceName(path)}, opts...)...)
+	}
+	if int64(int(size)) != size {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: syscall.EFBIG}
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	defer syscall.Munmap(data)
+	return ParseBytes(data, append([]ParseOption{WithSourceName(path)}, opts...)...)
+}
@@ -0,0 +1,238 @@
+package goxml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The methods in this file change the structure of a document. Nodes that
+// are inserted into a document get new IDs, and so do the nodes after them in
+// document order, so that SortByDocumentOrder keeps working. This takes time
+// proportional to the number of nodes after the insertion point, appending
+// near the end of a document is cheap. The user data stays with its nodes.
+// The changes are reported to the observers of the document like those of
+// Append and recorded for Undo.
+
+// documentOf returns the document n belongs to, nil if there is none.
+func documentOf(n XMLNode) *XMLDocument {
+	for {
+		switch t := n.(type) {
+		case *XMLDocument:
+			return t
+		case *Element:
+			n = t.Parent
+		default:
+			return nil
+		}
+	}
+}
+
+// isAncestorOrSelf reports whether n is elt or one of its descendants.
+func (elt *Element) isAncestorOrSelf(n XMLNode) bool {
+	for n != nil {
+		if n == XMLNode(elt) {
+			return true
+		}
+		cur, ok := n.(*Element)
+		if !ok {
+			return false
+		}
+		n = cur.Parent
+	}
+	return false
+}
+
+// childIndex returns the index of n in the children of parent, -1 if it is
+// not a child of parent.
+func childIndex(parent XMLNode, n XMLNode) int {
+	for i, c := range parent.Children() {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertChild inserts n at index into the children of parent, which is an
+// element or a document. An element n is removed from its old place first.
+func insertChild(parent XMLNode, index int, n XMLNode) error {
+	switch parent.(type) {
+	case *Element, *XMLDocument:
+	default:
+		return errors.New("insertion into a node without children")
+	}
+	// the index is checked before an element is taken from its old place,
+	// so that a failed insertion does not change the tree
+	if index < 0 || index > len(parent.Children()) {
+		return fmt.Errorf("index %d out of range", index)
+	}
+	switch t := n.(type) {
+	case nil:
+		return errors.New("insertion of nil")
+	case Attribute:
+		return errors.New("insertion of an attribute as a child")
+	case *XMLDocument:
+		return errors.New("insertion of a document as a child")
+	case *Element:
+		if t.isAncestorOrSelf(parent) {
+			return fmt.Errorf("insertion of <%s> into itself", t.qualifiedName())
+		}
+		if t.Parent != nil {
+			if t.Parent == parent && childIndex(parent, t) < index {
+				index--
+			}
+			t.Remove()
+		}
+	}
+	var doc *XMLDocument
+	switch p := parent.(type) {
+	case *Element:
+		doc = p.invalidate()
+		p.children = append(p.children[:index:index], append([]XMLNode{n}, p.children[index:]...)...)
+	case *XMLDocument:
+		doc = p
+		p.changeChildren()
+		p.children = append(p.children[:index:index], append([]XMLNode{n}, p.children[index:]...)...)
+	}
+	n.setParent(parent)
+	if doc != nil {
+		doc.renumberFrom(parent, index)
+		n = parent.Children()[index]
+	}
+	doc.notify(MutationEvent{Type: NodeAppended, Target: parent, Node: n, Index: index})
+	return nil
+}
+
+// removeChild removes the child at index from parent and returns it.
+func removeChild(parent XMLNode, index int) (XMLNode, error) {
+	children := parent.Children()
+	if index < 0 || index >= len(children) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	n := children[index]
+	var doc *XMLDocument
+	switch p := parent.(type) {
+	case *Element:
+		doc = p.invalidate()
+		p.children = append(p.children[:index:index], p.children[index+1:]...)
+	case *XMLDocument:
+		doc = p
+		p.changeChildren()
+		p.children = append(p.children[:index:index], p.children[index+1:]...)
+	default:
+		return nil, errors.New("removal from a node without children")
+	}
+	if elt, ok := n.(*Element); ok {
+		elt.Parent = nil
+	}
+	doc.notify(MutationEvent{Type: NodeRemoved, Target: parent, Node: n, Index: index})
+	return n, nil
+}
+
+// InsertChild inserts n before the child at index, or after the last child
+// if index is the number of children. An element that is part of a tree is
+// moved from its old place. Attributes and documents cannot be inserted.
+func (elt *Element) InsertChild(index int, n XMLNode) error {
+	return insertChild(elt, index, n)
+}
+
+// RemoveChild removes the child at index, which can be a text node or any
+// other node, and returns it.
+func (elt *Element) RemoveChild(index int) (XMLNode, error) {
+	return removeChild(elt, index)
+}
+
+// InsertChild inserts n before the child of the document at index, see
+// Element.InsertChild.
+func (xr *XMLDocument) InsertChild(index int, n XMLNode) error {
+	return insertChild(xr, index, n)
+}
+
+// RemoveChild removes the child of the document at index and returns it.
+func (xr *XMLDocument) RemoveChild(index int) (XMLNode, error) {
+	return removeChild(xr, index)
+}
+
+// Remove removes the element from its parent. It does nothing for an
+// element without parent.
+func (elt *Element) Remove() {
+	if elt.Parent == nil {
+		return
+	}
+	if i := childIndex(elt.Parent, elt); i >= 0 {
+		removeChild(elt.Parent, i)
+		return
+	}
+	elt.Parent = nil
+}
+
+// InsertBefore inserts n as the sibling before elt. It returns an error if
+// elt has no parent.
+func (elt *Element) InsertBefore(n XMLNode) error {
+	return elt.insertSibling(n, 0)
+}
+
+// InsertAfter inserts n as the sibling after elt. It returns an error if elt
+// has no parent.
+func (elt *Element) InsertAfter(n XMLNode) error {
+	return elt.insertSibling(n, 1)
+}
+
+func (elt *Element) insertSibling(n XMLNode, offset int) error {
+	parent := elt.Parent
+	if parent == nil {
+		return fmt.Errorf("insertion next to <%s>, which has no parent", elt.qualifiedName())
+	}
+	if cld, ok := n.(*Element); ok {
+		if cld.isAncestorOrSelf(elt) {
+			return fmt.Errorf("insertion of <%s> next to itself", cld.qualifiedName())
+		}
+		if cld.Parent != nil {
+			// the index of elt can change
+			cld.Remove()
+		}
+	}
+	return insertChild(parent, childIndex(parent, elt)+offset, n)
+}
+
+// ReplaceWith puts n in the place of elt, which is removed from its parent.
+// It returns an error if elt has no parent.
+func (elt *Element) ReplaceWith(n XMLNode) error {
+	if n == XMLNode(elt) {
+		return nil
+	}
+	if err := elt.InsertAfter(n); err != nil {
+		return err
+	}
+	elt.Remove()
+	return nil
+}
+
+// Clone returns a deep copy of the element without parent. The nodes of the
+// copy get new IDs from the document of elt, if it belongs to one. The
+// namespace bindings the element inherits from its ancestors are declared on
+// the copy, so that its prefixes keep their meaning wherever it is used.
+func (elt *Element) Clone() *Element {
+	cp := copyElement(elt)
+	rescope(cp, elt.inheritedNamespaces(), nil)
+	if doc := documentOf(elt); doc != nil {
+		renumberChildren(doc, []XMLNode{cp}, nil)
+	}
+	return cp
+}
+
+// Clone returns a deep copy of the document with a new document ID. The
+// user data, undo history, observers and versions are not copied. The copy
+// is not frozen.
+func (xr *XMLDocument) Clone() *XMLDocument {
+	doc := NewDocument()
+	doc.baseURI = xr.baseURI
+	doc.source = xr.source
+	doc.doctype = xr.doctype
+	doc.doctypeIndex = xr.doctypeIndex
+	for _, c := range xr.children {
+		doc.children = append(doc.children, snapshotNode(c, doc))
+	}
+	doc.renumber()
+	return doc
+}
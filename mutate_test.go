@@ -0,0 +1,123 @@
+package goxml
+
+import (
+	"strings"
+	"testing"
+)
+
+func parseRoot(t *testing.T, s string) (*XMLDocument, *Element) {
+	t.Helper()
+	doc, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := doc.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc, root
+}
+
+func TestInsertChildIDs(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a/>t<b x="1"/>u</r>`)
+	b := r.children[2]
+	doc.SetUserData(b, "k", "b")
+	if err := r.InsertChild(0, &Element{Name: "n"}); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, doc)
+	if got := doc.UserData(b, "k"); got != "b" {
+		t.Errorf("user data after InsertChild = %v, want b", got)
+	}
+	if err := r.InsertChild(len(r.children), &Element{Name: "end"}); err != nil {
+		t.Fatal(err)
+	}
+	checkIDs(t, doc)
+}
+
+func TestInsertChildOutOfRange(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a/><b/></r>`)
+	b := r.children[1].(*Element)
+	err := r.InsertChild(99, b)
+	if err == nil || !strings.Contains(err.Error(), "index 99") {
+		t.Errorf("InsertChild(99, b) = %v, want an error for index 99", err)
+	}
+	if b.Parent != XMLNode(r) || len(r.children) != 2 {
+		t.Errorf("failed InsertChild changed the tree: %s", doc.ToXML())
+	}
+}
+
+func TestAppendIDs(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a/><b>t</b></r>`)
+	a := r.children[0].(*Element)
+	n := &Element{Name: "n"}
+	n.Append(&Element{Name: "m"})
+	a.Append(n)
+	a.Append(CharData{Contents: "x"})
+	doc.Append(Comment{Contents: "c"})
+	checkIDs(t, doc)
+	if n.ID == 0 || n.children[0].getID() == 0 {
+		t.Error("the appended nodes have no IDs")
+	}
+}
+
+func TestMutations(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a/><b/><c/></r>`)
+	a, b, c := r.children[0].(*Element), r.children[1].(*Element), r.children[2].(*Element)
+	if err := a.InsertBefore(CharData{Contents: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.InsertAfter(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReplaceWith(&Element{Name: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.ToXML(), `<r>x<a /><c /><d /></r>`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	checkIDs(t, doc)
+	if b.Parent != nil {
+		t.Error("the replaced element keeps its parent")
+	}
+	a.Remove()
+	if got, want := doc.ToXML(), `<r>x<c /><d /></r>`; got != want {
+		t.Errorf("after Remove: %s, want %s", got, want)
+	}
+	if err := b.InsertAfter(a); err == nil {
+		t.Error("InsertAfter succeeds next to an element without parent")
+	}
+	if err := c.InsertChild(0, r); err == nil {
+		t.Error("an element can be inserted into its descendant")
+	}
+}
+
+func TestCloneNamespaces(t *testing.T) {
+	doc, r := parseRoot(t, `<r xmlns="d" xmlns:p="P" xmlns:q="Q"><p:a q:x="1"><b/></p:a><c xmlns=""><p:e/></c></r>`)
+	a := r.children[0].(*Element)
+	cp := a.Clone()
+	if cp.Parent != nil || cp.ID == a.ID {
+		t.Errorf("the clone has the parent %v and the ID %d of the original", cp.Parent, cp.ID)
+	}
+	want := `<p:a xmlns="d" xmlns:p="P" xmlns:q="Q" q:x="1"><b /></p:a>`
+	if got := cp.ToXML(); got != want {
+		t.Errorf("clone of <p:a>: %s, want %s", got, want)
+	}
+	// the clone is a complete document
+	clone, err := Parse(strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := clone.Root()
+	if got := root.FirstChildElement().NamespaceURI(); got != "d" {
+		t.Errorf("namespace of <b> in the clone = %q, want d", got)
+	}
+	e := r.children[1].(*Element).children[0].(*Element)
+	if got, want := e.Clone().ToXML(), `<p:e xmlns:p="P" xmlns:q="Q" />`; got != want {
+		t.Errorf("clone of <p:e>: %s, want %s", got, want)
+	}
+	// the original is unchanged
+	if got := doc.ToXML(); !strings.HasPrefix(got, `<r xmlns="d" xmlns:p="P" xmlns:q="Q"><p:a q:x="1">`) {
+		t.Errorf("Clone changed the document: %s", got)
+	}
+}
@@ -0,0 +1,143 @@
+package goxml
+
+import "sort"
+
+// NamespaceInfo describes how a namespace is declared and used in a
+// document.
+type NamespaceInfo struct {
+	URI string
+	// Prefixes contains the prefixes bound to the namespace in the order of
+	// their first declaration, the empty prefix stands for the default
+	// namespace.
+	Prefixes []string
+	// Declarations contains the declarations of the namespace in document
+	// order.
+	Declarations []*NamespaceDeclaration
+	// Elements and Attributes count the elements and attributes in the
+	// namespace.
+	Elements   int
+	Attributes int
+}
+
+// NamespaceDeclaration is a namespace declaration on an element.
+type NamespaceDeclaration struct {
+	// Element is the element with the declaration, its Line is the
+	// location in the source.
+	Element *Element
+	Prefix  string
+	URI     string
+	// Uses counts the names of elements and attributes that get their
+	// namespace from this declaration.
+	Uses int
+	// Redundant is set if an ancestor already binds the prefix to the same
+	// namespace.
+	Redundant bool
+	// Conflict is set if the prefix is bound to another namespace elsewhere
+	// in the document.
+	Conflict bool
+}
+
+// Unused reports whether no element or attribute name uses the declaration.
+// Prefixes can also be used in attribute values and text, for example in
+// QNames such as xsi:type="xs:string", which are not counted, so a
+// declaration should not be removed on this alone if the vocabulary uses
+// QNames in content.
+func (nd *NamespaceDeclaration) Unused() bool {
+	return nd.Uses == 0
+}
+
+// Namespaces returns the namespaces declared or used in the document in the
+// order of their first occurrence. Undeclarations such as xmlns="" are not
+// listed. Names with an unbound prefix are not counted. The xml namespace is
+// listed if it is used.
+func (xr *XMLDocument) Namespaces() []*NamespaceInfo {
+	var infos []*NamespaceInfo
+	byURI := make(map[string]*NamespaceInfo)
+	info := func(uri string) *NamespaceInfo {
+		ni, ok := byURI[uri]
+		if !ok {
+			ni = &NamespaceInfo{URI: uri}
+			byURI[uri] = ni
+			infos = append(infos, ni)
+		}
+		return ni
+	}
+	// the URIs bound to each prefix, for the conflicts
+	bound := make(map[string][]*NamespaceDeclaration)
+	var visit func(elt *Element, scope map[string]*NamespaceDeclaration)
+	visit = func(elt *Element, scope map[string]*NamespaceDeclaration) {
+		if len(elt.Namespaces) > 0 {
+			inner := make(map[string]*NamespaceDeclaration, len(scope)+len(elt.Namespaces))
+			for prefix, nd := range scope {
+				inner[prefix] = nd
+			}
+			prefixes := make([]string, 0, len(elt.Namespaces))
+			for prefix := range elt.Namespaces {
+				prefixes = append(prefixes, prefix)
+			}
+			sort.Strings(prefixes)
+			for _, prefix := range prefixes {
+				nd := &NamespaceDeclaration{Element: elt, Prefix: prefix, URI: elt.Namespaces[prefix]}
+				if outer, ok := scope[prefix]; ok && outer.URI == nd.URI {
+					nd.Redundant = true
+				}
+				inner[prefix] = nd
+				if nd.URI == "" {
+					continue
+				}
+				ni := info(nd.URI)
+				if !containsName(ni.Prefixes, prefix) {
+					ni.Prefixes = append(ni.Prefixes, prefix)
+				}
+				ni.Declarations = append(ni.Declarations, nd)
+				bound[prefix] = append(bound[prefix], nd)
+			}
+			scope = inner
+		}
+		if nd, ok := scope[elt.Prefix]; ok && nd.URI != "" {
+			nd.Uses++
+			info(nd.URI).Elements++
+		} else if ns := elt.NamespaceURI(); ns != "" {
+			// a binding inherited from outside of the document
+			info(ns).Elements++
+		}
+		for _, attr := range elt.attributes {
+			if attr.Prefix == "" {
+				continue
+			}
+			if nd, ok := scope[attr.Prefix]; ok && nd.URI != "" {
+				nd.Uses++
+				info(nd.URI).Attributes++
+			} else if attr.Prefix == "xml" {
+				ni := info(xmlNamespace)
+				if len(ni.Prefixes) == 0 {
+					ni.Prefixes = append(ni.Prefixes, "xml")
+				}
+				ni.Attributes++
+			} else if ns, ok := elt.LookupNamespace(attr.Prefix); ok {
+				info(ns).Attributes++
+			}
+		}
+		for _, c := range elt.children {
+			if cld, ok := c.(*Element); ok {
+				visit(cld, scope)
+			}
+		}
+	}
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			visit(elt, nil)
+		}
+	}
+	for _, decls := range bound {
+		for _, nd := range decls {
+			if nd.URI != decls[0].URI {
+				for _, other := range decls {
+					other.Conflict = true
+				}
+				break
+			}
+		}
+	}
+	return infos
+}
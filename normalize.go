@@ -0,0 +1,165 @@
+package goxml
+
+import (
+	"sort"
+	"strings"
+)
+
+// NormalizeProfile selects the changes Normalize makes to the serialized
+// form. The namespace declarations are always sorted by prefix, since their
+// order is not kept otherwise.
+type NormalizeProfile struct {
+	// SortAttributes writes the attributes sorted by their qualified names.
+	SortAttributes bool
+	// CollapseWhitespace replaces each run of white space in text by a
+	// single space and drops the white space between elements in element
+	// only content. The text of elements with xml:space="preserve" is kept.
+	CollapseWhitespace bool
+	// ExpandEmpty writes empty elements with a start and an end tag like
+	// <br></br> instead of <br />.
+	ExpandEmpty bool
+	// StripComments and StripProcInsts leave out comments and processing
+	// instructions.
+	StripComments  bool
+	StripProcInsts bool
+}
+
+var (
+	// GoldenProfile is for golden files in tests, which should not change
+	// when the attribute order or the indentation of the output change.
+	GoldenProfile = NormalizeProfile{SortAttributes: true, CollapseWhitespace: true, ExpandEmpty: true}
+	// CacheKeyProfile is for cache keys, which should be the same for
+	// documents with the same contents. Comments and processing
+	// instructions are dropped as well.
+	CacheKeyProfile = NormalizeProfile{SortAttributes: true, CollapseWhitespace: true, ExpandEmpty: true, StripComments: true, StripProcInsts: true}
+)
+
+// Normalize returns the document or element n as XML in a stable form
+// selected by profile, for golden files and cache keys. It is much cheaper
+// than canonical XML, which also rewrites the namespace declarations and
+// character references, and the result is still readable. Characters not
+// allowed in XML are replaced by U+FFFD.
+func Normalize(n XMLNode, profile NormalizeProfile) string {
+	var sb strings.Builder
+	xw := newXMLWriter(&sb)
+	xw.illegalChars = CharReplace
+	nw := normalizer{xw: xw, profile: profile}
+	switch t := n.(type) {
+	case *Element:
+		xw.inherited = t.inheritedNamespaces()
+		nw.element(t, false)
+	default:
+		nw.children(n.Children(), true, false)
+	}
+	return sb.String()
+}
+
+type normalizer struct {
+	xw      *xmlWriter
+	profile NormalizeProfile
+}
+
+// children writes the nodes. collapse drops the white space between the
+// nodes, preserve keeps the text unchanged.
+func (nw *normalizer) children(nodes []XMLNode, collapse, preserve bool) {
+	// text is collected until the next written markup, so that the text
+	// around a dropped comment is joined
+	var text strings.Builder
+	flush := func() {
+		s := text.String()
+		text.Reset()
+		if nw.profile.CollapseWhitespace && !preserve {
+			if collapse && isSpace(s) {
+				return
+			}
+			s = collapseSpace(s)
+		}
+		nw.xw.writeCharData(s)
+	}
+	for _, c := range nodes {
+		switch t := c.(type) {
+		case CharData:
+			text.WriteString(t.Contents)
+			continue
+		case Comment:
+			if nw.profile.StripComments {
+				continue
+			}
+		case ProcInst:
+			if nw.profile.StripProcInsts {
+				continue
+			}
+		}
+		flush()
+		if elt, ok := c.(*Element); ok {
+			nw.element(elt, preserve)
+		} else {
+			c.serialize(nw.xw)
+		}
+	}
+	flush()
+}
+
+func (nw *normalizer) element(elt *Element, preserve bool) {
+	xw := nw.xw
+	xw.writeString("<")
+	elt.writeName(xw)
+	namespaces := make(map[string]string, len(elt.Namespaces)+len(xw.inherited))
+	for prefix, ns := range xw.inherited {
+		namespaces[prefix] = ns
+	}
+	xw.inherited = nil
+	for prefix, ns := range elt.Namespaces {
+		namespaces[prefix] = ns
+	}
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		xw.writeString(" ")
+		xw.writeNamespace(prefix, namespaces[prefix])
+	}
+	attributes := elt.attributes
+	if nw.profile.SortAttributes {
+		attributes = append([]*Attribute(nil), attributes...)
+		sort.SliceStable(attributes, func(i, j int) bool {
+			return qualifiedName(attributes[i].Prefix, attributes[i].Name) < qualifiedName(attributes[j].Prefix, attributes[j].Name)
+		})
+	}
+	for _, attr := range attributes {
+		xw.writeString(" ", qualifiedName(attr.Prefix, attr.Name), "=\"")
+		xw.writeAttributeValue(attr.Value)
+		xw.writeString("\"")
+	}
+	if len(elt.children) == 0 && !nw.profile.ExpandEmpty {
+		xw.writeString(" />")
+		return
+	}
+	xw.writeString(">")
+	preserve = preserve || elt.spacePreserved()
+	nw.children(elt.children, elt.elementOnly(), preserve)
+	elt.writeEndTag(xw)
+}
+
+// collapseSpace replaces each run of white space in s by a single space.
+func collapseSpace(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if isXMLSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.WriteRune(r)
+	}
+	if space {
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
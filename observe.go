@@ -0,0 +1,102 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// MutationType is the kind of change reported in a MutationEvent.
+type MutationType int
+
+const (
+	// NodeAppended reports that Node has been appended to the children of
+	// Target.
+	NodeAppended MutationType = iota
+	// NodeRemoved reports that Node has been removed from the children of
+	// Target.
+	NodeRemoved
+	// AttributeChanged reports that the attribute Name of Target has been
+	// set from OldValue to NewValue. OldValue is empty for new attributes.
+	AttributeChanged
+	// TextChanged reports that the text node Node of Target has changed
+	// from OldValue to NewValue, for example when text is appended to it.
+	TextChanged
+)
+
+var mutationTypeNames = []string{"append", "remove", "attribute", "text"}
+
+func (t MutationType) String() string {
+	if t >= 0 && int(t) < len(mutationTypeNames) {
+		return mutationTypeNames[t]
+	}
+	return fmt.Sprintf("MutationType(%d)", int(t))
+}
+
+// MarshalText returns the name of the mutation type, such as append, so that
+// journal entries are readable in JSON.
+func (t MutationType) MarshalText() ([]byte, error) {
+	if t < 0 || int(t) >= len(mutationTypeNames) {
+		return nil, fmt.Errorf("unknown mutation type %d", int(t))
+	}
+	return []byte(mutationTypeNames[t]), nil
+}
+
+// UnmarshalText sets t to the mutation type with the name text.
+func (t *MutationType) UnmarshalText(text []byte) error {
+	for i, name := range mutationTypeNames {
+		if name == string(text) {
+			*t = MutationType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mutation type %q", text)
+}
+
+// MutationEvent describes a change of a document.
+type MutationEvent struct {
+	Type MutationType
+	// Target is the element or document that has changed.
+	Target XMLNode
+	// Node is the appended, removed or changed child.
+	Node XMLNode
+	// Index is the position of Node in the children of Target, for removed
+	// nodes the position before the removal.
+	Index int
+	// Name is the name of the changed attribute, the space is the
+	// namespace URI.
+	Name     xml.Name
+	OldValue string
+	NewValue string
+}
+
+type observer struct {
+	f func(MutationEvent)
+}
+
+// Observe calls f after each change of the document made through the methods
+// of its nodes, such as Append and SetAttribute. Changes to the exported
+// fields of the nodes are not noticed. Elements report their changes to the
+// document they belong to at the time of the change, detached elements report
+// nothing. The returned function stops the notifications.
+func (xr *XMLDocument) Observe(f func(MutationEvent)) (cancel func()) {
+	o := &observer{f: f}
+	xr.observers = append(xr.observers, o)
+	return func() {
+		for i, cur := range xr.observers {
+			if cur == o {
+				xr.observers = append(xr.observers[:i:i], xr.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify passes ev to the observers of the document. xr may be nil.
+func (xr *XMLDocument) notify(ev MutationEvent) {
+	if xr == nil {
+		return
+	}
+	for _, o := range xr.observers {
+		o.f(ev)
+	}
+}
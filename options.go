@@ -0,0 +1,454 @@
+package goxml
+
+import "io"
+
+// ParseOption changes the behavior of Parse.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	arenaSize     int
+	keepOnly      []string
+	sourceName    string
+	collectErrors bool
+	repair        bool
+	illegalChars  CharPolicy
+	normalize     bool
+	defaultNS     string
+	attributeNS   AttributeNamespace
+	baseURI       string
+	entityRefs    bool
+	invalidUTF8   UTF8Policy
+	keepCR        bool
+	rawAttributes bool
+	attachComment bool
+	tagLayout     bool
+	dtdDefaults   bool
+	maxDepth      int
+	maxSize       int64
+	noDoctype     bool
+	inherited     map[string]string
+	progress      func(bytesRead int64, nodes int)
+	charsetReader func(charset string, input io.Reader) (io.Reader, error)
+	entities      map[string]string
+	noComments    bool
+	noProcInsts   bool
+	noWhitespace  bool
+	cdata         bool
+}
+
+// WithArena lets Parse allocate the elements and attribute lists of a
+// document in blocks of size elements. This reduces the number of allocations
+// considerably when parsing many documents. The memory of a block is released
+// when none of its elements is referenced anymore, so keeping a single element
+// alive keeps its block alive.
+func WithArena(size int) ParseOption {
+	return func(po *parseOptions) {
+		po.arenaSize = size
+	}
+}
+
+// WithKeepOnly restricts Parse to the elements matching one of the paths and
+// their subtrees. A path is a list of local names separated by slashes,
+// starting at the root element, for example "/catalog/book". The name * matches
+// any element. The ancestors of the matching elements are kept without their
+// text content, all other elements are skipped without being built.
+func WithKeepOnly(paths ...string) ParseOption {
+	return func(po *parseOptions) {
+		po.keepOnly = append(po.keepOnly, paths...)
+	}
+}
+
+// WithSourceName sets the name of the input, such as a file name, which is
+// reported in a ParseError and returned by the Source methods of the
+// document and its elements.
+func WithSourceName(name string) ParseOption {
+	return func(po *parseOptions) {
+		po.sourceName = name
+	}
+}
+
+// WithBaseURI sets the base URI of the document, against which xml:base
+// attributes and relative references are resolved.
+func WithBaseURI(uri string) ParseOption {
+	return func(po *parseOptions) {
+		po.baseURI = uri
+	}
+}
+
+// CharPolicy determines what happens to characters that are not allowed in
+// XML 1.0, such as most control characters below U+0020. Invalid UTF-8
+// sequences are treated the same way.
+type CharPolicy int
+
+const (
+	// CharError rejects the characters with an error.
+	CharError CharPolicy = iota
+	// CharReplace replaces each character by U+FFFD.
+	CharReplace
+	// CharStrip removes the characters.
+	CharStrip
+)
+
+// WithIllegalChars sets the policy for characters not allowed in XML 1.0 in
+// the input. This includes character references such as &#1;. The default
+// is CharError. With WithCollectErrors, CharError behaves like CharReplace.
+func WithIllegalChars(policy CharPolicy) ParseOption {
+	return func(po *parseOptions) {
+		po.illegalChars = policy
+	}
+}
+
+// UTF8Policy determines what happens to byte sequences in the input that are
+// not valid UTF-8.
+type UTF8Policy int
+
+const (
+	// UTF8Error treats invalid sequences like characters not allowed in XML,
+	// see WithIllegalChars. With the default CharError, Parse fails with a
+	// ParseError that has the position of the sequence.
+	UTF8Error UTF8Policy = iota
+	// UTF8Replace replaces each invalid byte by U+FFFD.
+	UTF8Replace
+	// UTF8Windows1252 decodes each invalid byte as windows-1252. This
+	// repairs documents that mix UTF-8 with a legacy encoding, as often
+	// happens when text is pasted from other applications.
+	UTF8Windows1252
+)
+
+// WithInvalidUTF8 sets the policy for invalid UTF-8 in the input. The default
+// is UTF8Error. Input in other encodings is not affected, it has to be
+// converted to UTF-8 first.
+func WithInvalidUTF8(policy UTF8Policy) ParseOption {
+	return func(po *parseOptions) {
+		po.invalidUTF8 = policy
+	}
+}
+
+// WithCollectErrors makes Parse continue after recoverable problems and
+// collect them in the Errors method of the document. Recoverable problems are
+// undefined entity references, which are kept as text, characters not
+// allowed in XML and invalid UTF-8, which are replaced by U+FFFD, duplicate
+// attributes, where the first one is kept, and invalid namespace
+// declarations, which are ignored. Other syntax errors stop
+// parsing. In this mode, Parse returns the partial document together with the
+// error.
+func WithCollectErrors() ParseOption {
+	return func(po *parseOptions) {
+		po.collectErrors = true
+	}
+}
+
+// WithRepair makes Parse repair mismatched end tags instead of failing. An
+// end tag that does not match the innermost open element closes all open
+// elements up to the matching one, an end tag without any open element of
+// that name is ignored and elements still open at the end of the input are
+// closed. Each repair is recorded in the Errors method of the document.
+func WithRepair() ParseOption {
+	return func(po *parseOptions) {
+		po.repair = true
+	}
+}
+
+// WithNormalizeAttributes makes Parse normalize attribute values as the XML
+// specification requires for attributes of type CDATA: each literal tab,
+// newline and carriage return, and each CR LF pair, becomes a space, while
+// characters written as references such as &#10; are kept. The value as
+// written in the source is available in Attribute.RawValue.
+func WithNormalizeAttributes() ParseOption {
+	return func(po *parseOptions) {
+		po.normalize = true
+	}
+}
+
+// WithRawAttributes makes Parse record the attribute values as written in the
+// source in Attribute.RawValue and their quote characters in
+// Attribute.Quote, so that SerializeOptions.RawAttributes can write
+// unchanged attributes exactly as they were read.
+func WithRawAttributes() ParseOption {
+	return func(po *parseOptions) {
+		po.rawAttributes = true
+	}
+}
+
+// WithAttachedComments makes Parse attach the comments directly before an
+// element, with only white space in between, to the element instead of
+// adding them to its parent, see Element.LeadingComments. Moving the element
+// to another place moves its comments along. Comments at the end of the
+// contents of an element stay children of the element.
+func WithAttachedComments() ParseOption {
+	return func(po *parseOptions) {
+		po.attachComment = true
+	}
+}
+
+// WithTagLayout makes Parse record the white space between the attributes
+// of each start tag and before its closing bracket, and whether an element
+// without content was written as an empty element tag, so that
+// SerializeOptions.KeepTagLayout can write start tags formatted by hand as
+// they were read. Together with WithRawAttributes and
+// WithoutLineEndNormalization, unchanged parts of a document are written as
+// a facsimile of the source.
+func WithTagLayout() ParseOption {
+	return func(po *parseOptions) {
+		po.tagLayout = true
+	}
+}
+
+// WithEntityRefs makes Parse keep the character references and the
+// references to entities other than the predefined ones in the text of
+// elements as EntityRef nodes instead of expanding them, so that they are
+// written unchanged when the document is serialized. Entities do not need to
+// be declared, &copy; is kept even though its replacement text is not known.
+// References in attribute values are expanded as usual.
+func WithEntityRefs() ParseOption {
+	return func(po *parseOptions) {
+		po.entityRefs = true
+	}
+}
+
+// WithCDATA makes Parse keep the CDATA sections in the text of elements as
+// CharData nodes with CDATA set, so that they are written as CDATA sections
+// again. Without WithCDATA, the text of a CDATA section is merged with the
+// text around it.
+func WithCDATA() ParseOption {
+	return func(po *parseOptions) {
+		po.cdata = true
+	}
+}
+
+// WithDTDDefaults makes Parse read the attribute declarations in the internal
+// subset of the DOCTYPE declaration. Attributes with a default value that
+// are missing on an element are added with Defaulted set, and namespace
+// declarations with a default value are applied. With
+// WithNormalizeAttributes, the values of attributes declared with a
+// tokenized type such as NMTOKENS are normalized as tokens. The external
+// subset is not read.
+func WithDTDDefaults() ParseOption {
+	return func(po *parseOptions) {
+		po.dtdDefaults = true
+	}
+}
+
+// WithoutLineEndNormalization keeps carriage returns in the input. By default,
+// each CR LF pair and each single CR is read as LF, as required by the XML
+// specification. Serialization writes carriage returns in text and attribute
+// values as &#13;, so documents with CR LF line ends can be written back
+// unchanged.
+func WithoutLineEndNormalization() ParseOption {
+	return func(po *parseOptions) {
+		po.keepCR = true
+	}
+}
+
+// WithMaxDepth makes Parse fail with ErrLimitExceeded if elements are nested
+// deeper than depth levels. The root element has depth 1. Zero means no
+// limit.
+func WithMaxDepth(depth int) ParseOption {
+	return func(po *parseOptions) {
+		po.maxDepth = depth
+	}
+}
+
+// WithMaxSize makes Parse fail with ErrLimitExceeded if the input is larger
+// than size bytes. Zero means no limit.
+func WithMaxSize(size int64) ParseOption {
+	return func(po *parseOptions) {
+		po.maxSize = size
+	}
+}
+
+// progressInterval is the number of input bytes between two calls of the
+// WithProgress callback.
+const progressInterval = 1 << 20
+
+// WithProgress makes Parse call progress after each MiB of input and once
+// at the end with the number of bytes read so far and the number of nodes
+// built: elements, text nodes, comments and processing instructions.
+// Comparing bytesRead with the size of the input gives a progress bar, the
+// node count can be used to warn about documents that get too large. The
+// callback runs on the goroutine of Parse and slows it down if it blocks.
+func WithProgress(progress func(bytesRead int64, nodes int)) ParseOption {
+	return func(po *parseOptions) {
+		po.progress = progress
+	}
+}
+
+// WithCharsetReader lets Parse read documents in encodings other than UTF-8.
+// If the XML declaration names another encoding, such as ISO-8859-1,
+// charsetReader is called with the name and the input and returns a reader
+// that converts the input to UTF-8, for example
+// charset.NewReaderLabel from golang.org/x/net/html/charset. The conversion
+// takes place before all other processing, so the offsets in the document,
+// see SourceSpan, refer to the converted input. The encoding in the XML
+// declaration of the document is changed to UTF-8.
+func WithCharsetReader(charsetReader func(charset string, input io.Reader) (io.Reader, error)) ParseOption {
+	return func(po *parseOptions) {
+		po.charsetReader = charsetReader
+	}
+}
+
+// WithEntities lets Parse expand the references to the entities in the map
+// from entity names to replacement texts, in text and in attribute values.
+// Pass xml.HTMLEntity to read documents that use HTML entities such as
+// &nbsp; without declaring them. Without WithEntities, references to other
+// entities than the predefined ones are errors. WithEntityRefs keeps the
+// references in text instead of expanding them.
+func WithEntities(entities map[string]string) ParseOption {
+	return func(po *parseOptions) {
+		po.entities = entities
+	}
+}
+
+// WithoutComments makes Parse leave out the comments of the document.
+func WithoutComments() ParseOption {
+	return func(po *parseOptions) {
+		po.noComments = true
+	}
+}
+
+// WithoutProcInsts makes Parse leave out the processing instructions of the
+// document. The XML declaration is kept.
+func WithoutProcInsts() ParseOption {
+	return func(po *parseOptions) {
+		po.noProcInsts = true
+	}
+}
+
+// WithoutWhitespace makes Parse leave out the text nodes that consist of
+// white space only, such as the indentation between elements, except in
+// elements with xml:space="preserve" and their descendants. In mixed
+// content, the space between two elements such as <b>bold</b> <i>italic</i>
+// is left out as well.
+func WithoutWhitespace() ParseOption {
+	return func(po *parseOptions) {
+		po.noWhitespace = true
+	}
+}
+
+// WithoutDoctype makes Parse reject documents with a DOCTYPE declaration.
+func WithoutDoctype() ParseOption {
+	return func(po *parseOptions) {
+		po.noDoctype = true
+	}
+}
+
+// limits of WithSecureDefaults
+const (
+	secureMaxDepth = 256
+	secureMaxSize  = 64 << 20
+)
+
+// WithSecureDefaults configures Parse for untrusted input. Parse never reads
+// external entities or the external DTD subset and does not expand entities
+// declared in the DOCTYPE declaration, so entity expansion attacks do not
+// apply in any case. WithSecureDefaults limits the nesting depth to 256
+// levels and the input to 64 MiB and turns off WithDTDDefaults. Options
+// given after WithSecureDefaults override these settings, for example
+// WithMaxSize for larger documents. Add WithoutDoctype to reject DOCTYPE
+// declarations altogether.
+func WithSecureDefaults() ParseOption {
+	return func(po *parseOptions) {
+		po.maxDepth = secureMaxDepth
+		po.maxSize = secureMaxSize
+		po.dtdDefaults = false
+	}
+}
+
+// AttributeNamespace determines the namespace of attributes without a prefix.
+type AttributeNamespace int
+
+const (
+	// AttributeNoNamespace puts unprefixed attributes in no namespace, as the
+	// Namespaces in XML recommendation requires. The default namespace does
+	// not apply to attributes.
+	AttributeNoNamespace AttributeNamespace = iota
+	// AttributeElementNamespace puts unprefixed attributes in the namespace
+	// of their element. This is not conforming, but some vocabularies are
+	// processed this way. The attributes stay unprefixed in the output.
+	AttributeElementNamespace
+)
+
+// WithUnprefixedAttributes sets the namespace of attributes without a prefix.
+// The default is AttributeNoNamespace.
+func WithUnprefixedAttributes(mode AttributeNamespace) ParseOption {
+	return func(po *parseOptions) {
+		po.attributeNS = mode
+	}
+}
+
+// WithDefaultNamespace sets the namespace of unprefixed elements in documents
+// that do not declare a default namespace on the root element. The namespace
+// is declared on the root element as if the document contained it, so it is
+// written when serializing the document.
+func WithDefaultNamespace(uri string) ParseOption {
+	return func(po *parseOptions) {
+		po.defaultNS = uri
+	}
+}
+
+// SerializeOptions controls the output of WriteXML.
+type SerializeOptions struct {
+	// Parallel is the number of goroutines that serialize the children of the
+	// root element concurrently. Values below 2 serialize sequentially.
+	Parallel int
+	// Cache lets every element keep its serialized form and reuse it in
+	// the next WriteXML call with Cache set, until the element or one of its
+	// descendants is changed through Append, SetAttribute or
+	// DeclareNamespace. Direct changes to exported fields or to the values
+	// returned by Attributes are not noticed. Each cached element holds a copy
+	// of its output, so the memory use grows with the nesting depth.
+	// Element.WriteXML caches the descendants of the element, not the element
+	// itself.
+	Cache bool
+	// IllegalChars is the policy for characters not allowed in XML 1.0 in
+	// text, attribute values, comments and processing instructions. With
+	// the default CharError, WriteXML stops with an error.
+	IllegalChars CharPolicy
+	// SkipDefaulted omits the attributes that have been added from a
+	// default value in the DTD.
+	SkipDefaulted bool
+	// RawAttributes writes the attributes read with WithRawAttributes with
+	// their original quotes and their values as written in the source,
+	// including references, as long as their values have not been changed.
+	// This keeps the differences to the source minimal when editing
+	// documents maintained by other tools.
+	RawAttributes bool
+	// KeepTagLayout writes the start tags of elements read with
+	// WithTagLayout with their original white space and the attributes in
+	// their original order, followed by the attributes added later. Empty
+	// elements are written as an empty element tag or as a start and end tag
+	// like in the source. It has no effect when indenting.
+	KeepTagLayout bool
+	// Indent pretty prints the output if it is not empty. The children of
+	// elements that contain only elements, comments and processing
+	// instructions are written on lines of their own, indented by Indent per
+	// nesting level, and the white space between them is dropped. Elements
+	// with text and elements with xml:space="preserve" are written
+	// unchanged including their descendants. Parallel is ignored when
+	// indenting and the Cache is not used.
+	Indent string
+	// WrapAttributes writes start tags with more than WrapAttributes
+	// attributes and namespace declarations with one attribute per line when
+	// indenting. Zero keeps all attributes on the line of the element name.
+	WrapAttributes int
+	// MaxLineLength writes start tags that would end after MaxLineLength
+	// columns with one attribute per line when indenting, like
+	// WrapAttributes. Each character, including those of Indent, counts as
+	// one column. Zero does not limit the length.
+	MaxLineLength int
+	// AttributeIndent is the indentation of wrapped attributes relative to
+	// the element name. The default is Indent.
+	AttributeIndent string
+	// ExpandEmpty writes elements without children with a start tag and an
+	// end tag instead of an empty element tag, for consumers that expect
+	// <br></br> rather than <br />. Elements written with KeepTagLayout keep
+	// the form of the source.
+	ExpandEmpty bool
+	// XMLDeclaration writes the declaration <?xml version="1.0"
+	// encoding="UTF-8"?> on a line of its own before the output, unless a
+	// document starts with a declaration of its own.
+	XMLDeclaration bool
+	// Stats receives the statistics of the written document if it is not
+	// nil.
+	Stats *Stats
+}
@@ -0,0 +1,856 @@
+package goxml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxNames limits the size of the name table a Parser keeps between
+// documents.
+const maxNames = 10000
+
+// Parser reads XML documents. A Parser keeps its read buffer, name table and
+// allocation arena between documents, which reduces the allocations when
+// parsing many small documents. A Parser must not be used concurrently.
+type Parser struct {
+	opts     parseOptions
+	r        io.Reader
+	br       *bufio.Reader
+	names    nameTable
+	nodes    arena
+	filter   *pathFilter
+	input    *inputFilter
+	attlists map[string][]AttributeDecl
+	eltstack []XMLNode
+	errors   []*ParseError
+	// pending holds the comments and white space read with
+	// WithAttachedComments that are not yet attached to an element
+	pending []XMLNode
+
+	// the state of the running Parse call
+	doc   *XMLDocument
+	dec   *xml.Decoder
+	stats Stats
+	// tokenStart is the offset of the current token in the decoder input
+	tokenStart int64
+	// src is the decoder input if it is known as a whole, see ParseBytes
+	src string
+	// nextProgress is the decoder offset of the next WithProgress call
+	nextProgress int64
+}
+
+// NewParser returns a Parser configured with opts. Call Reset to set the
+// input before calling Parse.
+func NewParser(opts ...ParseOption) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(&p.opts)
+	}
+	p.names = make(nameTable)
+	p.nodes = arena{size: p.opts.arenaSize}
+	return p
+}
+
+// Reset sets the input of the parser to r. r is not closed.
+func (p *Parser) Reset(r io.Reader) {
+	p.errors = nil
+	p.input = nil
+	if p.opts.maxSize > 0 {
+		r = &sizeLimiter{r: r, n: p.opts.maxSize}
+	}
+	if p.opts.charsetReader != nil {
+		r = &charsetInput{r: r, convert: p.opts.charsetReader}
+	}
+	if p.opts.collectErrors || p.opts.illegalChars != CharError || p.opts.invalidUTF8 != UTF8Error || p.opts.normalize || p.opts.rawAttributes || p.opts.entityRefs || p.opts.keepCR || p.opts.tagLayout || p.opts.cdata {
+		f := newInputFilter(r, p.opts.illegalChars)
+		f.invalidUTF8 = p.opts.invalidUTF8
+		f.normalize = p.opts.normalize
+		f.recordRaw = p.opts.rawAttributes
+		f.recordTags = p.opts.tagLayout
+		f.recordCDATA = p.opts.cdata
+		f.keepRefs = p.opts.entityRefs
+		f.keepCR = p.opts.keepCR
+		f.entities = p.opts.entities
+		if p.opts.collectErrors {
+			f.checkEntities = true
+			f.report = func(line, column int, offset int64, msg string) {
+				p.errors = append(p.errors, &ParseError{
+					Source: p.opts.sourceName,
+					Line:   line,
+					Column: column,
+					Offset: offset,
+					Err:    errors.New(msg),
+				})
+			}
+		}
+		p.input = f
+		r = f
+	}
+	if _, ok := r.(io.ByteReader); ok {
+		// the decoder does not add its own buffer
+		p.r = r
+	} else {
+		if p.br == nil {
+			p.br = bufio.NewReader(r)
+		} else {
+			p.br.Reset(r)
+		}
+		p.r = p.br
+	}
+	if len(p.names) > maxNames {
+		p.names = make(nameTable)
+	}
+}
+
+// Parse reads one XML document from the input set with Reset. With
+// WithCollectErrors, a fatal error is returned together with the part of the
+// document read so far.
+func (p *Parser) Parse() (*XMLDocument, error) {
+	if p.r == nil {
+		return nil, errors.New("parser has no input")
+	}
+	doc := p.begin()
+	start := time.Now()
+	defer func() {
+		// do not keep the nodes of the document alive
+		for i := range p.eltstack {
+			p.eltstack[i] = nil
+		}
+		p.eltstack = p.eltstack[:0]
+		for i := range p.pending {
+			p.pending[i] = nil
+		}
+		p.pending = p.pending[:0]
+		p.doc = nil
+		p.dec = nil
+		p.src = ""
+	}()
+
+	for {
+		p.tokenStart = p.dec.InputOffset()
+		tok, err := p.dec.RawToken()
+		if err == io.EOF {
+			p.flushPending()
+			if err = p.endOfInput(); err != nil {
+				return p.fail(doc, p.newParseError(p.dec, p.current(), err))
+			}
+			break
+		}
+		if err == nil {
+			err = p.token(tok)
+		}
+		if err != nil {
+			return p.fail(doc, p.newParseError(p.dec, p.current(), err))
+		}
+		if p.opts.progress != nil && p.dec.InputOffset() >= p.nextProgress {
+			p.reportProgress()
+			p.nextProgress = p.dec.InputOffset() + progressInterval
+		}
+	}
+	if p.opts.progress != nil {
+		p.reportProgress()
+	}
+	doc.ClearDirty()
+	p.stats.Bytes = p.dec.InputOffset()
+	p.stats.Duration = time.Since(start)
+	doc.stats = p.stats
+	doc.errors = p.errors
+	return doc, nil
+}
+
+// begin starts reading a document from the input set with Reset and returns
+// the new document.
+func (p *Parser) begin() *XMLDocument {
+	doc := NewDocument()
+	doc.baseURI = p.opts.baseURI
+	doc.source = p.opts.sourceName
+	p.doc = doc
+	p.eltstack = append(p.eltstack[:0], doc)
+	p.dec = xml.NewDecoder(p.r)
+	p.dec.Entity = p.opts.entities
+	if p.opts.charsetReader != nil {
+		// charsetInput has converted the input already
+		p.dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		}
+	}
+	p.r = nil
+	p.filter = newPathFilter(p.opts.keepOnly)
+	p.attlists = nil
+	p.pending = p.pending[:0]
+	p.stats = Stats{}
+	p.nextProgress = progressInterval
+	return doc
+}
+
+// sourceOffset returns the offset in the input for the offset in the
+// decoder input.
+func (p *Parser) sourceOffset(offset int64) int64 {
+	if p.input == nil {
+		return offset
+	}
+	return p.input.inputOffset(offset)
+}
+
+// reportProgress calls the WithProgress callback.
+func (p *Parser) reportProgress() {
+	nodes := p.stats.Elements + p.stats.CharData + p.stats.Comments + p.stats.ProcInsts
+	p.opts.progress(p.sourceOffset(p.dec.InputOffset()), nodes)
+}
+
+// nodeAppender is implemented by the nodes the parser appends to. The parser
+// numbers the nodes itself, so they are appended without new IDs.
+type nodeAppender interface {
+	appendNode(n XMLNode, number bool)
+}
+
+// current returns the innermost open node.
+func (p *Parser) current() XMLNode {
+	return p.eltstack[len(p.eltstack)-1]
+}
+
+// token adds the token to the document.
+func (p *Parser) token(tok xml.Token) error {
+	switch tok.(type) {
+	case xml.CharData, xml.ProcInst, xml.Comment:
+		if !p.filter.keepContent() || p.dropped(tok) {
+			return nil
+		}
+	}
+	if p.opts.attachComment && p.attach(tok) {
+		return nil
+	}
+	cur := p.current()
+	switch v := tok.(type) {
+	case xml.StartElement:
+		if !p.filter.startElement(v.Name.Local) {
+			p.flushPending()
+			if p.opts.normalize || p.opts.rawAttributes {
+				p.rawValues(len(v.Attr))
+			}
+			if p.opts.tagLayout {
+				p.input.nextTagLayout()
+			}
+			return p.skip()
+		}
+		return p.startElement(v)
+	case xml.EndElement:
+		return p.endElement(v)
+	case xml.Directive:
+		if !bytes.HasPrefix(v, []byte("DOCTYPE")) {
+			return nil
+		}
+		if p.opts.noDoctype {
+			return p.syntaxError("DOCTYPE declaration not allowed")
+		}
+		p.doc.doctype = string(v)
+		p.doc.doctypeIndex = len(p.doc.children)
+		if p.opts.dtdDefaults {
+			p.attlists = parseAttlists(string(v))
+		}
+	case xml.CharData:
+		c, ok := cur.(nodeAppender)
+		if !ok {
+			return nil
+		}
+		if p.opts.cdata && p.input.isCDATA(p.tokenStart) {
+			p.cdataSection(c, string(v))
+			return nil
+		}
+		if p.opts.entityRefs {
+			p.entityRefs(c, string(v))
+			return nil
+		}
+		p.charData(c, p.text(v))
+	case xml.ProcInst:
+		pi := ProcInst{ID: p.doc.NextID()}
+		pi.Target = p.names.intern(v.Target)
+		pi.Inst = v.Copy().Inst
+		if pi.Target == "xml" && p.opts.charsetReader != nil {
+			// the document has been converted to UTF-8
+			if start, end := encodingValue(string(pi.Inst)); end > start {
+				pi.Inst = append(append(pi.Inst[:start:start], "UTF-8"...), pi.Inst[end:]...)
+			}
+		}
+		if !p.opts.keepCR {
+			pi.Inst = normalizeLineEnds(pi.Inst)
+		}
+		if c, ok := cur.(nodeAppender); ok {
+			c.appendNode(pi, false)
+		}
+		p.stats.ProcInsts++
+	case xml.Comment:
+		if !p.opts.keepCR {
+			v = normalizeLineEnds(v)
+		}
+		cmt := Comment{ID: p.doc.NextID(), Contents: p.text(v)}
+		if c, ok := cur.(nodeAppender); ok {
+			c.appendNode(cmt, false)
+		}
+		p.stats.Comments++
+	}
+	return nil
+}
+
+// dropped reports whether tok is left out with WithoutComments,
+// WithoutProcInsts or WithoutWhitespace.
+func (p *Parser) dropped(tok xml.Token) bool {
+	switch v := tok.(type) {
+	case xml.Comment:
+		return p.opts.noComments
+	case xml.ProcInst:
+		return p.opts.noProcInsts && v.Target != "xml"
+	case xml.CharData:
+		return p.opts.noWhitespace && isSpace(string(v)) && !p.preserveSpace()
+	}
+	return false
+}
+
+// preserveSpace reports whether the current element is in the scope of
+// xml:space="preserve".
+func (p *Parser) preserveSpace() bool {
+	for i := len(p.eltstack) - 1; i > 0; i-- {
+		elt, ok := p.eltstack[i].(*Element)
+		if !ok {
+			continue
+		}
+		for _, attr := range elt.attributes {
+			if attr.Name == "space" && attr.Namespace == xmlNamespace {
+				return attr.Value == "preserve"
+			}
+		}
+	}
+	return false
+}
+
+// attach collects the comments and the white space after them that may be
+// attached to the next element. It reports whether tok has been collected.
+// Other tokens than start tags add the collected nodes to the current node.
+func (p *Parser) attach(tok xml.Token) bool {
+	switch v := tok.(type) {
+	case xml.Comment:
+		if !p.opts.keepCR {
+			v = normalizeLineEnds(v)
+		}
+		p.pending = append(p.pending, Comment{ID: p.doc.NextID(), Contents: p.text(v)})
+		p.stats.Comments++
+		return true
+	case xml.CharData:
+		if len(p.pending) > 0 && isSpace(string(v)) {
+			p.pending = append(p.pending, CharData{ID: p.doc.NextID(), Contents: p.text(v)})
+			p.stats.CharData++
+			p.stats.TextBytes += int64(len(v))
+			return true
+		}
+	case xml.StartElement:
+		return false
+	}
+	p.flushPending()
+	return false
+}
+
+// flushPending adds the collected comments to the current node.
+func (p *Parser) flushPending() {
+	if len(p.pending) == 0 {
+		return
+	}
+	if c, ok := p.current().(nodeAppender); ok {
+		for _, n := range p.pending {
+			c.appendNode(n, false)
+		}
+	}
+	for i := range p.pending {
+		p.pending[i] = nil
+	}
+	p.pending = p.pending[:0]
+}
+
+// text returns the text of the current text or comment token. If the
+// input is known as a whole and b is the text as written in it, the result
+// is a part of the input instead of a copy.
+func (p *Parser) text(b []byte) string {
+	if p.src == "" {
+		return string(b)
+	}
+	raw := p.src[p.tokenStart:p.dec.InputOffset()]
+	switch {
+	case strings.HasPrefix(raw, "<!--"):
+		raw = raw[4 : len(raw)-3]
+	case strings.HasPrefix(raw, "<![CDATA["):
+		raw = raw[9 : len(raw)-3]
+	}
+	if raw == string(b) {
+		return raw
+	}
+	return string(b)
+}
+
+// charData appends the text s as a CharData node.
+func (p *Parser) charData(c nodeAppender, s string) {
+	c.appendNode(CharData{ID: p.doc.NextID(), Contents: s}, false)
+	p.stats.CharData++
+	p.stats.TextBytes += int64(len(s))
+}
+
+// cdataSection appends the text of a CDATA section.
+func (p *Parser) cdataSection(c nodeAppender, s string) {
+	if p.opts.entityRefs {
+		// the input filter has marked the literal refMarker runes only
+		s = strings.ReplaceAll(s, string(refMarker)+string(refEscape), string(refMarker))
+	}
+	c.appendNode(CharData{ID: p.doc.NextID(), Contents: s, CDATA: true}, false)
+	p.stats.CharData++
+	p.stats.TextBytes += int64(len(s))
+}
+
+// entityRefs appends the text s, which contains the references marked by the
+// input filter, as CharData and EntityRef nodes.
+func (p *Parser) entityRefs(c nodeAppender, s string) {
+	var sb strings.Builder
+	for {
+		i := strings.IndexRune(s, refMarker)
+		if i < 0 {
+			break
+		}
+		sb.WriteString(s[:i])
+		s = s[i+utf8.RuneLen(refMarker):]
+		if strings.HasPrefix(s, string(refEscape)) {
+			sb.WriteRune(refMarker)
+			s = s[utf8.RuneLen(refEscape):]
+			continue
+		}
+		name, rest, _ := strings.Cut(s, ";")
+		s = rest
+		if sb.Len() > 0 {
+			p.charData(c, sb.String())
+			sb.Reset()
+		}
+		ref := EntityRef{ID: p.doc.NextID(), Name: name}
+		if r, ok := parseCharReference(name); ok {
+			ref.Value = string(r)
+		}
+		c.appendNode(ref, false)
+	}
+	sb.WriteString(s)
+	if sb.Len() > 0 {
+		p.charData(c, sb.String())
+	}
+}
+
+func (p *Parser) startElement(v xml.StartElement) error {
+	if p.opts.maxDepth > 0 && len(p.eltstack) > p.opts.maxDepth {
+		return fmt.Errorf("%w: elements nested deeper than %d levels", ErrLimitExceeded, p.opts.maxDepth)
+	}
+	cur := p.current()
+	tmp := p.nodes.newElement()
+	tmp.ID = p.doc.NextID()
+	tmp.Line, tmp.Pos = p.dec.InputPos()
+	tmp.spanStart = p.sourceOffset(p.tokenStart)
+	tmp.Name = p.names.intern(v.Name.Local)
+	tmp.Prefix = p.names.intern(v.Name.Space)
+	tmp.Parent = cur
+	tmp.attributes = p.nodes.newAttributeList(len(v.Attr))
+	var raw []rawValue
+	if p.opts.normalize || p.opts.rawAttributes {
+		raw = p.rawValues(len(v.Attr))
+	}
+	if p.opts.tagLayout {
+		tmp.startTag = p.input.nextTagLayout()
+	}
+
+	for i, att := range v.Attr {
+		var prefix string
+		if att.Name.Space == "" && att.Name.Local == "xmlns" {
+			prefix = ""
+		} else if att.Name.Space == "xmlns" {
+			prefix = att.Name.Local
+		} else {
+			continue
+		}
+		if msg := checkNamespaceDeclaration(v.Attr[:i], prefix, att.Value); msg != "" {
+			if err := p.wellFormednessError(tmp, msg); err != nil {
+				return err
+			}
+			continue
+		}
+		tmp.DeclareNamespace(p.names.intern(prefix), p.names.intern(att.Value))
+	}
+	if p.attlists != nil {
+		p.defaultNamespaces(tmp)
+	}
+	if _, ok := cur.(*XMLDocument); ok {
+		for prefix, uri := range p.opts.inherited {
+			if _, ok := tmp.Namespaces[prefix]; !ok {
+				tmp.DeclareNamespace(prefix, uri)
+			}
+		}
+		if _, ok := tmp.Namespaces[""]; !ok && p.opts.defaultNS != "" {
+			tmp.DeclareNamespace("", p.opts.defaultNS)
+		}
+	}
+attributes:
+	for i, att := range v.Attr {
+		if att.Name.Space == "" && att.Name.Local == "xmlns" || att.Name.Space == "xmlns" {
+			continue
+		}
+		attr := p.nodes.newAttribute()
+		attr.Name = p.names.intern(att.Name.Local)
+		attr.Value = att.Value
+		if raw != nil {
+			attr.RawValue = raw[i].value
+			if p.opts.rawAttributes {
+				attr.Quote = raw[i].quote
+				attr.parsed = attr.Value
+			}
+		}
+		if prefix := att.Name.Space; prefix != "" {
+			attr.Prefix = p.names.intern(prefix)
+			if prefix == "xml" {
+				attr.Namespace = xmlNamespace
+			} else if ns, ok := tmp.LookupNamespace(prefix); ok {
+				attr.Namespace = ns
+			} else {
+				// unbound prefix, keep it like encoding/xml does
+				attr.Namespace = attr.Prefix
+			}
+		}
+		// attributes are compared by their expanded names
+		for _, prev := range tmp.attributes {
+			if prev.Name != attr.Name || prev.Namespace != attr.Namespace {
+				continue
+			}
+			var msg string
+			if prev.Prefix == attr.Prefix {
+				msg = "duplicate attribute " + qualifiedName(attr.Prefix, attr.Name)
+			} else {
+				msg = "attributes " + qualifiedName(prev.Prefix, prev.Name) + " and " + qualifiedName(attr.Prefix, attr.Name) + " have the same name in namespace " + attr.Namespace
+			}
+			if err := p.wellFormednessError(tmp, msg); err != nil {
+				return err
+			}
+			continue attributes
+		}
+		if attr.Prefix == "" && p.opts.attributeNS == AttributeElementNamespace {
+			attr.Namespace = tmp.NamespaceURI()
+		}
+		attr.ID = p.doc.NextID()
+		tmp.attributes = append(tmp.attributes, attr)
+	}
+	if p.attlists != nil {
+		p.addDefaultAttributes(tmp, p.opts.normalize)
+	}
+
+	if len(p.pending) > 0 {
+		tmp.leading = append([]XMLNode(nil), p.pending...)
+		p.pending = p.pending[:0]
+	}
+	if c, ok := cur.(nodeAppender); ok {
+		c.appendNode(tmp, false)
+	}
+	p.eltstack = append(p.eltstack, tmp)
+	p.stats.Elements++
+	p.stats.Attributes += len(tmp.attributes)
+	if depth := len(p.eltstack) - 1; depth > p.stats.MaxDepth {
+		p.stats.MaxDepth = depth
+	}
+	return nil
+}
+
+// endElement closes the innermost open element. In repair mode, an end tag
+// that does not match closes the open elements up to the matching one, and an
+// end tag without any matching start tag is ignored.
+func (p *Parser) endElement(v xml.EndElement) error {
+	name := v.Name.Local
+	if v.Name.Space != "" {
+		name = v.Name.Space + ":" + name
+	}
+	top := len(p.eltstack) - 1
+	if top > 0 && p.eltstack[top].(*Element).hasName(v.Name) {
+		p.eltstack[top].(*Element).spanEnd = p.sourceOffset(p.dec.InputOffset())
+		p.pop()
+		return nil
+	}
+	if !p.opts.repair {
+		if top == 0 {
+			return p.syntaxError("unexpected end element </" + name + ">")
+		}
+		return p.syntaxError("element <" + p.eltstack[top].(*Element).qualifiedName() + "> closed by </" + name + ">")
+	}
+	for i := top - 1; i > 0; i-- {
+		if elt := p.eltstack[i].(*Element); elt.hasName(v.Name) {
+			elt.spanEnd = p.sourceOffset(p.dec.InputOffset())
+			for j := top; j > i; j-- {
+				p.repaired("element <" + p.current().(*Element).qualifiedName() + "> closed by </" + name + ">")
+				p.pop()
+			}
+			p.pop()
+			return nil
+		}
+	}
+	p.repaired("end element </" + name + "> without start element ignored")
+	return nil
+}
+
+// endOfInput checks that all elements are closed at the end of the input.
+func (p *Parser) endOfInput() error {
+	if len(p.eltstack) == 1 {
+		return nil
+	}
+	if !p.opts.repair {
+		return p.syntaxError("unexpected EOF")
+	}
+	for len(p.eltstack) > 1 {
+		p.repaired("element <" + p.current().(*Element).qualifiedName() + "> not closed at the end of the input")
+		p.pop()
+	}
+	return nil
+}
+
+func (p *Parser) pop() {
+	p.filter.endElement()
+	p.eltstack[len(p.eltstack)-1] = nil
+	p.eltstack = p.eltstack[:len(p.eltstack)-1]
+}
+
+// skip reads up to and including the end tag of the element whose start tag
+// has just been read.
+func (p *Parser) skip() error {
+	for depth := 1; depth > 0; {
+		tok, err := p.dec.RawToken()
+		if err == io.EOF {
+			return p.syntaxError("unexpected EOF")
+		}
+		if err != nil {
+			return err
+		}
+		switch v := tok.(type) {
+		case xml.StartElement:
+			if p.opts.normalize || p.opts.rawAttributes {
+				p.rawValues(len(v.Attr))
+			}
+			if p.opts.tagLayout {
+				p.input.nextTagLayout()
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
+// checkNamespaceDeclaration checks the declaration of prefix against the
+// earlier attributes of the start tag and the reserved prefixes. It returns
+// the description of the problem or an empty string.
+func checkNamespaceDeclaration(earlier []xml.Attr, prefix, uri string) string {
+	name := "xmlns"
+	if prefix != "" {
+		name += ":" + prefix
+	}
+	for _, att := range earlier {
+		if prefix == "" && att.Name.Space == "" && att.Name.Local == "xmlns" || prefix != "" && att.Name.Space == "xmlns" && att.Name.Local == prefix {
+			if att.Value == uri {
+				return "duplicate namespace declaration " + name
+			}
+			return "conflicting namespace declarations " + name + "=\"" + att.Value + "\" and " + name + "=\"" + uri + "\""
+		}
+	}
+	switch {
+	case prefix == "xmlns":
+		return "the prefix xmlns must not be declared"
+	case prefix == "xml" && uri != xmlNamespace:
+		return "the prefix xml must not be bound to " + uri
+	case prefix != "xml" && uri == xmlNamespace:
+		return "the XML namespace must not be bound by " + name
+	case prefix != "" && uri == "":
+		return "the prefix " + prefix + " must not be undeclared"
+	}
+	return ""
+}
+
+// wellFormednessError returns a syntax error for msg. With WithCollectErrors,
+// the problem is recorded instead and nil is returned.
+func (p *Parser) wellFormednessError(cur XMLNode, msg string) error {
+	if !p.opts.collectErrors {
+		return p.syntaxError(msg)
+	}
+	p.errors = append(p.errors, p.newParseError(p.dec, cur, errors.New(msg)))
+	return nil
+}
+
+// rawValues returns the source text of the next n attribute values.
+func (p *Parser) rawValues(n int) []rawValue {
+	raw := make([]rawValue, n)
+	for i := range raw {
+		raw[i] = p.input.nextRawValue()
+	}
+	return raw
+}
+
+func (p *Parser) syntaxError(msg string) error {
+	line, _ := p.dec.InputPos()
+	return &xml.SyntaxError{Msg: msg, Line: line}
+}
+
+// repaired records a problem that has been repaired.
+func (p *Parser) repaired(msg string) {
+	p.errors = append(p.errors, p.newParseError(p.dec, p.current(), errors.New(msg)))
+}
+
+// fail returns the error of a failed Parse call.
+func (p *Parser) fail(doc *XMLDocument, err *ParseError) (*XMLDocument, error) {
+	if !p.opts.collectErrors {
+		return nil, err
+	}
+	p.errors = append(p.errors, err)
+	doc.errors = p.errors
+	return doc, err
+}
+
+// Parse reads the XML file from r. r is not closed.
+func Parse(r io.Reader, opts ...ParseOption) (*XMLDocument, error) {
+	p := NewParser(opts...)
+	p.Reset(r)
+	return p.Parse()
+}
+
+// ParseBytes reads the XML document from b. b is copied once, and the text
+// nodes and comments that are written without references, CR characters or
+// CDATA markup are parts of this copy instead of a string each, which saves
+// most of the allocations for text-heavy documents. Names are shared through
+// the name table as with Parse. b can be changed or reused afterwards. The
+// copy stays in memory as long as one of these nodes is used, so extract the
+// strings that are kept longer than the document with strings.Clone. With the
+// options that filter the input, such as WithCollectErrors, WithNormalizeAttributes
+// or WithCharsetReader, the text is copied node by node as with Parse.
+func ParseBytes(b []byte, opts ...ParseOption) (*XMLDocument, error) {
+	src := string(b)
+	p := NewParser(opts...)
+	p.Reset(strings.NewReader(src))
+	if p.input == nil && p.opts.charsetReader == nil {
+		p.src = src
+	}
+	return p.Parse()
+}
+
+// normalizeLineEnds replaces CR LF pairs and single CRs by LF. The decoder
+// does this for text and attribute values only.
+func normalizeLineEnds(b []byte) []byte {
+	if bytes.IndexByte(b, '\r') < 0 {
+		return b
+	}
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(b, []byte("\r"), []byte("\n"))
+}
+
+// sizeLimiter fails with ErrLimitExceeded when more than n bytes are read
+// from r.
+type sizeLimiter struct {
+	r io.Reader
+	n int64
+}
+
+func (l *sizeLimiter) Read(b []byte) (int, error) {
+	if l.n < 0 {
+		return 0, fmt.Errorf("%w: input larger than the maximum size", ErrLimitExceeded)
+	}
+	// read one byte more than allowed to notice an input that is too large
+	if int64(len(b)) > l.n+1 {
+		b = b[:l.n+1]
+	}
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return 0, fmt.Errorf("%w: input larger than the maximum size", ErrLimitExceeded)
+	}
+	return n, err
+}
+
+// charsetInput converts the input to UTF-8 with convert if the XML
+// declaration names another encoding, so that the input filter and the
+// decoder only see UTF-8.
+type charsetInput struct {
+	r       io.Reader
+	convert func(charset string, input io.Reader) (io.Reader, error)
+}
+
+func (ci *charsetInput) Read(b []byte) (int, error) {
+	if ci.convert != nil {
+		convert := ci.convert
+		ci.convert = nil
+		br := bufio.NewReader(ci.r)
+		ci.r = br
+		if enc := declaredEncoding(br); enc != "" && !strings.EqualFold(enc, "utf-8") {
+			r, err := convert(strings.ToLower(enc), br)
+			if err != nil {
+				return 0, err
+			}
+			ci.r = r
+		}
+	}
+	return ci.r.Read(b)
+}
+
+// declaredEncoding returns the encoding in the XML declaration at the start
+// of the input, without consuming it.
+func declaredEncoding(br *bufio.Reader) string {
+	// Peek returns what it has at the end of the input
+	head, _ := br.Peek(256)
+	decl := string(head)
+	if !strings.HasPrefix(decl, "<?xml") {
+		return ""
+	}
+	decl, _, ok := strings.Cut(decl, "?>")
+	if !ok {
+		return ""
+	}
+	start, end := encodingValue(decl)
+	return decl[start:end]
+}
+
+// encodingValue returns the start and end of the value of the encoding in
+// the XML declaration decl, or two zeros.
+func encodingValue(decl string) (start, end int) {
+	i := strings.Index(decl, "encoding")
+	if i < 0 {
+		return 0, 0
+	}
+	i += len("encoding")
+	for i < len(decl) && isXMLSpace(rune(decl[i])) {
+		i++
+	}
+	if i == len(decl) || decl[i] != '=' {
+		return 0, 0
+	}
+	i++
+	for i < len(decl) && isXMLSpace(rune(decl[i])) {
+		i++
+	}
+	if i == len(decl) || decl[i] != '"' && decl[i] != '\'' {
+		return 0, 0
+	}
+	n := strings.IndexByte(decl[i+1:], decl[i])
+	if n < 0 {
+		return 0, 0
+	}
+	return i + 1, i + 1 + n
+}
+
+// nameTable interns the element and attribute names and namespace URIs of a
+// document, so that all nodes with the same name share one string.
+type nameTable map[string]string
+
+func (nt nameTable) intern(s string) string {
+	if is, ok := nt[s]; ok {
+		return is
+	}
+	nt[s] = s
+	return s
+}
@@ -0,0 +1,125 @@
+package goxml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseRepair(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		repairs int
+	}{
+		{`<a><b><c></a>`, `<a><b><c /></b></a>`, 2},
+		{`<a></x></a>`, `<a />`, 1},
+		{`<a></a></b>`, `<a />`, 1},
+		{`<a><b>`, `<a><b /></a>`, 2},
+		{`<a><b/></a>`, `<a><b /></a>`, 0},
+	}
+	for _, tc := range tests {
+		doc, err := Parse(strings.NewReader(tc.in), WithRepair())
+		if err != nil {
+			t.Errorf("Parse(%q, WithRepair()): %v", tc.in, err)
+			continue
+		}
+		if got := doc.ToXML(); got != tc.want {
+			t.Errorf("Parse(%q, WithRepair()).ToXML() = %q, want %q", tc.in, got, tc.want)
+		}
+		if got := len(doc.Errors()); got != tc.repairs {
+			t.Errorf("Parse(%q, WithRepair()) recorded %d repairs, want %d: %v", tc.in, got, tc.repairs, doc.Errors())
+		}
+	}
+}
+
+func TestParseRepairErrors(t *testing.T) {
+	doc, err := Parse(strings.NewReader("<a>\n<b>\n</a>\n</x>\n<c><d>"), WithRepair())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		line    int
+		element string
+		msg     string
+	}{
+		{3, "b", "element <b> closed by </a>"},
+		{4, "", "end element </x> without start element ignored"},
+		{5, "d", "element <d> not closed at the end of the input"},
+		{5, "c", "element <c> not closed at the end of the input"},
+	}
+	errs := doc.Errors()
+	if len(errs) != len(want) {
+		t.Fatalf("%d repairs recorded, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, w := range want {
+		if e := errs[i]; e.Line != w.line || e.Element != w.element || !strings.Contains(e.Error(), w.msg) {
+			t.Errorf("repair %d: %v (line %d, element %q), want %q in line %d, element %q", i, e, e.Line, e.Element, w.msg, w.line, w.element)
+		}
+	}
+	// a repaired document keeps the IDs in document order
+	checkIDs(t, doc)
+}
+
+func TestParseLineEnds(t *testing.T) {
+	in := "<a x='1\r\n2\r3'>\r\n t\r u\r\n<!--c\r\n--><?pi a\r\nb?></a>"
+	tests := []struct {
+		opts []ParseOption
+		attr string
+		want string
+	}{
+		{nil, "1\n2\n3", "<a x=\"1&#10;2&#10;3\">\n t\n u\n<!--c\n--><?pi a\nb?></a>"},
+		{[]ParseOption{WithoutLineEndNormalization()}, "1\r\n2\r3", "<a x=\"1&#13;&#10;2&#13;3\">&#13;\n t&#13; u&#13;\n<!--c\r\n--><?pi a\r\nb?></a>"},
+	}
+	for _, tc := range tests {
+		doc, err := Parse(strings.NewReader(in), tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ := doc.Root()
+		if got := r.Attributes()[0].Value; got != tc.attr {
+			t.Errorf("attribute value %q, want %q", got, tc.attr)
+		}
+		if got := doc.ToXML(); got != tc.want {
+			t.Errorf("ToXML() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for _, in := range []string{
+		"<a>text<!--c-->&amp;more<![CDATA[<x>]]>\r\n<b x='1'>t</b>tail</a>",
+		"<!--before--><a>\n  <b>one</b>\n  <b>two</b>\n</a><!--after-->",
+		"<a>äö<!-- ü --></a>",
+	} {
+		want, err := Parse(strings.NewReader(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range [][]ParseOption{nil, {WithAttachedComments()}, {WithCollectErrors()}} {
+			doc, err := ParseBytes([]byte(in), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := doc.ToXML(); got != want.ToXML() {
+				t.Errorf("ParseBytes(%q) = %q, want %q", in, got, want.ToXML())
+			}
+		}
+	}
+}
+
+func TestParseBytesAllocs(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("<r>")
+	for i := 0; i < 500; i++ {
+		sb.WriteString("<p>some text</p><!--note-->")
+	}
+	sb.WriteString("</r>")
+	b := []byte(sb.String())
+	fromReader := testing.AllocsPerRun(5, func() { Parse(bytes.NewReader(b)) })
+	fromBytes := testing.AllocsPerRun(5, func() { ParseBytes(b) })
+	// no string for each of the 1000 text nodes and comments
+	if fromBytes > fromReader-900 {
+		t.Errorf("ParseBytes allocates %.0f times, Parse %.0f times", fromBytes, fromReader)
+	}
+}
@@ -0,0 +1,847 @@
+package goxml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled path expression. The path language is the abbreviated
+// syntax of XPath 1.0 location paths with simple predicates, which covers
+// the common lookups without an XPath engine:
+//
+//	/book/chapter   the chapter children of the root element book
+//	//title         all title elements of the document
+//	chapter/@id     the id attributes of the chapter children
+//	para/text()     the text nodes of the para children
+//	..              the parent
+//	x:*             all child elements in the namespace bound to x
+//	item[@sku='X']  the item children with the attribute sku="X"
+//	item[2]         the second item child
+//
+// Steps are separated by a slash, a double slash selects the descendants.
+// Names match the local name and the namespace of elements and attributes.
+// Prefixes are resolved with the bindings passed to CompilePath, the prefix
+// xml is always bound. A binding for the empty prefix applies to unprefixed
+// element names, otherwise these match elements in no namespace. The other
+// node tests are *, prefix:*, node(), text(), comment() and
+// processing-instruction().
+//
+// A step can be followed by predicates in brackets, which keep the nodes
+// that pass them:
+//
+//	[2]             the node at this position, counting from 1
+//	[last()]        the last node, [last()-1] the one before it
+//	[@id]           elements with the attribute id
+//	[@id='x']       elements with the attribute id="x"
+//	[title]         elements with a child element title
+//	[title='Intro'] elements with a child element title with the text Intro
+//	[text()='x']    elements with a text node x
+//	[.='x']         nodes with the string value x
+//
+// Values are in single or double quotes. Positions count the nodes of the
+// step from one context node that pass the predicates before, so //item[1]
+// is the first item child of each element, as in XPath. A Path can be used
+// concurrently.
+type Path struct {
+	expr     string
+	absolute bool
+	steps    []pathStep
+}
+
+// axes of path steps
+const (
+	axisChild = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisSelf
+	axisParent
+	axisAttribute
+)
+
+// node tests of path steps
+const (
+	testName = iota
+	testNode
+	testText
+	testComment
+	testProcInst
+)
+
+type pathStep struct {
+	axis int
+	test int
+	// space and local are the namespace and the local name of a name test,
+	// local is * for a wildcard. anySpace is set for *.
+	space    string
+	local    string
+	anySpace bool
+	// predicates filter the nodes of the step in order
+	predicates []pathPredicate
+}
+
+// kinds of path predicates
+const (
+	predPosition = iota
+	predAttribute
+	predChild
+	predText
+	predSelf
+)
+
+// pathPredicate is a predicate of a path step. name is the name test of an
+// attribute or child predicate. value is compared with the string value if
+// hasValue is set. A position counts from the last node if fromEnd is set.
+type pathPredicate struct {
+	kind     int
+	position int
+	fromEnd  bool
+	name     pathStep
+	value    string
+	hasValue bool
+}
+
+// CompilePath parses the path expression expr. namespaces binds the prefixes
+// used in expr to namespace URIs and may be nil.
+func CompilePath(expr string, namespaces map[string]string) (*Path, error) {
+	p := &Path{expr: expr}
+	s := strings.TrimSpace(expr)
+	if s == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	descendants := false
+	if strings.HasPrefix(s, "/") {
+		p.absolute = true
+		if s == "/" {
+			return p, nil
+		}
+		s, descendants = cutSlash(s)
+	}
+	for {
+		tok, rest := s, ""
+		if i := indexOutside(s, '/'); i >= 0 {
+			tok, rest = s[:i], s[i:]
+		}
+		step, err := compileStep(strings.TrimSpace(tok), namespaces)
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %w", expr, err)
+		}
+		if descendants {
+			if step.axis == axisChild && !step.positional() {
+				// descendant-or-self::node()/child::x selects the same nodes
+				// as descendant::x
+				step.axis = axisDescendant
+			} else {
+				p.steps = append(p.steps, pathStep{axis: axisDescendantOrSelf, test: testNode})
+			}
+		}
+		p.steps = append(p.steps, step)
+		if rest == "" {
+			return p, nil
+		}
+		s, descendants = cutSlash(rest)
+	}
+}
+
+// cutSlash removes the slash or double slash at the start of s and reports
+// whether it was a double slash.
+func cutSlash(s string) (string, bool) {
+	if strings.HasPrefix(s, "//") {
+		return s[2:], true
+	}
+	return s[1:], false
+}
+
+// indexOutside returns the index of the first c in s that is not inside of
+// brackets or quotes, -1 if there is none.
+func indexOutside(s string, c byte) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == c && depth == 0:
+			return i
+		case s[i] == '[':
+			depth++
+		case s[i] == ']':
+			depth--
+		}
+	}
+	return -1
+}
+
+func compileStep(tok string, namespaces map[string]string) (pathStep, error) {
+	var predicates []pathPredicate
+	if i := indexOutside(tok, '['); i >= 0 {
+		var err error
+		predicates, err = compilePredicates(tok[i:], namespaces)
+		if err != nil {
+			return pathStep{}, err
+		}
+		tok = strings.TrimSpace(tok[:i])
+	}
+	step, err := compileNodeTest(tok, namespaces)
+	if err != nil {
+		return step, err
+	}
+	if len(predicates) > 0 && (step.axis == axisSelf || step.axis == axisParent) {
+		return step, fmt.Errorf("predicate after %s", tok)
+	}
+	step.predicates = predicates
+	return step, nil
+}
+
+// compilePredicates parses the predicates in brackets at the end of a step.
+func compilePredicates(s string, namespaces map[string]string) ([]pathPredicate, error) {
+	var predicates []pathPredicate
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] != '[' {
+			return nil, fmt.Errorf("invalid predicate %q", s)
+		}
+		end := indexOutside(s[1:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("missing ] in %q", s)
+		}
+		pred, err := compilePredicate(strings.TrimSpace(s[1:end+1]), namespaces)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, pred)
+		s = s[end+2:]
+	}
+	return predicates, nil
+}
+
+func compilePredicate(s string, namespaces map[string]string) (pathPredicate, error) {
+	var pred pathPredicate
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return pred, fmt.Errorf("invalid position [%s]", s)
+		}
+		pred.kind, pred.position = predPosition, n
+		return pred, nil
+	}
+	if strings.HasPrefix(s, "last()") {
+		n := 0
+		if rest := strings.TrimSpace(s[len("last()"):]); rest != "" {
+			var err error
+			if rest[0] == '-' {
+				n, err = strconv.Atoi(strings.TrimSpace(rest[1:]))
+			}
+			if rest[0] != '-' || err != nil || n < 0 {
+				return pred, fmt.Errorf("invalid position [%s]", s)
+			}
+		}
+		pred.kind, pred.position, pred.fromEnd = predPosition, n+1, true
+		return pred, nil
+	}
+	lhs := s
+	if i := indexOutside(s, '='); i >= 0 {
+		lhs = strings.TrimSpace(s[:i])
+		v := strings.TrimSpace(s[i+1:])
+		if len(v) < 2 || v[0] != '\'' && v[0] != '"' || v[len(v)-1] != v[0] || strings.IndexByte(v[1:len(v)-1], v[0]) >= 0 {
+			return pred, fmt.Errorf("invalid value in predicate [%s]", s)
+		}
+		pred.value, pred.hasValue = v[1:len(v)-1], true
+	}
+	switch lhs {
+	case ".":
+		pred.kind = predSelf
+		return pred, nil
+	case "text()":
+		pred.kind = predText
+		return pred, nil
+	}
+	name, err := compileNodeTest(lhs, namespaces)
+	if err != nil || name.test != testName {
+		return pred, fmt.Errorf("invalid predicate [%s]", s)
+	}
+	pred.kind, pred.name = predChild, name
+	if name.axis == axisAttribute {
+		pred.kind = predAttribute
+	}
+	return pred, nil
+}
+
+// positional reports whether the step has a position predicate.
+func (s pathStep) positional() bool {
+	for _, pred := range s.predicates {
+		if pred.kind == predPosition {
+			return true
+		}
+	}
+	return false
+}
+
+func compileNodeTest(tok string, namespaces map[string]string) (pathStep, error) {
+	step := pathStep{axis: axisChild}
+	switch tok {
+	case "":
+		return step, fmt.Errorf("missing step")
+	case ".":
+		step.axis, step.test = axisSelf, testNode
+		return step, nil
+	case "..":
+		step.axis, step.test = axisParent, testNode
+		return step, nil
+	case "node()":
+		step.test = testNode
+		return step, nil
+	case "text()":
+		step.test = testText
+		return step, nil
+	case "comment()":
+		step.test = testComment
+		return step, nil
+	case "processing-instruction()":
+		step.test = testProcInst
+		return step, nil
+	}
+	if strings.HasPrefix(tok, "@") {
+		step.axis = axisAttribute
+		tok = tok[1:]
+	}
+	if tok == "*" {
+		step.local, step.anySpace = "*", true
+		return step, nil
+	}
+	prefix, local, prefixed := strings.Cut(tok, ":")
+	if !prefixed {
+		prefix, local = "", tok
+	}
+	if !isPathName(local) && local != "*" || prefixed && !isPathName(prefix) {
+		return step, fmt.Errorf("invalid step %q", tok)
+	}
+	step.local = local
+	switch {
+	case prefix == "xml":
+		step.space = xmlNamespace
+	case prefixed:
+		ns, ok := namespaces[prefix]
+		if !ok {
+			return step, fmt.Errorf("prefix %q is not bound", prefix)
+		}
+		step.space = ns
+	case step.axis != axisAttribute:
+		// the default namespace does not apply to attributes
+		step.space = namespaces[""]
+	}
+	return step, nil
+}
+
+// isPathName reports whether s can be a prefix or local name in a path.
+func isPathName(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\r\n/@:*()[]='\"")
+}
+
+// String returns the source of the path expression.
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Select returns the nodes selected by the path with n as the context node,
+// in document order and without duplicates. Absolute paths start at the
+// document of n, or at the outermost ancestor of n if n is not part of a
+// document. Attributes are returned as *Attribute.
+func (p *Path) Select(n XMLNode) []XMLNode {
+	ctx := []location{locate(n)}
+	if p.absolute {
+		ctx[0] = ctx[0].root()
+	}
+	// disjoint is set if no node in ctx is a descendant of another. The
+	// steps from disjoint nodes in document order find the nodes in
+	// document order.
+	disjoint := true
+	for _, step := range p.steps {
+		var next []location
+		for _, l := range ctx {
+			next = step.apply(l, next)
+		}
+		switch step.axis {
+		case axisChild:
+			if !disjoint {
+				next = documentOrder(next)
+			}
+		case axisDescendant, axisDescendantOrSelf:
+			if !disjoint {
+				next = documentOrder(next)
+			}
+			disjoint = false
+		case axisParent:
+			if len(ctx) > 1 {
+				next = documentOrder(next)
+			}
+			disjoint = false
+		case axisAttribute:
+			disjoint = true
+		}
+		ctx = next
+		if len(ctx) == 0 {
+			return nil
+		}
+	}
+	nodes := make([]XMLNode, len(ctx))
+	for i, l := range ctx {
+		nodes[i] = l.node
+	}
+	return nodes
+}
+
+// First returns the first node selected by the path with n as the context
+// node, nil if there is none. The evaluation stops at the first match, see
+// Each.
+func (p *Path) First(n XMLNode) XMLNode {
+	var first XMLNode
+	p.Each(n, func(found XMLNode) bool {
+		first = found
+		return false
+	})
+	return first
+}
+
+// SelectN returns at most limit nodes selected by the path with n as the
+// context node, the first ones in document order. The evaluation stops
+// after limit matches, see Each. A limit of zero or less returns all nodes
+// like Select.
+func (p *Path) SelectN(n XMLNode, limit int) []XMLNode {
+	if limit <= 0 {
+		return p.Select(n)
+	}
+	var nodes []XMLNode
+	p.Each(n, func(found XMLNode) bool {
+		nodes = append(nodes, found)
+		return len(nodes) < limit
+	})
+	return nodes
+}
+
+// Find returns the nodes selected by the path expression expr with n as the
+// context node, see Path.
+func Find(n XMLNode, expr string, namespaces map[string]string) ([]XMLNode, error) {
+	p, err := CompilePath(expr, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return p.Select(n), nil
+}
+
+// FindFirst returns the first node selected by the path expression expr
+// with n as the context node, nil if there is none, see Path.First.
+func FindFirst(n XMLNode, expr string, namespaces map[string]string) (XMLNode, error) {
+	p, err := CompilePath(expr, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return p.First(n), nil
+}
+
+// apply appends the nodes on the axis of the step from l that pass the node
+// test to found.
+func (s pathStep) apply(l location, found []location) []location {
+	if len(s.predicates) > 0 {
+		start := len(found)
+		found = s.nodes(l, found)
+		return append(found[:start], s.filter(found[start:])...)
+	}
+	return s.nodes(l, found)
+}
+
+// nodes appends the nodes on the axis of the step from l that pass the node
+// test to found.
+func (s pathStep) nodes(l location, found []location) []location {
+	switch s.axis {
+	case axisChild:
+		for i, c := range l.node.Children() {
+			if s.matches(c) {
+				found = append(found, location{node: c, parent: l.node, index: i})
+			}
+		}
+	case axisDescendantOrSelf:
+		if s.matches(l.node) {
+			found = append(found, l)
+		}
+		found = s.descendants(l.node, found)
+	case axisDescendant:
+		found = s.descendants(l.node, found)
+	case axisSelf:
+		if s.matches(l.node) {
+			found = append(found, l)
+		}
+	case axisParent:
+		if l.parent != nil && s.matches(l.parent) {
+			found = append(found, locate(l.parent))
+		}
+	case axisAttribute:
+		if elt, ok := l.node.(*Element); ok {
+			for i, attr := range elt.attributes {
+				if s.matches(attr) {
+					found = append(found, location{node: attr, parent: elt, index: -1 - i})
+				}
+			}
+		}
+	}
+	return found
+}
+
+// filter returns the locations that pass the predicates of the step. The
+// result uses the memory of locs.
+func (s pathStep) filter(locs []location) []location {
+	for _, pred := range s.predicates {
+		if pred.kind == predPosition {
+			if pred.position > len(locs) {
+				return locs[:0]
+			}
+			i := pred.position - 1
+			if pred.fromEnd {
+				i = len(locs) - pred.position
+			}
+			locs = append(locs[:0], locs[i])
+			continue
+		}
+		kept := locs[:0]
+		for _, l := range locs {
+			if pred.accepts(l.node) {
+				kept = append(kept, l)
+			}
+		}
+		locs = kept
+	}
+	return locs
+}
+
+// accepts reports whether n passes the predicates of the step, which must not
+// contain positions.
+func (s pathStep) accepts(n XMLNode) bool {
+	for _, pred := range s.predicates {
+		if !pred.accepts(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// accepts reports whether n passes the predicate, which is not a position.
+func (pred pathPredicate) accepts(n XMLNode) bool {
+	switch pred.kind {
+	case predSelf:
+		return !pred.hasValue || nodeString(n) == pred.value
+	case predAttribute:
+		if elt, ok := n.(*Element); ok {
+			for _, attr := range elt.attributes {
+				if pred.name.matches(attr) && (!pred.hasValue || attr.Value == pred.value) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, c := range n.Children() {
+		switch t := c.(type) {
+		case *Element:
+			if pred.kind == predChild && pred.name.matches(t) && (!pred.hasValue || t.stringvalue() == pred.value) {
+				return true
+			}
+		case CharData:
+			if pred.kind == predText && (!pred.hasValue || t.Contents == pred.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeString returns the string value of n.
+func nodeString(n XMLNode) string {
+	switch t := n.(type) {
+	case *Element:
+		return t.stringvalue()
+	case *Attribute:
+		return t.Value
+	case CharData:
+		return t.Contents
+	case Comment:
+		return t.Contents
+	case ProcInst:
+		return string(t.Inst)
+	case EntityRef:
+		return t.Value
+	}
+	var sb strings.Builder
+	for _, c := range n.Children() {
+		if elt, ok := c.(*Element); ok {
+			sb.WriteString(elt.stringvalue())
+		}
+	}
+	return sb.String()
+}
+
+func (s pathStep) descendants(n XMLNode, found []location) []location {
+	for i, c := range n.Children() {
+		if s.matches(c) {
+			found = append(found, location{node: c, parent: n, index: i})
+		}
+		if _, ok := c.(*Element); ok {
+			found = s.descendants(c, found)
+		}
+	}
+	return found
+}
+
+// matches reports whether n passes the node test of the step.
+func (s pathStep) matches(n XMLNode) bool {
+	switch s.test {
+	case testNode:
+		return true
+	case testText:
+		_, ok := n.(CharData)
+		return ok
+	case testComment:
+		_, ok := n.(Comment)
+		return ok
+	case testProcInst:
+		_, ok := n.(ProcInst)
+		return ok
+	}
+	var space, local string
+	switch t := n.(type) {
+	case *Element:
+		if s.axis == axisAttribute {
+			return false
+		}
+		space, local = t.NamespaceURI(), t.Name
+	case *Attribute:
+		if s.axis != axisAttribute {
+			return false
+		}
+		space, local = t.Namespace, t.Name
+	default:
+		return false
+	}
+	return (s.anySpace || s.space == space) && (s.local == "*" || s.local == local)
+}
+
+// location is a node in a path result together with its place in the tree,
+// since only elements know their parent. index is the position in the
+// children of parent, or -1-i for the ith attribute of parent.
+type location struct {
+	node   XMLNode
+	parent XMLNode
+	index  int
+}
+
+type locationKey struct {
+	parent XMLNode
+	index  int
+}
+
+// locate returns the location of n. The parent of nodes other than elements
+// is not known.
+func locate(n XMLNode) location {
+	elt, ok := n.(*Element)
+	if !ok || elt.Parent == nil {
+		return location{node: n}
+	}
+	for i, c := range elt.Parent.Children() {
+		if c == n {
+			return location{node: n, parent: elt.Parent, index: i}
+		}
+	}
+	return location{node: n}
+}
+
+// key identifies the location in its tree, where only the root has no
+// parent.
+func (l location) key() locationKey {
+	return locationKey{parent: l.parent, index: l.index}
+}
+
+// root returns the location of the document or of the outermost ancestor.
+func (l location) root() location {
+	n := l.parent
+	if n == nil {
+		return l
+	}
+	for {
+		elt, ok := n.(*Element)
+		if !ok || elt.Parent == nil {
+			return location{node: n}
+		}
+		n = elt.Parent
+	}
+}
+
+// documentOrder sorts the locations in document order and removes the
+// duplicates. All locations must be in the same tree.
+func documentOrder(locs []location) []location {
+	want := make(map[locationKey]bool, len(locs))
+	for _, l := range locs {
+		want[l.key()] = true
+	}
+	if len(want) == 1 {
+		return locs[:1]
+	}
+	sorted := make([]location, 0, len(want))
+	var walk func(l location)
+	walk = func(l location) {
+		if len(sorted) == len(want) {
+			return
+		}
+		if want[l.key()] {
+			sorted = append(sorted, l)
+		}
+		if elt, ok := l.node.(*Element); ok {
+			for i, attr := range elt.attributes {
+				if want[locationKey{parent: elt, index: -1 - i}] {
+					sorted = append(sorted, location{node: attr, parent: elt, index: -1 - i})
+				}
+			}
+		}
+		for i, c := range l.node.Children() {
+			walk(location{node: c, parent: l.node, index: i})
+		}
+	}
+	walk(locs[0].root())
+	return sorted
+}
+
+// Each calls fn for the nodes selected by the path with n as the context
+// node, in the order of Select, until fn returns false. The nodes are found
+// during a single walk over the tree without collecting them first, so the
+// memory used does not grow with the number of results, and the walk ends
+// as soon as fn returns false. Paths with a .. step, with steps after an
+// attribute step or with position predicates are evaluated with Select.
+func (p *Path) Each(n XMLNode, fn func(XMLNode) bool) {
+	if !p.streamable() {
+		for _, found := range p.Select(n) {
+			if !fn(found) {
+				return
+			}
+		}
+		return
+	}
+	start := locate(n)
+	if p.absolute {
+		start = start.root()
+	}
+	if len(p.steps) == 0 {
+		fn(start.node)
+		return
+	}
+	ps := pathStream{steps: p.steps, fn: fn}
+	for _, step := range p.steps {
+		switch step.axis {
+		case axisChild:
+			ps.depth++
+		case axisDescendant, axisDescendantOrSelf:
+			ps.depth = -1
+		}
+		if ps.depth < 0 {
+			break
+		}
+	}
+	ps.walk(start.node)
+}
+
+// streamable reports whether Each can evaluate the path in one walk.
+func (p *Path) streamable() bool {
+	for i, step := range p.steps {
+		if step.axis == axisParent || step.axis == axisAttribute && i < len(p.steps)-1 || step.positional() {
+			return false
+		}
+	}
+	return true
+}
+
+// pathStream finds the nodes of a path in a walk over the tree in document
+// order. A node is selected if the steps of the path lead from the start
+// node to it, which is checked backwards through its ancestors.
+type pathStream struct {
+	steps []pathStep
+	fn    func(XMLNode) bool
+	// stack contains the start node and the ancestors of the current node
+	// down to the current node
+	stack []XMLNode
+	// memo holds the results of matches per stack level and step: 0 for
+	// unknown, 1 for no match, 2 for a match
+	memo [][]int8
+	// depth limits the walk for paths without descendant steps, -1 for no
+	// limit
+	depth int
+}
+
+// walk visits n and its descendants. It returns false when fn has stopped
+// the walk.
+func (ps *pathStream) walk(n XMLNode) bool {
+	j := len(ps.stack)
+	ps.stack = append(ps.stack, n)
+	if j < len(ps.memo) {
+		for k := range ps.memo[j] {
+			ps.memo[j][k] = 0
+		}
+	} else {
+		ps.memo = append(ps.memo, make([]int8, len(ps.steps)))
+	}
+	defer func() { ps.stack = ps.stack[:j] }()
+	last := len(ps.steps) - 1
+	if ps.matches(j, last) && !ps.fn(n) {
+		return false
+	}
+	elt, ok := n.(*Element)
+	if ok && last >= 0 && ps.steps[last].axis == axisAttribute {
+		for _, attr := range elt.attributes {
+			if ps.steps[last].matches(attr) && ps.steps[last].accepts(attr) && ps.matches(j, last-1) && !ps.fn(attr) {
+				return false
+			}
+		}
+	}
+	if ps.depth >= 0 && j >= ps.depth {
+		return true
+	}
+	for _, c := range n.Children() {
+		if !ps.walk(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the steps up to and including the step k lead
+// from the start node to the node on stack level j. For k == -1 this is the
+// start node itself.
+func (ps *pathStream) matches(j, k int) bool {
+	if k < 0 {
+		return j == 0
+	}
+	if m := ps.memo[j][k]; m != 0 {
+		return m == 2
+	}
+	step := ps.steps[k]
+	found := false
+	if step.axis != axisAttribute && step.matches(ps.stack[j]) && step.accepts(ps.stack[j]) {
+		switch step.axis {
+		case axisChild:
+			found = j > 0 && ps.matches(j-1, k-1)
+		case axisSelf:
+			found = ps.matches(j, k-1)
+		case axisDescendant, axisDescendantOrSelf:
+			to := j - 1
+			if step.axis == axisDescendantOrSelf {
+				to = j
+			}
+			for i := 0; i <= to && !found; i++ {
+				found = ps.matches(i, k-1)
+			}
+		}
+	}
+	ps.memo[j][k] = 1
+	if found {
+		ps.memo[j][k] = 2
+	}
+	return found
+}
@@ -0,0 +1,150 @@
+package goxml
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pathResult returns the selected nodes as a space separated list of element
+// names, attribute values and texts.
+func pathResult(nodes []XMLNode) string {
+	var s []string
+	for _, n := range nodes {
+		switch t := n.(type) {
+		case *Element:
+			s = append(s, t.Name)
+		case *Attribute:
+			s = append(s, "@"+t.Value)
+		default:
+			s = append(s, nodeString(n))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+const pathTestDoc = `<shop>
+<item sku="A"><name>x</name>one</item>
+<item sku="B"><name>y</name>z</item>
+<item sku="C"><name>x</name><part sku="D"/>z</item>
+</shop>`
+
+func TestPathPredicates(t *testing.T) {
+	doc, _ := parseRoot(t, pathTestDoc)
+	tests := []struct {
+		expr, want string
+	}{
+		{"/shop/item[@sku='B']/name", "name"},
+		{"/shop/item[@sku='B']/@sku", "@B"},
+		{"/shop/item[@sku]/@sku", "@A @B @C"},
+		{"//*[@sku='D']", "part"},
+		{"/shop/item[text()='z']/@sku", "@B @C"},
+		{"/shop/item[name='x']/@sku", "@A @C"},
+		{"/shop/item[part]/@sku", "@C"},
+		{"/shop/item[.='xone']/@sku", "@A"},
+		{"/shop/item[2]/@sku", "@B"},
+		{"/shop/item[4]", ""},
+		{"/shop/item[last()]/@sku", "@C"},
+		{"/shop/item[last()-1]/@sku", "@B"},
+		{"/shop/item[last() - 2]/@sku", "@A"},
+		{"/shop/item[last()-3]", ""},
+		{"/shop/item[name='x'][last()]/@sku", "@C"},
+		{"/shop/item[last()][name='y']", ""},
+		{"//name[1]", "name name name"},
+		{"(//name)[1]", ""},
+	}
+	for _, tc := range tests {
+		p, err := CompilePath(tc.expr, nil)
+		if err != nil {
+			if tc.want != "" {
+				t.Errorf("CompilePath(%q): %v", tc.expr, err)
+			}
+			continue
+		}
+		if got := pathResult(p.Select(doc)); got != tc.want {
+			t.Errorf("%s selects %q, want %q", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestPathInvalidPredicates(t *testing.T) {
+	for _, expr := range []string{
+		"item[0]",
+		"item[last()+1]",
+		"item[last()-x]",
+		"item[@sku=B]",
+		"item[@sku='B]",
+		"item[@sku='B'",
+		"..[1]",
+	} {
+		if _, err := CompilePath(expr, nil); err == nil {
+			t.Errorf("CompilePath(%q) succeeds, want an error", expr)
+		}
+	}
+}
+
+func TestPathConcurrentSelect(t *testing.T) {
+	doc, _ := parseRoot(t, pathTestDoc)
+	p, err := CompilePath("//item[name='x'][.='xz']/@sku", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := pathResult(p.Select(doc)); got != "@C" {
+				t.Errorf("Select = %q, want @C", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+const pathStepDoc = `<book xmlns:x="X" xml:lang="en"><chapter id="1"><title>A</title><x:note>n</x:note><!--c--><?pi data?></chapter><chapter id="2"><para>p<b>q</b></para></chapter></book>`
+
+func TestPathSteps(t *testing.T) {
+	doc, root := parseRoot(t, pathStepDoc)
+	tests := []struct {
+		expr string
+		ns   map[string]string
+		want string
+	}{
+		{"/book/chapter/@id", nil, "@1 @2"},
+		{"//title", nil, "title"},
+		{"/book/@xml:lang", nil, "@en"},
+		{"//para/text()", nil, "p"},
+		{"//b/../..", nil, "chapter"},
+		{"/book/*/*", nil, "title note para"},
+		{"//y:*", map[string]string{"y": "X"}, "note"},
+		{"//y:note", map[string]string{"y": "X"}, "note"},
+		{"//note", nil, ""},
+		{"/book/chapter[1]/comment()", nil, "c"},
+		{"/book/chapter[1]/processing-instruction()", nil, "data"},
+		{"/book/chapter[1]/node()", nil, "title note c data"},
+		{"chapter[2]//node()", nil, "para p b q"},
+		{"//chapter/.", nil, "chapter chapter"},
+	}
+	for _, tc := range tests {
+		p, err := CompilePath(tc.expr, tc.ns)
+		if err != nil {
+			t.Errorf("CompilePath(%q): %v", tc.expr, err)
+			continue
+		}
+		ctx := XMLNode(doc)
+		if !strings.HasPrefix(tc.expr, "/") {
+			ctx = root
+		}
+		if got := pathResult(p.Select(ctx)); got != tc.want {
+			t.Errorf("%s selects %q, want %q", tc.expr, got, tc.want)
+		}
+	}
+	if _, err := CompilePath("//u:x", nil); err == nil {
+		t.Error("CompilePath succeeds with an unbound prefix")
+	}
+	p, _ := CompilePath("//chapter", nil)
+	if got := p.First(doc); got == nil || got.(*Element).Attributes()[0].Value != "1" {
+		t.Errorf("First returns %v, want the first chapter", got)
+	}
+}
@@ -0,0 +1,236 @@
+package goxml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// TokenFilter is one step of a Pipeline. Filter is called for every token
+// and passes tokens on to the next step by calling emit, any number of times.
+// The names of the tokens are not namespace-resolved, Name.Space contains the
+// prefix as written. Tokens are only valid during the call, a filter that
+// keeps a token must copy it with xml.CopyToken.
+type TokenFilter interface {
+	Filter(tok xml.Token, emit func(xml.Token) error) error
+}
+
+// TokenFilterFunc is a function that is a TokenFilter.
+type TokenFilterFunc func(tok xml.Token, emit func(xml.Token) error) error
+
+// Filter calls f.
+func (f TokenFilterFunc) Filter(tok xml.Token, emit func(xml.Token) error) error {
+	return f(tok, emit)
+}
+
+// Pipeline streams a document from a reader through a chain of TokenFilters
+// to a writer, without building the tree. Memory use does not depend on the
+// size of the document.
+type Pipeline struct {
+	filters []TokenFilter
+}
+
+// NewPipeline returns a Pipeline that applies filters in the given order.
+func NewPipeline(filters ...TokenFilter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Add appends filters to the pipeline.
+func (pl *Pipeline) Add(filters ...TokenFilter) *Pipeline {
+	pl.filters = append(pl.filters, filters...)
+	return pl
+}
+
+// Run reads the document from r, passes each token through the filters and
+// writes the result to w.
+func (pl *Pipeline) Run(r io.Reader, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	tw := &tokenWriter{xw: newXMLWriter(bw)}
+	emit := tw.write
+	for i := len(pl.filters) - 1; i >= 0; i-- {
+		f, next := pl.filters[i], emit
+		emit = func(tok xml.Token) error {
+			return f.Filter(tok, next)
+		}
+	}
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = emit(tok); err != nil {
+			return err
+		}
+	}
+	if err := tw.finish(); err != nil {
+		return err
+	}
+	_, err := tw.xw.flush(bw)
+	return err
+}
+
+// tokenWriter serializes tokens. The closing bracket of a start tag is
+// delayed, so that elements without content are written as empty element
+// tags.
+type tokenWriter struct {
+	xw      *xmlWriter
+	pending bool
+}
+
+func (tw *tokenWriter) write(tok xml.Token) error {
+	xw := tw.xw
+	if _, ok := tok.(xml.EndElement); ok && tw.pending {
+		tw.pending = false
+		xw.writeString(" />")
+		return xw.err
+	}
+	tw.finish()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		xw.writeString("<", qualifiedName(t.Name.Space, t.Name.Local))
+		for _, attr := range t.Attr {
+			xw.writeString(" ", qualifiedName(attr.Name.Space, attr.Name.Local), "=\"")
+			xw.writeAttributeValue(attr.Value)
+			xw.writeString("\"")
+		}
+		tw.pending = true
+	case xml.EndElement:
+		xw.writeString("</", qualifiedName(t.Name.Space, t.Name.Local), ">")
+	case xml.CharData:
+		xw.writeCharData(string(t))
+	case xml.Comment:
+		xw.writeString("<!--")
+		xw.writeText(string(t), escapeNone)
+		xw.writeString("-->")
+	case xml.ProcInst:
+		xw.writeString("<?", t.Target)
+		if len(t.Inst) > 0 {
+			xw.writeString(" ")
+			xw.writeText(string(t.Inst), escapeNone)
+		}
+		xw.writeString("?>")
+	case xml.Directive:
+		xw.writeString("<!", string(t), ">")
+	}
+	return xw.err
+}
+
+// finish closes a pending start tag.
+func (tw *tokenWriter) finish() error {
+	if tw.pending {
+		tw.pending = false
+		tw.xw.writeString(">")
+	}
+	return tw.xw.err
+}
+
+// RenameElements returns a filter that renames the elements whose qualified
+// name is a key of names to the corresponding value.
+func RenameElements(names map[string]string) TokenFilter {
+	rename := func(name xml.Name) xml.Name {
+		if n, ok := names[qualifiedName(name.Space, name.Local)]; ok {
+			return splitQName(n)
+		}
+		return name
+	}
+	return TokenFilterFunc(func(tok xml.Token, emit func(xml.Token) error) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			t.Name = rename(t.Name)
+			return emit(t)
+		case xml.EndElement:
+			t.Name = rename(t.Name)
+			return emit(t)
+		}
+		return emit(tok)
+	})
+}
+
+// DropElements returns a filter that removes the elements with one of the
+// qualified names together with their contents. The filter keeps track of
+// the nesting, so it must not be used in two pipelines at the same time.
+func DropElements(names ...string) TokenFilter {
+	drop := make(map[string]bool, len(names))
+	for _, n := range names {
+		drop[n] = true
+	}
+	depth := 0
+	return TokenFilterFunc(func(tok xml.Token, emit func(xml.Token) error) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth > 0 || drop[qualifiedName(t.Name.Space, t.Name.Local)] {
+				depth++
+				return nil
+			}
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+				return nil
+			}
+		}
+		if depth > 0 {
+			return nil
+		}
+		return emit(tok)
+	})
+}
+
+// AddAttribute returns a filter that sets the attribute name to value on all
+// elements with the qualified name element. An existing attribute of that
+// name is replaced.
+func AddAttribute(element, name, value string) TokenFilter {
+	attrName := splitQName(name)
+	return TokenFilterFunc(func(tok xml.Token, emit func(xml.Token) error) error {
+		t, ok := tok.(xml.StartElement)
+		if !ok || qualifiedName(t.Name.Space, t.Name.Local) != element {
+			return emit(tok)
+		}
+		attrs := make([]xml.Attr, 0, len(t.Attr)+1)
+		for _, attr := range t.Attr {
+			if attr.Name != attrName {
+				attrs = append(attrs, attr)
+			}
+		}
+		t.Attr = append(attrs, xml.Attr{Name: attrName, Value: value})
+		return emit(t)
+	})
+}
+
+// RewriteNamespace returns a filter that changes all declarations of the
+// namespace oldURI to newURI, which moves the elements and attributes in
+// that namespace to the new namespace.
+func RewriteNamespace(oldURI, newURI string) TokenFilter {
+	return TokenFilterFunc(func(tok xml.Token, emit func(xml.Token) error) error {
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			return emit(tok)
+		}
+		var attrs []xml.Attr
+		for i, attr := range t.Attr {
+			isDecl := attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns"
+			if isDecl && attr.Value == oldURI {
+				if attrs == nil {
+					attrs = append([]xml.Attr(nil), t.Attr...)
+				}
+				attrs[i].Value = newURI
+			}
+		}
+		if attrs != nil {
+			t.Attr = attrs
+		}
+		return emit(t)
+	})
+}
+
+// splitQName returns the qualified name s with the prefix in Space.
+func splitQName(s string) xml.Name {
+	if prefix, local, ok := strings.Cut(s, ":"); ok {
+		return xml.Name{Space: prefix, Local: local}
+	}
+	return xml.Name{Local: s}
+}
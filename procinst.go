@@ -0,0 +1,66 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// PseudoAttributes reads the contents of the processing instruction as
+// pseudo-attributes like those of xml-stylesheet:
+//
+//	<?xml-stylesheet type="text/xsl" href="style.xsl"?>
+//
+// The values are in single or double quotes, character references and the
+// predefined entities in them are expanded. Contents that are not a list of
+// pseudo-attributes return an error.
+func (pi ProcInst) PseudoAttributes() ([]xml.Attr, error) {
+	var attrs []xml.Attr
+	s := string(pi.Inst)
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return attrs, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("<?%s?>: missing = in pseudo-attribute %q", pi.Target, s)
+		}
+		name := strings.TrimRight(s[:eq], " \t\r\n")
+		if strings.ContainsAny(name, " \t\r\n\"'") {
+			return nil, fmt.Errorf("<?%s?>: invalid pseudo-attribute name %q", pi.Target, name)
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t\r\n")
+		if s == "" || s[0] != '"' && s[0] != '\'' {
+			return nil, fmt.Errorf("<?%s?>: value of pseudo-attribute %s not quoted", pi.Target, name)
+		}
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return nil, fmt.Errorf("<?%s?>: unterminated value of pseudo-attribute %s", pi.Target, name)
+		}
+		value, _ := unquoteDTD(s[:end+2])
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+		s = s[end+2:]
+		if s != "" && !isXMLSpace(rune(s[0])) {
+			return nil, fmt.Errorf("<?%s?>: missing space after pseudo-attribute %s", pi.Target, name)
+		}
+	}
+}
+
+// SetPseudoAttributes sets the contents of the processing instruction to
+// the pseudo-attributes in attrs. The values are written in double quotes
+// with &, <, >, " and white space other than spaces escaped. A ProcInst is
+// a value, so to change one in a document, the changed copy has to replace
+// the original.
+func (pi *ProcInst) SetPseudoAttributes(attrs []xml.Attr) {
+	var sb strings.Builder
+	for i, attr := range attrs {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(attr.Name.Local)
+		sb.WriteByte('=')
+		sb.WriteString(strings.ReplaceAll(AttrQuote(attr.Value), ">", "&gt;"))
+	}
+	pi.Inst = []byte(sb.String())
+}
@@ -0,0 +1,130 @@
+package goxml
+
+import "strings"
+
+// ProfileOptions selects the elements Profile keeps.
+type ProfileOptions struct {
+	// Conditions maps the names of the profiling attributes, such as
+	// audience or os, to the selected values.
+	Conditions map[string][]string
+	// Namespace is the namespace of the profiling attributes, empty for
+	// attributes without prefix.
+	Namespace string
+	// Separator separates several values in an attribute. The default is
+	// ";" as in DocBook, white space around the values is ignored.
+	Separator string
+	// Match reports whether the value of an attribute matches a selected
+	// value. The default compares the values for equality.
+	Match func(value, selected string) bool
+	// RemoveAttributes removes the profiling attributes of Conditions from
+	// the elements that are kept.
+	RemoveAttributes bool
+}
+
+// Profile returns a copy of the document without the elements that are not
+// selected by the profiling attributes in opts, like the effectivity
+// attributes of DocBook:
+//
+//	<para os="linux;mac">...</para>
+//
+// is kept with the condition os: linux and removed with os: windows. An
+// element is kept if each of its profiling attributes has a value that
+// matches one of the selected values of the attribute. Attributes without
+// a condition, empty attributes and elements without profiling attributes
+// do not restrict anything. A removed element is removed with its subtree,
+// the white space around it is kept. If the root element is removed, the
+// copy has none. The nodes of the copy are numbered anew, xr is not
+// changed.
+func (xr *XMLDocument) Profile(opts ProfileOptions) *XMLDocument {
+	if opts.Separator == "" {
+		opts.Separator = ";"
+	}
+	if opts.Match == nil {
+		opts.Match = func(value, selected string) bool { return value == selected }
+	}
+	doc := NewDocument()
+	doc.baseURI = xr.baseURI
+	doc.source = xr.source
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok && !opts.selected(elt) {
+			continue
+		}
+		cp := snapshotNode(c, doc)
+		if elt, ok := cp.(*Element); ok {
+			opts.prune(elt)
+		}
+		doc.children = append(doc.children, cp)
+	}
+	doc.renumber()
+	return doc
+}
+
+// selected reports whether the profiling attributes of elt match the
+// conditions.
+func (opts *ProfileOptions) selected(elt *Element) bool {
+	for _, attr := range elt.attributes {
+		if attr.Namespace != opts.Namespace {
+			continue
+		}
+		wanted, ok := opts.Conditions[attr.Name]
+		if !ok || strings.TrimSpace(attr.Value) == "" {
+			continue
+		}
+		if !opts.matches(attr.Value, wanted) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether one of the values in value matches one of the
+// wanted values.
+func (opts *ProfileOptions) matches(value string, wanted []string) bool {
+	for _, v := range strings.Split(value, opts.Separator) {
+		v = strings.TrimSpace(v)
+		for _, w := range wanted {
+			if opts.Match(v, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prune removes the children of the copied element elt that are not
+// selected and the profiling attributes if requested. It reports whether
+// the subtree has changed.
+func (opts *ProfileOptions) prune(elt *Element) bool {
+	changed := false
+	if opts.RemoveAttributes {
+		attrs := elt.attributes[:0]
+		for _, attr := range elt.attributes {
+			if _, ok := opts.Conditions[attr.Name]; !ok || attr.Namespace != opts.Namespace {
+				attrs = append(attrs, attr)
+			}
+		}
+		changed = len(attrs) < len(elt.attributes)
+		elt.attributes = attrs
+	}
+	children := elt.children[:0]
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			if !opts.selected(cld) {
+				changed = true
+				continue
+			}
+			if opts.prune(cld) {
+				changed = true
+			}
+		}
+		children = append(children, c)
+	}
+	elt.children = children
+	if changed {
+		// the copy is not written like the source
+		elt.svCache = &stringvalueCache{}
+		elt.serializedCached = false
+		elt.spanEnd = 0
+	}
+	return changed
+}
@@ -0,0 +1,161 @@
+package goxml
+
+import (
+	"errors"
+	"strings"
+)
+
+// RecordOptions controls Records.
+type RecordOptions struct {
+	// Row is a path expression, see Path, that selects the row elements. If
+	// it is empty, the rows are the elements that occur most often at the
+	// same position in the tree, such as the book elements of a catalog.
+	// Elements without attributes and child elements are not taken as rows.
+	Row string
+	// Namespaces binds the prefixes in Row.
+	Namespaces map[string]string
+	// AttributePrefix is put before the names of attribute columns, such as
+	// "@", to tell them from child elements with the same name. Without a
+	// prefix, a child element overrides an attribute of the same name.
+	AttributePrefix string
+	// Separator joins the values of child elements that occur more than
+	// once in a row, such as several authors of a book. If it is empty, the
+	// first value is taken.
+	Separator string
+}
+
+// Records returns the data of the row elements, one map per row, so that
+// data files can be processed without code for each format. The keys are
+// the column names and columns contains them in the order of their first
+// occurrence. The columns of a row are its attributes and its child
+// elements with their string values, trimmed of white space at both ends.
+// Child elements with element children of their own are not columns, their
+// children and attributes are, with the names joined by slashes, such as
+// address/city. Names are local names, prefixes are ignored. Namespace
+// declarations are not columns.
+func (xr *XMLDocument) Records(opts RecordOptions) (records []map[string]string, columns []string, err error) {
+	var rows []*Element
+	if opts.Row != "" {
+		nodes, err := Find(xr, opts.Row, opts.Namespaces)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, n := range nodes {
+			if elt, ok := n.(*Element); ok {
+				rows = append(rows, elt)
+			}
+		}
+	} else {
+		rows = xr.repeatingElements()
+		if rows == nil {
+			return nil, nil, errors.New("no repeating elements found")
+		}
+	}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		record := make(map[string]string)
+		opts.collect(record, make(map[string]bool), "", row)
+		for _, name := range opts.columns(row, "") {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, columns, nil
+}
+
+// collect adds the columns of elt to record, their names start with prefix.
+// children contains the names of the columns taken from child elements so
+// far, to tell repeated elements from attributes of the same name.
+func (opts *RecordOptions) collect(record map[string]string, children map[string]bool, prefix string, elt *Element) {
+	for _, attr := range elt.attributes {
+		name := prefix + opts.AttributePrefix + attr.Name
+		if _, ok := record[name]; !ok {
+			record[name] = attr.Value
+		}
+	}
+	for _, c := range elt.children {
+		cld, ok := c.(*Element)
+		if !ok {
+			continue
+		}
+		name := prefix + cld.Name
+		if hasChildElements(cld) {
+			opts.collect(record, children, name+"/", cld)
+			continue
+		}
+		value := strings.TrimSpace(cld.Stringvalue())
+		switch {
+		case !children[name]:
+			record[name] = value
+			children[name] = true
+		case opts.Separator != "":
+			record[name] += opts.Separator + value
+		}
+	}
+}
+
+// columns returns the column names of elt in document order.
+func (opts *RecordOptions) columns(elt *Element, prefix string) []string {
+	var names []string
+	for _, attr := range elt.attributes {
+		names = append(names, prefix+opts.AttributePrefix+attr.Name)
+	}
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			if hasChildElements(cld) {
+				names = append(names, opts.columns(cld, prefix+cld.Name+"/")...)
+			} else {
+				names = append(names, prefix+cld.Name)
+			}
+		}
+	}
+	return names
+}
+
+func hasChildElements(elt *Element) bool {
+	for _, c := range elt.children {
+		if _, ok := c.(*Element); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatingElements returns the elements at the path from the root element
+// that occurs most often, taking only paths whose elements have attributes
+// or child elements. If several paths occur equally often, the first one in
+// document order wins. The result is nil if no path occurs twice.
+func (xr *XMLDocument) repeatingElements() []*Element {
+	elements := make(map[string][]*Element)
+	var order []string
+	var visit func(elt *Element, path string)
+	visit = func(elt *Element, path string) {
+		path += "/" + elt.qualifiedName()
+		if len(elt.attributes) > 0 || hasChildElements(elt) {
+			if _, ok := elements[path]; !ok {
+				order = append(order, path)
+			}
+			elements[path] = append(elements[path], elt)
+		}
+		for _, c := range elt.children {
+			if cld, ok := c.(*Element); ok {
+				visit(cld, path)
+			}
+		}
+	}
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok {
+			visit(elt, "")
+		}
+	}
+	var rows []*Element
+	for _, path := range order {
+		if found := elements[path]; len(found) > 1 && len(found) > len(rows) {
+			rows = found
+		}
+	}
+	return rows
+}
@@ -0,0 +1,101 @@
+package goxml
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ReplaceOptions selects the text that ReplaceText and ReplaceTextRegexp
+// change. Element names are local names, so that they match regardless of
+// the prefix. The zero value selects all text.
+type ReplaceOptions struct {
+	// Include limits the changes to the text inside of the elements with
+	// these names, including their descendants. An empty list selects the
+	// text of all elements.
+	Include []string
+	// Exclude leaves the text inside of the elements with these names and
+	// their descendants unchanged, for example code or pre. Exclude takes
+	// precedence over Include.
+	Exclude []string
+}
+
+// ReplaceText replaces all occurrences of old by new in the text of the
+// document and returns the number of replacements. Only character data is
+// changed, never names, attributes, comments or other markup, so the result
+// is always well-formed, whatever the strings contain. Each text node is
+// searched on its own, text that is interrupted by markup such as <b> or an
+// entity reference kept with WithEntityRefs does not match. Changes are
+// reported to the observers as TextChanged events. An empty old string
+// changes nothing.
+func (xr *XMLDocument) ReplaceText(old, new string, opts ReplaceOptions) int {
+	if old == "" {
+		return 0
+	}
+	return replaceText(xr, opts, func(s string) (string, int) {
+		n := strings.Count(s, old)
+		if n == 0 {
+			return s, 0
+		}
+		return strings.ReplaceAll(s, old, new), n
+	})
+}
+
+// ReplaceTextRegexp replaces the matches of re in the text of the document by
+// repl like regexp.Regexp.ReplaceAllString, so repl can refer to submatches
+// with $1 or ${name}. It returns the number of replaced matches. The text
+// is selected like with ReplaceText.
+func (xr *XMLDocument) ReplaceTextRegexp(re *regexp.Regexp, repl string, opts ReplaceOptions) int {
+	return replaceText(xr, opts, func(s string) (string, int) {
+		n := len(re.FindAllStringIndex(s, -1))
+		if n == 0 {
+			return s, 0
+		}
+		return re.ReplaceAllString(s, repl), n
+	})
+}
+
+// replaceText applies replace to the selected text nodes below n.
+func replaceText(n XMLNode, opts ReplaceOptions, replace func(string) (string, int)) int {
+	count := 0
+	var visit func(elt *Element, selected bool)
+	visit = func(elt *Element, selected bool) {
+		if containsName(opts.Exclude, elt.Name) {
+			return
+		}
+		selected = selected || containsName(opts.Include, elt.Name)
+		for i, c := range elt.children {
+			switch t := c.(type) {
+			case *Element:
+				visit(t, selected)
+			case CharData:
+				if !selected {
+					continue
+				}
+				s, n := replace(t.Contents)
+				if n == 0 {
+					continue
+				}
+				count += n
+				doc := elt.invalidate()
+				changed := CharData{ID: t.ID, Contents: s, CDATA: t.CDATA}
+				elt.children[i] = changed
+				doc.notify(MutationEvent{Type: TextChanged, Target: elt, Node: changed, Index: i, OldValue: t.Contents, NewValue: s})
+			}
+		}
+	}
+	for _, c := range n.Children() {
+		if elt, ok := c.(*Element); ok {
+			visit(elt, len(opts.Include) == 0)
+		}
+	}
+	return count
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package goxml
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Building documents from untrusted strings
+//
+// Strings from users or other systems should never be joined with markup to
+// build XML, since a value with < or & changes the structure of the document.
+// Text is added as a node with Append(Textf(...)) and attributes with
+// SetAttribute, the serializer escapes their contents. AttrQuote and
+// EscapeText are for the places where XML has to be written as a string
+// nevertheless. ParseUntrusted parses documents from an untrusted source with
+// limits, ParseTrusted documents of the application itself.
+
+// Textf returns a text node with the text formatted like fmt.Sprintf. The
+// arguments cannot add markup, whatever characters they contain. Characters
+// not allowed in XML are handled when the document is serialized.
+func Textf(format string, args ...any) CharData {
+	return CharData{Contents: fmt.Sprintf(format, args...)}
+}
+
+// AttrQuote returns value as an attribute value in double quotes with &, <,
+// " and the white space characters that a parser would normalize escaped.
+// Characters not allowed in XML are replaced by U+FFFD.
+func AttrQuote(value string) string {
+	var sb strings.Builder
+	xw := newXMLWriter(&sb)
+	xw.illegalChars = CharReplace
+	xw.writeString(`"`)
+	xw.writeAttributeValue(value)
+	xw.writeString(`"`)
+	return sb.String()
+}
+
+// EscapeText returns s escaped for the contents of an element. Characters
+// not allowed in XML are replaced by U+FFFD.
+func EscapeText(s string) string {
+	var sb strings.Builder
+	xw := newXMLWriter(&sb)
+	xw.illegalChars = CharReplace
+	xw.writeCharData(s)
+	return sb.String()
+}
+
+// ParseTrusted parses a document of the application itself, such as a
+// template or a configuration file, like Parse.
+func ParseTrusted(r io.Reader, opts ...ParseOption) (*XMLDocument, error) {
+	return Parse(r, opts...)
+}
+
+// ParseUntrusted parses a document from an untrusted source with
+// WithSecureDefaults and WithoutDoctype. The options are applied afterwards,
+// so they can raise the limits.
+func ParseUntrusted(r io.Reader, opts ...ParseOption) (*XMLDocument, error) {
+	return Parse(r, append([]ParseOption{WithSecureDefaults(), WithoutDoctype()}, opts...)...)
+}
@@ -0,0 +1,90 @@
+package goxml
+
+import "io"
+
+// Sealed is an immutable document that is safe to share between goroutines,
+// for example a configuration or specification that is parsed once, kept in
+// a global variable and read by many requests at the same time. Create it
+// with XMLDocument.Seal.
+type Sealed struct {
+	doc *XMLDocument
+}
+
+// Seal freezes the document, see Freeze, and returns an immutable handle of
+// it. Besides the changes Freeze rejects, SetUserData and ClearUserData
+// panic for a sealed document, so that all methods of the handle, including
+// UserData, are safe for concurrent use. Undo recording and open
+// transactions of the document are dropped. The exported fields of the nodes
+// must not be changed, as Go cannot prevent that. Use Copy to get a
+// document that can be changed again.
+func (xr *XMLDocument) Seal() *Sealed {
+	xr.Freeze()
+	xr.sealed = true
+	xr.history = nil
+	xr.tx = nil
+	return &Sealed{doc: xr}
+}
+
+// Sealed reports whether Seal has been called for the document.
+func (xr *XMLDocument) Sealed() bool {
+	return xr.sealed
+}
+
+// errSealed is the panic value of a change of the user data of a sealed
+// document.
+const errSealed = "goxml: change of the user data of a sealed document"
+
+// Document returns the sealed document for the functions that read an
+// XMLDocument, such as LinkGraph and Namespaces. Its methods that change
+// the document panic.
+func (s *Sealed) Document() *XMLDocument {
+	return s.doc
+}
+
+// Root returns the root element of the document.
+func (s *Sealed) Root() (*Element, error) {
+	return s.doc.Root()
+}
+
+// Children returns the children of the document node.
+func (s *Sealed) Children() []XMLNode {
+	return s.doc.Children()
+}
+
+// Find returns the nodes selected by the path expression expr, see Find.
+func (s *Sealed) Find(expr string, namespaces map[string]string) ([]XMLNode, error) {
+	return Find(s.doc, expr, namespaces)
+}
+
+// FindFirst returns the first node selected by the path expression expr,
+// see FindFirst.
+func (s *Sealed) FindFirst(expr string, namespaces map[string]string) (XMLNode, error) {
+	return FindFirst(s.doc, expr, namespaces)
+}
+
+// Select returns the nodes selected by the compiled path p with the
+// document node as the context node.
+func (s *Sealed) Select(p *Path) []XMLNode {
+	return p.Select(s.doc)
+}
+
+// UserData returns the value attached to the node n under key before the
+// document was sealed, see XMLDocument.UserData.
+func (s *Sealed) UserData(n XMLNode, key string) any {
+	return s.doc.UserData(n, key)
+}
+
+// WriteTo writes the XML representation of the document to w.
+func (s *Sealed) WriteTo(w io.Writer) (int64, error) {
+	return s.doc.WriteTo(w)
+}
+
+// WriteXML writes the XML representation of the document to w with opts.
+func (s *Sealed) WriteXML(w io.Writer, opts SerializeOptions) error {
+	return s.doc.WriteXML(w, opts)
+}
+
+// Copy returns a copy of the document that can be changed, see Snapshot.
+func (s *Sealed) Copy() *XMLDocument {
+	return s.doc.Snapshot()
+}
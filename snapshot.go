@@ -0,0 +1,70 @@
+package goxml
+
+// Snapshot returns a copy of the document that is not affected by later
+// changes to xr. Only the node structure is copied. Names and text are
+// strings, which are immutable, so they are shared with xr and a snapshot
+// needs much less memory than the original document. The nodes in the
+// snapshot keep their IDs, so they can be matched with the nodes in xr. The
+// snapshot has a copy of the user data, the values themselves are shared.
+//
+// Snapshot is not copy-on-write: all elements and attributes are copied at
+// once, which takes time proportional to the size of the document. The tree
+// cannot share unchanged subtrees with xr, because every node has a single
+// Parent.
+func (xr *XMLDocument) Snapshot() *XMLDocument {
+	doc := &XMLDocument{ID: xr.ID, lastID: xr.lastID, baseURI: xr.baseURI, doctype: xr.doctype, doctypeIndex: xr.doctypeIndex}
+	doc.children = make([]XMLNode, len(xr.children))
+	for i, c := range xr.children {
+		doc.children[i] = snapshotNode(c, doc)
+	}
+	if xr.userData != nil {
+		doc.userData = make(map[int64]map[string]any, len(xr.userData))
+		for id, data := range xr.userData {
+			cp := make(map[string]any, len(data))
+			for k, v := range data {
+				cp[k] = v
+			}
+			doc.userData[id] = cp
+		}
+	}
+	return doc
+}
+
+func snapshotNode(n XMLNode, parent XMLNode) XMLNode {
+	elt, ok := n.(*Element)
+	if !ok {
+		// all other node types are values
+		return n
+	}
+	cp := *elt
+	cp.Parent = parent
+	cp.frozen = false
+	cp.inArena = false
+	cp.textTail = nil
+	if elt.svCache != nil {
+		// the copy changes independently of elt
+		sv := *elt.svCache
+		cp.svCache = &sv
+	}
+	if elt.Namespaces != nil {
+		cp.Namespaces = make(map[string]string, len(elt.Namespaces))
+		for k, v := range elt.Namespaces {
+			cp.Namespaces[k] = v
+		}
+	}
+	if elt.attributes != nil {
+		attrs := make([]Attribute, len(elt.attributes))
+		cp.attributes = make([]*Attribute, len(elt.attributes))
+		for i, attr := range elt.attributes {
+			attrs[i] = *attr
+			cp.attributes[i] = &attrs[i]
+		}
+	}
+	if elt.children != nil {
+		cp.children = make([]XMLNode, len(elt.children))
+		for i, c := range elt.children {
+			cp.children[i] = snapshotNode(c, &cp)
+		}
+	}
+	return &cp
+}
@@ -0,0 +1,59 @@
+package goxml
+
+import "sort"
+
+// SortChildren sorts the child elements of elt with less and keeps the order
+// of equal elements, for example to bring lists whose order has no meaning
+// into a canonical form. The text, comments and processing instructions
+// before a child element, back to the previous child element, move with it,
+// so that a comment stays in front of the element it describes and the
+// indentation is kept. The nodes after the last child element stay at the
+// end. In mixed content, the text before an element moves with it as well.
+// If the order changes, the children and the nodes after them in document
+// order get new IDs, as after InsertChild, and the observers of the document
+// are notified of the removal of all children and their insertion in the new
+// order.
+func (elt *Element) SortChildren(less func(a, b *Element) bool) {
+	type group struct {
+		elt   *Element
+		nodes []XMLNode
+	}
+	var groups []group
+	start := 0
+	for i, c := range elt.children {
+		if cld, ok := c.(*Element); ok {
+			groups = append(groups, group{elt: cld, nodes: elt.children[start : i+1]})
+			start = i + 1
+		}
+	}
+	sorted := append([]group(nil), groups...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i].elt, sorted[j].elt)
+	})
+	changed := false
+	for i := range groups {
+		if sorted[i].elt != groups[i].elt {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	doc := elt.invalidate()
+	old := elt.children
+	children := make([]XMLNode, 0, len(old))
+	for _, g := range sorted {
+		children = append(children, g.nodes...)
+	}
+	elt.children = append(children, old[start:]...)
+	if doc != nil {
+		doc.renumberFrom(elt, 0)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		doc.notify(MutationEvent{Type: NodeRemoved, Target: elt, Node: old[i], Index: i})
+	}
+	for i, n := range elt.children {
+		doc.notify(MutationEvent{Type: NodeAppended, Target: elt, Node: n, Index: i})
+	}
+}
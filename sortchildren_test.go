@@ -0,0 +1,40 @@
+package goxml
+
+import "testing"
+
+func TestSortChildren(t *testing.T) {
+	doc, r := parseRoot(t, `<r><p>hello<i>x</i>world</p><c n="2"/><c n="1"/>tail</r>`)
+	r.SortChildren(func(a, b *Element) bool {
+		return a.Name == "p" && b.Name == "c" || a.Name == "c" && b.Name == "c" && a.attributes[0].Value < b.attributes[0].Value
+	})
+	checkIDs(t, doc)
+	if got, want := r.ToXML(), `<r><p>hello<i>x</i>world</p><c n="1" /><c n="2" />tail</r>`; got != want {
+		t.Errorf("after SortChildren: %s, want %s", got, want)
+	}
+}
+
+func TestSortChildrenKeepsLeadingNodes(t *testing.T) {
+	doc, r := parseRoot(t, "<deps>\n  <!-- b --><dep>b</dep>\n  <dep>a</dep>\n  <dep>c</dep>\n</deps>")
+	c := r.children[6].(*Element)
+	doc.SetUserData(c, "k", "v")
+	events := 0
+	doc.Observe(func(MutationEvent) { events++ })
+	byText := func(a, b *Element) bool { return a.Stringvalue() < b.Stringvalue() }
+	r.SortChildren(byText)
+	if got, want := doc.ToXML(), "<deps>\n  <dep>a</dep>\n  <!-- b --><dep>b</dep>\n  <dep>c</dep>\n</deps>"; got != want {
+		t.Errorf("after SortChildren:\n%s\nwant\n%s", got, want)
+	}
+	checkIDs(t, doc)
+	if got := doc.UserData(c, "k"); got != "v" {
+		t.Errorf("user data after SortChildren = %v, want v", got)
+	}
+	if events == 0 {
+		t.Error("SortChildren does not notify the observers")
+	}
+	// sorted children are not changed
+	events = 0
+	r.SortChildren(byText)
+	if events != 0 {
+		t.Errorf("SortChildren of sorted children reports %d changes", events)
+	}
+}
@@ -0,0 +1,58 @@
+package goxml
+
+// Source returns the name of the input the document has been parsed from, as
+// set with WithSourceName.
+func (xr *XMLDocument) Source() string {
+	return xr.source
+}
+
+// Source returns the name of the input the element comes from. For
+// documents assembled from several inputs, this is the URI of the resource
+// an element has been included from by XInclude, or the source of the
+// document the element has been taken from by Merge3. Otherwise it is the
+// source of the document the element belongs to, see WithSourceName. Text
+// and other nodes come from the source of their parent element. Together
+// with Line and Pos, the source locates an element in the input.
+func (elt *Element) Source() string {
+	for cur := elt; ; {
+		if cur.source != "" {
+			return cur.source
+		}
+		switch p := cur.Parent.(type) {
+		case *Element:
+			cur = p
+		case *XMLDocument:
+			return p.source
+		default:
+			return ""
+		}
+	}
+}
+
+// SourceSpan returns the byte offsets of the start of the start tag and the
+// end of the end tag of the element in its source, see Source. ok is false
+// for elements not read by Parse, for elements without an end tag in the
+// source that Parse has repaired, and for elements whose contents or
+// attributes have been changed since, including changes to their
+// descendants. The offsets of elements parsed with ParseIndexed count from
+// the start of the indexed element.
+func (elt *Element) SourceSpan() (start, end int64, ok bool) {
+	if elt.spanEnd == 0 {
+		return 0, 0, false
+	}
+	return elt.spanStart, elt.spanEnd, true
+}
+
+// RawBytes returns the bytes of the element in source, which must be the
+// input the element has been parsed from, exactly as they were written:
+// with the original quotes, white space, references and comments. Unchanged
+// elements can be copied this way from the source to the output. ok is false
+// if the span of the element is not known, see SourceSpan, or lies outside
+// of source. The result is a part of source.
+func (elt *Element) RawBytes(source []byte) ([]byte, bool) {
+	start, end, ok := elt.SourceSpan()
+	if !ok || end > int64(len(source)) {
+		return nil, false
+	}
+	return source[start:end], true
+}
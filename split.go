@@ -0,0 +1,152 @@
+package goxml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SplitAt splits the text at offset, counted in characters, and returns the
+// two parts. The first part keeps the ID of cd. Offsets outside of the text
+// are moved to its start or end, so one of the parts is empty.
+func (cd CharData) SplitAt(offset int) (CharData, CharData) {
+	i := 0
+	for pos := range cd.Contents {
+		if i == offset {
+			return CharData{ID: cd.ID, Contents: cd.Contents[:pos], CDATA: cd.CDATA}, CharData{Contents: cd.Contents[pos:], CDATA: cd.CDATA}
+		}
+		i++
+	}
+	if offset <= 0 {
+		return CharData{ID: cd.ID, CDATA: cd.CDATA}, CharData{Contents: cd.Contents, CDATA: cd.CDATA}
+	}
+	return cd, CharData{CDATA: cd.CDATA}
+}
+
+// SplitAt splits the element in two at the child with the index child and
+// returns the new element, which is inserted as the next sibling of elt. elt
+// keeps the children before the index, the new element gets the others. If
+// the child is a text node, it is split at offset, counted in characters,
+// see CharData.SplitAt, and the text before the offset stays in elt.
+// Otherwise offset must be zero. child may be the number of children, which
+// gives an empty new element. The new element has the name, the namespace
+// declarations and copies of the attributes of elt, except for id and
+// xml:id, whose values must be unique in a document. Splitting a paragraph at a
+// page break or wrapping a range of text in a new element are done this way,
+// MergeNext is the inverse. The new element and the nodes after it in
+// document order get new IDs, as after InsertChild. The changes are reported
+// to the observers of the document. elt must have a parent element.
+func (elt *Element) SplitAt(child, offset int) (*Element, error) {
+	parent, ok := elt.Parent.(*Element)
+	if !ok {
+		return nil, errors.New("split of an element without parent element")
+	}
+	if child < 0 || child > len(elt.children) {
+		return nil, fmt.Errorf("split of <%s> at child %d out of range", elt.qualifiedName(), child)
+	}
+	var head, tail CharData
+	split := false
+	if offset != 0 {
+		var cd CharData
+		ok := false
+		if child < len(elt.children) {
+			cd, ok = elt.children[child].(CharData)
+		}
+		if !ok {
+			return nil, fmt.Errorf("split of <%s> at offset %d of child %d, which is not a text node", elt.qualifiedName(), offset, child)
+		}
+		head, tail = cd.SplitAt(offset)
+		split = head.Contents != "" && tail.Contents != ""
+		if head.Contents != "" && tail.Contents == "" {
+			// the whole text stays
+			child++
+		}
+	}
+	parent.invalidate()
+	doc := elt.invalidate()
+	cp := &Element{
+		Name:   elt.Name,
+		Prefix: elt.Prefix,
+		Line:   elt.Line,
+		Pos:    elt.Pos,
+		source: elt.source,
+	}
+	if len(elt.Namespaces) > 0 {
+		cp.Namespaces = make(map[string]string, len(elt.Namespaces))
+		for prefix, ns := range elt.Namespaces {
+			cp.Namespaces[prefix] = ns
+		}
+	}
+	for _, attr := range elt.attributes {
+		if attr.Name == "id" && (attr.Namespace == "" || attr.Namespace == xmlNamespace) {
+			continue
+		}
+		a := *attr
+		cp.attributes = append(cp.attributes, &a)
+	}
+	moved := append([]XMLNode(nil), elt.children[child:]...)
+	elt.children = elt.children[:child:child]
+	if split {
+		old := moved[0].(CharData)
+		elt.children = append(elt.children, head)
+		doc.notify(MutationEvent{Type: TextChanged, Target: elt, Node: head, Index: child, OldValue: old.Contents, NewValue: head.Contents})
+		moved[0] = tail
+	}
+	for i, n := range moved {
+		if i > 0 || !split {
+			// the nodes are reported as removed one after the other
+			doc.notify(MutationEvent{Type: NodeRemoved, Target: elt, Node: n, Index: len(elt.children)})
+		}
+		n.setParent(cp)
+	}
+	cp.children = moved
+
+	index := 0
+	for i, c := range parent.children {
+		if c == XMLNode(elt) {
+			index = i + 1
+			parent.children = append(parent.children[:index], append([]XMLNode{cp}, parent.children[index:]...)...)
+			break
+		}
+	}
+	cp.Parent = parent
+	if doc != nil {
+		// the new element and the nodes moved into it get new IDs
+		doc.renumberFrom(parent, index)
+	}
+	doc.notify(MutationEvent{Type: NodeAppended, Target: parent, Node: cp, Index: index})
+	return cp, nil
+}
+
+// MergeNext moves the children of the next sibling of elt to the end of elt
+// and removes the sibling, which must be an element with the same name and
+// namespace. Adjacent text at the join becomes one text node. This is the
+// inverse of SplitAt. The attributes of the sibling are dropped. The changes
+// are reported to the observers of the document.
+func (elt *Element) MergeNext() error {
+	parent, ok := elt.Parent.(*Element)
+	if !ok {
+		return errors.New("merge of an element without parent element")
+	}
+	i := 0
+	for i < len(parent.children) && parent.children[i] != XMLNode(elt) {
+		i++
+	}
+	if i+1 >= len(parent.children) {
+		return fmt.Errorf("merge of <%s>: no next sibling", elt.qualifiedName())
+	}
+	next, ok := parent.children[i+1].(*Element)
+	if !ok || next.Name != elt.Name || next.NamespaceURI() != elt.NamespaceURI() {
+		return fmt.Errorf("merge of <%s>: the next sibling is not a <%s> element", elt.qualifiedName(), elt.qualifiedName())
+	}
+	next.invalidate()
+	doc := parent.invalidate()
+	for _, c := range next.children {
+		// the children of next follow elt in document order already
+		elt.appendNode(c, false)
+	}
+	next.children = nil
+	parent.children = append(parent.children[:i+1], parent.children[i+2:]...)
+	next.Parent = nil
+	doc.notify(MutationEvent{Type: NodeRemoved, Target: parent, Node: next, Index: i + 1})
+	return nil
+}
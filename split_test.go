@@ -0,0 +1,76 @@
+package goxml
+
+import "testing"
+
+func TestCharDataSplitAt(t *testing.T) {
+	cd := CharData{ID: 7, Contents: "äbc€d"}
+	tests := []struct {
+		offset     int
+		head, tail string
+	}{
+		{0, "", "äbc€d"},
+		{1, "ä", "bc€d"},
+		{4, "äbc€", "d"},
+		{5, "äbc€d", ""},
+		{-1, "", "äbc€d"},
+		{9, "äbc€d", ""},
+	}
+	for _, tc := range tests {
+		head, tail := cd.SplitAt(tc.offset)
+		if head.Contents != tc.head || tail.Contents != tc.tail || head.ID != cd.ID {
+			t.Errorf("SplitAt(%d) = %q (ID %d), %q, want %q (ID %d), %q", tc.offset, head.Contents, head.ID, tail.Contents, tc.head, cd.ID, tc.tail)
+		}
+	}
+}
+
+func TestElementSplitAt(t *testing.T) {
+	tests := []struct {
+		child, offset int
+		want          string
+	}{
+		{0, 3, `<r><p xml:id="p" id="q" class="c">hel</p><p class="c">lo<i>x</i>world</p>tail</r>`},
+		{1, 0, `<r><p xml:id="p" id="q" class="c">hello</p><p class="c"><i>x</i>world</p>tail</r>`},
+		{0, 5, `<r><p xml:id="p" id="q" class="c">hello</p><p class="c"><i>x</i>world</p>tail</r>`},
+		{3, 0, `<r><p xml:id="p" id="q" class="c">hello<i>x</i>world</p><p class="c" />tail</r>`},
+	}
+	for _, tc := range tests {
+		doc, r := parseRoot(t, `<r><p xml:id="p" id="q" class="c">hello<i>x</i>world</p>tail</r>`)
+		p := r.children[0].(*Element)
+		cp, err := p.SplitAt(tc.child, tc.offset)
+		if err != nil {
+			t.Errorf("SplitAt(%d, %d): %v", tc.child, tc.offset, err)
+			continue
+		}
+		if got := doc.ToXML(); got != tc.want {
+			t.Errorf("SplitAt(%d, %d): %s, want %s", tc.child, tc.offset, got, tc.want)
+		}
+		if cp.Parent != XMLNode(r) || r.children[1] != XMLNode(cp) {
+			t.Errorf("SplitAt(%d, %d) does not insert the new element after <p>", tc.child, tc.offset)
+		}
+		checkIDs(t, doc)
+		// MergeNext is the inverse
+		if err := p.MergeNext(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := doc.ToXML(), `<r><p xml:id="p" id="q" class="c">hello<i>x</i>world</p>tail</r>`; got != want {
+			t.Errorf("MergeNext after SplitAt(%d, %d): %s, want %s", tc.child, tc.offset, got, want)
+		}
+	}
+}
+
+func TestElementSplitAtErrors(t *testing.T) {
+	_, r := parseRoot(t, `<r><p>a<i/></p></r>`)
+	p := r.children[0].(*Element)
+	if _, err := r.SplitAt(0, 0); err == nil {
+		t.Error("SplitAt of the root element succeeds")
+	}
+	if _, err := p.SplitAt(3, 0); err == nil {
+		t.Error("SplitAt(3, 0) succeeds for an element with two children")
+	}
+	if _, err := p.SplitAt(1, 1); err == nil {
+		t.Error("SplitAt(1, 1) succeeds at an element child")
+	}
+	if err := p.MergeNext(); err == nil {
+		t.Error("MergeNext succeeds without a next sibling")
+	}
+}
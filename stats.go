@@ -0,0 +1,203 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats contains counters about a document and the time spent on reading or
+// writing it.
+type Stats struct {
+	Elements   int
+	Attributes int
+	CharData   int
+	Comments   int
+	ProcInsts  int
+	// MaxDepth is the deepest nesting level of elements, the root element
+	// has depth 1.
+	MaxDepth int
+	// TextBytes is the total length of all character data.
+	TextBytes int64
+	// Bytes is the number of bytes read by Parse or written by WriteXML.
+	Bytes    int64
+	Duration time.Duration
+}
+
+// ParseStats returns the statistics collected while the document was parsed.
+// For documents not created by Parse, all counters are zero.
+func (xr *XMLDocument) ParseStats() Stats {
+	return xr.stats
+}
+
+// CollectStats counts the nodes in the subtree starting at n. The Bytes and
+// Duration fields are not set.
+func CollectStats(n XMLNode) Stats {
+	var s Stats
+	s.collect(n, 0)
+	return s
+}
+
+func (s *Stats) collect(n XMLNode, depth int) {
+	switch t := n.(type) {
+	case *Element:
+		depth++
+		s.Elements++
+		s.Attributes += len(t.attributes)
+		if depth > s.MaxDepth {
+			s.MaxDepth = depth
+		}
+	case CharData:
+		s.CharData++
+		s.TextBytes += int64(len(t.Contents))
+	case Comment:
+		s.Comments++
+	case ProcInst:
+		s.ProcInsts++
+	}
+	for _, c := range n.Children() {
+		s.collect(c, depth)
+	}
+}
+
+// Report describes the shape of a document: which elements, attributes and
+// namespaces it uses and how deep it is nested.
+type Report struct {
+	Stats
+	// ElementCounts counts the elements by their expanded name, where the
+	// space is the namespace URI.
+	ElementCounts map[xml.Name]int
+	// AttributeCounts counts the attributes by the expanded names of the
+	// element and the attribute.
+	AttributeCounts map[xml.Name]map[xml.Name]int
+	// NamespaceCounts counts the elements and attributes in each namespace,
+	// the empty string stands for no namespace.
+	NamespaceCounts map[string]int
+	// DepthCounts is the number of elements on each nesting level, the
+	// root element is counted in DepthCounts[1].
+	DepthCounts []int
+}
+
+// Report returns a description of the shape of the document. Bytes is the
+// length of the serialized document. The time for creating the report is
+// stored in Duration.
+func (xr *XMLDocument) Report() *Report {
+	start := time.Now()
+	r := &Report{
+		ElementCounts:   make(map[xml.Name]int),
+		AttributeCounts: make(map[xml.Name]map[xml.Name]int),
+		NamespaceCounts: make(map[string]int),
+		DepthCounts:     []int{0},
+	}
+	r.Stats = CollectStats(xr)
+	r.collect(xr, 0)
+	xw := newXMLWriter(io.Discard)
+	xw.illegalChars = CharReplace
+	xr.serialize(xw)
+	r.Bytes = xw.n
+	r.Duration = time.Since(start)
+	return r
+}
+
+func (r *Report) collect(n XMLNode, depth int) {
+	for _, c := range n.Children() {
+		elt, ok := c.(*Element)
+		if !ok {
+			continue
+		}
+		if depth+1 == len(r.DepthCounts) {
+			r.DepthCounts = append(r.DepthCounts, 0)
+		}
+		r.DepthCounts[depth+1]++
+		name := xml.Name{Space: elt.NamespaceURI(), Local: elt.Name}
+		r.ElementCounts[name]++
+		r.NamespaceCounts[name.Space]++
+		if len(elt.attributes) > 0 && r.AttributeCounts[name] == nil {
+			r.AttributeCounts[name] = make(map[xml.Name]int)
+		}
+		for _, attr := range elt.attributes {
+			r.AttributeCounts[name][xml.Name{Space: attr.Namespace, Local: attr.Name}]++
+			r.NamespaceCounts[attr.Namespace]++
+		}
+		r.collect(elt, depth+1)
+	}
+}
+
+// TextRatio returns the share of character data in the serialized document,
+// between 0 for a document without text and 1.
+func (r *Report) TextRatio() float64 {
+	if r.Bytes == 0 {
+		return 0
+	}
+	ratio := float64(r.TextBytes) / float64(r.Bytes)
+	if ratio > 1 {
+		// escaped characters are longer in the output
+		ratio = 1
+	}
+	return ratio
+}
+
+// String returns the report as text, with the elements and attributes
+// ordered by frequency.
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "elements: %d, attributes: %d, text nodes: %d, comments: %d, processing instructions: %d\n",
+		r.Elements, r.Attributes, r.CharData, r.Comments, r.ProcInsts)
+	fmt.Fprintf(&sb, "bytes: %d, text bytes: %d (%.1f%%), maximum depth: %d\n", r.Bytes, r.TextBytes, 100*r.TextRatio(), r.MaxDepth)
+	sb.WriteString("depths:\n")
+	for depth, count := range r.DepthCounts[1:] {
+		fmt.Fprintf(&sb, "  %4d %d\n", depth+1, count)
+	}
+	sb.WriteString("namespaces:\n")
+	spaces := make([]string, 0, len(r.NamespaceCounts))
+	for ns := range r.NamespaceCounts {
+		spaces = append(spaces, ns)
+	}
+	sort.Slice(spaces, func(i, j int) bool {
+		ci, cj := r.NamespaceCounts[spaces[i]], r.NamespaceCounts[spaces[j]]
+		return ci > cj || ci == cj && spaces[i] < spaces[j]
+	})
+	for _, ns := range spaces {
+		if ns == "" {
+			fmt.Fprintf(&sb, "  %8d (no namespace)\n", r.NamespaceCounts[ns])
+		} else {
+			fmt.Fprintf(&sb, "  %8d %s\n", r.NamespaceCounts[ns], ns)
+		}
+	}
+	sb.WriteString("elements:\n")
+	for _, name := range sortedNames(r.ElementCounts) {
+		fmt.Fprintf(&sb, "  %8d %s\n", r.ElementCounts[name], clarkName(name))
+		attrs := r.AttributeCounts[name]
+		for _, attr := range sortedNames(attrs) {
+			fmt.Fprintf(&sb, "  %8d   @%s\n", attrs[attr], clarkName(attr))
+		}
+	}
+	return sb.String()
+}
+
+// sortedNames returns the names in counts, the most frequent first.
+func sortedNames(counts map[xml.Name]int) []xml.Name {
+	names := make([]xml.Name, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := counts[names[i]], counts[names[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return clarkName(names[i]) < clarkName(names[j])
+	})
+	return names
+}
+
+// clarkName returns name in the notation {namespace}local.
+func clarkName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
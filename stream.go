@@ -0,0 +1,230 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// StreamEventType is the kind of a StreamEvent.
+type StreamEventType int
+
+const (
+	// StreamStartElement is the start tag of an element.
+	StreamStartElement StreamEventType = iota
+	// StreamEndElement is the end tag of an element.
+	StreamEndElement
+	// StreamText is a text node or, with WithEntityRefs, a reference.
+	StreamText
+	// StreamComment is a comment.
+	StreamComment
+	// StreamProcInst is a processing instruction, including the XML
+	// declaration.
+	StreamProcInst
+)
+
+// StreamEvent is a part of a document read by StreamParser.Next.
+type StreamEvent struct {
+	Type StreamEventType
+	// Element is the element of a StreamStartElement or StreamEndElement
+	// event. It has its attributes and namespace declarations, but no
+	// children, see StreamParser.DecodeElement. Its Parent is the element of
+	// the enclosing start event, so that the namespace bindings, the
+	// language and the base URI of the ancestors apply, but the parent does
+	// not list it as a child.
+	Element *Element
+	// Node is the CharData, EntityRef, Comment or ProcInst of the other
+	// events.
+	Node XMLNode
+	// Depth is the number of ancestor elements of the element or node, zero
+	// for the root element.
+	Depth int
+}
+
+// StreamParser reads a document event by event without building the whole
+// tree, so that large files such as data exports with millions of records
+// can be processed in constant memory. The elements of interest are read
+// with DecodeElement and can be used with the Element API, the others are
+// passed over with Skip. The parse options apply as with Parse, except for
+// WithKeepOnly and WithProgress, which are ignored. The node IDs are unique
+// within the stream and increase in document order.
+//
+// Without WithArena, the elements are taken from the pool that Release fills.
+// An element returned by DecodeElement, or the element of a start event that
+// has been passed over with Skip, can be handed back with Release once it
+// has been processed and nothing refers to it anymore, so that reading
+// millions of records does not allocate a new element for each of them.
+type StreamParser struct {
+	p *Parser
+	// events are the events read but not yet returned by Next
+	events []StreamEvent
+	// started is the element of the last event if it was a
+	// StreamStartElement event
+	started *Element
+	err     error
+}
+
+// NewStreamParser returns a StreamParser that reads the document from r.
+func NewStreamParser(r io.Reader, opts ...ParseOption) *StreamParser {
+	p := NewParser(opts...)
+	p.opts.keepOnly = nil
+	p.opts.progress = nil
+	p.Reset(r)
+	p.begin()
+	return &StreamParser{p: p}
+}
+
+// Next returns the next event. At the end of the document, it returns
+// io.EOF. Errors in the document are returned as *ParseError, after an error
+// Next returns the same error again.
+func (sp *StreamParser) Next() (StreamEvent, error) {
+	sp.started = nil
+	for len(sp.events) == 0 {
+		if sp.err != nil {
+			return StreamEvent{}, sp.err
+		}
+		sp.err = sp.read()
+	}
+	ev := sp.events[0]
+	sp.events[0] = StreamEvent{}
+	sp.events = sp.events[1:]
+	if ev.Type == StreamStartElement && len(sp.events) == 0 {
+		sp.started = ev.Element
+	}
+	return ev, nil
+}
+
+// read reads the next token and queues its events.
+func (sp *StreamParser) read() error {
+	p := sp.p
+	p.tokenStart = p.dec.InputOffset()
+	tok, err := p.dec.RawToken()
+	if err == io.EOF {
+		open := append([]XMLNode(nil), p.eltstack...)
+		if err = p.endOfInput(); err != nil {
+			return p.newParseError(p.dec, p.current(), err)
+		}
+		// the elements closed in repair mode
+		sp.endElements(open)
+		// comments collected with WithAttachedComments
+		p.flushPending()
+		sp.collect(p.doc, 0)
+		return io.EOF
+	}
+	if err != nil {
+		return p.newParseError(p.dec, p.current(), err)
+	}
+	cur := p.current()
+	depth := len(p.eltstack) - 1
+	if _, ok := tok.(xml.EndElement); ok {
+		open := append([]XMLNode(nil), p.eltstack...)
+		if err = p.endElement(tok.(xml.EndElement)); err != nil {
+			return p.newParseError(p.dec, cur, err)
+		}
+		// more than one element is closed in repair mode
+		sp.endElements(open)
+		return nil
+	}
+	if err = p.token(tok); err != nil {
+		return p.newParseError(p.dec, cur, err)
+	}
+	sp.collect(cur, depth)
+	return nil
+}
+
+// endElements queues the end events of the elements of open, a copy of the
+// element stack before a token, that the token has closed.
+func (sp *StreamParser) endElements(open []XMLNode) {
+	for i := len(open) - 1; i >= len(sp.p.eltstack); i-- {
+		sp.events = append(sp.events, StreamEvent{Type: StreamEndElement, Element: open[i].(*Element), Depth: i - 1})
+	}
+}
+
+// collect queues the events of the children of cur, which have depth
+// ancestor elements, and takes them out of the tree, so that it does not
+// grow.
+func (sp *StreamParser) collect(cur XMLNode, depth int) {
+	var nodes []XMLNode
+	switch t := cur.(type) {
+	case *Element:
+		nodes, t.children = t.children, nil
+	case *XMLDocument:
+		nodes, t.children = t.children, nil
+	}
+	for _, n := range nodes {
+		ev := StreamEvent{Node: n, Depth: depth}
+		switch t := n.(type) {
+		case *Element:
+			ev = StreamEvent{Type: StreamStartElement, Element: t, Depth: depth}
+		case Comment:
+			ev.Type = StreamComment
+		case ProcInst:
+			ev.Type = StreamProcInst
+		default:
+			ev.Type = StreamText
+		}
+		sp.events = append(sp.events, ev)
+	}
+}
+
+// DecodeElement reads the children of the element of the StreamStartElement
+// event that Next has just returned and returns the element with its
+// subtree. The element keeps its Parent, see StreamEvent.Element, and its
+// line and position. Use ExtractDocument to get a document of its own. No
+// StreamEndElement event is returned for the element, the next event is the
+// one after its end tag.
+func (sp *StreamParser) DecodeElement() (*Element, error) {
+	elt, err := sp.current()
+	if err != nil {
+		return nil, err
+	}
+	p := sp.p
+	depth := len(p.eltstack)
+	for len(p.eltstack) >= depth {
+		p.tokenStart = p.dec.InputOffset()
+		tok, err := p.dec.RawToken()
+		if err == io.EOF {
+			err = p.endOfInput()
+			if err == nil {
+				break
+			}
+		}
+		if err == nil {
+			err = p.token(tok)
+		}
+		if err != nil {
+			sp.err = p.newParseError(p.dec, p.current(), err)
+			return nil, sp.err
+		}
+	}
+	return elt, nil
+}
+
+// Skip reads past the end tag of the element of the StreamStartElement event
+// that Next has just returned, without building its children.
+func (sp *StreamParser) Skip() error {
+	if _, err := sp.current(); err != nil {
+		return err
+	}
+	p := sp.p
+	if err := p.skip(); err != nil {
+		sp.err = p.newParseError(p.dec, p.current(), err)
+		return sp.err
+	}
+	p.pop()
+	return nil
+}
+
+// current returns the element of the last StreamStartElement event and
+// makes sure that it is not used again.
+func (sp *StreamParser) current() (*Element, error) {
+	if sp.err != nil {
+		return nil, sp.err
+	}
+	elt := sp.started
+	if elt == nil {
+		return nil, errors.New("no start element event before")
+	}
+	sp.started = nil
+	return elt, nil
+}
@@ -0,0 +1,158 @@
+package goxml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// streamEvents returns the events of the stream as lines of the type, the
+// name or contents and the depth.
+func streamEvents(t *testing.T, sp *StreamParser) []string {
+	t.Helper()
+	var events []string
+	for {
+		ev, err := sp.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s string
+		switch ev.Type {
+		case StreamStartElement:
+			s = "start " + ev.Element.Name
+		case StreamEndElement:
+			s = "end " + ev.Element.Name
+		case StreamText:
+			s = "text " + nodeString(ev.Node)
+		case StreamComment:
+			s = "comment " + nodeString(ev.Node)
+		case StreamProcInst:
+			s = "pi " + ev.Node.(ProcInst).Target
+		}
+		events = append(events, fmt.Sprintf("%s %d", s, ev.Depth))
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	sp := NewStreamParser(strings.NewReader(`<?xml version="1.0"?><r><!--c--><a x="1">t<b/></a><?pi?></r>`))
+	got := strings.Join(streamEvents(t, sp), ", ")
+	want := "pi xml 0, start r 0, comment c 1, start a 1, text t 2, start b 2, end b 2, end a 1, pi pi 1, end r 0"
+	if got != want {
+		t.Errorf("events:\n%s\nwant\n%s", got, want)
+	}
+	// Next returns io.EOF again
+	if _, err := sp.Next(); err != io.EOF {
+		t.Errorf("Next after the end returns %v, want io.EOF", err)
+	}
+}
+
+func TestStreamDecodeElement(t *testing.T) {
+	sp := NewStreamParser(strings.NewReader(`<export><skip><x/>text</skip><record id="1"><v>a</v></record><record id="2"><v>b</v></record></export>`))
+	var records []*Element
+	var events []string
+	var lastID int64
+	for {
+		ev, err := sp.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != StreamStartElement {
+			events = append(events, fmt.Sprint(ev.Type, " ", ev.Element.Name))
+			continue
+		}
+		events = append(events, ev.Element.Name)
+		if ev.Element.ID <= lastID {
+			t.Errorf("ID %d of <%s> does not increase", ev.Element.ID, ev.Element.Name)
+		}
+		lastID = ev.Element.ID
+		switch ev.Element.Name {
+		case "skip":
+			if err = sp.Skip(); err != nil {
+				t.Fatal(err)
+			}
+		case "record":
+			elt, err := sp.DecodeElement()
+			if err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, elt)
+		}
+	}
+	// no events for the children and the end tags of skipped and decoded
+	// elements
+	if got, want := strings.Join(events, " "), fmt.Sprint("export skip record record ", StreamEndElement, " export"); got != want {
+		t.Errorf("events %q, want %q", got, want)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records decoded, want 2", len(records))
+	}
+	for i, r := range records {
+		if got, want := r.ToXML(), fmt.Sprintf(`<record id="%d"><v>%c</v></record>`, i+1, 'a'+i); got != want {
+			t.Errorf("record %d: %s, want %s", i, got, want)
+		}
+		if p, ok := r.Parent.(*Element); !ok || p.Name != "export" {
+			t.Errorf("parent of record %d is %v, want export", i, r.Parent)
+		}
+	}
+}
+
+func TestStreamDecodeWithoutStart(t *testing.T) {
+	sp := NewStreamParser(strings.NewReader(`<r>t<a/></r>`))
+	if _, err := sp.DecodeElement(); err == nil {
+		t.Error("DecodeElement before the first event succeeds")
+	}
+	sp.Next()
+	sp.Next()
+	if err := sp.Skip(); err == nil {
+		t.Error("Skip after a text event succeeds")
+	}
+	ev, _ := sp.Next()
+	if ev.Type != StreamStartElement {
+		t.Fatalf("event %v, want the start of a", ev.Type)
+	}
+	if _, err := sp.DecodeElement(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sp.DecodeElement(); err == nil {
+		t.Error("DecodeElement succeeds twice for the same start event")
+	}
+}
+
+func TestStreamRepair(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`<a><b><c></a>`, "start a 0, start b 1, start c 2, end c 2, end b 1, end a 0"},
+		{`<a></x><b/></a>`, "start a 0, start b 1, end b 1, end a 0"},
+		{`<a><b>`, "start a 0, start b 1, end b 1, end a 0"},
+	}
+	for _, tc := range tests {
+		sp := NewStreamParser(strings.NewReader(tc.in), WithRepair())
+		if got := strings.Join(streamEvents(t, sp), ", "); got != tc.want {
+			t.Errorf("events of %s:\n%s\nwant\n%s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	sp := NewStreamParser(strings.NewReader(`<a><b></a>`))
+	var err error
+	for err == nil {
+		_, err = sp.Next()
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Next returns %v, want a *ParseError", err)
+	}
+	if _, again := sp.Next(); again != err {
+		t.Errorf("Next after an error returns %v, want the same error", again)
+	}
+}
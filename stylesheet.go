@@ -0,0 +1,132 @@
+package goxml
+
+import "encoding/xml"
+
+// Stylesheet is a style sheet associated with a document by an
+// xml-stylesheet processing instruction before the root element:
+//
+//	<?xml-stylesheet type="text/xsl" href="style.xsl"?>
+type Stylesheet struct {
+	Href    string
+	Type    string
+	Title   string
+	Media   string
+	Charset string
+	// Alternate is set for alternate="yes".
+	Alternate bool
+}
+
+// procInst returns the processing instruction for the style sheet.
+func (s Stylesheet) procInst() ProcInst {
+	var attrs []xml.Attr
+	add := func(name, value string) {
+		if value != "" {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+		}
+	}
+	add("type", s.Type)
+	add("href", s.Href)
+	add("title", s.Title)
+	add("media", s.Media)
+	add("charset", s.Charset)
+	if s.Alternate {
+		add("alternate", "yes")
+	}
+	pi := ProcInst{Target: "xml-stylesheet"}
+	pi.SetPseudoAttributes(attrs)
+	return pi
+}
+
+// stylesheet reads the xml-stylesheet processing instruction n. It returns
+// false for other nodes and for contents that are not pseudo-attributes.
+func stylesheet(n XMLNode) (Stylesheet, bool) {
+	pi, ok := n.(ProcInst)
+	if !ok || pi.Target != "xml-stylesheet" {
+		return Stylesheet{}, false
+	}
+	attrs, err := pi.PseudoAttributes()
+	if err != nil {
+		return Stylesheet{}, false
+	}
+	var s Stylesheet
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "href":
+			s.Href = attr.Value
+		case "type":
+			s.Type = attr.Value
+		case "title":
+			s.Title = attr.Value
+		case "media":
+			s.Media = attr.Value
+		case "charset":
+			s.Charset = attr.Value
+		case "alternate":
+			s.Alternate = attr.Value == "yes"
+		}
+	}
+	return s, true
+}
+
+// prologEnd returns the index of the root element in the children of the
+// document, the number of children if there is none.
+func (xr *XMLDocument) prologEnd() int {
+	for i, c := range xr.children {
+		if _, ok := c.(*Element); ok {
+			return i
+		}
+	}
+	return len(xr.children)
+}
+
+// Stylesheets returns the style sheets of the xml-stylesheet processing
+// instructions before the root element in document order. Processing
+// instructions whose contents are not pseudo-attributes are skipped.
+func (xr *XMLDocument) Stylesheets() []Stylesheet {
+	var sheets []Stylesheet
+	for _, c := range xr.children[:xr.prologEnd()] {
+		if s, ok := stylesheet(c); ok {
+			sheets = append(sheets, s)
+		}
+	}
+	return sheets
+}
+
+// AddStylesheet adds an xml-stylesheet processing instruction for s after
+// the last one before the root element, or right before the root element
+// if there is none. Empty fields are not written.
+func (xr *XMLDocument) AddStylesheet(s Stylesheet) {
+	end := xr.prologEnd()
+	index := end
+	for i := end - 1; i >= 0; i-- {
+		if pi, ok := xr.children[i].(ProcInst); ok && pi.Target == "xml-stylesheet" {
+			index = i + 1
+			break
+		}
+	}
+	pi := s.procInst()
+	pi.ID = xr.NextID()
+	xr.changeChildren()
+	xr.children = append(xr.children[:index], append([]XMLNode{pi}, xr.children[index:]...)...)
+	pi.setParent(xr)
+	xr.notify(MutationEvent{Type: NodeAppended, Target: xr, Node: pi, Index: index})
+}
+
+// RemoveStylesheets removes the xml-stylesheet processing instructions
+// before the root element for which match returns true and returns their
+// number.
+func (xr *XMLDocument) RemoveStylesheets(match func(Stylesheet) bool) int {
+	removed := 0
+	for i := 0; i < xr.prologEnd(); {
+		n := xr.children[i]
+		if s, ok := stylesheet(n); !ok || !match(s) {
+			i++
+			continue
+		}
+		xr.changeChildren()
+		xr.children = append(xr.children[:i], xr.children[i+1:]...)
+		xr.notify(MutationEvent{Type: NodeRemoved, Target: xr, Node: n, Index: i})
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,266 @@
+package goxml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TemplateNamespace is the namespace of the template attributes that
+// ExecuteTemplate evaluates.
+const TemplateNamespace = "urn:speedata:goxml:template"
+
+// ExecuteTemplate returns a new document built from the template document
+// tpl and data. The template is a document whose elements carry attributes
+// in TemplateNamespace, written here with the prefix tpl:
+//
+//	tpl:repeat="items"   repeat the element for each item of the slice
+//	tpl:if="paid"        keep the element only if the value is not empty
+//	tpl:if="!paid"       keep the element only if the value is empty
+//	tpl:with="customer"  use the value as the context of the contents
+//	tpl:text="name"      replace the contents by the value as text
+//	tpl:href="url"       set the attribute href to the value
+//
+// Values are selected by paths of field names and map keys separated by
+// dots, such as customer.name, starting at the context. The context is data
+// at first, each item in a repeated element and the value of tpl:with. A
+// path whose first name is not found in the context is looked up in the
+// outer contexts, and the path . is the context itself. Field names match
+// the Go name or the name in a json struct tag, ignoring case. Empty values
+// are nil, false, zero numbers, empty strings, slices and maps. tpl:repeat
+// is evaluated first, so tpl:if can select items.
+//
+// The output is built as nodes, so values never change the structure of the
+// document, whatever characters they contain. The template attributes and
+// the declarations of TemplateNamespace are not copied to the output. tpl
+// is not changed.
+func ExecuteTemplate(tpl *XMLDocument, data any) (*XMLDocument, error) {
+	te := &templateExecutor{contexts: []reflect.Value{reflect.ValueOf(data)}}
+	doc := NewDocument()
+	doc.baseURI = tpl.baseURI
+	for _, c := range tpl.children {
+		if err := te.node(doc, c); err != nil {
+			return nil, err
+		}
+	}
+	doc.renumber()
+	return doc, nil
+}
+
+type templateExecutor struct {
+	// contexts is the stack of context values, the innermost one last
+	contexts []reflect.Value
+}
+
+func (te *templateExecutor) node(dest Appender, n XMLNode) error {
+	elt, ok := n.(*Element)
+	if !ok {
+		dest.Append(n)
+		return nil
+	}
+	repeat, ok := templateAttribute(elt, "repeat")
+	if !ok {
+		return te.element(dest, elt)
+	}
+	list, err := te.value(elt, repeat)
+	if err != nil {
+		return err
+	}
+	list = indirect(list)
+	if !list.IsValid() {
+		return nil
+	}
+	if k := list.Kind(); k != reflect.Slice && k != reflect.Array {
+		return templateError(elt, "repeat", fmt.Sprintf("%s is a %s, not a slice", repeat, list.Type()))
+	}
+	for i := 0; i < list.Len(); i++ {
+		te.contexts = append(te.contexts, list.Index(i))
+		err := te.element(dest, elt)
+		te.contexts = te.contexts[:len(te.contexts)-1]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// element adds the instance of the template element elt to dest, unless
+// tpl:if excludes it.
+func (te *templateExecutor) element(dest Appender, elt *Element) error {
+	if cond, ok := templateAttribute(elt, "if"); ok {
+		negate := strings.HasPrefix(cond, "!")
+		v, err := te.value(elt, strings.TrimPrefix(cond, "!"))
+		if err != nil {
+			return err
+		}
+		if isEmptyValue(v) != negate {
+			return nil
+		}
+	}
+	out := NewElement()
+	out.Name, out.Prefix = elt.Name, elt.Prefix
+	out.Line, out.Pos = elt.Line, elt.Pos
+	for prefix, ns := range elt.Namespaces {
+		if ns != TemplateNamespace {
+			out.Namespaces[prefix] = ns
+		}
+	}
+	dest.Append(out)
+	for _, attr := range elt.attributes {
+		if attr.Namespace != TemplateNamespace {
+			out.Append(*attr)
+		}
+	}
+	pushed := false
+	for _, attr := range elt.attributes {
+		if attr.Namespace != TemplateNamespace {
+			continue
+		}
+		switch attr.Name {
+		case "repeat", "if", "text":
+		case "with":
+			v, err := te.value(elt, attr.Value)
+			if err != nil {
+				return err
+			}
+			te.contexts = append(te.contexts, v)
+			pushed = true
+		default:
+			v, err := te.value(elt, attr.Value)
+			if err != nil {
+				return err
+			}
+			out.Append(Attribute{Name: attr.Name, Value: templateString(v)})
+		}
+	}
+	if pushed {
+		defer func() { te.contexts = te.contexts[:len(te.contexts)-1] }()
+	}
+	if text, ok := templateAttribute(elt, "text"); ok {
+		v, err := te.value(elt, text)
+		if err != nil {
+			return err
+		}
+		if s := templateString(v); s != "" {
+			out.Append(CharData{Contents: s})
+		}
+		return nil
+	}
+	for _, c := range elt.children {
+		if err := te.node(out, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value returns the value of path. A missing map key or a nil pointer on the
+// way gives the invalid Value.
+func (te *templateExecutor) value(elt *Element, path string) (reflect.Value, error) {
+	path = strings.TrimSpace(path)
+	if path == "." {
+		return te.contexts[len(te.contexts)-1], nil
+	}
+	names := strings.Split(path, ".")
+	for _, name := range names {
+		if name == "" {
+			return reflect.Value{}, templateError(elt, "", fmt.Sprintf("invalid path %q", path))
+		}
+	}
+	// the first name decides which context is used
+	for i := len(te.contexts) - 1; i >= 0; i-- {
+		v, found := templateField(te.contexts[i], names[0])
+		if !found {
+			continue
+		}
+		for _, name := range names[1:] {
+			if v, found = templateField(v, name); !found {
+				if !v.IsValid() {
+					return v, nil
+				}
+				return reflect.Value{}, templateError(elt, "", fmt.Sprintf("%s: %s has no field %s", path, v.Type(), name))
+			}
+		}
+		return v, nil
+	}
+	return reflect.Value{}, templateError(elt, "", fmt.Sprintf("%s: %s not found", path, names[0]))
+}
+
+// templateField returns the field or map entry name of v. found is false if
+// v does not have it. For nil pointers and missing map keys found is false
+// and v is returned invalid.
+func templateField(v reflect.Value, name string) (reflect.Value, bool) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return v, false
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v, false
+		}
+		e := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		return e, e.IsValid()
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if strings.EqualFold(f.Name, name) || tag != "" && strings.EqualFold(tag, name) {
+				return v.Field(i), true
+			}
+		}
+	}
+	return v, false
+}
+
+// isEmptyValue reports whether v is false in tpl:if.
+func isEmptyValue(v reflect.Value) bool {
+	v = indirect(v)
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// templateString returns the text of v.
+func templateString(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// indirect returns the value v points to or contains. The result is invalid
+// for nil pointers and interfaces.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+	return v
+}
+
+// templateAttribute returns the value of the template attribute name of elt.
+func templateAttribute(elt *Element, name string) (string, bool) {
+	for _, attr := range elt.attributes {
+		if attr.Namespace == TemplateNamespace && attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func templateError(elt *Element, attr, msg string) error {
+	if attr != "" {
+		return fmt.Errorf("line %d: <%s> tpl:%s: %s", elt.Line, elt.qualifiedName(), attr, msg)
+	}
+	return fmt.Errorf("line %d: <%s>: %s", elt.Line, elt.qualifiedName(), msg)
+}
@@ -0,0 +1,74 @@
+package goxml
+
+import "strings"
+
+// StringvalueExcept returns the string value of the element without the
+// text of the descendant elements with the given local names and their
+// descendants, for example the visible text of a paragraph without its
+// footnotes. elt itself is not checked against names.
+func (elt *Element) StringvalueExcept(names ...string) string {
+	return elt.StringvalueFunc(func(e *Element) bool {
+		return !containsName(names, e.Name)
+	})
+}
+
+// StringvalueFunc returns the string value of the element with the text of
+// the descendant elements for which keep returns true. The descendants of
+// an element that is not kept are skipped without calling keep.
+func (elt *Element) StringvalueFunc(keep func(*Element) bool) string {
+	var sb strings.Builder
+	var collect func(*Element)
+	collect = func(e *Element) {
+		for _, c := range e.children {
+			switch t := c.(type) {
+			case CharData:
+				sb.WriteString(t.Contents)
+			case EntityRef:
+				sb.WriteString(t.Value)
+			case *Element:
+				if keep(t) {
+					collect(t)
+				}
+			}
+		}
+	}
+	collect(elt)
+	return sb.String()
+}
+
+// NormalizedStringvalue returns the string value of the element with white
+// space normalized like the XPath function normalize-space: leading and
+// trailing white space is removed and each run of white space is replaced by
+// a single space.
+func (elt *Element) NormalizedStringvalue() string {
+	return NormalizeSpace(elt.Stringvalue())
+}
+
+// NormalizeSpace returns s with leading and trailing white space removed
+// and each run of white space replaced by a single space, like the XPath
+// function normalize-space. White space is space, tab, carriage return and
+// newline.
+func NormalizeSpace(s string) string {
+	var sb strings.Builder
+	for _, tok := range strings.FieldsFunc(s, isXMLSpace) {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(tok)
+	}
+	return sb.String()
+}
+
+// TextEqual reports whether a and b are the same text. With foldWhitespace,
+// the texts are compared after NormalizeSpace, so that differences in
+// indentation and line breaks do not count.
+func TextEqual(a, b string, foldWhitespace bool) bool {
+	if !foldWhitespace {
+		return a == b
+	}
+	return NormalizeSpace(a) == NormalizeSpace(b)
+}
+
+func isXMLSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+}
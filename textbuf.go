@@ -0,0 +1,36 @@
+package goxml
+
+import "strings"
+
+// largeText is the length from which appended text is collected in a
+// textBuffer instead of being concatenated.
+const largeText = 1024
+
+// textBuffer collects the text of the last child of an element. The strings
+// returned by the builder share its memory, but the builder only ever adds
+// bytes after them, so the strings never change.
+type textBuffer struct {
+	sb strings.Builder
+}
+
+// appendText returns text + more for the text node at the end of the
+// children of elt. Concatenating the strings would copy the text each time,
+// which is quadratic when a large text, such as an embedded base64 blob,
+// arrives in many pieces. Large texts are therefore collected in a buffer
+// that grows like a slice, so that appending is amortized O(1). The buffer
+// is only extended if text is the last string made from it, otherwise a new
+// one is started.
+func (elt *Element) appendText(text, more string) string {
+	if len(text)+len(more) < largeText {
+		return text + more
+	}
+	tb := elt.textTail
+	if tb == nil || tb.sb.Len() != len(text) || tb.sb.String() != text {
+		tb = &textBuffer{}
+		tb.sb.Grow(2 * (len(text) + len(more)))
+		tb.sb.WriteString(text)
+		elt.textTail = tb
+	}
+	tb.sb.WriteString(more)
+	return tb.sb.String()
+}
@@ -0,0 +1,39 @@
+package goxml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppendLargeText(t *testing.T) {
+	elt := NewElement()
+	elt.Name = "a"
+	piece := strings.Repeat("x", 700)
+	var want strings.Builder
+	var earlier []string
+	for i := 0; i < 20; i++ {
+		elt.Append(CharData{Contents: piece})
+		want.WriteString(piece)
+		earlier = append(earlier, want.String())
+	}
+	if got := elt.Children(); len(got) != 1 || got[0].(CharData).Contents != want.String() {
+		t.Fatalf("the appended text is not merged into one node of length %d", want.Len())
+	}
+	// a text taken before must not change when more text is appended
+	s := elt.Children()[0].(CharData).Contents
+	elt.Append(CharData{Contents: "y"})
+	if s != want.String() {
+		t.Error("appending text changed an earlier string")
+	}
+	// other text than the last one starts a new buffer
+	other := NewElement()
+	other.Name = "b"
+	other.Append(CharData{Contents: earlier[5]})
+	other.Append(CharData{Contents: piece})
+	if got := other.Children()[0].(CharData).Contents; got != earlier[6] {
+		t.Errorf("text of length %d, want %d", len(got), len(earlier[6]))
+	}
+	if s != want.String() || elt.Children()[0].(CharData).Contents != want.String()+"y" {
+		t.Error("appending to another element changed the text")
+	}
+}
@@ -0,0 +1,145 @@
+package goxml
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// TextPosition is the place of a character of a string value in the text
+// nodes it has been taken from.
+type TextPosition struct {
+	// Parent is the element that contains the node and Index the index of
+	// the node in the children of Parent.
+	Parent *Element
+	Index  int
+	// Node is the CharData or EntityRef node.
+	Node XMLNode
+	// Offset is the position of the character in the text of the node,
+	// counted in characters like the offsets of CharData.SplitAt and
+	// Element.SplitAt.
+	Offset int
+}
+
+// TextMap maps the character offsets in the string value of an element back
+// to the text nodes, see Element.StringvalueWithMap. It describes the
+// element at the time it has been created, changes of the element other
+// than InsertText make it invalid.
+type TextMap struct {
+	segments []textSegment
+	length   int
+}
+
+// textSegment is the text of a node in the string value, from the character
+// offset start on. inserted records the text added by InsertText as pairs
+// of the original offset in the node and the number of characters.
+type textSegment struct {
+	start    int
+	parent   *Element
+	index    int
+	inserted [][2]int
+}
+
+// nodeOffset returns the current offset in the node of the character at the
+// original offset.
+func (seg *textSegment) nodeOffset(offset int) int {
+	cur := offset
+	for _, ins := range seg.inserted {
+		if ins[0] <= offset {
+			cur += ins[1]
+		}
+	}
+	return cur
+}
+
+// StringvalueWithMap returns the string value of the element together with
+// a map from the offsets in the string value to the text nodes, so that the
+// results of hyphenation or text analysis done on the string value can be
+// written back to the right nodes.
+func (elt *Element) StringvalueWithMap() (string, *TextMap) {
+	m := &TextMap{}
+	var sb []byte
+	var collect func(*Element)
+	collect = func(e *Element) {
+		for i, c := range e.children {
+			var s string
+			switch t := c.(type) {
+			case CharData:
+				s = t.Contents
+			case EntityRef:
+				s = t.Value
+			case *Element:
+				collect(t)
+				continue
+			default:
+				continue
+			}
+			if s == "" {
+				continue
+			}
+			m.segments = append(m.segments, textSegment{start: m.length, parent: e, index: i})
+			m.length += utf8.RuneCountInString(s)
+			sb = append(sb, s...)
+		}
+	}
+	collect(elt)
+	return string(sb), m
+}
+
+// Len returns the length of the string value in characters.
+func (m *TextMap) Len() int {
+	return m.length
+}
+
+// segment returns the index of the segment that contains the character at
+// offset, -1 if offset is outside of the string value.
+func (m *TextMap) segment(offset int) int {
+	if offset < 0 || offset >= m.length {
+		return -1
+	}
+	return sort.Search(len(m.segments), func(i int) bool { return m.segments[i].start > offset }) - 1
+}
+
+// Locate returns the position of the character at offset in the string
+// value. ok is false if offset is outside of the string value.
+func (m *TextMap) Locate(offset int) (pos TextPosition, ok bool) {
+	i := m.segment(offset)
+	if i < 0 {
+		return TextPosition{}, false
+	}
+	seg := &m.segments[i]
+	return TextPosition{
+		Parent: seg.parent,
+		Index:  seg.index,
+		Node:   seg.parent.children[seg.index],
+		Offset: seg.nodeOffset(offset - seg.start),
+	}, true
+}
+
+// InsertText inserts s into the text node before the character at offset in
+// the string value, or at the end of the last text node if offset is the
+// length of the string value. Offsets keep referring to the string value as
+// it was returned by StringvalueWithMap, so the results of an analysis can
+// be inserted in any order. Text cannot be inserted into an entity
+// reference. The change is reported to the observers of the document.
+func (m *TextMap) InsertText(offset int, s string) error {
+	i := m.segment(offset)
+	if i < 0 && offset == m.length && m.length > 0 {
+		i = len(m.segments) - 1
+	}
+	if i < 0 {
+		return fmt.Errorf("offset %d out of range", offset)
+	}
+	seg := &m.segments[i]
+	cd, ok := seg.parent.children[seg.index].(CharData)
+	if !ok {
+		return fmt.Errorf("offset %d is in an entity reference", offset)
+	}
+	head, tail := cd.SplitAt(seg.nodeOffset(offset - seg.start))
+	changed := CharData{ID: cd.ID, Contents: head.Contents + s + tail.Contents, CDATA: cd.CDATA}
+	doc := seg.parent.invalidate()
+	seg.parent.children[seg.index] = changed
+	doc.notify(MutationEvent{Type: TextChanged, Target: seg.parent, Node: changed, Index: seg.index, OldValue: cd.Contents, NewValue: changed.Contents})
+	seg.inserted = append(seg.inserted, [2]int{offset - seg.start, utf8.RuneCountInString(s)})
+	return nil
+}
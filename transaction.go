@@ -0,0 +1,76 @@
+package goxml
+
+import "errors"
+
+// Transaction is a group of changes of a document that can be reverted as a
+// whole, see XMLDocument.Begin.
+type Transaction struct {
+	doc    *XMLDocument
+	outer  *Transaction
+	step   *undoStep
+	closed bool
+}
+
+// errTransactionClosed is returned for a transaction that has been
+// committed or rolled back.
+var errTransactionClosed = errors.New("transaction already committed or rolled back")
+
+// Begin starts a transaction. The changes made through the methods of the
+// nodes until Commit or Rollback belong to it, so that a transformation in
+// several steps that fails halfway can restore the document as it was
+// before Begin with Rollback. The changes take effect at once and are
+// visible to other code that reads the document, there is no isolation.
+// Transactions can be nested, the changes of a committed inner transaction
+// are reverted by a rollback of the outer one. Like all changes, a
+// transaction must not be used concurrently with other access to the
+// document.
+func (xr *XMLDocument) Begin() *Transaction {
+	tx := &Transaction{doc: xr, outer: xr.tx, step: newUndoStep()}
+	xr.tx = tx
+	return tx
+}
+
+// Commit keeps the changes of the transaction.
+func (tx *Transaction) Commit() error {
+	if err := tx.close(); err != nil {
+		return err
+	}
+	if outer := tx.outer; outer != nil {
+		// the outer transaction keeps the older states
+		for _, st := range tx.step.elements {
+			if !outer.step.seen[st.elt] {
+				outer.step.seen[st.elt] = true
+				outer.step.elements = append(outer.step.elements, st)
+			}
+		}
+		if tx.step.docChildren && !outer.step.docChildren {
+			outer.step.children = tx.step.children
+			outer.step.docChildren = true
+		}
+	}
+	return nil
+}
+
+// Rollback reverts the changes of the transaction. Changes of the exported
+// fields of the nodes are not reverted. The nodes are numbered anew like
+// after Undo.
+func (tx *Transaction) Rollback() error {
+	if err := tx.close(); err != nil {
+		return err
+	}
+	tx.doc.restore(tx.step)
+	return nil
+}
+
+// close ends the transaction, which must be the innermost open one.
+func (tx *Transaction) close() error {
+	if tx.closed {
+		return errTransactionClosed
+	}
+	if tx.doc.tx != tx {
+		return errors.New("transaction has an open inner transaction")
+	}
+	tx.closed = true
+	tx.doc.tx = tx.outer
+	return nil
+}
@@ -0,0 +1,91 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestTransactionRollback(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a x="1"/>t<b/>u</r>`)
+	before := doc.ToXML()
+	a := r.children[0].(*Element)
+	tx := doc.Begin()
+	a.SetAttribute(xml.Attr{Name: xml.Name{Local: "x"}, Value: "2"})
+	if err := r.InsertChild(1, CharData{Contents: "z"}); err != nil {
+		t.Fatal(err)
+	}
+	r.children[3].(*Element).Remove()
+	doc.Append(Comment{Contents: "c"})
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.ToXML(); got != before {
+		t.Errorf("after Rollback: %s, want %s", got, before)
+	}
+	checkIDs(t, doc)
+	if err := tx.Commit(); err != errTransactionClosed {
+		t.Errorf("Commit after Rollback = %v, want %v", err, errTransactionClosed)
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	doc, r := parseRoot(t, `<r/>`)
+	tx := doc.Begin()
+	r.Append(&Element{Name: "a"})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.ToXML(), `<r><a /></r>`; got != want {
+		t.Errorf("after Commit: %s, want %s", got, want)
+	}
+	if err := tx.Rollback(); err != errTransactionClosed {
+		t.Errorf("Rollback after Commit = %v, want %v", err, errTransactionClosed)
+	}
+}
+
+func TestNestedTransactions(t *testing.T) {
+	doc, r := parseRoot(t, `<r/>`)
+	outer := doc.Begin()
+	r.Append(&Element{Name: "a"})
+	inner := doc.Begin()
+	r.Append(&Element{Name: "b"})
+	if err := outer.Commit(); err == nil {
+		t.Error("Commit of the outer transaction succeeds while the inner one is open")
+	}
+	if err := inner.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	inner = doc.Begin()
+	r.Append(&Element{Name: "c"})
+	if err := inner.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.ToXML(), `<r><a /><b /></r>`; got != want {
+		t.Errorf("after the inner Rollback: %s, want %s", got, want)
+	}
+	// the outer rollback reverts the committed inner transaction
+	if err := outer.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.ToXML(), `<r />`; got != want {
+		t.Errorf("after the outer Rollback: %s, want %s", got, want)
+	}
+}
+
+func TestTransactionWithUndo(t *testing.T) {
+	doc, r := parseRoot(t, `<r/>`)
+	doc.EnableUndo(0)
+	r.Append(&Element{Name: "a"})
+	doc.Checkpoint()
+	tx := doc.Begin()
+	r.Append(&Element{Name: "b"})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Undo() {
+		t.Fatal("Undo() = false")
+	}
+	if got, want := doc.ToXML(), `<r><a /></r>`; got != want {
+		t.Errorf("Undo after Commit: %s, want %s", got, want)
+	}
+}
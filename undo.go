@@ -0,0 +1,229 @@
+package goxml
+
+// history records the state of the nodes before each change, so that the
+// changes can be undone. A step holds the state of each changed node before
+// its first change in the step, restoring these states undoes the whole
+// step.
+type history struct {
+	limit   int
+	current *undoStep
+	undo    []*undoStep
+	redo    []*undoStep
+}
+
+type undoStep struct {
+	elements []elementState
+	seen     map[*Element]bool
+	// children is the list of children of the document, if it has been
+	// changed
+	children    []XMLNode
+	docChildren bool
+}
+
+// elementState is the part of an element that the methods of the element
+// change.
+type elementState struct {
+	elt        *Element
+	children   []XMLNode
+	attributes []*Attribute
+	values     []Attribute
+	namespaces map[string]string
+	leading    []XMLNode
+}
+
+// EnableUndo starts recording the changes of the document, so that they can
+// be undone with Undo and redone with Redo. The changes are grouped into
+// steps, which end with a call to Checkpoint, so that one user action in an
+// editor is undone as a whole. limit is the number of steps kept, zero keeps
+// all. Only the changes made through the methods of the nodes are recorded,
+// changes to the exported fields are not. The state of each changed element
+// is kept, not a copy of the document. Undo and Redo do not notify the
+// observers of the document. Afterwards, all nodes are numbered anew in
+// document order. The user data stays with the elements, but that of text
+// nodes and other values in the restored elements is lost if the undone
+// changes gave them new IDs.
+func (xr *XMLDocument) EnableUndo(limit int) {
+	if xr.history == nil {
+		xr.history = &history{}
+	}
+	xr.history.limit = limit
+}
+
+// DisableUndo stops recording the changes and drops the recorded steps.
+func (xr *XMLDocument) DisableUndo() {
+	xr.history = nil
+}
+
+// Checkpoint ends the current undo step. The following changes form a new
+// step.
+func (xr *XMLDocument) Checkpoint() {
+	if h := xr.history; h != nil && h.current != nil {
+		h.undo = append(h.undo, h.current)
+		h.current = nil
+		if h.limit > 0 && len(h.undo) > h.limit {
+			h.undo = append(h.undo[:0], h.undo[len(h.undo)-h.limit:]...)
+		}
+	}
+}
+
+// CanUndo reports whether there are changes that Undo can revert.
+func (xr *XMLDocument) CanUndo() bool {
+	h := xr.history
+	return h != nil && (h.current != nil || len(h.undo) > 0)
+}
+
+// CanRedo reports whether there are undone changes that Redo can restore.
+func (xr *XMLDocument) CanRedo() bool {
+	return xr.history != nil && len(xr.history.redo) > 0
+}
+
+// Undo reverts the changes of the last step, including the changes since
+// the last Checkpoint. It returns false if there is nothing to undo.
+func (xr *XMLDocument) Undo() bool {
+	if !xr.CanUndo() {
+		return false
+	}
+	xr.Checkpoint()
+	h := xr.history
+	step := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, xr.restore(step))
+	return true
+}
+
+// Redo restores the changes of the last undone step. It returns false if
+// there is nothing to redo. New changes after Undo drop the undone steps.
+func (xr *XMLDocument) Redo() bool {
+	if !xr.CanRedo() {
+		return false
+	}
+	xr.Checkpoint()
+	h := xr.history
+	step := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, xr.restore(step))
+	return true
+}
+
+// restore sets the nodes to the states in step and returns the step that
+// reverts this.
+func (xr *XMLDocument) restore(step *undoStep) *undoStep {
+	reverse := &undoStep{}
+	for _, st := range step.elements {
+		reverse.elements = append(reverse.elements, captureElement(st.elt))
+	}
+	if step.docChildren {
+		xr.checkMutable()
+		reverse.children = append([]XMLNode(nil), xr.children...)
+		reverse.docChildren = true
+		xr.children = step.children
+		for _, c := range xr.children {
+			if elt, ok := c.(*Element); ok {
+				elt.Parent = xr
+			}
+		}
+	}
+	// the parents are set first, so that the caches of the right ancestors
+	// are cleared
+	for _, st := range step.elements {
+		st.elt.checkMutable()
+		st.elt.children = st.children
+		for _, c := range st.children {
+			if elt, ok := c.(*Element); ok {
+				elt.Parent = st.elt
+			}
+		}
+		st.elt.attributes = st.attributes
+		for i, attr := range st.attributes {
+			*attr = st.values[i]
+		}
+		st.elt.Namespaces = st.namespaces
+		st.elt.leading = st.leading
+	}
+	xr.restoring = true
+	for _, st := range step.elements {
+		st.elt.invalidate()
+	}
+	xr.restoring = false
+	// the restored text nodes and other values have the IDs from before the
+	// changes, which the inserted nodes or the nodes after them can have now
+	xr.reorder()
+	return reverse
+}
+
+// recordElement saves the state of elt before its first change in the
+// current undo step and in the open transaction.
+func (xr *XMLDocument) recordElement(elt *Element) {
+	if xr.restoring {
+		return
+	}
+	if xr.history != nil {
+		xr.history.step().addElement(elt)
+	}
+	if xr.tx != nil {
+		xr.tx.step.addElement(elt)
+	}
+}
+
+// recordDocument saves the children of the document before their first
+// change in the current undo step and in the open transaction.
+func (xr *XMLDocument) recordDocument() {
+	if xr.restoring {
+		return
+	}
+	if xr.history != nil {
+		xr.history.step().addDocument(xr)
+	}
+	if xr.tx != nil {
+		xr.tx.step.addDocument(xr)
+	}
+}
+
+func (step *undoStep) addElement(elt *Element) {
+	if step.seen[elt] {
+		return
+	}
+	step.seen[elt] = true
+	step.elements = append(step.elements, captureElement(elt))
+}
+
+func (step *undoStep) addDocument(xr *XMLDocument) {
+	if !step.docChildren {
+		step.children = append([]XMLNode(nil), xr.children...)
+		step.docChildren = true
+	}
+}
+
+// step returns the current step. A new change makes the undone steps
+// invalid.
+func (h *history) step() *undoStep {
+	h.redo = nil
+	if h.current == nil {
+		h.current = newUndoStep()
+	}
+	return h.current
+}
+
+func newUndoStep() *undoStep {
+	return &undoStep{seen: make(map[*Element]bool)}
+}
+
+func captureElement(elt *Element) elementState {
+	st := elementState{
+		elt:        elt,
+		children:   append([]XMLNode(nil), elt.children...),
+		attributes: append([]*Attribute(nil), elt.attributes...),
+		values:     make([]Attribute, len(elt.attributes)),
+		leading:    append([]XMLNode(nil), elt.leading...),
+	}
+	for i, attr := range elt.attributes {
+		st.values[i] = *attr
+	}
+	if elt.Namespaces != nil {
+		st.namespaces = make(map[string]string, len(elt.Namespaces))
+		for prefix, ns := range elt.Namespaces {
+			st.namespaces[prefix] = ns
+		}
+	}
+	return st
+}
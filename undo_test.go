@@ -0,0 +1,104 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a x="1"/>t</r>`)
+	states := []string{doc.ToXML()}
+	doc.EnableUndo(0)
+	a := r.children[0].(*Element)
+
+	a.SetAttribute(xml.Attr{Name: xml.Name{Local: "x"}, Value: "2"})
+	a.Append(CharData{Contents: "in a"})
+	doc.Checkpoint()
+	states = append(states, doc.ToXML())
+
+	a.Remove()
+	doc.Checkpoint()
+	states = append(states, doc.ToXML())
+
+	r.DeclareNamespace("p", "P")
+	doc.Append(Comment{Contents: "end"})
+	states = append(states, doc.ToXML())
+
+	for i := len(states) - 2; i >= 0; i-- {
+		if !doc.Undo() {
+			t.Fatalf("Undo() = false, want state %d", i)
+		}
+		if got := doc.ToXML(); got != states[i] {
+			t.Errorf("after Undo: %s, want %s", got, states[i])
+		}
+		checkIDs(t, doc)
+	}
+	if doc.CanUndo() || doc.Undo() {
+		t.Error("Undo succeeds at the first state")
+	}
+	for i := 1; i < len(states); i++ {
+		if !doc.Redo() {
+			t.Fatalf("Redo() = false, want state %d", i)
+		}
+		if got := doc.ToXML(); got != states[i] {
+			t.Errorf("after Redo: %s, want %s", got, states[i])
+		}
+		checkIDs(t, doc)
+	}
+	if doc.CanRedo() {
+		t.Error("CanRedo() = true at the last state")
+	}
+}
+
+func TestUndoDropsRedo(t *testing.T) {
+	doc, r := parseRoot(t, `<r/>`)
+	doc.EnableUndo(0)
+	r.Append(&Element{Name: "a"})
+	doc.Undo()
+	r.Append(&Element{Name: "b"})
+	if doc.CanRedo() {
+		t.Error("a change after Undo keeps the undone step")
+	}
+	if got, want := doc.ToXML(), `<r><b /></r>`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestUndoLimit(t *testing.T) {
+	doc, r := parseRoot(t, `<r/>`)
+	doc.EnableUndo(2)
+	for _, name := range []string{"a", "b", "c"} {
+		r.Append(&Element{Name: name})
+		doc.Checkpoint()
+	}
+	undone := 0
+	for doc.Undo() {
+		undone++
+	}
+	if undone != 2 {
+		t.Errorf("%d steps undone, want 2", undone)
+	}
+	if got, want := doc.ToXML(), `<r><a /></r>`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestUndoInsertIDs(t *testing.T) {
+	doc, r := parseRoot(t, `<r><a/>t<b/>u</r>`)
+	doc.EnableUndo(0)
+	if err := r.InsertChild(0, &Element{Name: "n"}); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Undo() {
+		t.Fatal("Undo() = false")
+	}
+	checkIDs(t, doc)
+	nodes := SortByDocumentOrder(append([]XMLNode(nil), r.children...)).SortAndEliminateDuplicates()
+	if len(nodes) != 4 {
+		t.Errorf("SortAndEliminateDuplicates returns %d of 4 nodes after Undo", len(nodes))
+	}
+	if !doc.Redo() {
+		t.Fatal("Redo() = false")
+	}
+	checkIDs(t, doc)
+}
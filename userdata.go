@@ -0,0 +1,48 @@
+package goxml
+
+// SetUserData attaches value to the node n of the document under key, for
+// example state computed by one processing pass for the next. The data is
+// kept in the document and found by the ID of the node, so it also works for
+// text nodes and other value types, and copies of a node share its data. A
+// nil value removes the entry. XInclude processing assigns new IDs to all
+// nodes and removes the user data of the document. SetUserData panics for a
+// sealed document, see Seal.
+func (xr *XMLDocument) SetUserData(n XMLNode, key string, value any) {
+	if xr.sealed {
+		panic(errSealed)
+	}
+	id := n.getID()
+	if value == nil {
+		if data, ok := xr.userData[id]; ok {
+			delete(data, key)
+			if len(data) == 0 {
+				delete(xr.userData, id)
+			}
+		}
+		return
+	}
+	if xr.userData == nil {
+		xr.userData = make(map[int64]map[string]any)
+	}
+	data := xr.userData[id]
+	if data == nil {
+		data = make(map[string]any)
+		xr.userData[id] = data
+	}
+	data[key] = value
+}
+
+// UserData returns the value attached to the node n under key with
+// SetUserData, or nil.
+func (xr *XMLDocument) UserData(n XMLNode, key string) any {
+	return xr.userData[n.getID()][key]
+}
+
+// ClearUserData removes the user data of all nodes of the document. It
+// panics for a sealed document.
+func (xr *XMLDocument) ClearUserData() {
+	if xr.sealed {
+		panic(errSealed)
+	}
+	xr.userData = nil
+}
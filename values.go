@@ -0,0 +1,131 @@
+package goxml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trimmedValue returns the string value of the element without leading and
+// trailing white space.
+func (elt *Element) trimmedValue() string {
+	return strings.Trim(elt.Stringvalue(), " \t\r\n")
+}
+
+// valueError returns the error for a string value that cannot be read as
+// the type kind.
+func (elt *Element) valueError(kind, value string) error {
+	return fmt.Errorf("line %d: <%s>: invalid %s %q", elt.Line, elt.qualifiedName(), kind, value)
+}
+
+// Int returns the string value of the element as a decimal integer. Leading
+// and trailing white space is ignored, a leading + is allowed.
+func (elt *Element) Int() (int, error) {
+	s := elt.trimmedValue()
+	i, err := strconv.ParseInt(s, 10, 0)
+	if err != nil {
+		return 0, elt.valueError("integer", s)
+	}
+	return int(i), nil
+}
+
+// Float returns the string value of the element as a floating point number
+// like 1.5, -2 or 3e8. Leading and trailing white space is ignored, INF,
+// -INF and NaN are read as in XML Schema.
+func (elt *Element) Float() (float64, error) {
+	s := elt.trimmedValue()
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, elt.valueError("number", s)
+	}
+	return f, nil
+}
+
+// Bool returns the string value of the element as a boolean value, which is
+// true, false, 1 or 0 as in XML Schema. Leading and trailing white space is
+// ignored.
+func (elt *Element) Bool() (bool, error) {
+	s := elt.trimmedValue()
+	switch s {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, elt.valueError("boolean", s)
+}
+
+// Time returns the string value of the element as a time in the layout of
+// time.Parse, such as time.RFC3339 or "2006-01-02". Leading and trailing
+// white space is ignored.
+func (elt *Element) Time(layout string) (time.Time, error) {
+	s := elt.trimmedValue()
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, elt.valueError("time", s)
+	}
+	return t, nil
+}
+
+// Child returns the first child element with the local name name, nil if
+// there is none.
+func (elt *Element) Child(name string) *Element {
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok && cld.Name == name {
+			return cld
+		}
+	}
+	return nil
+}
+
+// child returns the first child element with the local name name or an
+// error if there is none.
+func (elt *Element) child(name string) (*Element, error) {
+	if cld := elt.Child(name); cld != nil {
+		return cld, nil
+	}
+	return nil, fmt.Errorf("line %d: <%s>: no child element <%s>", elt.Line, elt.qualifiedName(), name)
+}
+
+// ChildInt returns the value of the first child element with the local name
+// name as an integer, see Int. It is an error if there is no such child.
+func (elt *Element) ChildInt(name string) (int, error) {
+	cld, err := elt.child(name)
+	if err != nil {
+		return 0, err
+	}
+	return cld.Int()
+}
+
+// ChildFloat returns the value of the first child element with the local
+// name name as a number, see Float. It is an error if there is no such
+// child.
+func (elt *Element) ChildFloat(name string) (float64, error) {
+	cld, err := elt.child(name)
+	if err != nil {
+		return 0, err
+	}
+	return cld.Float()
+}
+
+// ChildBool returns the value of the first child element with the local
+// name name as a boolean value, see Bool. It is an error if there is no such
+// child.
+func (elt *Element) ChildBool(name string) (bool, error) {
+	cld, err := elt.child(name)
+	if err != nil {
+		return false, err
+	}
+	return cld.Bool()
+}
+
+// ChildTime returns the value of the first child element with the local
+// name name as a time, see Time. It is an error if there is no such child.
+func (elt *Element) ChildTime(name, layout string) (time.Time, error) {
+	cld, err := elt.child(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cld.Time(layout)
+}
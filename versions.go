@@ -0,0 +1,107 @@
+package goxml
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Version is a committed state of a document, see XMLDocument.Commit.
+type Version struct {
+	// Number is the position of the version in the history of the
+	// document, starting at 1.
+	Number int
+	Label  string
+	Time   time.Time
+	// doc is the frozen snapshot of the document, mark the dirty epoch of
+	// the commit, see dirtyEpoch
+	doc  *XMLDocument
+	mark int64
+}
+
+// Commit stores the current state of the document as a new version with the
+// label and returns it, so that earlier versions can be read with At and
+// compared with DiffVersions, for example in an editing backend. A version
+// is a frozen Snapshot, which shares names and text with the document but
+// not the node structure, so each version needs memory for all elements and
+// attributes of the document. If the document has not been changed through
+// its methods since the last version, the new version shares the snapshot of
+// the last one. The versions are kept until ClearVersions is called.
+func (xr *XMLDocument) Commit(label string) Version {
+	v := &Version{Number: len(xr.versions) + 1, Label: label, Time: time.Now()}
+	if n := len(xr.versions); n > 0 && !xr.changedSince(xr.versions[n-1].mark) {
+		v.doc = xr.versions[n-1].doc
+	} else {
+		v.doc = xr.Snapshot()
+		v.doc.Freeze()
+	}
+	// changes made from now on are newer than the mark
+	v.mark = atomic.AddInt64(&dirtyEpoch, 1)
+	xr.versions = append(xr.versions, v)
+	return *v
+}
+
+// changedSince reports whether the document has been changed through its
+// methods after the dirty epoch mark.
+func (xr *XMLDocument) changedSince(mark int64) bool {
+	if xr.modified > mark {
+		return true
+	}
+	for _, c := range xr.children {
+		if elt, ok := c.(*Element); ok && elt.modifiedBelow > mark {
+			return true
+		}
+	}
+	return false
+}
+
+// Versions returns the committed versions in the order of their numbers.
+func (xr *XMLDocument) Versions() []Version {
+	versions := make([]Version, len(xr.versions))
+	for i, v := range xr.versions {
+		versions[i] = *v
+	}
+	return versions
+}
+
+// LookupVersion returns the last version with the label.
+func (xr *XMLDocument) LookupVersion(label string) (Version, bool) {
+	for i := len(xr.versions) - 1; i >= 0; i-- {
+		if xr.versions[i].Label == label {
+			return *xr.versions[i], true
+		}
+	}
+	return Version{}, false
+}
+
+// At returns the document as it was committed in the version with the
+// number. The result is frozen, so it can be read by several goroutines at
+// the same time, and its nodes have the IDs of the nodes of the document at
+// the time of the commit. Use Snapshot on it to get a document that can be
+// changed.
+func (xr *XMLDocument) At(version int) (*XMLDocument, error) {
+	if version < 1 || version > len(xr.versions) {
+		return nil, fmt.Errorf("version %d not found, the document has %d versions", version, len(xr.versions))
+	}
+	return xr.versions[version-1].doc, nil
+}
+
+// DiffVersions returns the differences between the versions from and to,
+// see Diff.
+func (xr *XMLDocument) DiffVersions(from, to int) ([]Difference, error) {
+	old, err := xr.At(from)
+	if err != nil {
+		return nil, err
+	}
+	new, err := xr.At(to)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(old, new), nil
+}
+
+// ClearVersions removes all versions. The next version committed gets the
+// number 1.
+func (xr *XMLDocument) ClearVersions() {
+	xr.versions = nil
+}
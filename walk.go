@@ -0,0 +1,54 @@
+package goxml
+
+// WalkAction tells Walk how to go on after visiting a node.
+type WalkAction int
+
+const (
+	// Continue visits the children of the node and then the following
+	// nodes.
+	Continue WalkAction = iota
+	// SkipChildren does not visit the children of the node, the walk goes
+	// on with its next sibling.
+	SkipChildren
+	// Stop ends the walk.
+	Stop
+)
+
+// Walk calls fn for n and its descendants in document order, the parent
+// before its children. Attributes are not visited. fn decides with its
+// result whether the children of a node are visited and whether the walk
+// goes on, so that scans of large documents can leave out the subtrees that
+// are of no interest. Walk returns Stop if fn has stopped the walk and
+// Continue otherwise. Nodes that fn appends to the current node are visited,
+// other changes to the tree during the walk give undefined results.
+func Walk(n XMLNode, fn func(XMLNode) WalkAction) WalkAction {
+	switch fn(n) {
+	case Stop:
+		return Stop
+	case SkipChildren:
+		return Continue
+	}
+	for _, c := range n.Children() {
+		if Walk(c, fn) == Stop {
+			return Stop
+		}
+	}
+	return Continue
+}
+
+// WalkElements calls fn for elt and its descendant elements in document
+// order like Walk, without the other nodes.
+func WalkElements(elt *Element, fn func(*Element) WalkAction) WalkAction {
+	switch fn(elt) {
+	case Stop:
+		return Stop
+	case SkipChildren:
+		return Continue
+	}
+	for _, c := range elt.children {
+		if cld, ok := c.(*Element); ok && WalkElements(cld, fn) == Stop {
+			return Stop
+		}
+	}
+	return Continue
+}
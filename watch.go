@@ -0,0 +1,119 @@
+package goxml
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// watchInterval is the time between two checks of a watched file. A change
+// is only read when the file has not changed for one more interval, so that
+// a file is not read while it is being written.
+const watchInterval = 500 * time.Millisecond
+
+// Watcher keeps the document of a file up to date. It is safe for
+// concurrent use.
+type Watcher struct {
+	path     string
+	opts     []ParseOption
+	onUpdate func(*XMLDocument, error)
+	doc      atomic.Pointer[XMLDocument]
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// WatchFile parses the file at path and parses it again each time it
+// changes. The new document replaces the previous one atomically and is
+// passed to onUpdate, which may be nil. If the changed file cannot be parsed,
+// onUpdate is called with the error and the previous document is kept. The
+// file is checked for changes by polling, so no platform specific file
+// system notifications are needed. An error is returned if the first parse
+// fails.
+func WatchFile(path string, onUpdate func(*XMLDocument, error), opts ...ParseOption) (*Watcher, error) {
+	w := &Watcher{
+		path:     path,
+		opts:     append([]ParseOption{WithSourceName(path), WithBaseURI(fileURI(path))}, opts...),
+		onUpdate: onUpdate,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := w.parse()
+	if err != nil {
+		return nil, err
+	}
+	w.doc.Store(doc)
+	go w.run(fi)
+	return w, nil
+}
+
+// Document returns the most recent document.
+func (w *Watcher) Document() *XMLDocument {
+	return w.doc.Load()
+}
+
+// Stop ends watching the file. onUpdate is not called after Stop returns.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *Watcher) parse() (*XMLDocument, error) {
+	b, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(b, w.opts...)
+}
+
+func (w *Watcher) run(last os.FileInfo) {
+	defer close(w.done)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	pending := false
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(w.path)
+		if err != nil {
+			// the file may be replaced right now
+			continue
+		}
+		if !sameFile(fi, last) {
+			last = fi
+			pending = true
+			continue
+		}
+		if !pending {
+			continue
+		}
+		pending = false
+		doc, err := w.parse()
+		if err == nil {
+			w.doc.Store(doc)
+		}
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		if w.onUpdate != nil {
+			w.onUpdate(doc, err)
+		}
+	}
+}
+
+// sameFile reports whether a and b describe the same version of a file.
+func sameFile(a, b os.FileInfo) bool {
+	return a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size() && os.SameFile(a, b)
+}
@@ -0,0 +1,302 @@
+package goxml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// xmlWriter collects the serialized output of the nodes. The first error
+// stops all further writes and is reported by flush.
+type xmlWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+	// inherited contains the namespace bindings from outside of the
+	// serialized subtree which must be declared on its first element.
+	inherited map[string]string
+	// cache makes elements keep their serialized form for the next run.
+	cache         bool
+	illegalChars  CharPolicy
+	skipDefaulted bool
+	rawAttributes bool
+	keepLayout    bool
+	// indent is the string written per nesting level when pretty printing,
+	// depth is the current level. keepSpace is set for the contents of
+	// elements that are written unchanged.
+	indent    string
+	depth     int
+	keepSpace bool
+	// wrapAttributes is the number of attributes above which a start tag is
+	// written with one attribute per line, maxLineLength the length of the
+	// start tag. attributeIndent indents the wrapped attributes.
+	wrapAttributes  int
+	maxLineLength   int
+	attributeIndent string
+	// expandEmpty writes empty elements with a start and an end tag.
+	expandEmpty bool
+}
+
+func newXMLWriter(w io.Writer) *xmlWriter {
+	return &xmlWriter{w: w}
+}
+
+// sub returns a writer to w with the same settings as xw.
+func (xw *xmlWriter) sub(w io.Writer) *xmlWriter {
+	return &xmlWriter{
+		w:               w,
+		cache:           xw.cache,
+		illegalChars:    xw.illegalChars,
+		skipDefaulted:   xw.skipDefaulted,
+		rawAttributes:   xw.rawAttributes,
+		keepLayout:      xw.keepLayout,
+		indent:          xw.indent,
+		depth:           xw.depth,
+		keepSpace:       xw.keepSpace,
+		wrapAttributes:  xw.wrapAttributes,
+		maxLineLength:   xw.maxLineLength,
+		attributeIndent: xw.attributeIndent,
+		expandEmpty:     xw.expandEmpty,
+	}
+}
+
+// apply sets the options that change the output.
+func (xw *xmlWriter) apply(opts SerializeOptions) {
+	xw.cache = opts.Cache
+	xw.illegalChars = opts.IllegalChars
+	xw.skipDefaulted = opts.SkipDefaulted
+	xw.rawAttributes = opts.RawAttributes
+	xw.keepLayout = opts.KeepTagLayout
+	xw.indent = opts.Indent
+	xw.wrapAttributes = opts.WrapAttributes
+	xw.maxLineLength = opts.MaxLineLength
+	xw.attributeIndent = opts.AttributeIndent
+	xw.expandEmpty = opts.ExpandEmpty
+}
+
+// cacheKey identifies the settings that change the serialized form of an
+// element, so that a cached form is only used with the same settings.
+func (xw *xmlWriter) cacheKey() int {
+	key := int(xw.illegalChars) << 4
+	if xw.skipDefaulted {
+		key |= 1
+	}
+	if xw.rawAttributes {
+		key |= 2
+	}
+	if xw.keepLayout {
+		key |= 4
+	}
+	if xw.expandEmpty {
+		key |= 8
+	}
+	return key
+}
+
+func (xw *xmlWriter) writeString(strs ...string) {
+	for _, s := range strs {
+		if xw.err != nil {
+			return
+		}
+		n, err := io.WriteString(xw.w, s)
+		xw.n += int64(n)
+		xw.err = err
+	}
+}
+
+// escape modes of writeText
+const (
+	escapeNone = iota
+	escapeText
+	escapeAttribute
+)
+
+// writeCharData writes s as the contents of an element.
+func (xw *xmlWriter) writeCharData(s string) {
+	xw.writeText(s, escapeText)
+}
+
+// writeCDATA writes s as a CDATA section. Text that contains ]]> or a
+// carriage return, which a parser would turn into a newline, is written like
+// other text.
+func (xw *xmlWriter) writeCDATA(s string) {
+	if strings.Contains(s, "]]>") || strings.Contains(s, "\r") {
+		xw.writeCharData(s)
+		return
+	}
+	xw.writeString("<![CDATA[")
+	xw.writeText(s, escapeNone)
+	xw.writeString("]]>")
+}
+
+// writeAttributeValue writes s as an attribute value in double quotes.
+func (xw *xmlWriter) writeAttributeValue(s string) {
+	xw.writeText(s, escapeAttribute)
+}
+
+// writeText writes s and replaces the characters that have a special meaning
+// in the escape mode by references. In text, these are &, <, " and the > of
+// ]]>, and carriage returns which a parser would turn into newlines. In
+// attribute values, tabs and newlines are escaped as well, since a parser
+// would normalize them to spaces. Characters not allowed in XML are handled
+// according to the policy of the writer. Runs of characters that need no
+// changes are written unchanged.
+func (xw *xmlWriter) writeText(s string, mode int) {
+	last := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		size := 1
+		var esc string
+		switch {
+		case mode != escapeNone && c == '&':
+			esc = "&amp;"
+		case mode != escapeNone && c == '<':
+			esc = "&lt;"
+		case mode != escapeNone && c == '"':
+			esc = "&quot;"
+		case mode == escapeText && c == '>' && i >= 2 && s[i-2] == ']' && s[i-1] == ']':
+			esc = "&gt;"
+		case mode != escapeNone && c == '\r':
+			esc = "&#13;"
+		case mode == escapeAttribute && c == '\n':
+			esc = "&#10;"
+		case mode == escapeAttribute && c == '\t':
+			esc = "&#9;"
+		case c < 0x20 && c != '\t' && c != '\n' && c != '\r':
+			esc = xw.illegalChar(rune(c))
+		case c >= utf8.RuneSelf:
+			var r rune
+			r, size = utf8.DecodeRuneInString(s[i:])
+			if (r != utf8.RuneError || size > 1) && isXMLChar(r) {
+				i += size
+				continue
+			}
+			esc = xw.illegalChar(r)
+		default:
+			i++
+			continue
+		}
+		xw.writeString(s[last:i], esc)
+		i += size
+		last = i
+	}
+	xw.writeString(s[last:])
+}
+
+// illegalChar returns the replacement for a character not allowed in XML. With
+// the CharError policy, it sets the error of the writer.
+func (xw *xmlWriter) illegalChar(r rune) string {
+	switch xw.illegalChars {
+	case CharReplace:
+		return "\uFFFD"
+	case CharStrip:
+		return ""
+	}
+	if xw.err == nil {
+		xw.err = fmt.Errorf("character %U is not allowed in XML", r)
+	}
+	return ""
+}
+
+// newline starts a new line indented to the current depth.
+func (xw *xmlWriter) newline() {
+	xw.writeString("\n")
+	for i := 0; i < xw.depth; i++ {
+		xw.writeString(xw.indent)
+	}
+}
+
+func (xw *xmlWriter) writeNamespace(prefix, ns string) {
+	if prefix == "" {
+		xw.writeString("xmlns=\"")
+	} else {
+		xw.writeString("xmlns:", prefix, "=\"")
+	}
+	xw.writeAttributeValue(ns)
+	xw.writeString("\"")
+}
+
+// flush writes the buffered data to the underlying writer and returns the
+// number of bytes written and the first error that occurred.
+func (xw *xmlWriter) flush(bw *bufio.Writer) (int64, error) {
+	if xw.err != nil {
+		return xw.n, xw.err
+	}
+	return xw.n, bw.Flush()
+}
+
+// serializeParallel writes elt and lets up to workers goroutines serialize
+// the children of elt concurrently.
+func (xw *xmlWriter) serializeParallel(elt *Element, workers int) {
+	elt.writeStartTag(xw)
+	if !elt.closeStartTag(xw) {
+		return
+	}
+	// serialize the children in windows to bound the memory held in the
+	// intermediate buffers
+	window := workers * 16
+	results := make([]string, window)
+	errs := make([]error, window)
+	for start := 0; start < len(elt.children); start += window {
+		end := start + window
+		if end > len(elt.children) {
+			end = len(elt.children)
+		}
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					child := elt.children[i]
+					var sb strings.Builder
+					sb.Grow(estimateSize(child))
+					cxw := xw.sub(&sb)
+					serializeChild(cxw, child)
+					results[i-start] = sb.String()
+					errs[i-start] = cxw.err
+				}
+			}()
+		}
+		for i := start; i < end; i++ {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		for i := 0; i < end-start; i++ {
+			if errs[i] != nil && xw.err == nil {
+				xw.err = errs[i]
+			}
+			xw.writeString(results[i])
+			results[i] = ""
+			errs[i] = nil
+		}
+	}
+	elt.writeEndTag(xw)
+}
+
+// estimateSize returns the approximate length of the serialized node, so
+// that the output buffer can be allocated in one piece.
+func estimateSize(n XMLNode) int {
+	switch t := n.(type) {
+	case *Element:
+		return t.estimateSize()
+	case CharData:
+		return len(t.Contents)
+	case EntityRef:
+		return len(t.Name) + 2
+	case Comment:
+		return len(t.Contents) + 7
+	case ProcInst:
+		return len(t.Target) + len(t.Inst) + 5
+	}
+	size := 0
+	for _, child := range n.Children() {
+		size += estimateSize(child)
+	}
+	return size
+}
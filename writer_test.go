@@ -0,0 +1,108 @@
+package goxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestElementWriteToMatchesToXML(t *testing.T) {
+	_, r := parseRoot(t, `<r xmlns:p="P"><p:a x="1">text</p:a></r>`)
+	r.Append(CharData{Contents: "bad\x01char"})
+	for _, elt := range []*Element{r, r.FirstChildElement()} {
+		var buf bytes.Buffer
+		if _, err := elt.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), elt.ToXML(); got != want {
+			t.Errorf("WriteTo = %q, ToXML = %q", got, want)
+		}
+	}
+	if got, want := r.ToXML(), `<r xmlns:p="P"><p:a x="1">text</p:a>bad`+"�"+`char</r>`; got != want {
+		t.Errorf("ToXML = %q, want %q", got, want)
+	}
+}
+
+func TestElementWriteXMLCache(t *testing.T) {
+	_, r := parseRoot(t, `<r><a>1</a><b>2</b></r>`)
+	var first, second strings.Builder
+	if err := r.WriteXML(&first, SerializeOptions{Cache: true}); err != nil {
+		t.Fatal(err)
+	}
+	a := r.FirstChildElement()
+	if !a.serializedCached {
+		t.Error("the child of the written element is not cached")
+	}
+	a.Append(CharData{Contents: "x"})
+	if err := r.WriteXML(&second, SerializeOptions{Cache: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := second.String(), `<r><a>1x</a><b>2</b></r>`; got != want {
+		t.Errorf("WriteXML after a change = %q, want %q", got, want)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`<a/>`, `<a />`},
+		{`<a x="1" y='2'>t &amp; &lt;u&gt; "q"</a>`, `<a x="1" y="2">t &amp; &lt;u> &quot;q&quot;</a>`},
+		{"<?xml version=\"1.0\"?>\n<!-- c --><a><?pi data?><b>x</b><!--y--></a>\n", "<?xml version=\"1.0\"?>\n<!-- c --><a><?pi data?><b>x</b><!--y--></a>\n"},
+		{`<a xmlns="d" xmlns:p="P"><p:b p:x="1" y="2"><c xmlns=""/></p:b></a>`, `<a xmlns="d" xmlns:p="P"><p:b p:x="1" y="2"><c xmlns="" /></p:b></a>`},
+	}
+	for _, tc := range tests {
+		doc, err := Parse(strings.NewReader(tc.in))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if got := doc.ToXML(); got != tc.want {
+			t.Errorf("Parse(%q).ToXML() = %q, want %q", tc.in, got, tc.want)
+		}
+		// the output parses to the same result
+		again, err := Parse(strings.NewReader(tc.want))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.want, err)
+			continue
+		}
+		if got := again.ToXML(); got != tc.want {
+			t.Errorf("Parse(%q).ToXML() = %q, want it unchanged", tc.want, got)
+		}
+	}
+}
+
+func TestWriteEscaping(t *testing.T) {
+	tests := []struct {
+		text, attr, want string
+	}{
+		{"plain", "plain", `<a v="plain">plain</a>`},
+		{`<&>"'`, `<&>"'`, `<a v="&lt;&amp;>&quot;'">&lt;&amp;>&quot;'</a>`},
+		{"]]>]>", "]]>", `<a v="]]>">]]&gt;]></a>`},
+		{"l1\r\nl2\tx", "l1\r\nl2\tx", `<a v="l1&#13;&#10;l2&#9;x">l1&#13;
+l2	x</a>`},
+		{"äö€𝄞", "äö€𝄞", `<a v="äö€𝄞">äö€𝄞</a>`},
+	}
+	for _, tc := range tests {
+		elt := NewElement()
+		elt.Name = "a"
+		elt.SetAttribute(xml.Attr{Name: xml.Name{Local: "v"}, Value: tc.attr})
+		elt.Append(CharData{Contents: tc.text})
+		got := elt.ToXML()
+		if got != tc.want {
+			t.Errorf("text %q, attribute %q: %s, want %s", tc.text, tc.attr, got, tc.want)
+			continue
+		}
+		// the parser restores the text and the attribute value
+		doc, err := Parse(strings.NewReader(got))
+		if err != nil {
+			t.Errorf("Parse(%q): %v", got, err)
+			continue
+		}
+		r, _ := doc.Root()
+		if v := r.Attributes()[0].Value; v != tc.attr || r.Stringvalue() != tc.text {
+			t.Errorf("%s parses to text %q, attribute %q", got, r.Stringvalue(), v)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package goxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// XIncludeNamespace is the namespace of the XInclude elements.
+const XIncludeNamespace = "http://www.w3.org/2001/XInclude"
+
+// Resolver opens the resources that documents refer to, such as included
+// files.
+type Resolver interface {
+	// Open returns the contents of the resource at the absolute URI uri.
+	Open(uri string) (io.ReadCloser, error)
+}
+
+// FileResolver opens file: URIs and URIs without a scheme from the file
+// system. Other schemes are rejected, so a document cannot make the program
+// fetch network resources.
+type FileResolver struct{}
+
+// Open opens the file uri refers to.
+func (FileResolver) Open(uri string) (io.ReadCloser, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "" && u.Scheme != "file" {
+		return nil, fmt.Errorf("cannot open %s: unsupported scheme %s", uri, u.Scheme)
+	}
+	return os.Open(filepath.FromSlash(u.Path))
+}
+
+// XIncludeOptions controls the XInclude processing of a document.
+type XIncludeOptions struct {
+	// Resolver opens the included resources. The default is FileResolver.
+	Resolver Resolver
+	// ParseOptions are used for parsing the included documents. The base
+	// URI is set to the URI of each document.
+	ParseOptions []ParseOption
+	// MaxDepth limits the nesting of inclusions, the default is 32.
+	MaxDepth int
+}
+
+// XInclude replaces the xi:include elements of the document by the resources
+// they refer to, as defined by XInclude 1.0. Included XML documents are
+// processed recursively. With parse="text", the resource is included as
+// text, which must be UTF-8 encoded. The xpointer attribute supports
+// shorthand pointers, which select the element with that xml:id or id
+// attribute, and the element() scheme. If a resource cannot be included, the
+// contents of the xi:fallback child are used instead, and without fallback
+// an error is returned. Top-level elements from other documents get an
+// xml:base attribute, so that relative references in them still resolve.
+// Other documents are parsed with their URI as source name, which is
+// reported in errors and by the Source method of the included elements.
+//
+// Relative references are resolved against the base URI of the include
+// element, so the document should be parsed with WithBaseURI. Afterwards,
+// all nodes of the document are numbered again to keep the IDs in document
+// order, and the user data of the document is removed, because it would
+// belong to other nodes. Each replaced include element is reported to the
+// observers of the document as removed and the included nodes as appended,
+// and the changes are recorded for Undo. If an inclusion fails, the changes
+// made so far are reverted like with Transaction.Rollback, the observers are
+// not notified and the error is returned.
+func (xr *XMLDocument) XInclude(opts XIncludeOptions) error {
+	xr.checkMutable()
+	if opts.Resolver == nil {
+		opts.Resolver = FileResolver{}
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 32
+	}
+	xi := &xincluder{opts: opts, doc: xr}
+	tx := xr.Begin()
+	if err := xi.process(xr, []inclusion{{uri: xr.baseURI}}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	xr.renumber()
+	for _, ev := range xi.events {
+		xr.notify(ev)
+	}
+	return nil
+}
+
+type xincluder struct {
+	opts XIncludeOptions
+	doc  *XMLDocument
+	// events are the changes of doc, which are reported when all
+	// inclusions have succeeded
+	events []MutationEvent
+}
+
+// inclusion is a resource being included.
+type inclusion struct {
+	uri      string
+	xpointer string
+}
+
+// process replaces the include elements below n. stack contains the
+// resources being included, the last one is the one n belongs to.
+func (xi *xincluder) process(n XMLNode, stack []inclusion) error {
+	var children []XMLNode
+	switch t := n.(type) {
+	case *XMLDocument:
+		children = t.children
+	case *Element:
+		children = t.children
+	default:
+		return nil
+	}
+	var result []XMLNode
+	// replaced are the include elements and the positions of their
+	// replacements in result
+	type replacement struct {
+		elt   *Element
+		index int
+		nodes []XMLNode
+	}
+	var replaced []replacement
+	changed := false
+	for i, c := range children {
+		elt, ok := c.(*Element)
+		if !ok {
+			if changed {
+				result = append(result, c)
+			}
+			continue
+		}
+		if !isXInclude(elt, "include") {
+			if err := xi.process(elt, stack); err != nil {
+				return err
+			}
+			if changed {
+				result = append(result, c)
+			}
+			continue
+		}
+		nodes, err := xi.include(elt, stack)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			result = append(result, children[:i]...)
+			changed = true
+		}
+		replaced = append(replaced, replacement{elt: elt, index: len(result), nodes: nodes})
+		result = append(result, nodes...)
+	}
+	if !changed {
+		return nil
+	}
+	var doc *XMLDocument
+	switch t := n.(type) {
+	case *XMLDocument:
+		doc = t
+		t.changeChildren()
+		t.children = result
+	case *Element:
+		doc = t.invalidate()
+		t.children = result
+	}
+	for _, c := range result {
+		c.setParent(n)
+	}
+	for _, r := range replaced {
+		r.elt.Parent = nil
+		if doc != xi.doc {
+			continue
+		}
+		xi.events = append(xi.events, MutationEvent{Type: NodeRemoved, Target: n, Node: r.elt, Index: r.index})
+		for i, c := range r.nodes {
+			xi.events = append(xi.events, MutationEvent{Type: NodeAppended, Target: n, Node: c, Index: r.index + i})
+		}
+	}
+	return nil
+}
+
+// include returns the nodes that replace the include element elt.
+func (xi *xincluder) include(elt *Element, stack []inclusion) ([]XMLNode, error) {
+	nodes, err := xi.resource(elt, stack)
+	if err == nil {
+		return nodes, nil
+	}
+	var fatal *xincludeError
+	if errors.As(err, &fatal) {
+		return nil, err
+	}
+	for _, c := range elt.children {
+		if fb, ok := c.(*Element); ok && isXInclude(fb, "fallback") {
+			if err := xi.process(fb, stack); err != nil {
+				return nil, err
+			}
+			// the children get another parent, which a rollback must
+			// revert
+			fb.invalidate()
+			for _, c := range fb.children {
+				if e, ok := c.(*Element); ok {
+					rescope(e, e.inheritedNamespaces(), elt.Parent)
+				}
+			}
+			return fb.children, nil
+		}
+	}
+	return nil, err
+}
+
+// xincludeError is an error that a fallback cannot repair.
+type xincludeError struct {
+	msg string
+}
+
+func (e *xincludeError) Error() string {
+	return "xinclude: " + e.msg
+}
+
+// resource reads the resource the include element elt refers to.
+func (xi *xincluder) resource(elt *Element, stack []inclusion) ([]XMLNode, error) {
+	var href, parse, xpointer, encoding string
+	parse = "xml"
+	for _, attr := range elt.attributes {
+		if attr.Namespace != "" {
+			continue
+		}
+		switch attr.Name {
+		case "href":
+			href = attr.Value
+		case "parse":
+			parse = attr.Value
+		case "xpointer":
+			xpointer = attr.Value
+		case "encoding":
+			encoding = attr.Value
+		}
+	}
+	if parse != "xml" && parse != "text" {
+		return nil, &xincludeError{"invalid value for parse: " + parse}
+	}
+	if href == "" && (parse == "text" || xpointer == "") {
+		return nil, &xincludeError{"missing href"}
+	}
+	if parse == "text" && xpointer != "" {
+		return nil, &xincludeError{"xpointer is not allowed with parse=\"text\""}
+	}
+	if len(stack) > xi.opts.MaxDepth {
+		return nil, &xincludeError{"inclusions nested too deeply"}
+	}
+	uri := stack[len(stack)-1].uri
+	if href != "" {
+		var err error
+		if uri, err = elt.ResolveReference(href); err != nil {
+			return nil, &xincludeError{err.Error()}
+		}
+	}
+	if parse == "text" {
+		return xi.text(uri, encoding)
+	}
+
+	cur := inclusion{uri: uri, xpointer: xpointer}
+	for _, s := range stack[1:] {
+		if s == cur || s.uri == uri && s.xpointer == "" {
+			return nil, &xincludeError{"inclusion loop at " + uri}
+		}
+	}
+	var src XMLNode
+	if href == "" {
+		src = xi.doc
+	} else {
+		r, err := xi.opts.Resolver.Open(uri)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		opts := append(append([]ParseOption{}, xi.opts.ParseOptions...), WithBaseURI(uri), WithSourceName(uri))
+		doc, err := Parse(r, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if err := xi.process(doc, append(stack, inclusion{uri: uri})); err != nil {
+			return nil, err
+		}
+		src = doc
+	}
+
+	var nodes []XMLNode
+	if xpointer == "" {
+		for _, c := range src.Children() {
+			// white space outside of the root element is not included
+			if _, ok := c.(CharData); !ok {
+				nodes = append(nodes, c)
+			}
+		}
+	} else {
+		target, err := evalXPointer(src, xpointer)
+		if err != nil {
+			return nil, err
+		}
+		if href == "" {
+			// the element is copied and may contain further includes
+			cp := copyElement(target)
+			if err := xi.process(cp, append(stack, cur)); err != nil {
+				return nil, err
+			}
+			rescope(cp, target.inheritedNamespaces(), elt.Parent)
+			return []XMLNode{cp}, nil
+		}
+		nodes = []XMLNode{target}
+	}
+	for _, n := range nodes {
+		if e, ok := n.(*Element); ok {
+			rescope(e, e.inheritedNamespaces(), elt.Parent)
+			e.SetAttribute(xmlBaseAttr(uri))
+			e.source = uri
+		}
+	}
+	return nodes, nil
+}
+
+// text reads the resource at uri as text.
+func (xi *xincluder) text(uri, encoding string) ([]XMLNode, error) {
+	switch strings.ToLower(encoding) {
+	case "", "utf-8", "us-ascii":
+	default:
+		return nil, &xincludeError{"unsupported encoding " + encoding}
+	}
+	r, err := xi.opts.Resolver.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("%s is not valid UTF-8", uri)
+	}
+	return []XMLNode{CharData{Contents: string(b)}}, nil
+}
+
+func isXInclude(elt *Element, local string) bool {
+	return elt.Name == local && elt.NamespaceURI() == XIncludeNamespace
+}
+
+// evalXPointer returns the element selected by a shorthand pointer or an
+// element() scheme pointer.
+func evalXPointer(src XMLNode, ptr string) (*Element, error) {
+	var id string
+	var steps []string
+	if strings.HasPrefix(ptr, "element(") && strings.HasSuffix(ptr, ")") {
+		steps = strings.Split(ptr[len("element("):len(ptr)-1], "/")
+		id = steps[0]
+		steps = steps[1:]
+	} else if strings.ContainsAny(ptr, "()/") {
+		return nil, fmt.Errorf("unsupported xpointer %s", ptr)
+	} else {
+		id = ptr
+	}
+	cur := src
+	if id != "" {
+		elt := findID(src, id)
+		if elt == nil {
+			return nil, fmt.Errorf("xpointer %s: no element with ID %s", ptr, id)
+		}
+		cur = elt
+	}
+	for _, step := range steps {
+		n, err := strconv.Atoi(step)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("xpointer %s: invalid step %s", ptr, step)
+		}
+		var next XMLNode
+		for _, c := range cur.Children() {
+			if elt, ok := c.(*Element); ok {
+				n--
+				if n == 0 {
+					next = elt
+					break
+				}
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("xpointer %s selects no element", ptr)
+		}
+		cur = next
+	}
+	elt, ok := cur.(*Element)
+	if !ok {
+		return nil, fmt.Errorf("xpointer %s selects no element", ptr)
+	}
+	return elt, nil
+}
+
+// findID returns the first element below n with an xml:id or id attribute
+// of value id.
+func findID(n XMLNode, id string) *Element {
+	for _, c := range n.Children() {
+		elt, ok := c.(*Element)
+		if !ok {
+			continue
+		}
+		for _, attr := range elt.attributes {
+			if attr.Value == id && attr.Name == "id" && (attr.Namespace == xmlNamespace || attr.Namespace == "") {
+				return elt
+			}
+		}
+		if found := findID(elt, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// copyElement returns a deep copy of elt without parent.
+func copyElement(elt *Element) *Element {
+	return snapshotNode(elt, nil).(*Element)
+}
+
+// rescope declares the namespaces elt needs to keep the meaning of its
+// prefixes when it is moved from a place where it inherited the bindings in
+// src to a child of dest.
+func rescope(elt *Element, src map[string]string, dest XMLNode) {
+	var destScope map[string]string
+	if d, ok := dest.(*Element); ok {
+		destScope = d.InScopeNamespaces()
+	}
+	for prefix, ns := range src {
+		if _, ok := elt.Namespaces[prefix]; !ok && destScope[prefix] != ns {
+			elt.DeclareNamespace(prefix, ns)
+		}
+	}
+	if _, ok := elt.Namespaces[""]; !ok && src[""] == "" && destScope[""] != "" {
+		elt.DeclareNamespace("", "")
+	}
+}
+
+func xmlBaseAttr(uri string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Space: xmlNamespace, Local: "base"}, Value: uri}
+}
@@ -0,0 +1,148 @@
+package goxml
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// mapResolver opens the resources from a map of URIs to contents.
+type mapResolver map[string]string
+
+func (m mapResolver) Open(uri string) (io.ReadCloser, error) {
+	s, ok := m[uri]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: uri, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(strings.NewReader(s)), nil
+}
+
+var xincludeFiles = mapResolver{
+	"/doc/chapter.xml":     `<chapter><xi:include xmlns:xi="http://www.w3.org/2001/XInclude" href="sub/section.xml"/></chapter>`,
+	"/doc/sub/section.xml": `<section>s</section>`,
+	"/doc/note.txt":        "1 < 2",
+	"/doc/ids.xml":         `<list><item xml:id="first">one</item><item id="second"><b>two</b></item></list>`,
+	"/doc/loop.xml":        `<loop><xi:include xmlns:xi="http://www.w3.org/2001/XInclude" href="loop.xml"/></loop>`,
+}
+
+// xinclude parses s with the base URI /doc/main.xml and processes the
+// includes with the files in xincludeFiles.
+func xinclude(t *testing.T, s string) (*XMLDocument, error) {
+	t.Helper()
+	doc, err := Parse(strings.NewReader(s), WithBaseURI("/doc/main.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc, doc.XInclude(XIncludeOptions{Resolver: xincludeFiles})
+}
+
+func TestXInclude(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"nested",
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="chapter.xml"/></r>`,
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><chapter xml:base="/doc/chapter.xml"><section xml:base="/doc/sub/section.xml">s</section></chapter></r>`,
+		},
+		{
+			"text",
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude">a <xi:include href="note.txt" parse="text"/> b</r>`,
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude">a 1 &lt; 2 b</r>`,
+		},
+		{
+			"fallback",
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="missing.xml"><xi:fallback><p>none</p></xi:fallback></xi:include></r>`,
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><p>none</p></r>`,
+		},
+		{
+			"include in fallback",
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="missing.xml"><xi:fallback><xi:include href="note.txt" parse="text"/></xi:fallback></xi:include></r>`,
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude">1 &lt; 2</r>`,
+		},
+		{
+			"shorthand xpointer",
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="ids.xml" xpointer="second"/></r>`,
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><item id="second" xml:base="/doc/ids.xml"><b>two</b></item></r>`,
+		},
+		{
+			"element scheme",
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="ids.xml" xpointer="element(/1/1)"/></r>`,
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><item xml:id="first" xml:base="/doc/ids.xml">one</item></r>`,
+		},
+		{
+			"same document",
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><a id="x">t</a><xi:include xpointer="x"/></r>`,
+			`<r xmlns:xi="http://www.w3.org/2001/XInclude"><a id="x">t</a><a id="x">t</a></r>`,
+		},
+	}
+	for _, tc := range tests {
+		doc, err := xinclude(t, tc.in)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := doc.ToXML(); got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+		checkIDs(t, doc)
+	}
+}
+
+func TestXIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name, in, msg string
+	}{
+		{"loop", `<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="loop.xml"/></r>`, "inclusion loop at /doc/loop.xml"},
+		{"same document loop", `<r xmlns:xi="http://www.w3.org/2001/XInclude" id="r"><xi:include xpointer="r"/></r>`, "inclusion loop"},
+		{"missing", `<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="missing.xml"/></r>`, "missing.xml"},
+		{"no element", `<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="ids.xml" xpointer="third"/></r>`, "no element with ID third"},
+		{"text with xpointer", `<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="note.txt" parse="text" xpointer="x"/></r>`, "xpointer is not allowed"},
+		// a fallback does not repair an invalid include element
+		{"invalid parse", `<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="note.txt" parse="html"><xi:fallback/></xi:include></r>`, "invalid value for parse"},
+	}
+	for _, tc := range tests {
+		_, err := xinclude(t, tc.in)
+		if err == nil || !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("%s: XInclude() = %v, want an error with %q", tc.name, err, tc.msg)
+		}
+	}
+	_, err := xinclude(t, `<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="missing.xml"/></r>`)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("XInclude() = %v, want the error of the resolver", err)
+	}
+}
+
+func TestXIncludeFailureKeepsDocument(t *testing.T) {
+	in := `<r xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="chapter.xml"/><a><xi:include href="missing.xml"><xi:fallback><p/></xi:fallback></xi:include></a><xi:include href="missing.xml"/></r>`
+	doc, err := Parse(strings.NewReader(in), WithBaseURI("/doc/main.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := doc.ToXML()
+	events := 0
+	doc.Observe(func(MutationEvent) { events++ })
+	if err := doc.XInclude(XIncludeOptions{Resolver: xincludeFiles}); err == nil {
+		t.Fatal("XInclude() succeeds with a missing resource")
+	}
+	if got := doc.ToXML(); got != before {
+		t.Errorf("failed XInclude changed the document to\n%s", got)
+	}
+	if events != 0 {
+		t.Errorf("failed XInclude reported %d changes", events)
+	}
+	checkIDs(t, doc)
+	// the include elements are in place again
+	r, _ := doc.Root()
+	for _, c := range r.children {
+		if elt, ok := c.(*Element); ok && elt.Parent != XMLNode(r) {
+			t.Errorf("<%s> has lost its parent", elt.Name)
+		}
+	}
+	p := r.children[1].(*Element).children[0].(*Element).children[0].(*Element).children[0].(*Element)
+	if p.Name != "p" || p.Parent.(*Element).Name != "fallback" {
+		t.Errorf("the fallback content has the parent %v", p.Parent)
+	}
+}
@@ -0,0 +1,94 @@
+package goxml
+
+import "encoding/xml"
+
+// XLinkNamespace is the namespace of the XLink attributes.
+const XLinkNamespace = "http://www.w3.org/1999/xlink"
+
+// XLink contains the XLink 1.1 attributes of an element, such as
+//
+//	<image xlink:type="simple" xlink:href="logo.svg" xlink:show="embed"/>
+//
+// Empty fields stand for missing attributes.
+type XLink struct {
+	// Type is simple, extended, locator, arc, resource, title or none.
+	Type string
+	Href string
+	// Role and Arcrole are URIs that describe the link.
+	Role    string
+	Arcrole string
+	Title   string
+	// Show is new, replace, embed, other or none.
+	Show string
+	// Actuate is onLoad, onRequest, other or none.
+	Actuate string
+	// Label, From and To connect the arcs of extended links.
+	Label string
+	From  string
+	To    string
+}
+
+// xlinkField is an XLink attribute with a pointer to its field.
+type xlinkField struct {
+	name  string
+	value *string
+}
+
+// fields returns the XLink attributes of l.
+func (l *XLink) fields() []xlinkField {
+	return []xlinkField{
+		{"type", &l.Type}, {"href", &l.Href}, {"role", &l.Role}, {"arcrole", &l.Arcrole},
+		{"title", &l.Title}, {"show", &l.Show}, {"actuate", &l.Actuate},
+		{"label", &l.Label}, {"from", &l.From}, {"to", &l.To},
+	}
+}
+
+// XLink returns the XLink attributes of the element. It returns false if the
+// element has none.
+func (elt *Element) XLink() (XLink, bool) {
+	var l XLink
+	found := false
+	fields := l.fields()
+	for _, attr := range elt.attributes {
+		if attr.Namespace != XLinkNamespace {
+			continue
+		}
+		for _, f := range fields {
+			if f.name == attr.Name {
+				*f.value = attr.Value
+				found = true
+			}
+		}
+	}
+	return l, found
+}
+
+// SetXLink sets the XLink attributes of the element to the non-empty fields
+// of l, other XLink attributes are kept. If no prefix is bound to
+// XLinkNamespace, the prefix xlink is declared on the element, or nsN if
+// xlink is bound to another namespace.
+func (elt *Element) SetXLink(l XLink) {
+	if _, ok := elt.LookupPrefix(XLinkNamespace); !ok {
+		if _, taken := elt.LookupNamespace("xlink"); !taken {
+			elt.DeclareNamespace("xlink", XLinkNamespace)
+		}
+	}
+	for _, f := range l.fields() {
+		if *f.value != "" {
+			elt.SetAttribute(xml.Attr{Name: xml.Name{Space: XLinkNamespace, Local: f.name}, Value: *f.value})
+		}
+	}
+}
+
+// SimpleLink returns a new element with the name and the contents like E,
+// which is a simple XLink to href:
+//
+//	goxml.SimpleLink("a", "https://example.com/", goxml.T("Example"))
+//
+// returns <a xmlns:xlink="http://www.w3.org/1999/xlink" xlink:type="simple"
+// xlink:href="https://example.com/">Example</a>.
+func SimpleLink(name, href string, contents ...XMLNode) *Element {
+	elt := E(name, contents...)
+	elt.SetXLink(XLink{Type: "simple", Href: href})
+	return elt
+}
@@ -1,36 +1,28 @@
 package goxml
 
 import (
+	"bufio"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
-var (
-	entitiesReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", "\"", "&quot;")
-	ids              chan int
-)
-
-func genIntegerSequence(ids chan int) {
-	i := int(0)
-	for {
-		ids <- i
-		i++
-	}
-}
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
 
-func init() {
-	ids = make(chan int)
-	go genIntegerSequence(ids)
-}
+// lastDocumentID is the number of the most recently created document.
+var lastDocumentID int64
 
-// XMLNode is one of Document, Element, CharData, ProcInst, Comment
+// XMLNode is one of Document, Element, CharData, EntityRef, ProcInst, Comment
 type XMLNode interface {
-	toxml(map[string]bool) string
+	serialize(*xmlWriter)
 	setParent(XMLNode)
-	getID() int
+	getID() int64
 	Children() []XMLNode
 }
 
@@ -61,17 +53,48 @@ type Appender interface {
 
 // Attribute represents an attribute
 type Attribute struct {
-	ID        int
+	ID        int64
 	Name      string
 	Namespace string
 	Prefix    string
 	Value     string
+	// Defaulted is set for attributes that are not in the document but
+	// added from a default value in the DTD.
+	Defaulted bool
+	// RawValue is the value as written in the source, without the quotes
+	// and with references not expanded. It is only set by Parse with
+	// WithNormalizeAttributes or WithRawAttributes.
+	RawValue string
+	// Quote is the quote character around the value in the source, ' or ".
+	// It is only set by Parse with WithRawAttributes.
+	Quote byte
+	// parsed is the value read by Parse, the raw form is only used as long
+	// as the value is unchanged
+	parsed string
 }
 
 func (a Attribute) String() string {
 	return fmt.Sprintf("%s=%q", a.Name, a.Value)
 }
 
+// TokenizedValue returns the value normalized like an attribute of a
+// tokenized type such as NMTOKENS or IDREFS: leading and trailing spaces are
+// removed and each run of spaces is replaced by a single space. goxml does not
+// read attribute types from the DTD, so the caller decides when this applies.
+func (a Attribute) TokenizedValue() string {
+	var sb strings.Builder
+	for _, tok := range strings.Split(a.Value, " ") {
+		if tok == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(tok)
+	}
+	return sb.String()
+}
+
 // Stringvalue returns the attribute value.
 func (a Attribute) Stringvalue() string {
 	return fmt.Sprintf("%s", a.Value)
@@ -87,31 +110,66 @@ func (a Attribute) setParent(n XMLNode) {
 }
 
 // getID returns the ID of this node
-func (a Attribute) getID() int {
+func (a Attribute) getID() int64 {
 	return a.ID
 }
 
-// toxml returns the XML representation of the attribute.
-func (a Attribute) toxml(namespacePrinted map[string]bool) string {
+// serialize writes the XML representation of the attribute.
+func (a Attribute) serialize(xw *xmlWriter) {
 	panic("nyi")
 }
 
 // Element represents an XML element
 type Element struct {
-	ID         int
-	Name       string
-	Prefix     string
-	Parent     XMLNode
+	ID     int64
+	Name   string
+	Prefix string
+	Parent XMLNode
+	// Namespaces contains the namespace declarations of this element, the
+	// keys are the prefixes. Use LookupNamespace and InScopeNamespaces to get
+	// the bindings inherited from the ancestors. The map is nil for parsed
+	// elements without namespace declarations.
 	Namespaces map[string]string
 	children   []XMLNode
-	attributes []xml.Attr
+	attributes []*Attribute
 	Line       int
 	Pos        int
+
+	// svCache is the cached string value, see Stringvalue
+	svCache          *stringvalueCache
+	serialized       string
+	serializedCached bool
+	serializedKey    int
+	// frozen is set by Freeze
+	frozen bool
+	// inArena is set for elements allocated from a block with WithArena,
+	// which Release does not put into the pool
+	inArena bool
+	// source is the name of the input the element comes from, if it
+	// differs from the one of its parent
+	source string
+	// leading contains the comments attached to the element and the white
+	// space after them, see LeadingComments
+	leading []XMLNode
+	// startTag is the layout of the start tag in the source, see
+	// WithTagLayout
+	startTag *tagLayout
+	// spanStart and spanEnd are the byte offsets of the element in the
+	// source, spanEnd is zero if they are not known
+	spanStart int64
+	spanEnd   int64
+	// modified marks changes of the element and modifiedBelow changes of
+	// the element or its descendants, see dirtyEpoch
+	modified      int64
+	modifiedBelow int64
+	// textTail holds the text of the last child when large text is
+	// appended piece by piece, see appendText
+	textTail *textBuffer
 }
 
 // NewElement returns an initialized Element.
 func NewElement() *Element {
-	elt := Element{}
+	elt := Element{svCache: &stringvalueCache{}}
 	elt.Namespaces = make(map[string]string)
 	return &elt
 }
@@ -124,45 +182,151 @@ func (elt Element) String() string {
 	return "<" + elt.Name + " " + strings.Join(as, " ") + ">"
 }
 
-// Stringvalue returns the text nodes of this elements and its children.
+// stringvalueCache holds the string value of an element. It is kept behind
+// a pointer, so that Stringvalue can fill it with a value receiver. mark is
+// the modifiedBelow mark of the element the value belongs to, copies of an
+// element value share the cache but not the mark once one of them changes.
+type stringvalueCache struct {
+	value  string
+	mark   int64
+	cached bool
+}
+
+// Stringvalue returns the text nodes of this elements and its children. The
+// result is cached until the element or one of its descendants changes, but
+// not for elements of a frozen document.
 func (elt Element) Stringvalue() string {
-	var as []string
+	if v, ok := elt.cachedStringvalue(); ok {
+		return v
+	}
+	var sb strings.Builder
+	elt.writeStringvalue(&sb)
+	if elt.frozen {
+		return sb.String()
+	}
+	if elt.svCache == nil {
+		// elt is a copy, the cache can only be set up by the methods that
+		// change the element
+		return sb.String()
+	}
+	*elt.svCache = stringvalueCache{value: sb.String(), mark: elt.modifiedBelow, cached: true}
+	return elt.svCache.value
+}
+
+// stringvalue returns the string value of the element like Stringvalue, but
+// does not fill the cache, so it can be used by concurrent readers.
+func (elt *Element) stringvalue() string {
+	if v, ok := elt.cachedStringvalue(); ok {
+		return v
+	}
+	var sb strings.Builder
+	elt.writeStringvalue(&sb)
+	return sb.String()
+}
+
+func (elt *Element) cachedStringvalue() (string, bool) {
+	if c := elt.svCache; c != nil && c.cached && c.mark == elt.modifiedBelow {
+		return c.value, true
+	}
+	return "", false
+}
+
+func (elt *Element) writeStringvalue(sb *strings.Builder) {
+	if v, ok := elt.cachedStringvalue(); ok {
+		sb.WriteString(v)
+		return
+	}
 	for _, cld := range elt.children {
 		switch t := cld.(type) {
 		case CharData:
-			as = append(as, string(t.Contents))
+			sb.WriteString(t.Contents)
+		case EntityRef:
+			sb.WriteString(t.Value)
 		case *Element:
-			as = append(as, t.Stringvalue())
+			t.writeStringvalue(sb)
 		}
 	}
-	return strings.Join(as, "")
 }
 
-// Append appends an XML node to the element.
+// invalidate drops the cached string value and serialization of the element
+// and all of its ancestors. It returns the document of the element, nil if it
+// is not part of a document.
+func (elt *Element) invalidate() *XMLDocument {
+	elt.checkMutable()
+	mark := atomic.LoadInt64(&dirtyEpoch) + 1
+	elt.modified = mark
+	cur := elt
+	for {
+		cur.modifiedBelow = mark
+		if cur.svCache == nil {
+			cur.svCache = &stringvalueCache{}
+		} else {
+			*cur.svCache = stringvalueCache{}
+		}
+		cur.serializedCached = false
+		cur.serialized = ""
+		cur.spanEnd = 0
+		switch p := cur.Parent.(type) {
+		case *Element:
+			cur = p
+		case *XMLDocument:
+			if p.history != nil || p.tx != nil {
+				p.recordElement(elt)
+			}
+			return p
+		default:
+			return nil
+		}
+	}
+}
+
+// Append appends an XML node to the element. Text is merged with a text node
+// at the end of the children. If the element belongs to a document, the
+// appended node gets new IDs like with InsertChild.
 func (elt *Element) Append(n XMLNode) {
+	elt.appendNode(n, true)
+}
+
+// appendNode appends n and gives it new IDs if number is set. The parser
+// numbers the nodes itself.
+func (elt *Element) appendNode(n XMLNode, number bool) {
+	doc := elt.invalidate()
 	switch t := n.(type) {
 	case Attribute:
-		for i, attr := range elt.attributes {
-			if attr.Name.Local == t.Name && attr.Name.Space == t.Namespace {
-				elt.attributes[i].Value = t.Value
+		if t.Namespace != "" && t.Prefix == "" {
+			t.Prefix = elt.attributePrefix(t.Namespace)
+		}
+		ev := MutationEvent{Type: AttributeChanged, Target: elt, Name: xml.Name{Space: t.Namespace, Local: t.Name}, NewValue: t.Value}
+		for _, attr := range elt.attributes {
+			if attr.Name == t.Name && attr.Namespace == t.Namespace {
+				ev.OldValue = attr.Value
+				attr.Value = t.Value
+				doc.notify(ev)
 				return
 			}
 		}
-		elt.attributes = append(elt.attributes, xml.Attr{
-			Name:  xml.Name{Local: t.Name, Space: t.Namespace},
-			Value: t.Value,
-		})
+		elt.attributes = append(elt.attributes, &t)
+		doc.notify(ev)
 		return
 	case CharData:
 		// combine string cdata string if necessary
 		if l := len(elt.children); l > 0 {
-			if str, ok := elt.children[l-1].(CharData); ok {
-				elt.children[l-1] = CharData{Contents: str.Contents + t.Contents}
+			if str, ok := elt.children[l-1].(CharData); ok && str.CDATA == t.CDATA {
+				merged := CharData{ID: str.ID, Contents: elt.appendText(str.Contents, t.Contents), CDATA: str.CDATA}
+				elt.children[l-1] = merged
+				doc.notify(MutationEvent{Type: TextChanged, Target: elt, Node: merged, Index: l - 1, OldValue: str.Contents, NewValue: merged.Contents})
 				return
 			}
 		}
 	}
 	elt.children = append(elt.children, n)
+	n.setParent(elt)
+	index := len(elt.children) - 1
+	if number && doc != nil {
+		doc.renumberFrom(elt, index)
+		n = elt.children[index]
+	}
+	doc.notify(MutationEvent{Type: NodeAppended, Target: elt, Node: n, Index: index})
 }
 
 // Children returns all child nodes from elt
@@ -171,90 +335,489 @@ func (elt Element) Children() []XMLNode {
 }
 
 // SetAttribute appends attr to the list of attributes of elt. If an attribute
-// of this name already exists, the existing one will be discarded.
+// of this name already exists, the existing one will be discarded. The space
+// of the name is the namespace URI, an empty space means no namespace, also if
+// a default namespace is in scope. For an attribute in a namespace, a prefix
+// bound to the namespace is used or a new one is declared on the element.
 func (elt *Element) SetAttribute(attr xml.Attr) {
-	var newAttributes = make([]xml.Attr, 0, len(elt.attributes)+1)
+	doc := elt.invalidate()
+	ev := MutationEvent{Type: AttributeChanged, Target: elt, Name: attr.Name, NewValue: attr.Value}
+	var newAttributes = make([]*Attribute, 0, len(elt.attributes)+1)
 	for _, curattr := range elt.attributes {
-		if curattr.Name != attr.Name {
+		if curattr.Name != attr.Name.Local || curattr.Namespace != attr.Name.Space {
 			newAttributes = append(newAttributes, curattr)
+		} else {
+			ev.OldValue = curattr.Value
 		}
 	}
-	newAttributes = append(newAttributes, attr)
+	newattr := &Attribute{
+		Name:      attr.Name.Local,
+		Namespace: attr.Name.Space,
+		Value:     attr.Value,
+	}
+	if attr.Name.Space != "" {
+		newattr.Prefix = elt.attributePrefix(attr.Name.Space)
+	}
+	newAttributes = append(newAttributes, newattr)
 	elt.attributes = newAttributes
+	doc.notify(ev)
 }
 
-// Attributes returns all attributes for this element
+// attributePrefix returns the prefix for an attribute in the namespace ns. If
+// no prefix is bound to ns, a new one is declared on the element.
+func (elt *Element) attributePrefix(ns string) string {
+	if ns == xmlNamespace {
+		return "xml"
+	}
+	// unprefixed attributes are in no namespace, so the default namespace
+	// does not help
+	for cur := elt; cur != nil; cur, _ = cur.Parent.(*Element) {
+		for prefix, uri := range cur.Namespaces {
+			if uri == ns && prefix != "" {
+				if inscope, _ := elt.LookupNamespace(prefix); inscope == ns {
+					return prefix
+				}
+			}
+		}
+	}
+	for i := 1; ; i++ {
+		prefix := "ns" + strconv.Itoa(i)
+		if _, ok := elt.LookupNamespace(prefix); !ok {
+			elt.DeclareNamespace(prefix, ns)
+			return prefix
+		}
+	}
+}
+
+// Attributes returns all attributes for this element. The returned slice
+// and the attributes are shared with the element and must not be modified,
+// use SetAttribute instead.
 func (elt Element) Attributes() []*Attribute {
-	var attribs []*Attribute
-	for _, xmlattr := range elt.attributes {
-		attr := Attribute{}
-		attr.Name = xmlattr.Name.Local
-		attr.Value = xmlattr.Value
-		attr.Namespace = xmlattr.Name.Space
-		attribs = append(attribs, &attr)
+	return elt.attributes
+}
+
+// DeclareNamespace adds a namespace declaration for prefix to the element.
+// The empty prefix declares the default namespace. An empty uri undeclares
+// the prefix in the scope of the element, so DeclareNamespace("", "") puts the
+// unprefixed elements in no namespace like xmlns="" does.
+func (elt *Element) DeclareNamespace(prefix, uri string) {
+	elt.invalidate()
+	if elt.Namespaces == nil {
+		elt.Namespaces = make(map[string]string)
 	}
-	return attribs
+	elt.Namespaces[prefix] = uri
+}
+
+// LookupNamespace returns the namespace URI bound to prefix in the scope of
+// the element. It returns false if the prefix is not bound or has been
+// undeclared.
+func (elt *Element) LookupNamespace(prefix string) (string, bool) {
+	for cur := elt; cur != nil; cur, _ = cur.Parent.(*Element) {
+		if ns, ok := cur.Namespaces[prefix]; ok {
+			return ns, ns != ""
+		}
+	}
+	return "", false
+}
+
+// LookupPrefix returns the prefix bound to the namespace URI ns in the scope
+// of the element. If several prefixes are bound to ns, the one declared
+// closest to the element is returned.
+func (elt *Element) LookupPrefix(ns string) (string, bool) {
+	for cur := elt; cur != nil; cur, _ = cur.Parent.(*Element) {
+		for prefix, uri := range cur.Namespaces {
+			if uri != ns || uri == "" {
+				continue
+			}
+			// the prefix could be rebound further down
+			if inscope, _ := elt.LookupNamespace(prefix); inscope == ns {
+				return prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// InScopeNamespaces returns all namespace bindings in the scope of the
+// element, the keys are the prefixes. Undeclared prefixes are not included.
+func (elt *Element) InScopeNamespaces() map[string]string {
+	namespaces := make(map[string]string)
+	for cur := elt; cur != nil; cur, _ = cur.Parent.(*Element) {
+		for prefix, ns := range cur.Namespaces {
+			if _, ok := namespaces[prefix]; !ok {
+				namespaces[prefix] = ns
+			}
+		}
+	}
+	for prefix, ns := range namespaces {
+		if ns == "" {
+			delete(namespaces, prefix)
+		}
+	}
+	return namespaces
+}
+
+// inheritedNamespaces returns the namespace bindings the element inherits
+// from its ancestors.
+func (elt *Element) inheritedNamespaces() map[string]string {
+	if parent, ok := elt.Parent.(*Element); ok {
+		return parent.InScopeNamespaces()
+	}
+	return nil
+}
+
+// NamespaceURI returns the namespace of the element, which is the namespace
+// bound to its prefix. Unprefixed elements are in the default namespace in
+// scope, if any. The result is empty for elements in no namespace and for
+// elements with an unbound prefix.
+func (elt *Element) NamespaceURI() string {
+	ns, _ := elt.LookupNamespace(elt.Prefix)
+	return ns
+}
+
+// qualifiedName returns the name of the element including the prefix.
+func (elt *Element) qualifiedName() string {
+	return qualifiedName(elt.Prefix, elt.Name)
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix != "" {
+		return prefix + ":" + local
+	}
+	return local
+}
+
+// hasName reports whether the element has the name as read from the input
+// with the prefix in Space.
+func (elt *Element) hasName(name xml.Name) bool {
+	return elt.Name == name.Local && elt.Prefix == name.Space
 }
 
 func (elt *Element) setParent(n XMLNode) {
+	elt.checkMutable()
 	elt.Parent = n
 }
 
 // getID returns the ID of this node
-func (elt Element) getID() int {
+func (elt Element) getID() int64 {
 	return elt.ID
 }
 
-// ToXML returns a valid XML document
+// ToXML returns a valid XML document. Characters not allowed in XML are
+// replaced by U+FFFD.
 func (elt Element) ToXML() string {
-	return elt.toxml(make(map[string]bool))
+	var sb strings.Builder
+	sb.Grow(elt.estimateSize())
+	xw := newXMLWriter(&sb)
+	xw.inherited = elt.inheritedNamespaces()
+	xw.illegalChars = CharReplace
+	// elt is a copy, so only the descendants can use the cache
+	elt.serializeUncached(xw)
+	return sb.String()
 }
 
-func (elt Element) toxml(namespacePrinted map[string]bool) string {
-	var sb strings.Builder
-	sb.WriteRune('<')
-	eltname := elt.Name
-	if elt.Prefix != "" {
-		eltname = elt.Prefix + ":" + eltname
+// WriteTo writes the XML representation of the element to w. It implements
+// io.WriterTo, so the output can be passed through a transformation such as
+// transform.NewWriter from golang.org/x/text in a single streaming pass. The
+// output is the same as that of ToXML, characters not allowed in XML are
+// replaced by U+FFFD.
+func (elt Element) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	xw := newXMLWriter(bw)
+	xw.inherited = elt.inheritedNamespaces()
+	xw.illegalChars = CharReplace
+	elt.serializeUncached(xw)
+	return xw.flush(bw)
+}
+
+// WriteXML writes the XML representation of the element to w.
+func (elt Element) WriteXML(w io.Writer, opts SerializeOptions) error {
+	start := time.Now()
+	bw := bufio.NewWriter(w)
+	xw := newXMLWriter(bw)
+	xw.inherited = elt.inheritedNamespaces()
+	xw.apply(opts)
+	if opts.XMLDeclaration {
+		xw.writeString(xmlDeclaration)
+	}
+	if opts.Parallel > 1 && opts.Indent == "" {
+		xw.serializeParallel(&elt, opts.Parallel)
+	} else {
+		elt.serializeUncached(xw)
+	}
+	n, err := xw.flush(bw)
+	if opts.Stats != nil {
+		*opts.Stats = CollectStats(&elt)
+		opts.Stats.Bytes = n
+		opts.Stats.Duration = time.Since(start)
+	}
+	return err
+}
+
+func (elt *Element) serialize(xw *xmlWriter) {
+	if !xw.cache || xw.inherited != nil || xw.indent != "" || elt.frozen && (!elt.serializedCached || elt.serializedKey != xw.cacheKey()) {
+		elt.serializeUncached(xw)
+		return
+	}
+	if !elt.serializedCached || elt.serializedKey != xw.cacheKey() {
+		var sb strings.Builder
+		sb.Grow(elt.estimateSize())
+		sub := xw.sub(&sb)
+		elt.serializeUncached(sub)
+		if sub.err != nil {
+			xw.err = sub.err
+			return
+		}
+		elt.serialized = sb.String()
+		elt.serializedCached = true
+		elt.serializedKey = xw.cacheKey()
+	}
+	xw.writeString(elt.serialized)
+}
+
+func (elt *Element) serializeUncached(xw *xmlWriter) {
+	if xw.err != nil {
+		// nothing more is written, so the subtree need not be visited
+		return
+	}
+	elt.writeStartTag(xw)
+	if !elt.closeStartTag(xw) {
+		return
+	}
+	elt.writeChildren(xw)
+	elt.writeEndTag(xw)
+}
+
+// writeChildren writes the children of the element. When indenting, the
+// children of element-only content are written on lines of their own and the
+// white space between them is dropped.
+func (elt *Element) writeChildren(xw *xmlWriter) {
+	if xw.indent == "" {
+		for _, child := range elt.children {
+			serializeChild(xw, child)
+		}
+		return
+	}
+	keepSpace := xw.keepSpace
+	defer func() { xw.keepSpace = keepSpace }()
+	if xw.keepSpace || elt.spacePreserved() || !elt.elementOnly() {
+		// white space added to the descendants would change the text
+		xw.keepSpace = true
+		for _, child := range elt.children {
+			serializeChild(xw, child)
+		}
+		return
+	}
+	xw.depth++
+	for _, child := range elt.children {
+		if _, ok := child.(CharData); ok {
+			continue
+		}
+		xw.newline()
+		serializeChild(xw, child)
 	}
-	sb.WriteString(eltname)
+	xw.depth--
+	xw.newline()
+}
 
-	for prefix, ns := range elt.Namespaces {
-		if _, ok := namespacePrinted[ns]; !ok {
-			namespacePrinted[ns] = true
-			if prefix == "" {
-				prefix = "xmlns"
-			} else {
-				prefix = "xmlns:" + prefix
+// elementOnly reports whether the element has children other than text and
+// all of its text is white space.
+func (elt *Element) elementOnly() bool {
+	markup := false
+	for _, child := range elt.children {
+		switch t := child.(type) {
+		case CharData:
+			if !isSpace(t.Contents) {
+				return false
 			}
-			fmt.Fprintf(&sb, " %s=\"%s\"", prefix, ns)
+		case EntityRef:
+			return false
+		default:
+			markup = true
+		}
+	}
+	return markup
+}
+
+// spacePreserved reports whether the element has xml:space="preserve".
+func (elt *Element) spacePreserved() bool {
+	for _, attr := range elt.attributes {
+		if attr.Name == "space" && attr.Namespace == xmlNamespace {
+			return attr.Value == "preserve"
 		}
 	}
+	return false
+}
+
+// isSpace reports whether s consists of XML white space only.
+func isSpace(s string) bool {
+	return strings.Trim(s, " \t\r\n") == ""
+}
+
+// writeStartTag writes the start tag of the element without the closing
+// angle bracket.
+func (elt Element) writeStartTag(xw *xmlWriter) {
+	xw.writeString("<")
+	elt.writeName(xw)
+
+	sep := " "
+	if elt.wrapStartTag(xw) {
+		if xw.attributeIndent != "" {
+			sep = "\n" + strings.Repeat(xw.indent, xw.depth) + xw.attributeIndent
+		} else {
+			sep = "\n" + strings.Repeat(xw.indent, xw.depth+1)
+		}
+	}
+	var written map[string]bool
+	if l := elt.layout(xw); l != nil {
+		written = elt.writeLayoutAttributes(xw, l)
+	}
+	for _, prefix := range sortedPrefixes(elt.Namespaces) {
+		if written[namespaceAttributeName(prefix)] {
+			continue
+		}
+		xw.writeString(sep)
+		xw.writeNamespace(prefix, elt.Namespaces[prefix])
+	}
+	// the first element of a serialized subtree declares the bindings of
+	// its ancestors
+	for _, prefix := range sortedPrefixes(xw.inherited) {
+		if _, ok := elt.Namespaces[prefix]; !ok {
+			xw.writeString(sep)
+			xw.writeNamespace(prefix, xw.inherited[prefix])
+		}
+	}
+	xw.inherited = nil
 
 	for _, att := range elt.attributes {
-		fmt.Fprintf(&sb, " %s=\"%s\"", att.Name.Local, escape(att.Value))
+		if att.Defaulted && xw.skipDefaulted || written[qualifiedName(att.Prefix, att.Name)] {
+			continue
+		}
+		xw.writeString(sep)
+		if att.Prefix != "" {
+			xw.writeString(att.Prefix, ":")
+		}
+		xw.writeString(att.Name, "=")
+		xw.writeQuotedValue(att)
 	}
-	if len(elt.children) == 0 {
-		sb.WriteString(" />")
-		return sb.String()
+}
+
+// sortedPrefixes returns the prefixes of the namespace bindings in sorted
+// order, so that the declarations are always written in the same order.
+func sortedPrefixes(namespaces map[string]string) []string {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// wrapStartTag reports whether the start tag is written with one attribute
+// per line, see SerializeOptions.WrapAttributes and MaxLineLength.
+func (elt Element) wrapStartTag(xw *xmlWriter) bool {
+	if xw.indent == "" {
+		return false
+	}
+	count := elt.attributeCount(xw)
+	if xw.wrapAttributes > 0 && count > xw.wrapAttributes {
+		return true
+	}
+	if xw.maxLineLength <= 0 || count == 0 {
+		return false
+	}
+	var sb strings.Builder
+	sub := xw.sub(&sb)
+	sub.inherited = xw.inherited
+	sub.wrapAttributes = 0
+	sub.maxLineLength = 0
+	elt.writeStartTag(sub)
+	end := ">"
+	if len(elt.children) == 0 && !xw.expandEmpty {
+		end = " />"
+	}
+	length := utf8.RuneCountInString(xw.indent)*xw.depth + utf8.RuneCountInString(sb.String()) + len(end)
+	return length > xw.maxLineLength
+}
+
+// writeQuotedValue writes the value of the attribute with its quotes.
+func (xw *xmlWriter) writeQuotedValue(att *Attribute) {
+	if xw.rawAttributes && att.Quote != 0 && att.Value == att.parsed {
+		q := string(att.Quote)
+		xw.writeString(q, att.RawValue, q)
+		return
+	}
+	xw.writeString("\"")
+	xw.writeAttributeValue(att.Value)
+	xw.writeString("\"")
+}
+
+// attributeCount returns the number of attributes and namespace
+// declarations writeStartTag writes.
+func (elt Element) attributeCount(xw *xmlWriter) int {
+	count := len(elt.Namespaces)
+	for prefix := range xw.inherited {
+		if _, ok := elt.Namespaces[prefix]; !ok {
+			count++
+		}
+	}
+	for _, att := range elt.attributes {
+		if !att.Defaulted || !xw.skipDefaulted {
+			count++
+		}
+	}
+	return count
+}
+
+func (elt Element) writeEndTag(xw *xmlWriter) {
+	xw.writeString("</")
+	elt.writeName(xw)
+	xw.writeString(">")
+}
+
+func (elt Element) writeName(xw *xmlWriter) {
+	if elt.Prefix != "" {
+		xw.writeString(elt.Prefix, ":")
+	}
+	xw.writeString(elt.Name)
+}
+
+// estimateSize returns the approximate length of the serialized element.
+func (elt Element) estimateSize() int {
+	namelen := len(elt.Name)
+	if elt.Prefix != "" {
+		namelen += len(elt.Prefix) + 1
+	}
+	size := 2*namelen + 5
+	for _, att := range elt.attributes {
+		size += len(att.Prefix) + len(att.Name) + len(att.Value) + 5
 	}
-	sb.WriteString(">")
 	for _, child := range elt.children {
-		sb.WriteString(child.toxml(namespacePrinted))
+		size += estimateSize(child)
 	}
-	fmt.Fprintf(&sb, "</%s>", eltname)
-	return sb.String()
+	return size
 }
 
 // CharData is a string
 type CharData struct {
-	ID       int
+	ID       int64
 	Contents string
+	// CDATA writes the text as a CDATA section, unless it contains ]]> or
+	// a carriage return. WithCDATA sets it for the CDATA sections of a
+	// parsed document.
+	CDATA bool
 }
 
-// toxml returns the XML representation of the string.
-func (cd CharData) toxml(namespacePrinted map[string]bool) string {
-	return escape(string(cd.Contents))
+// serialize writes the XML representation of the string.
+func (cd CharData) serialize(xw *xmlWriter) {
+	if cd.CDATA {
+		xw.writeCDATA(cd.Contents)
+		return
+	}
+	xw.writeCharData(cd.Contents)
 }
 
 func (cd CharData) setParent(n XMLNode) {
@@ -267,19 +830,52 @@ func (cd CharData) Children() []XMLNode {
 }
 
 // getID returns the ID of this node
-func (cd CharData) getID() int {
+func (cd CharData) getID() int64 {
 	return cd.ID
 }
 
+// EntityRef is a character or entity reference in the text of an element,
+// which is kept unexpanded with WithEntityRefs.
+type EntityRef struct {
+	ID int64
+	// Name is the name of the entity, such as copy, or the character
+	// reference such as #8212 or #x2014.
+	Name string
+	// Value is the referenced character of a character reference. It is
+	// empty for entity references, whose replacement text is not known.
+	Value string
+}
+
+// serialize writes the reference as it has been written in the source.
+func (er EntityRef) serialize(xw *xmlWriter) {
+	xw.writeString("&", er.Name, ";")
+}
+
+func (er EntityRef) setParent(n XMLNode) {
+	// dummy
+}
+
+// Children is a dummy function
+func (er EntityRef) Children() []XMLNode {
+	return nil
+}
+
+// getID returns the ID of this node
+func (er EntityRef) getID() int64 {
+	return er.ID
+}
+
 // Comment is a string
 type Comment struct {
-	ID       int
+	ID       int64
 	Contents string
 }
 
-// toxml returns the XML representation of the comment.
-func (cmt Comment) toxml(namespacePrinted map[string]bool) string {
-	return fmt.Sprintf("<!--%s-->", cmt.Contents)
+// serialize writes the XML representation of the comment.
+func (cmt Comment) serialize(xw *xmlWriter) {
+	xw.writeString("<!--")
+	xw.writeText(cmt.Contents, escapeNone)
+	xw.writeString("-->")
 }
 
 func (cmt Comment) setParent(n XMLNode) {
@@ -292,20 +888,22 @@ func (cmt Comment) Children() []XMLNode {
 }
 
 // getID returns the ID of this node
-func (cmt Comment) getID() int {
+func (cmt Comment) getID() int64 {
 	return cmt.ID
 }
 
 // ProcInst is a string
 type ProcInst struct {
-	ID     int
+	ID     int64
 	Target string
 	Inst   []byte
 }
 
-// toxml returns the XML representation of the string.
-func (pi ProcInst) toxml(namespacePrinted map[string]bool) string {
-	return fmt.Sprintf("<?%s %s?>", pi.Target, string(pi.Inst))
+// serialize writes the XML representation of the processing instruction.
+func (pi ProcInst) serialize(xw *xmlWriter) {
+	xw.writeString("<?", pi.Target, " ")
+	xw.writeText(string(pi.Inst), escapeNone)
+	xw.writeString("?>")
 }
 
 func (pi ProcInst) setParent(n XMLNode) {
@@ -318,24 +916,110 @@ func (pi ProcInst) Children() []XMLNode {
 }
 
 // getID returns the ID of this node
-func (pi ProcInst) getID() int {
+func (pi ProcInst) getID() int64 {
 	return pi.ID
 }
 
 // XMLDocument represents an XML file for decoding
 type XMLDocument struct {
-	ID       int
-	children []XMLNode
+	ID        int64
+	children  []XMLNode
+	stats     Stats
+	errors    []*ParseError
+	lastID    int64
+	baseURI   string
+	source    string
+	observers []*observer
+	frozen    bool
+	// sealed is set by Seal
+	sealed bool
+	// userData is set with SetUserData, by node ID
+	userData map[int64]map[string]any
+	// modified is the change mark of the document node, cleanEpoch the
+	// epoch of the last ClearDirty call, see dirtyEpoch
+	modified   int64
+	cleanEpoch int64
+	// history records the changes for Undo, see EnableUndo, and tx those of
+	// the innermost open transaction. restoring is set while changes are
+	// reverted, these are not recorded.
+	history   *history
+	tx        *Transaction
+	restoring bool
+	// doctype is the DOCTYPE directive of a parsed document, see DTD, and
+	// doctypeIndex the number of children before it
+	doctype      string
+	doctypeIndex int
+	// versions are the versions stored by Commit
+	versions []*Version
+}
+
+const (
+	// maxDocumentNumber is the highest document number. The number is the
+	// upper half of the IDs, which must stay positive.
+	maxDocumentNumber = 1<<31 - 1
+	// maxNodeNumber is the highest number of a node within its document, the
+	// lower half of its ID.
+	maxNodeNumber = 1<<32 - 1
+)
+
+// errIDsExhausted is the panic value of NextID for a document that has used
+// up its IDs.
+const errIDsExhausted = "goxml: no more node IDs in the document"
+
+// NewDocument returns an empty document with a unique ID. The documents are
+// numbered from 1 to 2^31-1, then the numbering starts again at 1. So the
+// IDs of documents that are created 2^31-1 documents apart can be the same,
+// and the nodes of such documents must not be sorted together.
+func NewDocument() *XMLDocument {
+	n := (atomic.AddInt64(&lastDocumentID, 1)-1)%maxDocumentNumber + 1
+	return &XMLDocument{ID: n << 32}
+}
+
+// NextID returns a new ID for a node of the document. The IDs of a document
+// are strictly increasing, so nodes numbered in document order can be sorted
+// with SortByDocumentOrder. The upper 32 bits of an ID are taken from the
+// document ID, so nodes of different documents never share an ID and sort
+// by the creation order of their documents. A document has 2^32-1 IDs, the
+// methods that number all nodes anew start again with the lowest one. NextID
+// panics if the IDs are used up.
+func (xr *XMLDocument) NextID() int64 {
+	if xr.lastID >= maxNodeNumber {
+		panic(errIDsExhausted)
+	}
+	xr.lastID++
+	return xr.ID + xr.lastID
 }
 
 func (xr XMLDocument) String() string {
 	return "<xmldoc>"
 }
 
-// Append appends an XML node to the document.
+// Append appends an XML node to the document. The node gets new IDs like
+// with InsertChild.
 func (xr *XMLDocument) Append(n XMLNode) {
+	xr.appendNode(n, true)
+}
+
+// appendNode appends n and gives it new IDs if number is set.
+func (xr *XMLDocument) appendNode(n XMLNode, number bool) {
+	xr.changeChildren()
 	xr.children = append(xr.children, n)
 	n.setParent(xr)
+	index := len(xr.children) - 1
+	if number {
+		xr.renumberFrom(xr, index)
+		n = xr.children[index]
+	}
+	xr.notify(MutationEvent{Type: NodeAppended, Target: xr, Node: n, Index: index})
+}
+
+// changeChildren prepares a change of the children of the document.
+func (xr *XMLDocument) changeChildren() {
+	xr.checkMutable()
+	if xr.history != nil || xr.tx != nil {
+		xr.recordDocument()
+	}
+	xr.modified = atomic.LoadInt64(&dirtyEpoch) + 1
 }
 
 // Children returns all child nodes from elt
@@ -353,9 +1037,25 @@ func (xr *XMLDocument) Root() (*Element, error) {
 	return nil, fmt.Errorf("cannot find root element")
 }
 
-// ToXML returns a valid XML document
+// ToXML returns a valid XML document. Characters not allowed in XML are
+// replaced by U+FFFD.
 func (xr *XMLDocument) ToXML() string {
-	return xr.toxml(make(map[string]bool))
+	var sb strings.Builder
+	sb.Grow(estimateSize(xr))
+	xw := newXMLWriter(&sb)
+	xw.illegalChars = CharReplace
+	xr.serialize(xw)
+	return sb.String()
+}
+
+// WriteTo writes the XML representation of the document to w. It implements
+// io.WriterTo, see Element.WriteTo.
+func (xr *XMLDocument) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	xw := newXMLWriter(bw)
+	xw.illegalChars = CharReplace
+	xr.serialize(xw)
+	return xw.flush(bw)
 }
 
 func (xr *XMLDocument) setParent(n XMLNode) {
@@ -363,98 +1063,98 @@ func (xr *XMLDocument) setParent(n XMLNode) {
 }
 
 // getID returns the ID of this node
-func (xr XMLDocument) getID() int {
+func (xr XMLDocument) getID() int64 {
 	return xr.ID
 }
 
-// toxml returns the XML representation of the document.
-func (xr *XMLDocument) toxml(namespacePrinted map[string]bool) string {
-	var sb strings.Builder
-	for _, v := range xr.children {
-		sb.WriteString(v.toxml(namespacePrinted))
+// WriteXML writes the XML representation of the document to w.
+func (xr *XMLDocument) WriteXML(w io.Writer, opts SerializeOptions) error {
+	start := time.Now()
+	bw := bufio.NewWriter(w)
+	xw := newXMLWriter(bw)
+	xw.apply(opts)
+	if opts.XMLDeclaration && !xr.hasDeclaration() {
+		xw.writeString(xmlDeclaration)
 	}
-	return sb.String()
+	xr.writeChildren(xw, opts.Parallel)
+	n, err := xw.flush(bw)
+	if opts.Stats != nil {
+		*opts.Stats = CollectStats(xr)
+		opts.Stats.Bytes = n
+		opts.Stats.Duration = time.Since(start)
+	}
+	return err
 }
 
-// Parse reads the XML file from r. r is not closed.
-func Parse(r io.Reader) (*XMLDocument, error) {
-	var err error
-	var tok xml.Token
+// xmlDeclaration is written before the output with XMLDeclaration.
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
 
-	var cur XMLNode
-	doc := &XMLDocument{ID: <-ids}
-	eltstack := []XMLNode{doc}
-	cur = doc
-	dec := xml.NewDecoder(r)
-
-	for {
-		tok, err = dec.Token()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		switch v := tok.(type) {
-		case xml.StartElement:
-			tmp := NewElement()
-			tmp.ID = <-ids
-			if c, ok := cur.(*Element); ok {
-				for k, v := range c.Namespaces {
-					tmp.Namespaces[k] = v
-				}
-			}
-			tmp.Line, tmp.Pos = dec.InputPos()
-			tmp.Name = v.Name.Local
-			tmp.Parent = cur
-
-			for _, att := range v.Attr {
-				if att.Name.Local == "xmlns" {
-					tmp.Namespaces[""] = att.Value
-				} else if att.Name.Space == "xmlns" {
-					tmp.Namespaces[att.Name.Local] = att.Value
-				} else {
-					tmp.attributes = append(tmp.attributes, att)
-				}
-			}
+// hasDeclaration reports whether the first child of the document is an XML
+// declaration.
+func (xr *XMLDocument) hasDeclaration() bool {
+	if len(xr.children) == 0 {
+		return false
+	}
+	pi, ok := xr.children[0].(ProcInst)
+	return ok && pi.Target == "xml"
+}
 
-			for prefix, ns := range tmp.Namespaces {
-				if v.Name.Space == ns {
-					tmp.Prefix = prefix
-				}
-			}
+// serialize writes the XML representation of the document.
+func (xr *XMLDocument) serialize(xw *xmlWriter) {
+	xr.writeChildren(xw, 0)
+}
 
-			if c, ok := cur.(Appender); ok {
-				c.Append(tmp)
-			}
-			cur = tmp
-			eltstack = append(eltstack, cur)
-		case xml.CharData:
-			cd := CharData{ID: <-ids, Contents: string(v)}
-			if c, ok := cur.(Appender); ok {
-				c.Append(cd)
-			}
-		case xml.ProcInst:
-			pi := ProcInst{ID: <-ids}
-			pi.Target = v.Copy().Target
-			pi.Inst = v.Copy().Inst
-			if c, ok := cur.(Appender); ok {
-				c.Append(pi)
-			}
-		case xml.Comment:
-			cmt := Comment{ID: <-ids, Contents: string(v)}
-			if c, ok := cur.(Appender); ok {
-				c.Append(cmt)
+// writeChildren writes the children of the document, the root element with
+// up to parallel goroutines. When indenting, each child is written on a line
+// of its own. The DOCTYPE declaration is written at its place in the source,
+// but always before the root element.
+func (xr *XMLDocument) writeChildren(xw *xmlWriter, parallel int) {
+	doctype := xr.doctype != ""
+	for i, v := range xr.children {
+		if _, ok := v.(*Element); doctype && (ok || i == xr.doctypeIndex) {
+			xr.writeDoctype(xw)
+			doctype = false
+		}
+		if xw.indent != "" {
+			if _, ok := v.(CharData); ok {
+				continue
 			}
-		case xml.EndElement:
-			cur, eltstack = eltstack[len(eltstack)-2], eltstack[:len(eltstack)-1]
 		}
+		if elt, ok := v.(*Element); ok {
+			elt.writeLeading(xw)
+		}
+		if elt, ok := v.(*Element); ok && parallel > 1 && xw.indent == "" {
+			xw.serializeParallel(elt, parallel)
+		} else {
+			serializeTopLevel(xw, v)
+		}
+		if xw.indent != "" {
+			xw.writeString("\n")
+		}
+	}
+	if doctype {
+		xr.writeDoctype(xw)
 	}
-	return doc, nil
 }
 
-func escape(in string) string {
-	return entitiesReplacer.Replace(in)
+// writeDoctype writes the DOCTYPE declaration, on a line of its own when
+// indenting.
+func (xr *XMLDocument) writeDoctype(xw *xmlWriter) {
+	xw.writeString("<!", xr.doctype, ">")
+	if xw.indent != "" {
+		xw.writeString("\n")
+	}
+}
+
+// serializeTopLevel writes a child of the document. Outside of the root
+// element, text can only be white space and references are not allowed, so
+// it is written unescaped.
+func serializeTopLevel(xw *xmlWriter, n XMLNode) {
+	if cd, ok := n.(CharData); ok {
+		xw.writeText(cd.Contents, escapeNone)
+		return
+	}
+	n.serialize(xw)
 }
 
 // SortByDocumentOrder sorts the nodes by document order.
@@ -0,0 +1,104 @@
+package goxml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringvalueCache(t *testing.T) {
+	_, r := parseRoot(t, `<r>a<b>b<c>c</c></b>d</r>`)
+	if got := r.Stringvalue(); got != "abcd" {
+		t.Errorf("Stringvalue() = %q, want abcd", got)
+	}
+	if _, ok := r.cachedStringvalue(); !ok {
+		t.Error("the string value of a parsed element is not cached")
+	}
+	c := r.FirstChildElement().FirstChildElement()
+	c.Append(CharData{Contents: "x"})
+	if got := r.Stringvalue(); got != "abcxd" {
+		t.Errorf("Stringvalue() after a change of a descendant = %q, want abcxd", got)
+	}
+}
+
+func TestStringvalueValueReceiver(t *testing.T) {
+	_, r := parseRoot(t, `<r>a<b>b</b></r>`)
+	// Stringvalue is in the method set of Element values
+	var s interface{ Stringvalue() string } = *r
+	if got := s.Stringvalue(); got != "ab" {
+		t.Errorf("Stringvalue() of an Element value = %q, want ab", got)
+	}
+	// a copy that changes does not pass its value to the original
+	cp := *r
+	cp.Append(CharData{Contents: "c"})
+	if got := cp.Stringvalue(); got != "abc" {
+		t.Errorf("Stringvalue() of the changed copy = %q, want abc", got)
+	}
+	if got := r.Stringvalue(); got != "ab" {
+		t.Errorf("Stringvalue() of the original = %q, want ab", got)
+	}
+}
+
+func TestParseNamespaces(t *testing.T) {
+	doc, err := Parse(strings.NewReader(`<a xmlns="d" xmlns:p="P"><p:b p:x="1" y="2"><c xmlns=""/><p:d xmlns:p="Q"/><e/><q:f/></p:b></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := doc.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := a.FirstChildElement()
+	children := b.ChildElements()
+	if len(children) != 4 {
+		t.Fatalf("<p:b> has %d child elements, want 4", len(children))
+	}
+	for _, tc := range []struct {
+		elt  *Element
+		want string
+	}{
+		{a, "d"},
+		{b, "P"},
+		{children[0], ""},
+		// redeclared prefix
+		{children[1], "Q"},
+		// inherited default namespace
+		{children[2], "d"},
+		// unbound prefix
+		{children[3], ""},
+	} {
+		if got := tc.elt.NamespaceURI(); got != tc.want {
+			t.Errorf("<%s>.NamespaceURI() = %q, want %q", tc.elt.qualifiedName(), got, tc.want)
+		}
+	}
+	for _, attr := range b.Attributes() {
+		want := ""
+		if attr.Name == "x" {
+			want = "P"
+		}
+		// attributes without prefix are in no namespace
+		if attr.Namespace != want {
+			t.Errorf("attribute %s is in namespace %q, want %q", attr.Name, attr.Namespace, want)
+		}
+	}
+	if ns, ok := children[0].LookupNamespace("p"); !ok || ns != "P" {
+		t.Errorf(`LookupNamespace("p") = %q, %t, want "P", true`, ns, ok)
+	}
+	if ns, ok := children[1].LookupNamespace("p"); !ok || ns != "Q" {
+		t.Errorf(`LookupNamespace("p") in the redeclaring element = %q, %t, want "Q", true`, ns, ok)
+	}
+	if _, ok := children[3].LookupNamespace("q"); ok {
+		t.Error(`LookupNamespace("q") reports an unbound prefix as bound`)
+	}
+	// the elements keep their own declarations only
+	for _, tc := range []struct {
+		elt  *Element
+		want int
+	}{{a, 2}, {b, 0}, {children[0], 1}, {children[1], 1}, {children[2], 0}} {
+		if got := len(tc.elt.Namespaces); got != tc.want {
+			t.Errorf("<%s> has %d namespace declarations, want %d: %v", tc.elt.qualifiedName(), got, tc.want, tc.elt.Namespaces)
+		}
+	}
+	if got := children[1].InScopeNamespaces(); len(got) != 2 || got[""] != "d" || got["p"] != "Q" {
+		t.Errorf("InScopeNamespaces() = %v, want the default namespace d and p bound to Q", got)
+	}
+}
@@ -0,0 +1,196 @@
+package xmltest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/speedata/goxml"
+)
+
+// GeneratorOptions control the documents of a Generator. The zero values
+// select small documents with some text and no namespaces.
+type GeneratorOptions struct {
+	// Seed initializes the random numbers, the same seed gives the same
+	// documents.
+	Seed int64
+	// MaxDepth is the maximum nesting depth of the elements below the root
+	// element, 4 if zero.
+	MaxDepth int
+	// MaxChildren is the maximum number of children of an element, 4 if
+	// zero.
+	MaxChildren int
+	// MaxAttributes is the maximum number of attributes of an element, 2 if
+	// zero. A negative value gives elements without attributes.
+	MaxAttributes int
+	// Namespaces is the number of namespaces declared on the root element
+	// and used for the names of elements and attributes.
+	Namespaces int
+	// TextRatio is the probability of a child being a text node instead of
+	// an element, 0.3 if zero. A negative value gives no text.
+	TextRatio float64
+	// CommentRatio is the probability of a child being a comment.
+	CommentRatio float64
+	// Root is the name of the root element. Children and Attributes give
+	// the names of the child elements and of the attributes allowed for
+	// each element name, a simple grammar that makes the documents look like
+	// the ones an application expects. Elements with names not in Children
+	// get no child elements. Without Children, the names are made up.
+	Root       string
+	Children   map[string][]string
+	Attributes map[string][]string
+}
+
+// Generator produces random documents for fuzzing code that processes XML
+// and for benchmarks. It is not safe for concurrent use.
+type Generator struct {
+	opts GeneratorOptions
+	rnd  *rand.Rand
+}
+
+// names are the made up element and attribute names.
+var names = []string{"a", "b", "item", "para", "section", "title", "x", "y", "data", "entry"}
+
+// words are the made up text, including characters that must be escaped.
+var words = []string{"lorem", "ipsum", "dolor", "a&b", "x<y", "\"quoted\"", "'single'", "Grüße", "日本語", "]]>", "tab\there", "line\nbreak"}
+
+// NewGenerator returns a generator for documents with the options.
+func NewGenerator(opts GeneratorOptions) *Generator {
+	return newGenerator(opts, rand.New(rand.NewSource(opts.Seed)))
+}
+
+// newGenerator returns a generator that takes the random numbers from rnd
+// and ignores the seed of the options.
+func newGenerator(opts GeneratorOptions, rnd *rand.Rand) *Generator {
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = 4
+	}
+	if opts.MaxChildren == 0 {
+		opts.MaxChildren = 4
+	}
+	if opts.MaxAttributes == 0 {
+		opts.MaxAttributes = 2
+	}
+	if opts.TextRatio == 0 {
+		opts.TextRatio = 0.3
+	}
+	return &Generator{opts: opts, rnd: rnd}
+}
+
+// Document returns the next random document and its serialized form, from
+// which the document has been parsed.
+func (g *Generator) Document() (*goxml.XMLDocument, string, error) {
+	var sb strings.Builder
+	root := g.opts.Root
+	if root == "" {
+		root = g.pick(names)
+	}
+	g.element(&sb, root, 0)
+	s := sb.String()
+	doc, err := goxml.Parse(strings.NewReader(s))
+	return doc, s, err
+}
+
+func (g *Generator) pick(list []string) string {
+	return list[g.rnd.Intn(len(list))]
+}
+
+// qualify returns name with a random prefix of the declared namespaces or
+// without prefix.
+func (g *Generator) qualify(name string) string {
+	if g.opts.Namespaces > 0 && g.rnd.Intn(2) == 0 {
+		return fmt.Sprintf("ns%d:%s", g.rnd.Intn(g.opts.Namespaces), name)
+	}
+	return name
+}
+
+func (g *Generator) element(sb *strings.Builder, name string, depth int) {
+	qname := name
+	if g.opts.Children == nil {
+		qname = g.qualify(name)
+	}
+	sb.WriteString("<" + qname)
+	if depth == 0 {
+		for i := 0; i < g.opts.Namespaces; i++ {
+			fmt.Fprintf(sb, ` xmlns:ns%d="urn:goxml:test:%d"`, i, i)
+		}
+	}
+	g.attributes(sb, name)
+	sb.WriteString(">")
+	childNames := names
+	if g.opts.Children != nil {
+		childNames = g.opts.Children[name]
+	}
+	n := g.rnd.Intn(g.opts.MaxChildren + 1)
+	for i := 0; i < n; i++ {
+		r := g.rnd.Float64()
+		switch {
+		case r < g.opts.TextRatio:
+			g.text(sb)
+		case r < g.opts.TextRatio+g.opts.CommentRatio:
+			sb.WriteString("<!--" + strings.ReplaceAll(g.pick(words), "-", " ") + "-->")
+		case depth < g.opts.MaxDepth && len(childNames) > 0:
+			g.element(sb, g.pick(childNames), depth+1)
+		}
+	}
+	sb.WriteString("</" + qname + ">")
+}
+
+func (g *Generator) attributes(sb *strings.Builder, name string) {
+	attrNames := names
+	if g.opts.Children != nil {
+		attrNames = g.opts.Attributes[name]
+	}
+	if g.opts.MaxAttributes < 0 || len(attrNames) == 0 {
+		return
+	}
+	n := g.rnd.Intn(g.opts.MaxAttributes + 1)
+	seen := map[string]bool{}
+	for i := 0; i < n; i++ {
+		qname := g.pick(attrNames)
+		if g.opts.Children == nil {
+			qname = g.qualify(qname)
+		}
+		// each namespace has a single prefix, so a qualified name used
+		// twice is the only way to get a duplicate attribute
+		if seen[qname] {
+			continue
+		}
+		seen[qname] = true
+		sb.WriteString(" " + qname + `="`)
+		escape(sb, g.pick(words), true)
+		sb.WriteString(`"`)
+	}
+}
+
+func (g *Generator) text(sb *strings.Builder) {
+	n := 1 + g.rnd.Intn(4)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		escape(sb, g.pick(words), false)
+	}
+}
+
+// escape writes s as text or attribute value.
+func escape(sb *strings.Builder, s string, attribute bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			sb.WriteString("&amp;")
+		case r == '<':
+			sb.WriteString("&lt;")
+		case r == '>':
+			sb.WriteString("&gt;")
+		case r == '"' && attribute:
+			sb.WriteString("&quot;")
+		case r == '\t' && attribute:
+			sb.WriteString("&#9;")
+		case r == '\n' && attribute:
+			sb.WriteString("&#10;")
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package xmltest
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/speedata/goxml"
+)
+
+// Tree is a random document for property based tests. It implements
+// quick.Generator, so testing/quick creates trees for the arguments of a
+// property:
+//
+//	f := func(t xmltest.Tree) bool {
+//		doc, err := goxml.Parse(strings.NewReader(t.Doc.ToXML()))
+//		return err == nil && xmltest.Equal(doc.ToXML(), t.XML, xmltest.Options{Comments: true})
+//	}
+//	if err := quick.Check(f, nil); err != nil {
+//		t.Error(err)
+//	}
+//
+// Other property testing libraries can create trees with GenerateTree. A
+// tree for which a property fails can be made smaller with Minimize.
+type Tree struct {
+	// Doc is the document parsed from XML.
+	Doc *goxml.XMLDocument
+	XML string
+}
+
+// Generate returns a random Tree, the size limits the depth and the number
+// of children of the elements.
+func (Tree) Generate(rnd *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(GenerateTree(rnd, size))
+}
+
+// GenerateTree returns a random tree with namespaces, attributes, text and
+// comments whose size grows with size.
+func GenerateTree(rnd *rand.Rand, size int) Tree {
+	g := newGenerator(GeneratorOptions{
+		MaxDepth:     1 + size/20,
+		MaxChildren:  1 + size/10,
+		Namespaces:   2,
+		CommentRatio: 0.1,
+	}, rnd)
+	doc, s, err := g.Document()
+	if err != nil {
+		// the generator writes well-formed documents only
+		panic(err)
+	}
+	return Tree{Doc: doc, XML: s}
+}
+
+// Minimize returns the smallest tree found by repeatedly shrinking t for
+// which fails still returns true. fails should check the property that
+// failed for t.
+func Minimize(t Tree, fails func(Tree) bool) Tree {
+	for {
+		smaller := false
+		for _, c := range Shrink(t) {
+			if fails(c) {
+				t = c
+				smaller = true
+				break
+			}
+		}
+		if !smaller {
+			return t
+		}
+	}
+}
+
+// Shrink returns the trees that are one step smaller than t: without one of
+// the elements, with an element replaced by its children, without one of the
+// attributes or namespace declarations and with shorter text. The steps
+// that change the outer nodes come first. Trees that would not be
+// well-formed are left out.
+func Shrink(t Tree) []Tree {
+	root := fromNodes(t.Doc.Children())
+	var edits []func(*qnode)
+	var collect func(path []int, n *qnode)
+	collect = func(path []int, n *qnode) {
+		for i, c := range n.children {
+			p := append(path[:len(path):len(path)], i)
+			i := i
+			if len(path) > 0 || c.kind != 'e' {
+				edits = append(edits, func(r *qnode) {
+					parent := r.at(p[:len(p)-1])
+					parent.children = append(parent.children[:i:i], parent.children[i+1:]...)
+				})
+			}
+			if c.kind == 'e' && len(path) > 0 {
+				edits = append(edits, func(r *qnode) {
+					parent := r.at(p[:len(p)-1])
+					inner := parent.children[i].children
+					parent.children = append(append(parent.children[:i:i], inner...), parent.children[i+1:]...)
+				})
+			}
+			for a := range c.attrs {
+				a := a
+				edits = append(edits, func(r *qnode) {
+					elt := r.at(p)
+					elt.attrs = append(elt.attrs[:a:a], elt.attrs[a+1:]...)
+				})
+			}
+			if runes := []rune(c.value); c.kind == 't' && len(runes) > 1 {
+				edits = append(edits, func(r *qnode) {
+					r.at(p).value = string(runes[:len(runes)/2])
+				})
+			}
+			collect(p, c)
+		}
+	}
+	collect(nil, root)
+	var trees []Tree
+	for _, edit := range edits {
+		c := root.clone()
+		edit(c)
+		var sb strings.Builder
+		c.write(&sb)
+		s := sb.String()
+		doc, err := goxml.Parse(strings.NewReader(s))
+		if err != nil || !prefixesBound(doc) {
+			continue
+		}
+		trees = append(trees, Tree{Doc: doc, XML: s})
+	}
+	return trees
+}
+
+// prefixesBound reports whether all prefixes in n are declared, the parser
+// accepts unbound prefixes.
+func prefixesBound(n goxml.XMLNode) bool {
+	for _, c := range n.Children() {
+		elt, ok := c.(*goxml.Element)
+		if !ok {
+			continue
+		}
+		if _, ok := elt.LookupNamespace(elt.Prefix); elt.Prefix != "" && !ok {
+			return false
+		}
+		for _, attr := range elt.Attributes() {
+			if _, ok := elt.LookupNamespace(attr.Prefix); attr.Prefix != "" && attr.Prefix != "xml" && !ok {
+				return false
+			}
+		}
+		if !prefixesBound(elt) {
+			return false
+		}
+	}
+	return true
+}
+
+// qnode is a node of a tree while it is shrunk. kind is d for the document,
+// e for elements, t for text, c for comments and p for processing
+// instructions.
+type qnode struct {
+	kind     byte
+	name     string
+	attrs    [][2]string
+	value    string
+	children []*qnode
+}
+
+func fromNodes(nodes []goxml.XMLNode) *qnode {
+	n := &qnode{kind: 'd'}
+	for _, c := range nodes {
+		switch t := c.(type) {
+		case *goxml.Element:
+			elt := &qnode{kind: 'e', name: qualified(t.Prefix, t.Name)}
+			prefixes := make([]string, 0, len(t.Namespaces))
+			for prefix := range t.Namespaces {
+				prefixes = append(prefixes, prefix)
+			}
+			sort.Strings(prefixes)
+			for _, prefix := range prefixes {
+				elt.attrs = append(elt.attrs, [2]string{qualified("xmlns", prefix), t.Namespaces[prefix]})
+			}
+			for _, attr := range t.Attributes() {
+				elt.attrs = append(elt.attrs, [2]string{qualified(attr.Prefix, attr.Name), attr.Value})
+			}
+			elt.children = fromNodes(t.Children()).children
+			n.children = append(n.children, elt)
+		case goxml.CharData:
+			n.children = append(n.children, &qnode{kind: 't', value: t.Contents})
+		case goxml.EntityRef:
+			n.children = append(n.children, &qnode{kind: 't', value: t.Value})
+		case goxml.Comment:
+			n.children = append(n.children, &qnode{kind: 'c', value: t.Contents})
+		case goxml.ProcInst:
+			n.children = append(n.children, &qnode{kind: 'p', name: t.Target, value: string(t.Inst)})
+		}
+	}
+	return n
+}
+
+func qualified(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	if prefix == "xmlns" && local == "" {
+		return prefix
+	}
+	return prefix + ":" + local
+}
+
+// at returns the node at the path of child indexes.
+func (n *qnode) at(path []int) *qnode {
+	for _, i := range path {
+		n = n.children[i]
+	}
+	return n
+}
+
+func (n *qnode) clone() *qnode {
+	c := *n
+	c.attrs = append([][2]string(nil), n.attrs...)
+	c.children = make([]*qnode, len(n.children))
+	for i, cld := range n.children {
+		c.children[i] = cld.clone()
+	}
+	return &c
+}
+
+func (n *qnode) write(sb *strings.Builder) {
+	switch n.kind {
+	case 'e':
+		sb.WriteString("<" + n.name)
+		for _, attr := range n.attrs {
+			sb.WriteString(" " + attr[0] + `="`)
+			escape(sb, attr[1], true)
+			sb.WriteString(`"`)
+		}
+		sb.WriteString(">")
+	case 't':
+		escape(sb, n.value, false)
+		return
+	case 'c':
+		sb.WriteString("<!--" + n.value + "-->")
+		return
+	case 'p':
+		sb.WriteString("<?" + n.name + " " + n.value + "?>")
+		return
+	}
+	for _, c := range n.children {
+		c.write(sb)
+	}
+	if n.kind == 'e' {
+		sb.WriteString("</" + n.name + ">")
+	}
+}
@@ -0,0 +1,239 @@
+// Package xmltest compares XML documents in tests. The comparison ignores
+// the differences that do not change the meaning of a document for most
+// applications: the order of the attributes, the namespace prefixes, white
+// space around and inside of text and, optionally, comments. Differences are
+// reported as a readable diff of the documents in an indented form. A
+// Generator produces random documents for fuzzing and benchmarks.
+//
+// A typical test compares generated XML with the expected output:
+//
+//	func TestRender(t *testing.T) {
+//		got := render()
+//		xmltest.AssertEqual(t, got, `<doc><title>Hello</title></doc>`, xmltest.Options{})
+//	}
+package xmltest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/speedata/goxml"
+)
+
+// Options select the differences that count.
+type Options struct {
+	// Comments compares the comments, which are ignored otherwise.
+	Comments bool
+	// Whitespace compares text exactly. Otherwise leading and trailing white
+	// space of text is ignored, runs of white space are compared as a single
+	// space and text that consists of white space only is dropped.
+	Whitespace bool
+	// ProcInsts compares the processing instructions, which are ignored
+	// otherwise.
+	ProcInsts bool
+}
+
+// Diff parses got and want and returns their differences, an empty string if
+// they are equal. The diff lists the lines of the indented form of want that
+// are missing in got with a leading - and the lines of got that are not in
+// want with a leading +, together with some lines of context.
+func Diff(got, want string, opts Options) (string, error) {
+	gotDoc, err := goxml.Parse(strings.NewReader(got))
+	if err != nil {
+		return "", fmt.Errorf("got: %w", err)
+	}
+	wantDoc, err := goxml.Parse(strings.NewReader(want))
+	if err != nil {
+		return "", fmt.Errorf("want: %w", err)
+	}
+	return DiffNodes(gotDoc, wantDoc, opts), nil
+}
+
+// DiffNodes returns the differences of the documents or elements got and
+// want like Diff.
+func DiffNodes(got, want goxml.XMLNode, opts Options) string {
+	return diffLines(Lines(want, opts), Lines(got, opts))
+}
+
+// Equal reports whether the XML documents got and want are equal with the
+// options. Documents that are not well-formed are not equal.
+func Equal(got, want string, opts Options) bool {
+	d, err := Diff(got, want, opts)
+	return err == nil && d == ""
+}
+
+// AssertEqual reports an error to t if the XML documents got and want are not
+// equal, with the diff of the documents.
+func AssertEqual(t testing.TB, got, want string, opts Options) {
+	t.Helper()
+	d, err := Diff(got, want, opts)
+	if err != nil {
+		t.Errorf("xmltest: %s", err)
+		return
+	}
+	if d != "" {
+		t.Errorf("XML mismatch (-want +got):\n%s", d)
+	}
+}
+
+// Lines returns the indented form of n that Diff compares, one node per line.
+// Elements and attributes are written with the namespace URI in braces
+// instead of the prefix, such as {urn:example}item, and the attributes are
+// sorted.
+func Lines(n goxml.XMLNode, opts Options) []string {
+	var lines []string
+	writeChildren(&lines, n, 0, opts)
+	return lines
+}
+
+func writeChildren(lines *[]string, n goxml.XMLNode, depth int, opts Options) {
+	indent := strings.Repeat("  ", depth)
+	var text strings.Builder
+	flush := func() {
+		s := text.String()
+		text.Reset()
+		if !opts.Whitespace {
+			s = goxml.NormalizeSpace(s)
+		}
+		if s != "" {
+			*lines = append(*lines, indent+fmt.Sprintf("%q", s))
+		}
+	}
+	for _, c := range n.Children() {
+		switch t := c.(type) {
+		case goxml.CharData:
+			text.WriteString(t.Contents)
+		case goxml.EntityRef:
+			if t.Value != "" {
+				text.WriteString(t.Value)
+			} else {
+				text.WriteString("&" + t.Name + ";")
+			}
+		case goxml.Comment:
+			if opts.Comments {
+				flush()
+				*lines = append(*lines, indent+"<!--"+t.Contents+"-->")
+			}
+		case goxml.ProcInst:
+			if opts.ProcInsts {
+				flush()
+				*lines = append(*lines, indent+"<?"+t.Target+" "+string(t.Inst)+"?>")
+			}
+		case *goxml.Element:
+			flush()
+			*lines = append(*lines, indent+startTag(t))
+			writeChildren(lines, t, depth+1, opts)
+		}
+	}
+	flush()
+}
+
+// startTag returns the line of an element with its sorted attributes.
+func startTag(elt *goxml.Element) string {
+	attrs := make([]string, 0, len(elt.Attributes()))
+	for _, attr := range elt.Attributes() {
+		attrs = append(attrs, expandedName(attr.Namespace, attr.Name)+"="+fmt.Sprintf("%q", attr.Value))
+	}
+	sort.Strings(attrs)
+	var sb strings.Builder
+	sb.WriteString("<" + expandedName(elt.NamespaceURI(), elt.Name))
+	for _, attr := range attrs {
+		sb.WriteString(" " + attr)
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+func expandedName(ns, local string) string {
+	if ns == "" {
+		return local
+	}
+	return "{" + ns + "}" + local
+}
+
+// context is the number of unchanged lines shown around a change.
+const context = 2
+
+// diffLines returns the lines of a and b marked as removed, added or
+// unchanged, using a longest common subsequence. Unchanged lines farther
+// than context lines from a change are left out.
+func diffLines(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	type line struct {
+		mark byte
+		text string
+	}
+	var out []line
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, line{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, line{'-', a[i]})
+			changed = true
+			i++
+		default:
+			out = append(out, line{'+', b[j]})
+			changed = true
+			j++
+		}
+	}
+	if !changed {
+		return ""
+	}
+	// show the unchanged lines near a change
+	show := make([]bool, len(out))
+	for k, l := range out {
+		if l.mark == ' ' {
+			continue
+		}
+		for m := k - context; m <= k+context; m++ {
+			if m >= 0 && m < len(out) {
+				show[m] = true
+			}
+		}
+	}
+	var sb strings.Builder
+	skipped := false
+	for k, l := range out {
+		if !show[k] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			sb.WriteString("  ...\n")
+			skipped = false
+		}
+		sb.WriteByte(l.mark)
+		sb.WriteByte(' ')
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+	if skipped {
+		sb.WriteString("  ...\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,93 @@
+package xmltest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/speedata/goxml"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		got, want string
+		opts      Options
+		equal     bool
+	}{
+		{`<a x="1" y="2"/>`, `<a y="2" x="1"></a>`, Options{}, true},
+		{`<p:a xmlns:p="urn:u"><p:b/></p:a>`, `<a xmlns="urn:u"><b/></a>`, Options{}, true},
+		{`<a xmlns="urn:u"/>`, `<a/>`, Options{}, false},
+		{"<a>\n  <b> x  y </b>\n</a>", `<a><b>x y</b></a>`, Options{}, true},
+		{"<a>\n  <b> x  y </b>\n</a>", `<a><b>x y</b></a>`, Options{Whitespace: true}, false},
+		{`<a>x<![CDATA[<y>]]></a>`, `<a>x&lt;y></a>`, Options{}, true},
+		{`<a><!--c-->x</a>`, `<a>x</a>`, Options{}, true},
+		{`<a><!--c-->x</a>`, `<a>x</a>`, Options{Comments: true}, false},
+		{`<a><?pi x?></a>`, `<a/>`, Options{}, true},
+		{`<a><?pi x?></a>`, `<a/>`, Options{ProcInsts: true}, false},
+		{`<a x="1"/>`, `<a x="2"/>`, Options{}, false},
+		{`<a><b/><c/></a>`, `<a><c/><b/></a>`, Options{}, false},
+		{`<a>`, `<a/>`, Options{}, false},
+	}
+	for _, tc := range tests {
+		if got := Equal(tc.got, tc.want, tc.opts); got != tc.equal {
+			t.Errorf("Equal(%q, %q, %+v) = %t, want %t", tc.got, tc.want, tc.opts, got, tc.equal)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	d, err := Diff(`<doc><a/><b/><c/><d/><e/><f x="2"/></doc>`, `<doc><a/><b/><c/><d/><e/><f x="1"/></doc>`, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "  ...\n    <d>\n    <e>\n-   <f x=\"1\">\n+   <f x=\"2\">\n"
+	if d != want {
+		t.Errorf("Diff:\n%s\nwant\n%s", d, want)
+	}
+	if _, err = Diff(`<a>`, `<a/>`, Options{}); err == nil || !strings.HasPrefix(err.Error(), "got: ") {
+		t.Errorf("Diff of a malformed document returns %v, want an error for got", err)
+	}
+	if _, err = Diff(`<a/>`, `<a`, Options{}); err == nil || !strings.HasPrefix(err.Error(), "want: ") {
+		t.Errorf("Diff of a malformed document returns %v, want an error for want", err)
+	}
+}
+
+func TestLines(t *testing.T) {
+	doc, err := goxml.Parse(strings.NewReader(`<r xmlns:p="urn:p"><p:x b="2" a="1">t &amp; u</p:x><!--c--></r>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(Lines(doc, Options{Comments: true}), "\n")
+	want := "<r>\n  <{urn:p}x a=\"1\" b=\"2\">\n    \"t & u\"\n  <!--c-->"
+	if got != want {
+		t.Errorf("Lines:\n%s\nwant\n%s", got, want)
+	}
+}
+
+// recorder is a testing.TB that records the errors.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEqual(t *testing.T) {
+	r := &recorder{TB: t}
+	AssertEqual(r, `<a x="1"/>`, `<a x='1'></a>`, Options{})
+	if len(r.errors) != 0 {
+		t.Errorf("AssertEqual of equal documents reports %v", r.errors)
+	}
+	AssertEqual(r, `<a><b/></a>`, `<a><c/></a>`, Options{})
+	AssertEqual(r, `<a>`, `<a/>`, Options{})
+	if len(r.errors) != 2 {
+		t.Fatalf("AssertEqual reports %d errors, want 2: %v", len(r.errors), r.errors)
+	}
+	if !strings.Contains(r.errors[0], "-   <c>\n+   <b>") {
+		t.Errorf("AssertEqual does not report the diff: %s", r.errors[0])
+	}
+}